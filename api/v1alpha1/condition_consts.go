@@ -33,4 +33,74 @@ const (
 	// BootstrappingReason documents (Severity=Info) a DockerMachine currently executing the bootstrap
 	// script that creates the Kubernetes node on the newly provisioned machine infrastructure.
 	BootstrappingReason = "Bootstrapping"
+
+	// LoadBalancerContainerReadyCondition documents the status of the container hosting the load balancer
+	// of a DockerCluster.
+	LoadBalancerContainerReadyCondition clusterv1.ConditionType = "LoadBalancerContainerReady"
+
+	// LoadBalancerProvisioningFailedReason (Severity=Warning) documents a DockerCluster controller detecting
+	// an error while creating or inspecting the load balancer container.
+	LoadBalancerProvisioningFailedReason = "LoadBalancerProvisioningFailed"
+
+	// LoadBalancerImageMissingReason (Severity=Warning) documents a load balancer container that can't be
+	// created because its image is missing and restoring it failed.
+	LoadBalancerImageMissingReason = "LoadBalancerImageMissing"
+
+	// LoadBalancerImageDigestFailedReason (Severity=Warning) documents a load balancer container whose image
+	// digest can't be determined, or does not match the digest the load balancer image is pinned to.
+	LoadBalancerImageDigestFailedReason = "LoadBalancerImageDigestFailed"
+
+	// WaitingForLoadBalancerAddressReason (Severity=Info) documents a load balancer container that exists
+	// but does not have an address yet.
+	WaitingForLoadBalancerAddressReason = "WaitingForLoadBalancerAddress"
+
+	// LoadBalancerUpdatingReason (Severity=Info) documents a load balancer container that was just recreated
+	// and is being reconfigured, e.g. after a network change.
+	LoadBalancerUpdatingReason = "LoadBalancerUpdating"
+
+	// LoadBalancerConfigAppliedCondition documents whether the load balancer serves the configuration
+	// for the current control plane nodes.
+	LoadBalancerConfigAppliedCondition clusterv1.ConditionType = "LoadBalancerConfigApplied"
+
+	// LoadBalancerConfigPendingReason (Severity=Info) documents a load balancer that does not serve the
+	// configuration for the current control plane nodes yet, e.g. because no control plane machine
+	// configured it yet or a reload is in progress.
+	LoadBalancerConfigPendingReason = "LoadBalancerConfigPending"
+
+	// LoadBalancerEndpointAvailableCondition documents whether the control plane endpoint served by
+	// the load balancer is published on the DockerCluster.
+	LoadBalancerEndpointAvailableCondition clusterv1.ConditionType = "LoadBalancerEndpointAvailable"
+
+	// LoadBalancerEndpointFailedReason (Severity=Warning) documents a DockerCluster controller failing to
+	// determine the control plane endpoint, e.g. because the load balancer DNS name is not resolvable.
+	LoadBalancerEndpointFailedReason = "LoadBalancerEndpointFailed"
+
+	// WaitingForLoadBalancerBackendsReason (Severity=Info) documents a DockerCluster holding back the
+	// control plane endpoint because the load balancer configuration does not route to any API server yet.
+	WaitingForLoadBalancerBackendsReason = "WaitingForLoadBalancerBackends"
+
+	// LoadBalancerHibernatedCondition documents whether the load balancer refuses connections to the
+	// control plane endpoint because the control plane of a DockerCluster with the hibernate annotation
+	// was scaled to zero. It is only set on DockerClusters with the annotation.
+	LoadBalancerHibernatedCondition clusterv1.ConditionType = "LoadBalancerHibernated"
+
+	// LoadBalancerUnstableCondition is set when the load balancer container was restarted by the runtime
+	// more times than DockerCluster.Spec.LoadBalancerRestartThreshold within 10 minutes, e.g. because HAProxy
+	// crashes on its configuration or runs out of memory. It is removed once the restarts stop.
+	LoadBalancerUnstableCondition clusterv1.ConditionType = "LoadBalancerUnstable"
+
+	// LoadBalancerRestartingReason (Severity=Warning) documents a load balancer container that keeps restarting.
+	LoadBalancerRestartingReason = "LoadBalancerRestarting"
+
+	// LoadBalancerConfigDriftCondition is set when DockerCluster.Spec.LoadBalancerConfigDriftMode is Observe and
+	// the load balancer configuration was changed in the container since the controller last wrote it.
+	// It is removed once the configuration is rewritten, e.g. with the ReconfigureLoadBalancerAnnotation.
+	LoadBalancerConfigDriftCondition clusterv1.ConditionType = "LoadBalancerConfigDrift"
+
+	// LoadBalancerConfigEditedReason (Severity=Warning) documents a load balancer configuration changed in the container.
+	LoadBalancerConfigEditedReason = "LoadBalancerConfigEdited"
+
+	// ControlPlaneRunningReason (Severity=Info) documents a DockerCluster with the hibernate annotation
+	// whose load balancer still routes to control plane machines, e.g. because they are being scaled down.
+	ControlPlaneRunningReason = "ControlPlaneRunning"
 )
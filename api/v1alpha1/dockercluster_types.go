@@ -25,8 +25,61 @@ const (
 	// ClusterFinalizer allows cleaning up resources associated with
 	// DockerCluster before removing it from the apiserver.
 	ClusterFinalizer = "dockercluster.infrastructure.cluster.x-k8s.io"
+
+	// RecreateLoadBalancerAnnotation can be set on a DockerCluster to force the load balancer
+	// container to be deleted and created again; the annotation is removed once this is done.
+	// NOTE: the new container gets a new IP, so the control plane endpoint changes and any
+	// kubeconfig pointing to the previous address stops working until it is regenerated.
+	RecreateLoadBalancerAnnotation = "dockercluster.infrastructure.cluster.x-k8s.io/recreate-loadbalancer"
+
+	// ReconfigureLoadBalancerAnnotation can be set on a DockerCluster to rewrite the load balancer
+	// configuration from scratch and reload it, even if it looks up to date, e.g. when the configuration
+	// in the container is suspected to be corrupt; the annotation is removed once this is done.
+	ReconfigureLoadBalancerAnnotation = "dockercluster.infrastructure.cluster.x-k8s.io/reconfigure-loadbalancer"
+
+	// HibernateLoadBalancerAnnotation can be set on a DockerCluster whose control plane is intentionally
+	// scaled to zero, e.g. to hibernate the cluster. Once the last control plane machine is gone the load
+	// balancer refuses connections to the control plane endpoint instead of routing them to no backend.
+	// Set it before scaling down; without it an empty control plane is treated as an outage.
+	HibernateLoadBalancerAnnotation = "dockercluster.infrastructure.cluster.x-k8s.io/hibernate"
+
+	// SNIRoutesAnnotation can be set on a DockerCluster to route the control plane traffic by the TLS server
+	// name clients request, e.g. when several API servers share the load balancer. It is a comma separated
+	// list of <server name>=<backend> routes, where the backend is kube-apiservers or a backend pool. The load
+	// balancer then rejects plaintext connections and connections for other server names, so clients must
+	// set the server name, e.g. with tls-server-name in their kubeconfig when the endpoint is an IP address.
+	// Requires the LoadBalancerSNIRouting feature gate.
+	SNIRoutesAnnotation = "dockercluster.infrastructure.cluster.x-k8s.io/sni-routes"
+
+	// LoadBalancerConfigSnapshotAnnotation is set by the provider on a DockerCluster to the configuration
+	// its load balancer serves, so the backends can be restored when a recreated container has no
+	// configuration yet and a restarted provider did not discover them again. It should not be edited.
+	LoadBalancerConfigSnapshotAnnotation = "dockercluster.infrastructure.cluster.x-k8s.io/loadbalancer-config-snapshot"
+)
+
+const (
+	// LoadBalancerConfigDriftEnforce reverts the changes made to the load balancer configuration in the container.
+	LoadBalancerConfigDriftEnforce = "Enforce"
+	// LoadBalancerConfigDriftObserve keeps the changes made to the load balancer configuration in the container
+	// and reports them with the LoadBalancerConfigDrift condition.
+	LoadBalancerConfigDriftObserve = "Observe"
+)
+
+const (
+	// LoadBalancerColocateWithControlPlane places the load balancer container on the host running most of the
+	// control plane nodes, see LoadBalancerPlacement.ControlPlaneAffinity.
+	LoadBalancerColocateWithControlPlane = "Colocate"
+	// LoadBalancerSpreadFromControlPlane keeps the load balancer container off the hosts running the control
+	// plane nodes, see LoadBalancerPlacement.ControlPlaneAffinity.
+	LoadBalancerSpreadFromControlPlane = "Spread"
 )
 
+// DefaultNetwork is the docker network of the cluster when DockerClusterSpec.Network is not set, the kind network.
+const DefaultNetwork = "kind"
+
+// LoadBalancerLogFormatJSON logs the load balancer connections as JSON objects, see DockerClusterSpec.LoadBalancerLogFormat.
+const LoadBalancerLogFormatJSON = "json"
+
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
@@ -40,9 +93,452 @@ type DockerClusterSpec struct {
 	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint"`
 
 	// LoadBalancerImage allows you override the load balancer image. If not specified a
-	// default image will be used.
+	// default image will be used. The image can be pinned by digest (e.g. "repository@sha256:..."),
+	// in which case the load balancer container is verified to run that exact image.
 	// +optional
 	LoadBalancerImage string `json:"loadbalancerImage,omitempty"`
+
+	// LoadBalancerImageArchive is the path, on the host running the provider, of an archive created
+	// by docker save containing the load balancer image. It is loaded before creating the load balancer
+	// container if the image is not present, for air-gapped environments without a registry.
+	// The archive must contain the load balancer image tag; images pinned by digest are not supported.
+	// +optional
+	LoadBalancerImageArchive string `json:"loadbalancerImageArchive,omitempty"`
+
+	// LoadBalancerFrontendPort is the port the load balancer frontend binds to inside the
+	// load balancer container. The published host port is mapped to it, while the control
+	// plane endpoint keeps advertising the control plane port. Defaults to 6443.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	LoadBalancerFrontendPort int32 `json:"loadbalancerFrontendPort,omitempty"`
+
+	// LoadBalancerThreads is the number of threads HAProxy runs in the load balancer container.
+	// Threads are preferred over processes on modern HAProxy versions. If not specified the
+	// HAProxy default is used.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=64
+	// +optional
+	LoadBalancerThreads int32 `json:"loadbalancerThreads,omitempty"`
+
+	// LoadBalancerProcesses is the number of HAProxy processes in the load balancer container.
+	// It cannot be greater than 1 when LoadBalancerThreads is greater than 1. If not specified
+	// a single process is used.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=64
+	// +optional
+	LoadBalancerProcesses int32 `json:"loadbalancerProcesses,omitempty"`
+
+	// LoadBalancerMaxConn is the maximum number of concurrent connections of each HAProxy process
+	// (the global maxconn). If not specified the HAProxy default, derived from the file descriptor
+	// limit of the load balancer container, is used.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	LoadBalancerMaxConn int32 `json:"loadbalancerMaxConn,omitempty"`
+
+	// LoadBalancerFrontendMaxConn is the maximum number of concurrent connections of the control
+	// plane frontend, e.g. to reserve some of LoadBalancerMaxConn for the stats page. It cannot be
+	// greater than LoadBalancerMaxConn. If not specified the HAProxy default is used.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	LoadBalancerFrontendMaxConn int32 `json:"loadbalancerFrontendMaxConn,omitempty"`
+
+	// LoadBalancerHostname is the hostname of the load balancer container, e.g. to correlate the
+	// logs of multiple load balancers. It must be a valid DNS label. HAProxy identifies its local
+	// peer by hostname, so leave it unset when multiple load balancers synchronize their stick
+	// tables. If not specified the hostname is the container name.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +optional
+	LoadBalancerHostname string `json:"loadbalancerHostname,omitempty"`
+
+	// LoadBalancerUseDNSName makes the control plane endpoint use the load balancer container
+	// name, which is resolvable on the cluster network and stable across restarts, instead of its IP.
+	// +optional
+	LoadBalancerUseDNSName bool `json:"loadbalancerUseDNSName,omitempty"`
+
+	// LoadBalancerUseBackendNames makes the load balancer reach the API servers by their container
+	// names, re-resolved through the docker embedded DNS server, instead of their IPs, so backends
+	// survive IP changes, e.g. on restart, without a reload.
+	// +optional
+	LoadBalancerUseBackendNames bool `json:"loadbalancerUseBackendNames,omitempty"`
+
+	// LoadBalancerInitAddr is the comma separated list of methods (last, libc, none or an IP address)
+	// HAProxy tries in order to resolve the API servers when it starts. HAProxy refuses to start if it
+	// cannot resolve a server and none is not listed. Defaults to last,libc,none with
+	// LoadBalancerUseBackendNames, so HAProxy starts while a control plane container is not resolvable,
+	// and to the HAProxy default otherwise.
+	// +optional
+	LoadBalancerInitAddr string `json:"loadbalancerInitAddr,omitempty"`
+
+	// LoadBalancerBackendSource is the local IP address the load balancer connects to the API servers
+	// from, e.g. on a multi-homed host whose other addresses the API servers firewall rejects. The
+	// address must be assigned to the load balancer container. If not specified the runtime picks it.
+	// +optional
+	LoadBalancerBackendSource string `json:"loadbalancerBackendSource,omitempty"`
+
+	// LoadBalancerWaitForBackends holds back publishing the control plane endpoint until the load
+	// balancer configuration routes to at least one API server, so early requests through the new
+	// address do not fail. It only applies once the control plane is initialized, as the first control
+	// plane machine can only be created after the endpoint is published.
+	// +optional
+	LoadBalancerWaitForBackends bool `json:"loadbalancerWaitForBackends,omitempty"`
+
+	// LoadBalancerReloadTimeout is how long to wait for HAProxy to serve a new configuration
+	// after it is signaled to reload, before reporting the reload as failed. Setting it to 0s
+	// disables the verification. Defaults to 10s.
+	// +optional
+	LoadBalancerReloadTimeout *metav1.Duration `json:"loadbalancerReloadTimeout,omitempty"`
+
+	// LoadBalancerRollbackUnhealthyConfig restores the previous load balancer configuration when none
+	// of the API servers passes its health checks after a configuration update, while some did before,
+	// e.g. because of a broken health check setting, and reports the update as failed. It requires the
+	// stats page. Defaults to true.
+	// +optional
+	LoadBalancerRollbackUnhealthyConfig *bool `json:"loadbalancerRollbackUnhealthyConfig,omitempty"`
+
+	// LoadBalancerBackendCacheTTL enables caching the discovered control plane backends for the given
+	// duration, so reconciles do not list containers and resolve their IPs every time. Changes to the
+	// control plane machines always refresh the cache. The TTL cannot exceed 1m. If not specified the
+	// backends are discovered on every update.
+	// +optional
+	LoadBalancerBackendCacheTTL *metav1.Duration `json:"loadbalancerBackendCacheTTL,omitempty"`
+
+	// LoadBalancerNodeIPTimeout is how long to wait for a control plane container to get an IP address
+	// when discovering the load balancer backends, e.g. right after it is created during a scale up.
+	// Setting it to 0s fails immediately. The timeout cannot exceed 1m. Defaults to 5s.
+	// +optional
+	LoadBalancerNodeIPTimeout *metav1.Duration `json:"loadbalancerNodeIPTimeout,omitempty"`
+
+	// LoadBalancerCheckPort is the port the load balancer health checks connect to on the control
+	// plane nodes, for API servers exposing healthz on a different port than the one serving
+	// traffic. If not specified the traffic port is checked.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	LoadBalancerCheckPort int32 `json:"loadbalancerCheckPort,omitempty"`
+
+	// LoadBalancerPreserveDestinationPort makes the load balancer connect to the control plane nodes
+	// on the port the client connected to, instead of the control plane port, for transparent setups
+	// where the nodes serve the backend pool ports themselves. The health checks then connect to
+	// LoadBalancerCheckPort, or to the control plane port if it is not specified. Only the ports the
+	// load balancer container publishes are reachable. Defaults to false.
+	// +optional
+	LoadBalancerPreserveDestinationPort bool `json:"loadbalancerPreserveDestinationPort,omitempty"`
+
+	// LoadBalancerHealthCheckType is how the load balancer health checks the API servers: tcp only
+	// connects to them, ssl completes a TLS handshake, httpHealthz expects a successful response from
+	// the healthz endpoint and httpReadyz a 200 response from the readyz endpoint, so a control plane
+	// node only receives traffic once its API server is ready to serve rather than merely live. The ssl
+	// and http checks verify the API server certificates with LoadBalancerVerifyBackends. Defaults to
+	// tcp.
+	// +kubebuilder:validation:Enum=tcp;ssl;httpHealthz;httpReadyz
+	// +kubebuilder:default=tcp
+	// +optional
+	LoadBalancerHealthCheckType string `json:"loadbalancerHealthCheckType,omitempty"`
+
+	// LoadBalancerMaxBackends limits the load balancer to the first N control plane nodes sorted by
+	// name, ignoring the others, e.g. for chaos tests fronting a subset of the control plane.
+	// If not specified all the control plane nodes are used.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	LoadBalancerMaxBackends int32 `json:"loadbalancerMaxBackends,omitempty"`
+
+	// LoadBalancerZeroBackendConfirmations is how many consecutive discoveries must find no control
+	// plane node before the backends are removed from the load balancer configuration, so a discovery
+	// glitch does not wipe them; until then the last configuration is kept and the update fails.
+	// Set it to 1 to remove the backends on the first empty discovery; 0 is the same as not setting
+	// it. Defaults to 2.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	LoadBalancerZeroBackendConfirmations int32 `json:"loadbalancerZeroBackendConfirmations,omitempty"`
+
+	// LoadBalancerRetainOnDelete keeps the load balancer container for post-mortem debugging when the
+	// cluster is deleted. The container is stopped and renamed to <cluster>-lb-retained-<timestamp>,
+	// is no longer managed by the provider and must be removed manually with docker rm.
+	// +optional
+	LoadBalancerRetainOnDelete bool `json:"loadbalancerRetainOnDelete,omitempty"`
+
+	// LoadBalancerStopTimeout is how long HAProxy is given to finish serving in-flight connections when
+	// the load balancer container is deleted or retained, before it is killed. Setting it to 0s kills it
+	// immediately. Defaults to 5s.
+	// +optional
+	LoadBalancerStopTimeout *metav1.Duration `json:"loadbalancerStopTimeout,omitempty"`
+
+	// LoadBalancerHardStopAfter is how long the HAProxy processes replaced by a reload are given to
+	// finish serving their connections before they are stopped, so they do not keep long-lived
+	// connections open indefinitely. It must be greater than 0s. Defaults to 30s.
+	// +optional
+	LoadBalancerHardStopAfter *metav1.Duration `json:"loadbalancerHardStopAfter,omitempty"`
+
+	// LoadBalancerRunAsUser is the user, as a name or a numeric ID, HAProxy drops its privileges to
+	// after starting. The configuration files written by the provider are owned by this user.
+	// Unset keeps the user of the load balancer image.
+	// +optional
+	LoadBalancerRunAsUser string `json:"loadbalancerRunAsUser,omitempty"`
+
+	// LoadBalancerRunAsGroup is the group, as a name or a numeric ID, HAProxy drops its privileges to
+	// after starting. The configuration files written by the provider are owned by this group.
+	// Unset keeps the group of the load balancer image.
+	// +optional
+	LoadBalancerRunAsGroup string `json:"loadbalancerRunAsGroup,omitempty"`
+
+	// LoadBalancerRestartThreshold is how many times the load balancer container can be restarted by
+	// the runtime within 10 minutes, e.g. because HAProxy crashes on its configuration or runs out of
+	// memory, before the DockerCluster reports the load balancer as unstable. Defaults to 3.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	LoadBalancerRestartThreshold int32 `json:"loadbalancerRestartThreshold,omitempty"`
+
+	// LoadBalancerRestartPolicy is the restart policy of the load balancer container. unless-stopped
+	// brings it back when the host or the docker daemon restarts, without waiting for a reconcile;
+	// on-failure only restarts it when HAProxy exits with an error, and no never restarts it. Containers
+	// stopped by the provider, e.g. retained on delete, stay stopped with any policy. Changing it does
+	// not affect an existing container. Defaults to unless-stopped.
+	// +kubebuilder:validation:Enum=no;on-failure;unless-stopped
+	// +optional
+	LoadBalancerRestartPolicy string `json:"loadbalancerRestartPolicy,omitempty"`
+
+	// LoadBalancerRetries is the number of times the load balancer retries a failed connection to an
+	// API server, e.g. one being drained during a rolling control plane update. Defaults to 3.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	LoadBalancerRetries *int32 `json:"loadbalancerRetries,omitempty"`
+
+	// LoadBalancerRedispatch makes the last retry of a failed connection use another API server
+	// instead of the one the connection failed on. Defaults to true.
+	// +optional
+	LoadBalancerRedispatch *bool `json:"loadbalancerRedispatch,omitempty"`
+
+	// LoadBalancerTCPSmartConnect makes the load balancer connect to an API server only once the client
+	// sent its first data, saving a packet on each of the many short-lived control plane connections.
+	// +optional
+	LoadBalancerTCPSmartConnect bool `json:"loadbalancerTCPSmartConnect,omitempty"`
+
+	// LoadBalancerHTTPReuse is how the load balancer shares its idle connections to the API servers
+	// between requests in http mode, from never to always; it cannot be set in tcp mode. Defaults to
+	// the HAProxy default, safe.
+	// +kubebuilder:validation:Enum=never;safe;aggressive;always
+	// +optional
+	LoadBalancerHTTPReuse string `json:"loadbalancerHTTPReuse,omitempty"`
+
+	// LoadBalancerShutdownSessionsOnDown makes the load balancer close the connections to an API server
+	// as soon as its health checks mark it down, e.g. while it is replaced during a rolling control
+	// plane update, so clients reconnect to another one instead of hanging on the dying one.
+	// +optional
+	LoadBalancerShutdownSessionsOnDown bool `json:"loadbalancerShutdownSessionsOnDown,omitempty"`
+
+	// LoadBalancerEnableMetrics enables the HAProxy built-in Prometheus exporter in the load
+	// balancer container. The scrape endpoint is reported in the status.
+	// +optional
+	LoadBalancerEnableMetrics bool `json:"loadbalancerEnableMetrics,omitempty"`
+
+	// LoadBalancerExposeConfigMap mirrors the live load balancer configuration, with credentials
+	// redacted, into the <name>-lb-config ConfigMap next to the DockerCluster, so it can be audited
+	// without access to the docker host. The ConfigMap is deleted with the DockerCluster.
+	// +optional
+	LoadBalancerExposeConfigMap bool `json:"loadbalancerExposeConfigMap,omitempty"`
+
+	// LoadBalancerMetricsPort is the port the Prometheus exporter listens on inside the load
+	// balancer container. Defaults to 8405.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	LoadBalancerMetricsPort int32 `json:"loadbalancerMetricsPort,omitempty"`
+
+	// LoadBalancerMetricsAuthSecretName is the name of a kubernetes.io/basic-auth Secret, in the
+	// namespace of the DockerCluster, holding the username and password the Prometheus exporter requires.
+	// Unless it or LoadBalancerMetricsAllowedSources is set, the exporter only accepts connections from
+	// inside the load balancer container.
+	// +optional
+	LoadBalancerMetricsAuthSecretName string `json:"loadbalancerMetricsAuthSecretName,omitempty"`
+
+	// LoadBalancerMetricsAllowedSources are the IP addresses or CIDRs allowed to connect to the
+	// Prometheus exporter, e.g. the docker network of the scraper. Combined with
+	// LoadBalancerMetricsAuthSecretName the scrapers must match both.
+	// +optional
+	LoadBalancerMetricsAllowedSources []string `json:"loadbalancerMetricsAllowedSources,omitempty"`
+
+	// LoadBalancerMode is the HAProxy proxy mode, tcp for layer 4 passthrough or http for layer 7
+	// routing and request logging. In http mode the load balancer terminates TLS and re-encrypts
+	// to the API servers, so the image must provide a certificate for the control plane endpoint
+	// in /usr/local/etc/haproxy/certs. Defaults to tcp.
+	// +kubebuilder:validation:Enum=tcp;http
+	// +optional
+	LoadBalancerMode string `json:"loadbalancerMode,omitempty"`
+
+	// LoadBalancerVerifyBackends makes the load balancer verify the serving certificates of the API
+	// servers against the cluster CA, read from the <cluster>-ca secret, instead of accepting any
+	// certificate. Only the certificate chain is verified, not the host name.
+	// +optional
+	LoadBalancerVerifyBackends bool `json:"loadbalancerVerifyBackends,omitempty"`
+
+	// LoadBalancerArgs overrides the command of the load balancer container, e.g. to pass extra
+	// HAProxy options such as -d for debugging. With the default image, arguments starting with a
+	// dash are passed to haproxy. The configuration file managed by the provider is always loaded,
+	// so -f cannot point to a different file. If not specified the image default is used.
+	// +optional
+	LoadBalancerArgs []string `json:"loadbalancerArgs,omitempty"`
+
+	// LoadBalancerEnv are environment variables to set in the load balancer container, e.g. for custom
+	// images configured through their environment. HAProxy itself is configured by the configuration
+	// file managed by the provider, which takes precedence over anything the image derives from them.
+	// +optional
+	LoadBalancerEnv map[string]string `json:"loadbalancerEnv,omitempty"`
+
+	// LoadBalancerSysctls are kernel parameters to set in the load balancer container, e.g.
+	// net.core.somaxconn for load balancers handling many connections. Only namespaced sysctls
+	// (net.*, kernel.shm*, kernel.msg*, kernel.sem and fs.mqueue.*) can be set; they do not
+	// require the container to be privileged. Node-level sysctls must be tuned on the host.
+	// +optional
+	LoadBalancerSysctls map[string]string `json:"loadbalancerSysctls,omitempty"`
+
+	// LoadBalancerCapAdd are the Linux capabilities to add to the load balancer container, e.g.
+	// NET_BIND_SERVICE to bind ports below 1024 as a non-root user, or NET_ADMIN for transparent
+	// binds. Seamless reloads pass the listening sockets over the stats socket and do not need
+	// additional capabilities. Names may omit the CAP_ prefix; ALL adds every capability.
+	// Capabilities only apply to unprivileged containers. If not specified the runtime defaults are used.
+	// +optional
+	LoadBalancerCapAdd []string `json:"loadbalancerCapAdd,omitempty"`
+
+	// LoadBalancerCapDrop are the Linux capabilities to drop from the load balancer container.
+	// Names may omit the CAP_ prefix; ALL drops every capability not explicitly added.
+	// Capabilities only apply to unprivileged containers. If not specified the runtime defaults are used.
+	// +optional
+	LoadBalancerCapDrop []string `json:"loadbalancerCapDrop,omitempty"`
+
+	// LoadBalancerExtraHosts are additional /etc/hosts entries of the load balancer container, in
+	// the name:ip format, e.g. to resolve backends by a custom name. The IP may be host-gateway to
+	// resolve the name to the docker host.
+	// +optional
+	LoadBalancerExtraHosts []string `json:"loadbalancerExtraHosts,omitempty"`
+
+	// LoadBalancerLogDriver is the docker log driver of the load balancer container, e.g. journald or
+	// fluentd to ship its logs with the ones of the platform. The driver must be supported by the
+	// container runtime. This does not change what HAProxy logs. If not specified the runtime default
+	// is used.
+	// +optional
+	LoadBalancerLogDriver string `json:"loadbalancerLogDriver,omitempty"`
+
+	// LoadBalancerLogOptions are the options of LoadBalancerLogDriver, e.g. fluentd-address. They are
+	// specific to the driver, so they require LoadBalancerLogDriver to be set.
+	// +optional
+	LoadBalancerLogOptions map[string]string `json:"loadbalancerLogOptions,omitempty"`
+
+	// LoadBalancerLogFormat is the format HAProxy logs the connections in: json logs them as JSON objects
+	// for structured ingestion, any other value is used as a custom HAProxy log-format, which must be on a
+	// single line. A configuration with a format HAProxy rejects is not applied. If not specified the
+	// standard HAProxy format is used.
+	// +optional
+	LoadBalancerLogFormat string `json:"loadbalancerLogFormat,omitempty"`
+
+	// LoadBalancerPrivileged runs the load balancer container in privileged mode, e.g. for transparent
+	// proxying setups tuning the network of the container beyond what capabilities allow. A privileged
+	// container has all the capabilities and access to the host devices, so HAProxy escaping it
+	// compromises the host; prefer LoadBalancerCapAdd, which is ignored in privileged mode. Namespaced
+	// LoadBalancerSysctls do not require it. Defaults to false.
+	// +optional
+	LoadBalancerPrivileged bool `json:"loadbalancerPrivileged,omitempty"`
+
+	// LoadBalancerReadOnlyRootFS runs the load balancer container with a read-only root filesystem, e.g.
+	// in hardened environments. The configuration directory stays writable on a volume, as do /var, /tmp
+	// and /run. Defaults to false.
+	// +optional
+	LoadBalancerReadOnlyRootFS bool `json:"loadbalancerReadOnlyRootFS,omitempty"`
+
+	// LoadBalancerBackendPools are additional backends routing part of the control plane traffic to a
+	// subset of the control plane nodes, selected by the load balancer port or the TLS server name.
+	// Control plane containers join the pools listed, comma separated, in their io.x-k8s.capd.lb-pool
+	// label; any other traffic is routed to all the control plane nodes.
+	// Requires the LoadBalancerBackendPools feature gate.
+	// +optional
+	LoadBalancerBackendPools []LoadBalancerBackendPool `json:"loadbalancerBackendPools,omitempty"`
+
+	// LoadBalancerConfigPatch adjusts the load balancer configuration of this cluster. It lists, in the
+	// HAProxy configuration syntax, the directives to set in the global, defaults, frontend and backend
+	// sections, e.g. timeout client 30s in the defaults section; a directive replaces the ones with
+	// the same keyword or is appended. The patch takes precedence over the other spec fields, which take
+	// precedence over the base configuration of the manager and the built-in defaults. The pidfile and
+	// stats socket directives the provider relies on cannot be patched.
+	// +optional
+	LoadBalancerConfigPatch string `json:"loadbalancerConfigPatch,omitempty"`
+
+	// LoadBalancerDefaultsOverride sets directives of the defaults section of the load balancer
+	// configuration, keyed by directive, e.g. "timeout client": "30s" or "option tcpka": "". Only known
+	// defaults directives are accepted, excluding the mode set with LoadBalancerMode. They take
+	// precedence over the other spec fields, LoadBalancerConfigPatch takes precedence over them.
+	// +optional
+	LoadBalancerDefaultsOverride map[string]string `json:"loadbalancerDefaultsOverride,omitempty"`
+
+	// LoadBalancerConfigDriftMode detects changes made to the load balancer configuration in the container
+	// since the controller last wrote it, e.g. by an operator debugging HAProxy. Enforce reverts them on the
+	// next configuration update. Observe keeps them, reporting the LoadBalancerConfigDrift condition, and
+	// stops updating the configuration, including its backends, until the ReconfigureLoadBalancerAnnotation
+	// is set. If not specified changes are reverted without being detected.
+	// +kubebuilder:validation:Enum=Enforce;Observe
+	// +optional
+	LoadBalancerConfigDriftMode string `json:"loadbalancerConfigDriftMode,omitempty"`
+
+	// Network is the docker network of the cluster: the load balancer and the machine containers are
+	// attached to it, and the load balancer reaches the control plane nodes on it. If it changes, the
+	// load balancer container is recreated on the new network, and the control plane endpoint moves to
+	// its new address; existing machine containers are not moved. Defaults to the kind network.
+	// +optional
+	Network string `json:"network,omitempty"`
+
+	// LoadBalancerNetworks are docker networks the load balancer container is connected to in addition
+	// to Network, e.g. a management network it must be reachable from. The networks must exist. If they
+	// change, the load balancer container is recreated. Defaults to none, leaving the load balancer on
+	// Network only.
+	// +optional
+	LoadBalancerNetworks []string `json:"loadbalancerNetworks,omitempty"`
+
+	// LoadBalancerPlacement are hints placing the load balancer container relative to the docker hosts
+	// and the control plane nodes. They only apply with multi-host runtimes serving the docker API, e.g.
+	// Docker Swarm standalone; a single docker daemon ignores them. They are evaluated when the container
+	// is created. Defaults to none, letting the runtime place the container.
+	// +optional
+	LoadBalancerPlacement *LoadBalancerPlacement `json:"loadbalancerPlacement,omitempty"`
+}
+
+// LoadBalancerPlacement are the placement hints of the load balancer container on multi-host runtimes.
+type LoadBalancerPlacement struct {
+	// HostSelector pins the load balancer container to the docker host with this name.
+	// +optional
+	HostSelector string `json:"hostSelector,omitempty"`
+
+	// ControlPlaneAffinity places the load balancer container relative to the control plane nodes:
+	// Colocate pins it to the host running most of them, for lower latency, and Spread keeps it off
+	// the hosts running any of them. Control plane nodes created after the load balancer are not
+	// accounted for. It cannot be set together with HostSelector.
+	// +kubebuilder:validation:Enum=Colocate;Spread
+	// +optional
+	ControlPlaneAffinity string `json:"controlPlaneAffinity,omitempty"`
+}
+
+// LoadBalancerBackendPool is a named backend for a subset of the control plane nodes.
+type LoadBalancerBackendPool struct {
+	// Name is the name of the pool, matched against the io.x-k8s.capd.lb-pool label of the
+	// control plane containers.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Port routes the connections to this port of the load balancer container to the pool.
+	// The port is not published on the host.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// SNI routes the TLS connections for this server name to the pool.
+	// +optional
+	SNI string `json:"sni,omitempty"`
+
+	// Protocol is the transport protocol of the pool port. Only tcp is supported: the HAProxy
+	// image does not proxy UDP, so udp pools are rejected. Defaults to tcp.
+	// +kubebuilder:validation:Enum=tcp;udp
+	// +optional
+	Protocol string `json:"protocol,omitempty"`
 }
 
 // DockerClusterStatus defines the observed state of DockerCluster
@@ -54,6 +550,43 @@ type DockerClusterStatus struct {
 	// +optional
 	// +kubebuilder:default=false
 	Ready bool `json:"ready"`
+
+	// LoadBalancerImageDigest is the digest of the image the load balancer container runs, or its
+	// image ID if the image has no registry digest, e.g. when loaded from LoadBalancerImageArchive.
+	// +optional
+	LoadBalancerImageDigest string `json:"loadbalancerImageDigest,omitempty"`
+
+	// LoadBalancerHostPort is the host port the load balancer frontend is published on, so the
+	// control plane endpoint can be reached from the docker host. It is not set while the frontend
+	// port is not published, e.g. after LoadBalancerFrontendPort changed until the load balancer
+	// container is recreated.
+	// +optional
+	LoadBalancerHostPort int32 `json:"loadbalancerHostPort,omitempty"`
+
+	// LoadBalancerMetricsEndpoint is the URL the load balancer Prometheus metrics can be scraped
+	// from, when metrics are enabled.
+	// +optional
+	LoadBalancerMetricsEndpoint string `json:"loadbalancerMetricsEndpoint,omitempty"`
+
+	// LoadBalancerRestarts tracks the restarts of the load balancer container.
+	// +optional
+	LoadBalancerRestarts *LoadBalancerRestartStatus `json:"loadbalancerRestarts,omitempty"`
+
+	// Conditions defines current service state of the DockerCluster.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// LoadBalancerRestartStatus tracks the restarts of the load balancer container over a time window.
+type LoadBalancerRestartStatus struct {
+	// Count is the number of times the runtime restarted the load balancer container.
+	Count int32 `json:"count"`
+
+	// WindowStart is when the current observation window started.
+	WindowStart metav1.Time `json:"windowStart"`
+
+	// WindowStartCount is the restart count observed when the current observation window started.
+	WindowStartCount int32 `json:"windowStartCount"`
 }
 
 //+kubebuilder:object:root=true
@@ -83,3 +616,13 @@ type DockerClusterList struct {
 func init() {
 	SchemeBuilder.Register(&DockerCluster{}, &DockerClusterList{})
 }
+
+// GetConditions returns the conditions of DockerCluster status
+func (dockerCluster *DockerCluster) GetConditions() clusterv1.Conditions {
+	return dockerCluster.Status.Conditions
+}
+
+// SetConditions sets the conditions of DockerCluster status
+func (dockerCluster *DockerCluster) SetConditions(conditions clusterv1.Conditions) {
+	dockerCluster.Status.Conditions = conditions
+}
@@ -18,10 +18,23 @@ package v1alpha1
 
 import (
 	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/feature"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
 )
 
 // log is for logging in this package.
@@ -59,6 +72,10 @@ func (r *DockerCluster) ValidateCreate() error {
 		return fmt.Errorf("docker cluster name cannot be kubecon-eu")
 	}
 
+	if err := r.validate(nil); err != nil {
+		return err
+	}
+	r.warnIfEndpointUnreachable()
 	return nil
 }
 
@@ -66,7 +83,14 @@ func (r *DockerCluster) ValidateCreate() error {
 func (r *DockerCluster) ValidateUpdate(old runtime.Object) error {
 	dockerclusterlog.Info("validate update", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object update.
+	oldCluster, _ := old.(*DockerCluster)
+	if err := r.validate(oldCluster); err != nil {
+		return err
+	}
+	// The endpoint is only dialed when it changes, not on every update of the DockerCluster.
+	if oldCluster == nil || oldCluster.Spec.ControlPlaneEndpoint != r.Spec.ControlPlaneEndpoint {
+		r.warnIfEndpointUnreachable()
+	}
 	return nil
 }
 
@@ -77,3 +101,492 @@ func (r *DockerCluster) ValidateDelete() error {
 	// TODO(user): fill in your validation logic upon object deletion.
 	return nil
 }
+
+// maxBackendCacheTTL is the longest the load balancer backends can be cached for.
+const maxBackendCacheTTL = time.Minute
+
+// maxNodeIPTimeout is the longest backend discovery can wait for a control plane node IP.
+const maxNodeIPTimeout = time.Minute
+
+// imageDigestPattern matches the digest part of an image reference pinned by digest.
+var imageDigestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// runAsPattern matches a user or group name as accepted by useradd and groupadd, or a numeric ID.
+var runAsPattern = regexp.MustCompile(`^([a-z_][a-z0-9_-]*|[0-9]+)$`)
+
+// CheckEndpointReachability makes the webhook dial the control plane endpoint of a DockerCluster on
+// admission and warn if it is unreachable. It is set from the command line.
+var CheckEndpointReachability bool
+
+// endpointDialTimeout is how long the webhook waits for the control plane endpoint to accept a connection.
+const endpointDialTimeout = 2 * time.Second
+
+// dialEndpoint opens and closes a TCP connection to address; it is replaced in tests.
+var dialEndpoint = func(address string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// validate checks the load balancer settings of the DockerCluster spec. old is the DockerCluster being
+// updated, nil on create.
+func (r *DockerCluster) validate(old *DockerCluster) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if i := strings.LastIndex(r.Spec.LoadBalancerImage, "@"); i >= 0 {
+		if digest := r.Spec.LoadBalancerImage[i+1:]; !imageDigestPattern.MatchString(digest) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerImage"), r.Spec.LoadBalancerImage,
+				"image digest must have the form sha256:<64 hex characters>"))
+		}
+	}
+
+	if r.Spec.LoadBalancerImageArchive != "" && strings.Contains(r.Spec.LoadBalancerImage, "@") {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("loadbalancerImageArchive"),
+			"image archives do not record image digests, the load balancer image must be referenced by tag"))
+	}
+
+	if r.Spec.LoadBalancerHostname != "" {
+		for _, msg := range validation.IsDNS1123Label(r.Spec.LoadBalancerHostname) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerHostname"), r.Spec.LoadBalancerHostname, msg))
+		}
+	}
+
+	if r.Spec.LoadBalancerInitAddr != "" {
+		for _, method := range strings.Split(r.Spec.LoadBalancerInitAddr, ",") {
+			if method != "last" && method != "libc" && method != "none" && net.ParseIP(method) == nil {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerInitAddr"), r.Spec.LoadBalancerInitAddr,
+					fmt.Sprintf("invalid method %q, must be last, libc, none or an IP address", method)))
+			}
+		}
+	}
+
+	if r.Spec.LoadBalancerBackendSource != "" && net.ParseIP(r.Spec.LoadBalancerBackendSource) == nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerBackendSource"), r.Spec.LoadBalancerBackendSource,
+			"must be a valid IP address"))
+	}
+
+	switch r.Spec.LoadBalancerHealthCheckType {
+	case loadbalancer.HealthCheckSSL, loadbalancer.HealthCheckHTTPHealthz, loadbalancer.HealthCheckHTTPReadyz:
+	case "", loadbalancer.HealthCheckTCP:
+		// In tcp mode the traffic is passed through, so only the health checks could verify the certificates.
+		if r.Spec.LoadBalancerVerifyBackends && r.Spec.LoadBalancerMode != loadbalancer.ModeHTTP {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("loadbalancerVerifyBackends"),
+				"the tcp health check does not use TLS, so there are no certificates to verify in tcp mode"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("loadbalancerHealthCheckType"), r.Spec.LoadBalancerHealthCheckType,
+			[]string{loadbalancer.HealthCheckTCP, loadbalancer.HealthCheckSSL, loadbalancer.HealthCheckHTTPHealthz, loadbalancer.HealthCheckHTTPReadyz}))
+	}
+
+	if r.Spec.LoadBalancerProcesses > 1 && r.Spec.LoadBalancerThreads > 1 {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("loadbalancerProcesses"),
+			"cannot run multiple processes together with multiple threads, prefer loadbalancerThreads"))
+	}
+
+	if r.Spec.LoadBalancerMaxConn > 0 && r.Spec.LoadBalancerFrontendMaxConn > r.Spec.LoadBalancerMaxConn {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerFrontendMaxConn"), r.Spec.LoadBalancerFrontendMaxConn,
+			fmt.Sprintf("must not be greater than loadbalancerMaxConn (%d)", r.Spec.LoadBalancerMaxConn)))
+	}
+
+	if r.Spec.LoadBalancerRetries != nil && *r.Spec.LoadBalancerRetries < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerRetries"), *r.Spec.LoadBalancerRetries,
+			"must be greater than or equal to 0"))
+	}
+
+	if r.Spec.LoadBalancerMaxBackends < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerMaxBackends"), r.Spec.LoadBalancerMaxBackends,
+			"must be greater than or equal to 0"))
+	}
+
+	if r.Spec.LoadBalancerZeroBackendConfirmations < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerZeroBackendConfirmations"), r.Spec.LoadBalancerZeroBackendConfirmations,
+			"must be greater than or equal to 0"))
+	}
+
+	if r.Spec.LoadBalancerCheckPort < 0 || r.Spec.LoadBalancerCheckPort > 65535 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerCheckPort"), r.Spec.LoadBalancerCheckPort,
+			"must be a valid port number between 1 and 65535"))
+	}
+
+	if r.Spec.LoadBalancerEnableMetrics && r.Spec.LoadBalancerMetricsPort != 0 {
+		frontendPort := r.Spec.LoadBalancerFrontendPort
+		if frontendPort == 0 {
+			frontendPort = loadbalancer.ControlPlanePort
+		}
+		if r.Spec.LoadBalancerMetricsPort == frontendPort || r.Spec.LoadBalancerMetricsPort == loadbalancer.StatsPort {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerMetricsPort"), r.Spec.LoadBalancerMetricsPort,
+				"must not conflict with the load balancer frontend or stats ports"))
+		}
+	}
+
+	for i, source := range r.Spec.LoadBalancerMetricsAllowedSources {
+		if _, _, err := net.ParseCIDR(source); err != nil && net.ParseIP(source) == nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerMetricsAllowedSources").Index(i), source,
+				"must be an IP address or a CIDR"))
+		}
+	}
+
+	// The load balancer is always attached to the cluster network.
+	clusterNetwork := r.Spec.Network
+	if clusterNetwork == "" {
+		clusterNetwork = DefaultNetwork
+	}
+	networks := map[string]bool{clusterNetwork: true}
+	for i, network := range r.Spec.LoadBalancerNetworks {
+		networkPath := specPath.Child("loadbalancerNetworks").Index(i)
+		switch {
+		case network == "":
+			allErrs = append(allErrs, field.Required(networkPath, "must not be empty"))
+		case networks[network]:
+			allErrs = append(allErrs, field.Duplicate(networkPath, network))
+		}
+		networks[network] = true
+	}
+
+	if placement := r.Spec.LoadBalancerPlacement; placement != nil && placement.HostSelector != "" && placement.ControlPlaneAffinity != "" {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("loadbalancerPlacement", "controlPlaneAffinity"),
+			"the load balancer cannot be placed relative to the control plane when pinned to a host"))
+	}
+
+	if ttl := r.Spec.LoadBalancerBackendCacheTTL; ttl != nil && (ttl.Duration < 0 || ttl.Duration > maxBackendCacheTTL) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerBackendCacheTTL"), ttl.Duration.String(),
+			fmt.Sprintf("must be between 0s and %s", maxBackendCacheTTL)))
+	}
+
+	if timeout := r.Spec.LoadBalancerNodeIPTimeout; timeout != nil && (timeout.Duration < 0 || timeout.Duration > maxNodeIPTimeout) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerNodeIPTimeout"), timeout.Duration.String(),
+			fmt.Sprintf("must be between 0s and %s", maxNodeIPTimeout)))
+	}
+
+	if timeout := r.Spec.LoadBalancerStopTimeout; timeout != nil && timeout.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerStopTimeout"), timeout.Duration.String(),
+			"must be greater than or equal to 0s"))
+	}
+
+	if hardStopAfter := r.Spec.LoadBalancerHardStopAfter; hardStopAfter != nil && hardStopAfter.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerHardStopAfter"), hardStopAfter.Duration.String(),
+			"must be greater than 0s"))
+	}
+
+	if user := r.Spec.LoadBalancerRunAsUser; user != "" && !runAsPattern.MatchString(user) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerRunAsUser"), user,
+			"must be a user name or a numeric ID"))
+	}
+	if group := r.Spec.LoadBalancerRunAsGroup; group != "" && !runAsPattern.MatchString(group) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerRunAsGroup"), group,
+			"must be a group name or a numeric ID"))
+	}
+
+	argsPath := specPath.Child("loadbalancerArgs")
+	for i, arg := range r.Spec.LoadBalancerArgs {
+		if arg != "-f" {
+			continue
+		}
+		if i+1 >= len(r.Spec.LoadBalancerArgs) || r.Spec.LoadBalancerArgs[i+1] != loadbalancer.ConfigPath {
+			allErrs = append(allErrs, field.Invalid(argsPath.Index(i), arg,
+				fmt.Sprintf("the load balancer configuration file is managed by the provider and must be %s", loadbalancer.ConfigPath)))
+		}
+	}
+
+	sysctlsPath := specPath.Child("loadbalancerSysctls")
+	for key := range r.Spec.LoadBalancerSysctls {
+		if !isNamespacedSysctl(key) {
+			allErrs = append(allErrs, field.Invalid(sysctlsPath.Key(key), key,
+				"only namespaced sysctls (net.*, kernel.shm*, kernel.msg*, kernel.sem, fs.mqueue.*) can be set in the load balancer container"))
+		}
+	}
+
+	envPath := specPath.Child("loadbalancerEnv")
+	for key := range r.Spec.LoadBalancerEnv {
+		for _, msg := range validation.IsEnvVarName(key) {
+			allErrs = append(allErrs, field.Invalid(envPath.Key(key), key, msg))
+		}
+	}
+
+	if r.Spec.LoadBalancerPrivileged && (len(r.Spec.LoadBalancerCapAdd) > 0 || len(r.Spec.LoadBalancerCapDrop) > 0) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("loadbalancerPrivileged"),
+			"capabilities are ignored in privileged containers, unset loadbalancerCapAdd and loadbalancerCapDrop"))
+	}
+
+	allErrs = append(allErrs, validateCapabilities(specPath.Child("loadbalancerCapAdd"), r.Spec.LoadBalancerCapAdd)...)
+	allErrs = append(allErrs, validateCapabilities(specPath.Child("loadbalancerCapDrop"), r.Spec.LoadBalancerCapDrop)...)
+	added := map[string]bool{}
+	for _, capability := range r.Spec.LoadBalancerCapAdd {
+		added[normalizeCapability(capability)] = true
+	}
+	for i, capability := range r.Spec.LoadBalancerCapDrop {
+		if name := normalizeCapability(capability); name != "ALL" && added[name] {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerCapDrop").Index(i), capability,
+				"cannot both add and drop the same capability"))
+		}
+	}
+
+	allErrs = append(allErrs, validateExtraHosts(specPath.Child("loadbalancerExtraHosts"), r.Spec.LoadBalancerExtraHosts)...)
+
+	if len(r.Spec.LoadBalancerLogOptions) > 0 && r.Spec.LoadBalancerLogDriver == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("loadbalancerLogDriver"),
+			"log options are specific to a log driver, set the driver they apply to"))
+	}
+
+	if r.Spec.LoadBalancerHTTPReuse != "" && r.Spec.LoadBalancerMode != loadbalancer.ModeHTTP {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("loadbalancerHTTPReuse"),
+			"connections are only reused between requests in http mode, tcp mode does not see the requests"))
+	}
+
+	if strings.ContainsAny(r.Spec.LoadBalancerLogFormat, "\r\n") {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerLogFormat"), r.Spec.LoadBalancerLogFormat,
+			"must be on a single line"))
+	}
+
+	allErrs = append(allErrs, r.validateBackendPools(specPath.Child("loadbalancerBackendPools"), old)...)
+	allErrs = append(allErrs, r.validateSNIRoutes(old)...)
+
+	if _, err := loadbalancer.DefaultsOverride(r.Spec.LoadBalancerDefaultsOverride); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerDefaultsOverride"), r.Spec.LoadBalancerDefaultsOverride, err.Error()))
+	}
+
+	if _, err := loadbalancer.ParseConfigOverrides(r.Spec.LoadBalancerConfigPatch); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("loadbalancerConfigPatch"), r.Spec.LoadBalancerConfigPatch, err.Error()))
+	}
+
+	allErrs = append(allErrs, validateEndpoint(specPath.Child("controlPlaneEndpoint"), r.Spec.ControlPlaneEndpoint)...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("DockerCluster").GroupKind(), r.Name, allErrs)
+}
+
+// validateEndpoint checks the endpoint is either unset or has a host, as an IP address or a DNS name, and a port.
+func validateEndpoint(fldPath *field.Path, endpoint clusterv1.APIEndpoint) field.ErrorList {
+	var allErrs field.ErrorList
+	if endpoint.IsZero() {
+		return nil
+	}
+	if endpoint.Host == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("host"), "must be set with the port"))
+	} else if net.ParseIP(endpoint.Host) == nil {
+		for _, msg := range validation.IsDNS1123Subdomain(endpoint.Host) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("host"), endpoint.Host, "must be an IP address or a DNS name: "+msg))
+		}
+	}
+	for _, msg := range validation.IsValidPortNum(int(endpoint.Port)) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), endpoint.Port, msg))
+	}
+	return allErrs
+}
+
+// warnIfEndpointUnreachable dials the control plane endpoint when CheckEndpointReachability is enabled,
+// and logs a warning if it is not reachable from the webhook.
+// An unreachable endpoint is not rejected, as it may only come up after the DockerCluster is admitted.
+func (r *DockerCluster) warnIfEndpointUnreachable() {
+	endpoint := r.Spec.ControlPlaneEndpoint
+	if !CheckEndpointReachability || endpoint.IsZero() {
+		return
+	}
+	if err := dialEndpoint(endpoint.String(), endpointDialTimeout); err != nil {
+		dockerclusterlog.Info("Control plane endpoint is not reachable", "name", r.Name, "endpoint", endpoint.String(), "error", err.Error())
+	}
+}
+
+// validateSNIRoutes checks the routes of the SNIRoutesAnnotation are well formed and go to an existing backend.
+func (r *DockerCluster) validateSNIRoutes(old *DockerCluster) field.ErrorList {
+	value, ok := r.Annotations[SNIRoutesAnnotation]
+	if !ok {
+		return nil
+	}
+	fldPath := field.NewPath("metadata", "annotations").Key(SNIRoutesAnnotation)
+	// As for the backend pools, only adding or changing the routes requires the gate.
+	oldValue, oldOK := "", false
+	if old != nil {
+		oldValue, oldOK = old.Annotations[SNIRoutesAnnotation]
+	}
+	unchanged := oldOK && oldValue == value
+	if !unchanged && !feature.Gates.Enabled(feature.LoadBalancerSNIRouting) {
+		return field.ErrorList{field.Forbidden(fldPath,
+			"can be set only if the LoadBalancerSNIRouting feature flag is enabled")}
+	}
+
+	routes, err := loadbalancer.ParseSNIRoutes(value)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fldPath, value, err.Error())}
+	}
+	backends := map[string]bool{loadbalancer.BackendName: true}
+	for _, pool := range r.Spec.LoadBalancerBackendPools {
+		backends[pool.Name] = true
+	}
+	var allErrs field.ErrorList
+	for _, route := range routes {
+		if !backends[route.Backend] {
+			allErrs = append(allErrs, field.Invalid(fldPath, value,
+				fmt.Sprintf("backend %q of server name %q must be %s or a backend pool", route.Backend, route.ServerName, loadbalancer.BackendName)))
+		}
+	}
+	return allErrs
+}
+
+// validateBackendPools checks the backend pools have unique names and routes that do not conflict
+// with each other or with the other load balancer frontends.
+func (r *DockerCluster) validateBackendPools(fldPath *field.Path, old *DockerCluster) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(r.Spec.LoadBalancerBackendPools) == 0 {
+		return nil
+	}
+	// Turning the gate off must not lock the clusters already using pools, e.g. out of their deletion,
+	// so only adding or changing pools requires it.
+	unchanged := old != nil && reflect.DeepEqual(old.Spec.LoadBalancerBackendPools, r.Spec.LoadBalancerBackendPools)
+	if !unchanged && !feature.Gates.Enabled(feature.LoadBalancerBackendPools) {
+		return append(allErrs, field.Forbidden(fldPath,
+			"can be set only if the LoadBalancerBackendPools feature flag is enabled"))
+	}
+
+	frontendPort := r.Spec.LoadBalancerFrontendPort
+	if frontendPort == 0 {
+		frontendPort = loadbalancer.ControlPlanePort
+	}
+	reservedPorts := map[int32]bool{frontendPort: true, loadbalancer.StatsPort: true}
+	if r.Spec.LoadBalancerEnableMetrics && r.Spec.LoadBalancerMetricsPort != 0 {
+		reservedPorts[r.Spec.LoadBalancerMetricsPort] = true
+	}
+
+	names := map[string]bool{}
+	ports := map[int32]bool{}
+	snis := map[string]bool{}
+	for i, pool := range r.Spec.LoadBalancerBackendPools {
+		poolPath := fldPath.Index(i)
+		switch {
+		case pool.Name == loadbalancer.BackendName:
+			allErrs = append(allErrs, field.Invalid(poolPath.Child("name"), pool.Name,
+				"is reserved for the default backend"))
+		case names[pool.Name]:
+			allErrs = append(allErrs, field.Duplicate(poolPath.Child("name"), pool.Name))
+		}
+		names[pool.Name] = true
+
+		if pool.Port == 0 && pool.SNI == "" {
+			allErrs = append(allErrs, field.Required(poolPath, "at least one of port or sni must be set"))
+		}
+		if pool.Port != 0 {
+			switch {
+			case pool.Port < 0 || pool.Port > 65535:
+				allErrs = append(allErrs, field.Invalid(poolPath.Child("port"), pool.Port,
+					"must be a valid port number between 1 and 65535"))
+			case reservedPorts[pool.Port]:
+				allErrs = append(allErrs, field.Invalid(poolPath.Child("port"), pool.Port,
+					"must not conflict with the load balancer frontend, stats or metrics ports"))
+			case ports[pool.Port]:
+				allErrs = append(allErrs, field.Duplicate(poolPath.Child("port"), pool.Port))
+			}
+			ports[pool.Port] = true
+		}
+		if pool.Protocol != "" && pool.Protocol != "tcp" {
+			allErrs = append(allErrs, field.NotSupported(poolPath.Child("protocol"), pool.Protocol,
+				[]string{"tcp"}))
+		}
+		if pool.SNI != "" {
+			if snis[pool.SNI] {
+				allErrs = append(allErrs, field.Duplicate(poolPath.Child("sni"), pool.SNI))
+			}
+			snis[pool.SNI] = true
+		}
+	}
+	return allErrs
+}
+
+// knownCapabilities are the Linux capabilities the container runtime accepts, without the CAP_ prefix.
+var knownCapabilities = map[string]bool{
+	"ALL":                true,
+	"AUDIT_CONTROL":      true,
+	"AUDIT_READ":         true,
+	"AUDIT_WRITE":        true,
+	"BLOCK_SUSPEND":      true,
+	"BPF":                true,
+	"CHECKPOINT_RESTORE": true,
+	"CHOWN":              true,
+	"DAC_OVERRIDE":       true,
+	"DAC_READ_SEARCH":    true,
+	"FOWNER":             true,
+	"FSETID":             true,
+	"IPC_LOCK":           true,
+	"IPC_OWNER":          true,
+	"KILL":               true,
+	"LEASE":              true,
+	"LINUX_IMMUTABLE":    true,
+	"MAC_ADMIN":          true,
+	"MAC_OVERRIDE":       true,
+	"MKNOD":              true,
+	"NET_ADMIN":          true,
+	"NET_BIND_SERVICE":   true,
+	"NET_BROADCAST":      true,
+	"NET_RAW":            true,
+	"PERFMON":            true,
+	"SETFCAP":            true,
+	"SETGID":             true,
+	"SETPCAP":            true,
+	"SETUID":             true,
+	"SYS_ADMIN":          true,
+	"SYS_BOOT":           true,
+	"SYS_CHROOT":         true,
+	"SYS_MODULE":         true,
+	"SYS_NICE":           true,
+	"SYS_PACCT":          true,
+	"SYS_PTRACE":         true,
+	"SYS_RAWIO":          true,
+	"SYS_RESOURCE":       true,
+	"SYS_TIME":           true,
+	"SYS_TTY_CONFIG":     true,
+	"SYSLOG":             true,
+	"WAKE_ALARM":         true,
+}
+
+// normalizeCapability returns the capability name in upper case without the CAP_ prefix.
+func normalizeCapability(capability string) string {
+	return strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+}
+
+// validateCapabilities checks all the capabilities are known.
+func validateCapabilities(fldPath *field.Path, capabilities []string) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, capability := range capabilities {
+		if !knownCapabilities[normalizeCapability(capability)] {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Index(i), capability, nil))
+		}
+	}
+	return allErrs
+}
+
+// validateExtraHosts checks all the extra hosts are a host name and an IP separated by a colon.
+func validateExtraHosts(fldPath *field.Path, extraHosts []string) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, extraHost := range extraHosts {
+		name, ip, ok := strings.Cut(extraHost, ":")
+		if !ok {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), extraHost, "must be in the name:ip format"))
+			continue
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), extraHost, msg))
+		}
+		if ip != "host-gateway" && net.ParseIP(ip) == nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), extraHost, "must end with a valid IP address or host-gateway"))
+		}
+	}
+	return allErrs
+}
+
+// isNamespacedSysctl returns true if the sysctl is namespaced, and can therefore be set on a single container.
+// This is the list the docker daemon accepts for containers that do not share the host network namespace.
+func isNamespacedSysctl(key string) bool {
+	if key == "kernel.sem" {
+		return true
+	}
+	for _, prefix := range []string{"kernel.shm", "kernel.msg", "fs.mqueue.", "net."} {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,646 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/feature"
+)
+
+func TestDockerClusterValidate(t *testing.T) {
+	negativeRetries := int32(-1)
+
+	tests := []struct {
+		name    string
+		spec    DockerClusterSpec
+		wantErr bool
+	}{
+		{
+			name: "empty spec",
+			spec: DockerClusterSpec{},
+		},
+		{
+			name: "multiple threads",
+			spec: DockerClusterSpec{LoadBalancerThreads: 4},
+		},
+		{
+			name: "multiple processes with a single thread",
+			spec: DockerClusterSpec{LoadBalancerProcesses: 2, LoadBalancerThreads: 1},
+		},
+		{
+			name: "image pinned by digest",
+			spec: DockerClusterSpec{LoadBalancerImage: "haproxytech/haproxy-alpine@sha256:" + strings.Repeat("a", 64)},
+		},
+		{
+			name:    "image with a malformed digest",
+			spec:    DockerClusterSpec{LoadBalancerImage: "haproxytech/haproxy-alpine@sha256:1234"},
+			wantErr: true,
+		},
+		{
+			name: "image archive",
+			spec: DockerClusterSpec{LoadBalancerImage: "haproxytech/haproxy-alpine:2.4", LoadBalancerImageArchive: "/images/haproxy.tar"},
+		},
+		{
+			name: "image archive with an image pinned by digest",
+			spec: DockerClusterSpec{
+				LoadBalancerImage:        "haproxytech/haproxy-alpine@sha256:" + strings.Repeat("a", 64),
+				LoadBalancerImageArchive: "/images/haproxy.tar",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "multiple processes with multiple threads",
+			spec:    DockerClusterSpec{LoadBalancerProcesses: 2, LoadBalancerThreads: 2},
+			wantErr: true,
+		},
+		{
+			name: "frontend maxconn within the global maxconn",
+			spec: DockerClusterSpec{LoadBalancerMaxConn: 4096, LoadBalancerFrontendMaxConn: 4000},
+		},
+		{
+			name: "frontend maxconn without a global maxconn",
+			spec: DockerClusterSpec{LoadBalancerFrontendMaxConn: 4000},
+		},
+		{
+			name:    "frontend maxconn greater than the global maxconn",
+			spec:    DockerClusterSpec{LoadBalancerMaxConn: 1000, LoadBalancerFrontendMaxConn: 2000},
+			wantErr: true,
+		},
+		{
+			name: "backend cache",
+			spec: DockerClusterSpec{LoadBalancerBackendCacheTTL: &metav1.Duration{Duration: 30 * time.Second}},
+		},
+		{
+			name:    "backend cache with a too long TTL",
+			spec:    DockerClusterSpec{LoadBalancerBackendCacheTTL: &metav1.Duration{Duration: time.Hour}},
+			wantErr: true,
+		},
+		{
+			name: "node IP timeout",
+			spec: DockerClusterSpec{LoadBalancerNodeIPTimeout: &metav1.Duration{Duration: 5 * time.Second}},
+		},
+		{
+			name:    "node IP timeout too long",
+			spec:    DockerClusterSpec{LoadBalancerNodeIPTimeout: &metav1.Duration{Duration: 2 * time.Minute}},
+			wantErr: true,
+		},
+		{
+			name: "capabilities",
+			spec: DockerClusterSpec{LoadBalancerCapAdd: []string{"NET_BIND_SERVICE", "cap_net_admin"}, LoadBalancerCapDrop: []string{"ALL"}},
+		},
+		{
+			name:    "unknown capability",
+			spec:    DockerClusterSpec{LoadBalancerCapAdd: []string{"NET_FOO"}},
+			wantErr: true,
+		},
+		{
+			name:    "capability added and dropped",
+			spec:    DockerClusterSpec{LoadBalancerCapAdd: []string{"NET_ADMIN"}, LoadBalancerCapDrop: []string{"CAP_NET_ADMIN"}},
+			wantErr: true,
+		},
+		{
+			name:    "negative retries",
+			spec:    DockerClusterSpec{LoadBalancerRetries: &negativeRetries},
+			wantErr: true,
+		},
+		{
+			name:    "negative stop timeout",
+			spec:    DockerClusterSpec{LoadBalancerStopTimeout: &metav1.Duration{Duration: -time.Second}},
+			wantErr: true,
+		},
+		{
+			name: "hard stop after",
+			spec: DockerClusterSpec{LoadBalancerHardStopAfter: &metav1.Duration{Duration: time.Minute}},
+		},
+		{
+			name:    "zero hard stop after",
+			spec:    DockerClusterSpec{LoadBalancerHardStopAfter: &metav1.Duration{}},
+			wantErr: true,
+		},
+		{
+			name: "control plane endpoint",
+			spec: DockerClusterSpec{ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "lb.example.com", Port: 6443}},
+		},
+		{
+			name: "control plane endpoint with an IPv6 address",
+			spec: DockerClusterSpec{ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "fd00::2", Port: 6443}},
+		},
+		{
+			name:    "control plane endpoint without a host",
+			spec:    DockerClusterSpec{ControlPlaneEndpoint: clusterv1.APIEndpoint{Port: 6443}},
+			wantErr: true,
+		},
+		{
+			name:    "control plane endpoint with a malformed host",
+			spec:    DockerClusterSpec{ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "https://lb.example.com", Port: 6443}},
+			wantErr: true,
+		},
+		{
+			name:    "control plane endpoint without a port",
+			spec:    DockerClusterSpec{ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "172.18.0.2"}},
+			wantErr: true,
+		},
+		{
+			name: "run as user and group",
+			spec: DockerClusterSpec{LoadBalancerRunAsUser: "haproxy", LoadBalancerRunAsGroup: "99"},
+		},
+		{
+			name:    "invalid run as user",
+			spec:    DockerClusterSpec{LoadBalancerRunAsUser: "haproxy user"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid run as group",
+			spec:    DockerClusterSpec{LoadBalancerRunAsGroup: "haproxy:haproxy"},
+			wantErr: true,
+		},
+		{
+			name: "config patch",
+			spec: DockerClusterSpec{LoadBalancerConfigPatch: "defaults\n  timeout client 30s\n"},
+		},
+		{
+			name:    "config patch outside of a section",
+			spec:    DockerClusterSpec{LoadBalancerConfigPatch: "timeout client 30s\n"},
+			wantErr: true,
+		},
+		{
+			name: "defaults override",
+			spec: DockerClusterSpec{LoadBalancerDefaultsOverride: map[string]string{"timeout client": "30s", "option tcpka": ""}},
+		},
+		{
+			name:    "defaults override with an unknown directive",
+			spec:    DockerClusterSpec{LoadBalancerDefaultsOverride: map[string]string{"mode": "http"}},
+			wantErr: true,
+		},
+		{
+			name: "init-addr",
+			spec: DockerClusterSpec{LoadBalancerInitAddr: "last,libc,172.18.0.3,none"},
+		},
+		{
+			name:    "invalid init-addr",
+			spec:    DockerClusterSpec{LoadBalancerInitAddr: "libc,dns"},
+			wantErr: true,
+		},
+		{
+			name: "backend source",
+			spec: DockerClusterSpec{LoadBalancerBackendSource: "172.18.0.100"},
+		},
+		{
+			name:    "invalid backend source",
+			spec:    DockerClusterSpec{LoadBalancerBackendSource: "172.18.0.100:0"},
+			wantErr: true,
+		},
+		{
+			name: "hostname",
+			spec: DockerClusterSpec{LoadBalancerHostname: "lb-1"},
+		},
+		{
+			name:    "hostname is not a DNS label",
+			spec:    DockerClusterSpec{LoadBalancerHostname: "lb.example.com"},
+			wantErr: true,
+		},
+		{
+			name: "privileged",
+			spec: DockerClusterSpec{LoadBalancerPrivileged: true},
+		},
+		{
+			name:    "privileged with capabilities",
+			spec:    DockerClusterSpec{LoadBalancerPrivileged: true, LoadBalancerCapAdd: []string{"NET_ADMIN"}},
+			wantErr: true,
+		},
+		{
+			name: "extra hosts",
+			spec: DockerClusterSpec{LoadBalancerExtraHosts: []string{"apiserver.test:172.18.0.3", "ipv6.test:fd00::3", "docker.test:host-gateway"}},
+		},
+		{
+			name:    "extra host without an IP",
+			spec:    DockerClusterSpec{LoadBalancerExtraHosts: []string{"apiserver.test"}},
+			wantErr: true,
+		},
+		{
+			name:    "extra host with an invalid IP",
+			spec:    DockerClusterSpec{LoadBalancerExtraHosts: []string{"apiserver.test:172.18.0"}},
+			wantErr: true,
+		},
+		{
+			name:    "extra host with an invalid name",
+			spec:    DockerClusterSpec{LoadBalancerExtraHosts: []string{"api_server:172.18.0.3"}},
+			wantErr: true,
+		},
+		{
+			name: "health check type",
+			spec: DockerClusterSpec{LoadBalancerHealthCheckType: "httpReadyz", LoadBalancerVerifyBackends: true},
+		},
+		{
+			name:    "unsupported health check type",
+			spec:    DockerClusterSpec{LoadBalancerHealthCheckType: "grpc"},
+			wantErr: true,
+		},
+		{
+			name:    "tcp health check verifying backends in tcp mode",
+			spec:    DockerClusterSpec{LoadBalancerHealthCheckType: "tcp", LoadBalancerVerifyBackends: true},
+			wantErr: true,
+		},
+		{
+			name: "tcp health check verifying backends in http mode",
+			spec: DockerClusterSpec{LoadBalancerHealthCheckType: "tcp", LoadBalancerVerifyBackends: true, LoadBalancerMode: "http"},
+		},
+		{
+			name: "environment variables",
+			spec: DockerClusterSpec{LoadBalancerEnv: map[string]string{"HAPROXY_DEBUG": "1", "lb.mode": "debug"}},
+		},
+		{
+			name:    "environment variable with an invalid name",
+			spec:    DockerClusterSpec{LoadBalancerEnv: map[string]string{"1HAPROXY": "1"}},
+			wantErr: true,
+		},
+		{
+			name: "log driver with options",
+			spec: DockerClusterSpec{LoadBalancerLogDriver: "fluentd", LoadBalancerLogOptions: map[string]string{"fluentd-address": "localhost:24224"}},
+		},
+		{
+			name:    "log options without a log driver",
+			spec:    DockerClusterSpec{LoadBalancerLogOptions: map[string]string{"max-size": "10m"}},
+			wantErr: true,
+		},
+		{
+			name: "json log format",
+			spec: DockerClusterSpec{LoadBalancerLogFormat: LoadBalancerLogFormatJSON},
+		},
+		{
+			name: "custom log format",
+			spec: DockerClusterSpec{LoadBalancerLogFormat: "%ci:%cp [%t] %ft %b/%s %Tw/%Tc/%Tt %B %ts"},
+		},
+		{
+			name:    "log format on several lines",
+			spec:    DockerClusterSpec{LoadBalancerLogFormat: "%ci\nbackend other"},
+			wantErr: true,
+		},
+		{
+			name: "http reuse in http mode",
+			spec: DockerClusterSpec{LoadBalancerMode: "http", LoadBalancerHTTPReuse: "aggressive"},
+		},
+		{
+			name:    "http reuse in tcp mode",
+			spec:    DockerClusterSpec{LoadBalancerHTTPReuse: "always"},
+			wantErr: true,
+		},
+		{
+			name: "max backends",
+			spec: DockerClusterSpec{LoadBalancerMaxBackends: 2},
+		},
+		{
+			name:    "negative max backends",
+			spec:    DockerClusterSpec{LoadBalancerMaxBackends: -1},
+			wantErr: true,
+		},
+		{
+			name: "zero backend confirmations",
+			spec: DockerClusterSpec{LoadBalancerZeroBackendConfirmations: 3},
+		},
+		{
+			name: "unset zero backend confirmations",
+			spec: DockerClusterSpec{LoadBalancerZeroBackendConfirmations: 0},
+		},
+		{
+			name:    "negative zero backend confirmations",
+			spec:    DockerClusterSpec{LoadBalancerZeroBackendConfirmations: -1},
+			wantErr: true,
+		},
+		{
+			name: "check port",
+			spec: DockerClusterSpec{LoadBalancerCheckPort: 10256},
+		},
+		{
+			name:    "check port out of range",
+			spec:    DockerClusterSpec{LoadBalancerCheckPort: 70000},
+			wantErr: true,
+		},
+		{
+			name: "extra args",
+			spec: DockerClusterSpec{LoadBalancerArgs: []string{"-d"}},
+		},
+		{
+			name: "args loading the managed config",
+			spec: DockerClusterSpec{LoadBalancerArgs: []string{"haproxy", "-f", "/usr/local/etc/haproxy/haproxy.cfg", "-d"}},
+		},
+		{
+			name:    "args loading a different config",
+			spec:    DockerClusterSpec{LoadBalancerArgs: []string{"-f", "/etc/haproxy.cfg"}},
+			wantErr: true,
+		},
+		{
+			name:    "args with a dangling config flag",
+			spec:    DockerClusterSpec{LoadBalancerArgs: []string{"-d", "-f"}},
+			wantErr: true,
+		},
+		{
+			name: "namespaced sysctls",
+			spec: DockerClusterSpec{LoadBalancerSysctls: map[string]string{"net.core.somaxconn": "4096", "kernel.shmmax": "68719476736"}},
+		},
+		{
+			name:    "node-level sysctls",
+			spec:    DockerClusterSpec{LoadBalancerSysctls: map[string]string{"vm.max_map_count": "262144"}},
+			wantErr: true,
+		},
+		{
+			name: "metrics on a custom port",
+			spec: DockerClusterSpec{LoadBalancerEnableMetrics: true, LoadBalancerMetricsPort: 9101},
+		},
+		{
+			name:    "metrics on the frontend port",
+			spec:    DockerClusterSpec{LoadBalancerEnableMetrics: true, LoadBalancerMetricsPort: 6443},
+			wantErr: true,
+		},
+		{
+			name:    "metrics on the stats port",
+			spec:    DockerClusterSpec{LoadBalancerEnableMetrics: true, LoadBalancerMetricsPort: 8404},
+			wantErr: true,
+		},
+		{
+			name: "additional load balancer networks",
+			spec: DockerClusterSpec{Network: "cluster", LoadBalancerNetworks: []string{"kind", "management"}},
+		},
+		{
+			name:    "load balancer network repeating the cluster network",
+			spec:    DockerClusterSpec{LoadBalancerNetworks: []string{"kind"}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate load balancer networks",
+			spec:    DockerClusterSpec{LoadBalancerNetworks: []string{"management", "management"}},
+			wantErr: true,
+		},
+		{
+			name:    "empty load balancer network",
+			spec:    DockerClusterSpec{LoadBalancerNetworks: []string{""}},
+			wantErr: true,
+		},
+		{
+			name: "load balancer pinned to a host",
+			spec: DockerClusterSpec{LoadBalancerPlacement: &LoadBalancerPlacement{HostSelector: "docker-host-1"}},
+		},
+		{
+			name: "load balancer colocated with the control plane",
+			spec: DockerClusterSpec{LoadBalancerPlacement: &LoadBalancerPlacement{ControlPlaneAffinity: LoadBalancerColocateWithControlPlane}},
+		},
+		{
+			name: "load balancer pinned to a host and spread from the control plane",
+			spec: DockerClusterSpec{LoadBalancerPlacement: &LoadBalancerPlacement{
+				HostSelector:         "docker-host-1",
+				ControlPlaneAffinity: LoadBalancerSpreadFromControlPlane,
+			}},
+			wantErr: true,
+		},
+		{
+			name: "metrics allowed from addresses and CIDRs",
+			spec: DockerClusterSpec{LoadBalancerEnableMetrics: true, LoadBalancerMetricsAllowedSources: []string{"172.18.0.5", "10.0.0.0/8", "fc00::/7"}},
+		},
+		{
+			name:    "metrics allowed from an invalid source",
+			spec:    DockerClusterSpec{LoadBalancerEnableMetrics: true, LoadBalancerMetricsAllowedSources: []string{"10.0.0.0/33"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			dockerCluster := &DockerCluster{Spec: tt.spec}
+			dockerCluster.Name = "test"
+
+			err := dockerCluster.validate(nil)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+}
+
+func TestDockerClusterValidateSNIRoutes(t *testing.T) {
+	t.Run("forbidden without the feature gate", func(t *testing.T) {
+		g := NewWithT(t)
+		dockerCluster := &DockerCluster{}
+		dockerCluster.Annotations = map[string]string{SNIRoutesAnnotation: "a.example.com=kube-apiservers"}
+		g.Expect(dockerCluster.validate(nil)).To(HaveOccurred())
+	})
+
+	t.Run("allowed without the feature gate on updates keeping the routes", func(t *testing.T) {
+		g := NewWithT(t)
+		old := &DockerCluster{}
+		old.Annotations = map[string]string{SNIRoutesAnnotation: "a.example.com=kube-apiservers"}
+		old.Finalizers = []string{ClusterFinalizer}
+		dockerCluster := old.DeepCopy()
+		dockerCluster.Finalizers = nil
+		g.Expect(dockerCluster.ValidateUpdate(old)).To(Succeed())
+
+		dockerCluster.Annotations[SNIRoutesAnnotation] = "b.example.com=kube-apiservers"
+		g.Expect(dockerCluster.ValidateUpdate(old)).ToNot(Succeed())
+	})
+
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.LoadBalancerSNIRouting, true)()
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.LoadBalancerBackendPools, true)()
+
+	tests := []struct {
+		name    string
+		routes  string
+		pools   []LoadBalancerBackendPool
+		wantErr bool
+	}{
+		{
+			name:   "route to the default backend",
+			routes: "a.example.com=kube-apiservers",
+		},
+		{
+			name:   "route to a backend pool",
+			routes: "a.example.com=kube-apiservers,b.example.com=readers",
+			pools:  []LoadBalancerBackendPool{{Name: "readers", Port: 7443}},
+		},
+		{
+			name:    "route to an unknown backend",
+			routes:  "b.example.com=readers",
+			wantErr: true,
+		},
+		{
+			name:    "malformed routes",
+			routes:  "a.example.com",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			dockerCluster := &DockerCluster{Spec: DockerClusterSpec{LoadBalancerBackendPools: tt.pools}}
+			dockerCluster.Name = "test"
+			dockerCluster.Annotations = map[string]string{SNIRoutesAnnotation: tt.routes}
+
+			err := dockerCluster.validate(nil)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+}
+
+func TestDockerClusterValidateBackendPools(t *testing.T) {
+	t.Run("forbidden without the feature gate", func(t *testing.T) {
+		g := NewWithT(t)
+		dockerCluster := &DockerCluster{Spec: DockerClusterSpec{
+			LoadBalancerBackendPools: []LoadBalancerBackendPool{{Name: "readers", Port: 7443}},
+		}}
+		g.Expect(dockerCluster.validate(nil)).To(HaveOccurred())
+	})
+
+	t.Run("allowed without the feature gate on updates keeping the pools", func(t *testing.T) {
+		g := NewWithT(t)
+		old := &DockerCluster{Spec: DockerClusterSpec{
+			LoadBalancerBackendPools: []LoadBalancerBackendPool{{Name: "readers", Port: 7443}},
+		}}
+		old.Finalizers = []string{ClusterFinalizer}
+		// e.g. removing the finalizer of a deleted cluster
+		dockerCluster := old.DeepCopy()
+		dockerCluster.Finalizers = nil
+		g.Expect(dockerCluster.ValidateUpdate(old)).To(Succeed())
+
+		dockerCluster.Spec.LoadBalancerBackendPools[0].Port = 7444
+		g.Expect(dockerCluster.ValidateUpdate(old)).ToNot(Succeed())
+	})
+
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.LoadBalancerBackendPools, true)()
+
+	tests := []struct {
+		name    string
+		pools   []LoadBalancerBackendPool
+		wantErr bool
+	}{
+		{
+			name:  "port and sni pools",
+			pools: []LoadBalancerBackendPool{{Name: "readers", Port: 7443}, {Name: "writers", SNI: "write.example.com"}},
+		},
+		{
+			name:    "pool without a route",
+			pools:   []LoadBalancerBackendPool{{Name: "readers"}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate names",
+			pools:   []LoadBalancerBackendPool{{Name: "readers", Port: 7443}, {Name: "readers", Port: 7444}},
+			wantErr: true,
+		},
+		{
+			name:    "reserved name",
+			pools:   []LoadBalancerBackendPool{{Name: "kube-apiservers", Port: 7443}},
+			wantErr: true,
+		},
+		{
+			name:    "port on the frontend port",
+			pools:   []LoadBalancerBackendPool{{Name: "readers", Port: 6443}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate ports",
+			pools:   []LoadBalancerBackendPool{{Name: "readers", Port: 7443}, {Name: "writers", Port: 7443}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate server names",
+			pools:   []LoadBalancerBackendPool{{Name: "readers", SNI: "example.com"}, {Name: "writers", SNI: "example.com"}},
+			wantErr: true,
+		},
+		{
+			name:  "tcp pool",
+			pools: []LoadBalancerBackendPool{{Name: "readers", Port: 7443, Protocol: "tcp"}},
+		},
+		{
+			name:    "udp pool",
+			pools:   []LoadBalancerBackendPool{{Name: "dns", Port: 53, Protocol: "udp"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			dockerCluster := &DockerCluster{Spec: DockerClusterSpec{LoadBalancerBackendPools: tt.pools}}
+			dockerCluster.Name = "test"
+
+			err := dockerCluster.validate(nil)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+}
+
+func TestDockerClusterEndpointReachability(t *testing.T) {
+	var dialed []string
+	defer func(dial func(string, time.Duration) error) { dialEndpoint = dial }(dialEndpoint)
+	dialEndpoint = func(address string, _ time.Duration) error {
+		dialed = append(dialed, address)
+		return errors.New("connection refused")
+	}
+	endpoint := clusterv1.APIEndpoint{Host: "172.18.0.2", Port: 6443}
+
+	t.Run("does not dial unless enabled", func(t *testing.T) {
+		g := NewWithT(t)
+		dialed = nil
+		g.Expect((&DockerCluster{Spec: DockerClusterSpec{ControlPlaneEndpoint: endpoint}}).ValidateCreate()).To(Succeed())
+		g.Expect(dialed).To(BeEmpty())
+	})
+
+	defer func(check bool) { CheckEndpointReachability = check }(CheckEndpointReachability)
+	CheckEndpointReachability = true
+
+	t.Run("admits an unreachable endpoint", func(t *testing.T) {
+		g := NewWithT(t)
+		dialed = nil
+		g.Expect((&DockerCluster{Spec: DockerClusterSpec{ControlPlaneEndpoint: endpoint}}).ValidateCreate()).To(Succeed())
+		g.Expect(dialed).To(Equal([]string{"172.18.0.2:6443"}))
+	})
+
+	t.Run("does not dial an unset endpoint", func(t *testing.T) {
+		g := NewWithT(t)
+		dialed = nil
+		g.Expect((&DockerCluster{}).ValidateCreate()).To(Succeed())
+		g.Expect(dialed).To(BeEmpty())
+	})
+
+	t.Run("only dials an endpoint changed by an update", func(t *testing.T) {
+		g := NewWithT(t)
+		dialed = nil
+		old := &DockerCluster{Spec: DockerClusterSpec{ControlPlaneEndpoint: endpoint}}
+		g.Expect((&DockerCluster{Spec: DockerClusterSpec{ControlPlaneEndpoint: endpoint}}).ValidateUpdate(old)).To(Succeed())
+		g.Expect(dialed).To(BeEmpty())
+
+		changed := clusterv1.APIEndpoint{Host: "172.18.0.3", Port: 6443}
+		g.Expect((&DockerCluster{Spec: DockerClusterSpec{ControlPlaneEndpoint: changed}}).ValidateUpdate(old)).To(Succeed())
+		g.Expect(dialed).To(Equal([]string{"172.18.0.3:6443"}))
+	})
+}
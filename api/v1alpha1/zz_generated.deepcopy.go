@@ -22,6 +22,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/cluster-api/api/v1beta1"
 )
@@ -31,8 +32,8 @@ func (in *DockerCluster) DeepCopyInto(out *DockerCluster) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DockerCluster.
@@ -89,6 +90,114 @@ func (in *DockerClusterList) DeepCopyObject() runtime.Object {
 func (in *DockerClusterSpec) DeepCopyInto(out *DockerClusterSpec) {
 	*out = *in
 	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	if in.LoadBalancerReloadTimeout != nil {
+		in, out := &in.LoadBalancerReloadTimeout, &out.LoadBalancerReloadTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.LoadBalancerRollbackUnhealthyConfig != nil {
+		in, out := &in.LoadBalancerRollbackUnhealthyConfig, &out.LoadBalancerRollbackUnhealthyConfig
+		*out = new(bool)
+		**out = **in
+	}
+	if in.LoadBalancerBackendCacheTTL != nil {
+		in, out := &in.LoadBalancerBackendCacheTTL, &out.LoadBalancerBackendCacheTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.LoadBalancerNodeIPTimeout != nil {
+		in, out := &in.LoadBalancerNodeIPTimeout, &out.LoadBalancerNodeIPTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.LoadBalancerStopTimeout != nil {
+		in, out := &in.LoadBalancerStopTimeout, &out.LoadBalancerStopTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.LoadBalancerHardStopAfter != nil {
+		in, out := &in.LoadBalancerHardStopAfter, &out.LoadBalancerHardStopAfter
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.LoadBalancerRetries != nil {
+		in, out := &in.LoadBalancerRetries, &out.LoadBalancerRetries
+		*out = new(int32)
+		**out = **in
+	}
+	if in.LoadBalancerRedispatch != nil {
+		in, out := &in.LoadBalancerRedispatch, &out.LoadBalancerRedispatch
+		*out = new(bool)
+		**out = **in
+	}
+	if in.LoadBalancerMetricsAllowedSources != nil {
+		in, out := &in.LoadBalancerMetricsAllowedSources, &out.LoadBalancerMetricsAllowedSources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoadBalancerArgs != nil {
+		in, out := &in.LoadBalancerArgs, &out.LoadBalancerArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoadBalancerEnv != nil {
+		in, out := &in.LoadBalancerEnv, &out.LoadBalancerEnv
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LoadBalancerSysctls != nil {
+		in, out := &in.LoadBalancerSysctls, &out.LoadBalancerSysctls
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LoadBalancerCapAdd != nil {
+		in, out := &in.LoadBalancerCapAdd, &out.LoadBalancerCapAdd
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoadBalancerCapDrop != nil {
+		in, out := &in.LoadBalancerCapDrop, &out.LoadBalancerCapDrop
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoadBalancerExtraHosts != nil {
+		in, out := &in.LoadBalancerExtraHosts, &out.LoadBalancerExtraHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoadBalancerLogOptions != nil {
+		in, out := &in.LoadBalancerLogOptions, &out.LoadBalancerLogOptions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LoadBalancerBackendPools != nil {
+		in, out := &in.LoadBalancerBackendPools, &out.LoadBalancerBackendPools
+		*out = make([]LoadBalancerBackendPool, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoadBalancerDefaultsOverride != nil {
+		in, out := &in.LoadBalancerDefaultsOverride, &out.LoadBalancerDefaultsOverride
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LoadBalancerNetworks != nil {
+		in, out := &in.LoadBalancerNetworks, &out.LoadBalancerNetworks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoadBalancerPlacement != nil {
+		in, out := &in.LoadBalancerPlacement, &out.LoadBalancerPlacement
+		*out = new(LoadBalancerPlacement)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DockerClusterSpec.
@@ -104,6 +213,18 @@ func (in *DockerClusterSpec) DeepCopy() *DockerClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DockerClusterStatus) DeepCopyInto(out *DockerClusterStatus) {
 	*out = *in
+	if in.LoadBalancerRestarts != nil {
+		in, out := &in.LoadBalancerRestarts, &out.LoadBalancerRestarts
+		*out = new(LoadBalancerRestartStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DockerClusterStatus.
@@ -329,6 +450,52 @@ func (in *DockerMachineTemplateStatus) DeepCopy() *DockerMachineTemplateStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerBackendPool) DeepCopyInto(out *LoadBalancerBackendPool) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerBackendPool.
+func (in *LoadBalancerBackendPool) DeepCopy() *LoadBalancerBackendPool {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerBackendPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerPlacement) DeepCopyInto(out *LoadBalancerPlacement) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerPlacement.
+func (in *LoadBalancerPlacement) DeepCopy() *LoadBalancerPlacement {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerPlacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerRestartStatus) DeepCopyInto(out *LoadBalancerRestartStatus) {
+	*out = *in
+	in.WindowStart.DeepCopyInto(&out.WindowStart)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerRestartStatus.
+func (in *LoadBalancerRestartStatus) DeepCopy() *LoadBalancerRestartStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerRestartStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Mount) DeepCopyInto(out *Mount) {
 	*out = *in
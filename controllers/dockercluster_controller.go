@@ -18,20 +18,30 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/klog/v2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/cluster-api/util/predicates"
+	"sigs.k8s.io/cluster-api/util/secret"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
@@ -40,6 +50,17 @@ import (
 	"github.com/pkg/errors"
 )
 
+// loadBalancerNotReadyRequeueAfter is how long to wait before checking again a load balancer that is not ready.
+const loadBalancerNotReadyRequeueAfter = 5 * time.Second
+
+const (
+	// loadBalancerConfigMapSuffix is appended to the DockerCluster name to name the ConfigMap mirroring
+	// the load balancer configuration, see DockerClusterSpec.LoadBalancerExposeConfigMap.
+	loadBalancerConfigMapSuffix = "-lb-config"
+	// loadBalancerConfigMapKey is the ConfigMap key holding the load balancer configuration.
+	loadBalancerConfigMapKey = "haproxy.cfg"
+)
+
 // DockerClusterReconciler reconciles a DockerCluster object
 type DockerClusterReconciler struct {
 	client.Client
@@ -51,6 +72,7 @@ type DockerClusterReconciler struct {
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=dockerclusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=dockerclusters/finalizers,verbs=update
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -97,6 +119,15 @@ func (r *DockerClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	if err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "failed to create helper for managing the externalLoadBalancer")
 	}
+	if err := setLoadBalancerBackendCA(ctx, r.Client, cluster, dockerCluster, externalLoadBalancer); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := setLoadBalancerMetricsAuth(ctx, r.Client, dockerCluster, externalLoadBalancer); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := setLoadBalancerUnhealthyMachines(ctx, r.Client, cluster, externalLoadBalancer); err != nil {
+		return ctrl.Result{}, err
+	}
 
 	// Initialize the patch helper
 	patchHelper, err := patch.NewHelper(dockerCluster, r.Client)
@@ -105,7 +136,7 @@ func (r *DockerClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	}
 	// Always attempt to Patch the DockerCluster object and status after each reconciliation.
 	defer func() {
-		if err := patchHelper.Patch(ctx, dockerCluster); err != nil {
+		if err := patchDockerCluster(ctx, patchHelper, dockerCluster); err != nil {
 			logger.Error(err, "failed to patch DockerCluster")
 			if rerr == nil {
 				rerr = err
@@ -119,14 +150,14 @@ func (r *DockerClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	}
 
 	// Handle non-deleted clusters
-	return r.reconcileNormal(ctx, dockerCluster, externalLoadBalancer)
+	return r.reconcileNormal(ctx, cluster, dockerCluster, externalLoadBalancer)
 
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *DockerClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
 	c, err := ctrl.NewControllerManagedBy(mgr).
-		For(&infrav1.DockerCluster{}).
+		For(&infrav1.DockerCluster{}, builder.WithPredicates(loadBalancerRelevantUpdates())).
 		//WithOptions(options).
 		WithEventFilter(predicates.ResourceNotPaused(ctrl.LoggerFrom(ctx))).
 		Build(r)
@@ -140,7 +171,176 @@ func (r *DockerClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl
 	)
 }
 
-func (r *DockerClusterReconciler) reconcileNormal(ctx context.Context, dockerCluster *infrav1.DockerCluster, externalLoadBalancer *docker.LoadBalancer) (ctrl.Result, error) {
+// loadBalancerRelevantUpdates filters out the DockerCluster updates that cannot affect the load balancer,
+// e.g. the controller publishing the control plane endpoint or updating the status.
+func loadBalancerRelevantUpdates() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldCluster, ok := e.ObjectOld.(*infrav1.DockerCluster)
+			if !ok {
+				return true
+			}
+			newCluster, ok := e.ObjectNew.(*infrav1.DockerCluster)
+			if !ok {
+				return true
+			}
+			// Periodic resyncs deliver an unchanged object, keep them to catch up with changes of the containers.
+			if oldCluster.ResourceVersion == newCluster.ResourceVersion {
+				return true
+			}
+			return docker.LBSpecChanged(oldCluster, newCluster) ||
+				!oldCluster.DeletionTimestamp.Equal(newCluster.DeletionTimestamp) ||
+				!reflect.DeepEqual(oldCluster.Annotations, newCluster.Annotations) ||
+				!reflect.DeepEqual(oldCluster.Labels, newCluster.Labels) ||
+				!reflect.DeepEqual(oldCluster.Finalizers, newCluster.Finalizers) ||
+				!reflect.DeepEqual(oldCluster.OwnerReferences, newCluster.OwnerReferences)
+		},
+	}
+}
+
+// setLoadBalancerBackendCA passes the cluster CA to the load balancer when it verifies the API server
+// certificates. Until the control plane provider generates the CA there are no API servers to verify,
+// so a missing CA leaves verification off.
+func setLoadBalancerBackendCA(ctx context.Context, c client.Client, cluster *clusterv1.Cluster, dockerCluster *infrav1.DockerCluster, externalLoadBalancer *docker.LoadBalancer) error {
+	if !dockerCluster.Spec.LoadBalancerVerifyBackends {
+		return nil
+	}
+
+	caSecret, err := secret.GetFromNamespacedName(ctx, c, util.ObjectKey(cluster), secret.ClusterCA)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.FromContext(ctx).Info("Cluster CA not found, not verifying the API server certificates yet")
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get the cluster CA for the load balancer")
+	}
+	ca, ok := caSecret.Data[secret.TLSCrtDataName]
+	if !ok {
+		return errors.Errorf("cluster CA secret %s is missing the %s key", klog.KObj(caSecret), secret.TLSCrtDataName)
+	}
+	externalLoadBalancer.SetBackendCA(ca)
+	return nil
+}
+
+// setLoadBalancerUnhealthyMachines passes the control plane Machines a MachineHealthCheck found unhealthy to
+// the load balancer, so it stops routing to them while they wait for remediation.
+func setLoadBalancerUnhealthyMachines(ctx context.Context, c client.Client, cluster *clusterv1.Cluster, externalLoadBalancer *docker.LoadBalancer) error {
+	machines := &clusterv1.MachineList{}
+	if err := c.List(ctx, machines, client.InNamespace(cluster.Namespace), client.MatchingLabels{clusterv1.ClusterLabelName: cluster.Name},
+		client.HasLabels{clusterv1.MachineControlPlaneLabelName}); err != nil {
+		return errors.Wrap(err, "failed to list the control plane machines for the load balancer")
+	}
+
+	unhealthy := []string{}
+	for i := range machines.Items {
+		if conditions.IsFalse(&machines.Items[i], clusterv1.MachineHealthCheckSucceededCondition) {
+			unhealthy = append(unhealthy, machines.Items[i].Name)
+		}
+	}
+	externalLoadBalancer.SetUnhealthyMachines(unhealthy...)
+	return nil
+}
+
+// setLoadBalancerMetricsAuth passes the credentials of the LoadBalancerMetricsAuthSecretName Secret to the
+// load balancer. A missing Secret fails the reconcile rather than exposing the metrics without them.
+func setLoadBalancerMetricsAuth(ctx context.Context, c client.Client, dockerCluster *infrav1.DockerCluster, externalLoadBalancer *docker.LoadBalancer) error {
+	if !dockerCluster.Spec.LoadBalancerEnableMetrics || dockerCluster.Spec.LoadBalancerMetricsAuthSecretName == "" {
+		return nil
+	}
+
+	authSecret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: dockerCluster.Namespace, Name: dockerCluster.Spec.LoadBalancerMetricsAuthSecretName}
+	if err := c.Get(ctx, key, authSecret); err != nil {
+		return errors.Wrapf(err, "failed to get the load balancer metrics credentials %s", key)
+	}
+	username, password := authSecret.Data[corev1.BasicAuthUsernameKey], authSecret.Data[corev1.BasicAuthPasswordKey]
+	if len(username) == 0 || len(password) == 0 {
+		return errors.Errorf("load balancer metrics credentials %s must have the %s and %s keys", key,
+			corev1.BasicAuthUsernameKey, corev1.BasicAuthPasswordKey)
+	}
+	externalLoadBalancer.SetMetricsAuth(string(username), string(password))
+	return nil
+}
+
+// syncLoadBalancerConfigMap mirrors the live load balancer configuration, with credentials redacted,
+// into a ConfigMap owned by the DockerCluster, or deletes the ConfigMap if the mirror is disabled.
+func syncLoadBalancerConfigMap(ctx context.Context, c client.Client, dockerCluster *infrav1.DockerCluster, externalLoadBalancer *docker.LoadBalancer) error {
+	if !dockerCluster.Spec.LoadBalancerExposeConfigMap {
+		return deleteLoadBalancerConfigMap(ctx, c, dockerCluster)
+	}
+
+	config, err := externalLoadBalancer.RedactedConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the load balancer configuration to mirror")
+	}
+
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      dockerCluster.Name + loadBalancerConfigMapSuffix,
+		Namespace: dockerCluster.Namespace,
+	}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, c, configMap, func() error {
+		if clusterName, ok := dockerCluster.Labels[clusterv1.ClusterLabelName]; ok {
+			if configMap.Labels == nil {
+				configMap.Labels = map[string]string{}
+			}
+			configMap.Labels[clusterv1.ClusterLabelName] = clusterName
+		}
+		configMap.Data = map[string]string{loadBalancerConfigMapKey: config}
+		return controllerutil.SetControllerReference(dockerCluster, configMap, c.Scheme())
+	}); err != nil {
+		return errors.Wrapf(err, "failed to mirror the load balancer configuration to ConfigMap %s", klog.KObj(configMap))
+	}
+	return nil
+}
+
+// deleteLoadBalancerConfigMap deletes the ConfigMap mirroring the load balancer configuration, if any.
+func deleteLoadBalancerConfigMap(ctx context.Context, c client.Client, dockerCluster *infrav1.DockerCluster) error {
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      dockerCluster.Name + loadBalancerConfigMapSuffix,
+		Namespace: dockerCluster.Namespace,
+	}}
+	if err := c.Delete(ctx, configMap); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete ConfigMap %s", klog.KObj(configMap))
+	}
+	return nil
+}
+
+// loadBalancerProvisioningFailedReason returns the reason of the LoadBalancerContainerReady condition
+// for an error creating the load balancer container.
+func loadBalancerProvisioningFailedReason(err error) string {
+	if errors.As(err, &container.ImageNotFoundError{}) {
+		return infrav1.LoadBalancerImageMissingReason
+	}
+	return infrav1.LoadBalancerProvisioningFailedReason
+}
+
+func patchDockerCluster(ctx context.Context, patchHelper *patch.Helper, dockerCluster *infrav1.DockerCluster) error {
+	// Always update the readyCondition by summarizing the state of other conditions.
+	// The load balancer configuration is owned by the control plane machines, so it is not part of the summary.
+	conditions.SetSummary(dockerCluster,
+		conditions.WithConditions(
+			infrav1.LoadBalancerContainerReadyCondition,
+			infrav1.LoadBalancerEndpointAvailableCondition,
+		),
+	)
+
+	// Patch the object, ignoring conflicts on the conditions owned by this controller.
+	return patchHelper.Patch(
+		ctx,
+		dockerCluster,
+		patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+			clusterv1.ReadyCondition,
+			infrav1.LoadBalancerContainerReadyCondition,
+			infrav1.LoadBalancerConfigAppliedCondition,
+			infrav1.LoadBalancerEndpointAvailableCondition,
+			infrav1.LoadBalancerHibernatedCondition,
+			infrav1.LoadBalancerUnstableCondition,
+			infrav1.LoadBalancerConfigDriftCondition,
+		}},
+	)
+}
+
+func (r *DockerClusterReconciler) reconcileNormal(ctx context.Context, cluster *clusterv1.Cluster, dockerCluster *infrav1.DockerCluster, externalLoadBalancer *docker.LoadBalancer) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 	logger.Info("Reconciling DockerCluster")
 
@@ -150,27 +350,240 @@ func (r *DockerClusterReconciler) reconcileNormal(ctx context.Context, dockerClu
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	// Recreate the docker container hosting the load balancer if requested.
+	// The control plane endpoint flaps to the new container IP, see RecreateLoadBalancerAnnotation.
+	// The annotation is removed once the container is recreated, so failing to configure the new container
+	// does not recreate it again; the configuration is retried by the control plane machines.
+	if _, ok := dockerCluster.Annotations[infrav1.RecreateLoadBalancerAnnotation]; ok {
+		if err := externalLoadBalancer.Recreate(ctx); err != nil {
+			conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerContainerReadyCondition, loadBalancerProvisioningFailedReason(err), clusterv1.ConditionSeverityWarning, err.Error())
+			return ctrl.Result{}, errors.Wrap(err, "failed to recreate load balancer")
+		}
+		delete(dockerCluster.Annotations, infrav1.RecreateLoadBalancerAnnotation)
+		if _, err := externalLoadBalancer.UpdateConfiguration(ctx); err != nil {
+			logger.Info("Failed to update the recreated load balancer configuration", "error", err.Error())
+			conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerConfigAppliedCondition, infrav1.LoadBalancerConfigPendingReason, clusterv1.ConditionSeverityWarning, err.Error())
+		}
+	}
+
+	// Migrate a load balancer container created by an earlier provider version to the current labels.
+	if _, err := externalLoadBalancer.Adopt(ctx); err != nil {
+		conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerContainerReadyCondition, loadBalancerProvisioningFailedReason(err), clusterv1.ConditionSeverityWarning, err.Error())
+		return ctrl.Result{}, errors.Wrap(err, "failed to adopt load balancer")
+	}
+
 	// Create the docker container hosting the load balancer.
-	if err := externalLoadBalancer.Create(ctx); err != nil {
-		return ctrl.Result{}, errors.Wrap(err, "failed to create load balancer")
+	state, err := externalLoadBalancer.Reconcile(ctx)
+	if err != nil {
+		conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerContainerReadyCondition, loadBalancerProvisioningFailedReason(err), clusterv1.ConditionSeverityWarning, err.Error())
+		return ctrl.Result{}, errors.Wrap(err, "failed to reconcile load balancer")
+	}
+	// Paused clusters are not reconciled in the first place; keep the last observed state rather than
+	// reporting the load balancer as not ready.
+	if state == docker.LoadBalancerPaused {
+		logger.Info("Load balancer is paused, not reconciling")
+		return ctrl.Result{}, nil
+	}
+	if err := r.reconcileLoadBalancerRestarts(ctx, dockerCluster, externalLoadBalancer); err != nil {
+		return ctrl.Result{}, err
+	}
+	// Do not publish the control plane endpoint until the load balancer has an address.
+	if state != docker.LoadBalancerReady {
+		reason := infrav1.WaitingForLoadBalancerAddressReason
+		if state == docker.LoadBalancerUpdating {
+			reason = infrav1.LoadBalancerUpdatingReason
+		}
+		conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerContainerReadyCondition, reason, clusterv1.ConditionSeverityInfo, "")
+		conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerEndpointAvailableCondition, reason, clusterv1.ConditionSeverityInfo, "")
+		logger.Info("Waiting for the load balancer to be ready", "state", state)
+		return ctrl.Result{RequeueAfter: loadBalancerNotReadyRequeueAfter}, nil
+	}
+	conditions.MarkTrue(dockerCluster, infrav1.LoadBalancerContainerReadyCondition)
+
+	// Rewrite the load balancer configuration from scratch if requested, see ReconfigureLoadBalancerAnnotation.
+	if _, ok := dockerCluster.Annotations[infrav1.ReconfigureLoadBalancerAnnotation]; ok {
+		if err := externalLoadBalancer.ForceReconfigure(ctx); err != nil {
+			conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerConfigAppliedCondition, infrav1.LoadBalancerConfigPendingReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return ctrl.Result{}, errors.Wrap(err, "failed to reconfigure load balancer")
+		}
+		delete(dockerCluster.Annotations, infrav1.ReconfigureLoadBalancerAnnotation)
+	}
+
+	// Control plane machines configure the load balancer, here we only observe whether the configuration is live.
+	if err := externalLoadBalancer.ConfigApplied(ctx); err != nil {
+		conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerConfigAppliedCondition, infrav1.LoadBalancerConfigPendingReason, clusterv1.ConditionSeverityInfo, err.Error())
+	} else {
+		conditions.MarkTrue(dockerCluster, infrav1.LoadBalancerConfigAppliedCondition)
+
+		// Record the live configuration, so its backends can be restored, see LoadBalancerConfigSnapshotAnnotation.
+		snapshot, err := externalLoadBalancer.ConfigSnapshot(ctx)
+		if err != nil {
+			logger.Info("Failed to snapshot the load balancer configuration", "error", err.Error())
+		} else {
+			if dockerCluster.Annotations == nil {
+				dockerCluster.Annotations = map[string]string{}
+			}
+			dockerCluster.Annotations[infrav1.LoadBalancerConfigSnapshotAnnotation] = snapshot
+		}
+	}
+
+	// Report the changes made to the configuration in the container that are kept on purpose.
+	drifted := false
+	if dockerCluster.Spec.LoadBalancerConfigDriftMode == infrav1.LoadBalancerConfigDriftObserve {
+		if drifted, err = externalLoadBalancer.ConfigDrift(ctx); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to check the load balancer configuration for changes")
+		}
+	}
+	if drifted {
+		conditions.Set(dockerCluster, &clusterv1.Condition{
+			Type:     infrav1.LoadBalancerConfigDriftCondition,
+			Status:   corev1.ConditionTrue,
+			Severity: clusterv1.ConditionSeverityWarning,
+			Reason:   infrav1.LoadBalancerConfigEditedReason,
+			Message:  "the load balancer configuration was changed in the container and is not updated until it is reconfigured",
+		})
+	} else {
+		conditions.Delete(dockerCluster, infrav1.LoadBalancerConfigDriftCondition)
+	}
+
+	if err := syncLoadBalancerConfigMap(ctx, r.Client, dockerCluster, externalLoadBalancer); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Report whether a cluster meant to be hibernated stopped serving its control plane.
+	hibernated := false
+	if _, ok := dockerCluster.Annotations[infrav1.HibernateLoadBalancerAnnotation]; ok {
+		if hibernated, err = externalLoadBalancer.Hibernated(ctx); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to check whether the load balancer is hibernated")
+		}
+		if hibernated {
+			conditions.MarkTrue(dockerCluster, infrav1.LoadBalancerHibernatedCondition)
+		} else {
+			conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerHibernatedCondition, infrav1.ControlPlaneRunningReason, clusterv1.ConditionSeverityInfo, "")
+		}
+	} else {
+		conditions.Delete(dockerCluster, infrav1.LoadBalancerHibernatedCondition)
+	}
+
+	// Report the reachability of the backends from the load balancer when debugging.
+	if debug := logger.V(4); debug.Enabled() {
+		probes, err := externalLoadBalancer.ProbeBackends(ctx)
+		if err != nil {
+			debug.Info("Failed to probe load balancer backends", "error", err.Error())
+		}
+		for server, probeErr := range probes {
+			if probeErr != nil {
+				debug.Info("Load balancer backend is not reachable", "server", server, "error", probeErr.Error())
+				continue
+			}
+			debug.Info("Load balancer backend is reachable", "server", server)
+		}
 	}
 
 	// Get the load balancer IP so we can use it for the enpoint address
 	lbIP, err := externalLoadBalancer.IP(ctx)
 	if err != nil {
+		conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerEndpointAvailableCondition, infrav1.LoadBalancerEndpointFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 		return ctrl.Result{}, errors.Wrap(err, "failed to get ip for the load balancer")
 	}
 
+	host := lbIP
+	if dockerCluster.Spec.LoadBalancerUseDNSName {
+		if err := externalLoadBalancer.ValidateDNSName(ctx); err != nil {
+			conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerEndpointAvailableCondition, infrav1.LoadBalancerEndpointFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return ctrl.Result{}, errors.Wrap(err, "failed to validate dns name for the load balancer")
+		}
+		host = externalLoadBalancer.DNSName()
+	}
+
+	// A container created before LoadBalancerFrontendPort changed does not publish the new port until it
+	// is recreated; the endpoint is still reachable on the load balancer address, so only report it.
+	lbStatus, err := externalLoadBalancer.Status(ctx)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to get host port for the load balancer")
+	}
+	if lbStatus.HostPort == 0 {
+		logger.Info("Load balancer frontend port is not published on the host, recreate the load balancer to publish it")
+	}
+	dockerCluster.Status.LoadBalancerHostPort = lbStatus.HostPort
+
+	metricsEndpoint, err := externalLoadBalancer.MetricsEndpoint(ctx)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to get metrics endpoint for the load balancer")
+	}
+	dockerCluster.Status.LoadBalancerMetricsEndpoint = metricsEndpoint
+
+	// Once the control plane is initialized, optionally wait for the load balancer to route to an API server
+	// before publishing the endpoint; before that there is no control plane machine to configure it.
+	// A hibernated load balancer has no backends on purpose, so the endpoint is kept.
+	if dockerCluster.Spec.LoadBalancerWaitForBackends && conditions.IsTrue(cluster, clusterv1.ControlPlaneInitializedCondition) && !hibernated {
+		ready, err := externalLoadBalancer.ReadyForTraffic(ctx)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to check the load balancer backends")
+		}
+		if !ready {
+			conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerEndpointAvailableCondition, infrav1.WaitingForLoadBalancerBackendsReason, clusterv1.ConditionSeverityInfo, "")
+			logger.Info("Waiting for the load balancer to route to the control plane")
+			return ctrl.Result{RequeueAfter: loadBalancerNotReadyRequeueAfter}, nil
+		}
+	}
+
+	if previous := dockerCluster.Spec.ControlPlaneEndpoint.Host; previous != "" && previous != host {
+		logger.Info("Load balancer address changed", "previous", previous, "current", host)
+	}
 	dockerCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{
-		Host: lbIP,
+		Host: host,
 		Port: 6443,
 	}
+	conditions.MarkTrue(dockerCluster, infrav1.LoadBalancerEndpointAvailableCondition)
 
 	dockerCluster.Status.Ready = true
 
+	// Record the digest of the image the load balancer runs. Pinned images are verified when the container
+	// is created, so a running load balancer keeps serving the endpoint and the failure is only reported.
+	digest, err := externalLoadBalancer.ImageDigest(ctx)
+	if err != nil {
+		logger.Info("Failed to get image digest for the load balancer", "error", err.Error())
+		conditions.MarkFalse(dockerCluster, infrav1.LoadBalancerContainerReadyCondition, infrav1.LoadBalancerImageDigestFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+	} else {
+		dockerCluster.Status.LoadBalancerImageDigest = digest
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// reconcileLoadBalancerRestarts tracks the restarts of the load balancer container, reporting a load balancer
+// that keeps being restarted by the runtime, e.g. because HAProxy crashes on its configuration, as unstable.
+func (r *DockerClusterReconciler) reconcileLoadBalancerRestarts(ctx context.Context, dockerCluster *infrav1.DockerCluster, externalLoadBalancer *docker.LoadBalancer) error {
+	status, err := externalLoadBalancer.Status(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get load balancer status")
+	}
+	if !status.Exists {
+		return nil
+	}
+
+	restarts, inWindow := docker.ObserveRestarts(dockerCluster.Status.LoadBalancerRestarts, status.RestartCount, time.Now())
+	dockerCluster.Status.LoadBalancerRestarts = restarts
+
+	threshold := int(dockerCluster.Spec.LoadBalancerRestartThreshold)
+	if threshold == 0 {
+		threshold = docker.DefaultLoadBalancerRestartThreshold
+	}
+	if inWindow <= threshold {
+		conditions.Delete(dockerCluster, infrav1.LoadBalancerUnstableCondition)
+		return nil
+	}
+	conditions.Set(dockerCluster, &clusterv1.Condition{
+		Type:     infrav1.LoadBalancerUnstableCondition,
+		Status:   corev1.ConditionTrue,
+		Severity: clusterv1.ConditionSeverityWarning,
+		Reason:   infrav1.LoadBalancerRestartingReason,
+		Message:  fmt.Sprintf("load balancer container restarted %d times since %s", inWindow, restarts.WindowStart.UTC().Format(time.RFC3339)),
+	})
+	return nil
+}
+
 func (r *DockerClusterReconciler) reconcileDelete(ctx context.Context, dockerCluster *infrav1.DockerCluster, externalLoadBalancer *docker.LoadBalancer) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 	logger.Info("Reconciling DockerCluster deletion")
@@ -180,6 +593,10 @@ func (r *DockerClusterReconciler) reconcileDelete(ctx context.Context, dockerClu
 		return ctrl.Result{}, errors.Wrap(err, "failed to delete load balancer")
 	}
 
+	if err := deleteLoadBalancerConfigMap(ctx, r.Client, dockerCluster); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// Cluster is deleted so remove the finalizer.
 	controllerutil.RemoveFinalizer(dockerCluster, infrav1.ClusterFinalizer)
 
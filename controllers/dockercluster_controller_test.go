@@ -0,0 +1,130 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker"
+)
+
+// newTestDockerCluster returns a DockerCluster owned by the test-cluster Cluster, past adding its finalizer.
+func newTestDockerCluster() *infrav1.DockerCluster {
+	return &infrav1.DockerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-cluster",
+			Namespace:  metav1.NamespaceDefault,
+			Finalizers: []string{infrav1.ClusterFinalizer},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Cluster",
+				Name:       "test-cluster",
+			}},
+		},
+	}
+}
+
+// newTestClient returns a fake client serving the given DockerCluster and the Cluster owning it.
+func newTestClient(g *WithT, dockerCluster *infrav1.DockerCluster) client.Client {
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	cluster := &clusterv1.Cluster{
+		TypeMeta:   metav1.TypeMeta{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: metav1.NamespaceDefault},
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, dockerCluster).Build()
+}
+
+// newTestLoadBalancerRuntime returns a fake runtime with a running load balancer container on the default network.
+func newTestLoadBalancerRuntime() *container.FakeRuntime {
+	containerRuntime := &container.FakeRuntime{}
+	containerRuntime.SetListContainersResult([]container.Container{{
+		Name:   "test-cluster-lb",
+		Image:  "haproxytech/haproxy-alpine:2.4",
+		Status: "Up 3 seconds",
+		Labels: map[string]string{docker.NodeRoleLabelKey: constants.ExternalLoadBalancerNodeRoleValue},
+	}})
+	containerRuntime.SetContainerNetworks(map[string][]string{"test-cluster-lb": {docker.DefaultNetwork}})
+	return containerRuntime
+}
+
+func TestDockerClusterReconcileImageArchive(t *testing.T) {
+	g := NewWithT(t)
+	dockerCluster := newTestDockerCluster()
+	dockerCluster.Spec.LoadBalancerImage = "haproxytech/haproxy-alpine:2.4"
+	dockerCluster.Spec.LoadBalancerImageArchive = filepath.Join(t.TempDir(), "haproxy.tar")
+	c := newTestClient(g, dockerCluster)
+
+	// The image loaded from the archive has no registry digest.
+	containerRuntime := newTestLoadBalancerRuntime()
+	defer containerRuntime.SetListContainersResult(nil)
+	defer containerRuntime.SetContainerNetworks(nil)
+
+	r := &DockerClusterReconciler{Client: c, ContainerRuntime: containerRuntime}
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(dockerCluster)})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(dockerCluster), dockerCluster)).To(Succeed())
+	g.Expect(dockerCluster.Status.Ready).To(BeTrue())
+	g.Expect(dockerCluster.Status.LoadBalancerImageDigest).To(Equal("test-cluster-lbImageID"))
+	g.Expect(conditions.IsTrue(dockerCluster, infrav1.LoadBalancerContainerReadyCondition)).To(BeTrue())
+	g.Expect(dockerCluster.Spec.ControlPlaneEndpoint.Host).ToNot(BeEmpty())
+}
+
+func TestDockerClusterReconcileRecreateAnnotation(t *testing.T) {
+	g := NewWithT(t)
+	dockerCluster := newTestDockerCluster()
+	dockerCluster.Annotations = map[string]string{infrav1.RecreateLoadBalancerAnnotation: ""}
+	c := newTestClient(g, dockerCluster)
+
+	containerRuntime := newTestLoadBalancerRuntime()
+	defer containerRuntime.SetListContainersResult(nil)
+	defer containerRuntime.SetContainerNetworks(nil)
+	containerRuntime.ResetDeleteContainerCallLogs()
+	// Configuring the new container fails.
+	containerRuntime.SetExecContainerErrors(map[string]error{"cp": errors.New("exec failed")})
+	defer containerRuntime.SetExecContainerErrors(nil)
+
+	r := &DockerClusterReconciler{Client: c, ContainerRuntime: containerRuntime}
+	_, _ = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(dockerCluster)})
+	g.Expect(containerRuntime.DeleteContainerCalls()).To(HaveLen(1))
+
+	// The next reconcile does not recreate the new container again.
+	g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(dockerCluster), dockerCluster)).To(Succeed())
+	g.Expect(dockerCluster.Annotations).ToNot(HaveKey(infrav1.RecreateLoadBalancerAnnotation))
+	_, _ = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(dockerCluster)})
+	g.Expect(containerRuntime.DeleteContainerCalls()).To(HaveLen(1))
+}
@@ -150,11 +150,32 @@ func (r *DockerMachineReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	if err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "failed to create helper for managing the externalMachine")
 	}
+	// The machines join the cluster network, so the load balancer can reach the control plane nodes.
+	externalMachine.SetNetwork(dockerCluster.Spec.Network)
 
 	externalLoadBalancer, err := docker.NewLoadBalancer(ctx, cluster, dockerCluster)
 	if err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "failed to create helper for managing the externalLoadBalancer")
 	}
+	if err := setLoadBalancerBackendCA(ctx, r.Client, cluster, dockerCluster, externalLoadBalancer); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := setLoadBalancerMetricsAuth(ctx, r.Client, dockerCluster, externalLoadBalancer); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := setLoadBalancerUnhealthyMachines(ctx, r.Client, cluster, externalLoadBalancer); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Control plane machines change the load balancer configuration, so refresh its mirror once done.
+	// The DockerCluster controller owns the mirror, hence failures do not fail the machine reconcile.
+	if util.IsControlPlaneMachine(machine) && dockerCluster.Spec.LoadBalancerExposeConfigMap {
+		defer func() {
+			if err := syncLoadBalancerConfigMap(ctx, r.Client, dockerCluster, externalLoadBalancer); err != nil {
+				logger.Info("Failed to refresh the load balancer configuration ConfigMap", "err", err.Error())
+			}
+		}()
+	}
 
 	// Handle deleted machines
 	if !dockerMachine.ObjectMeta.DeletionTimestamp.IsZero() {
@@ -199,6 +220,25 @@ func (r *DockerMachineReconciler) reconcileNormal(ctx context.Context, cluster *
 		return ctrl.Result{}, nil
 	}
 
+	// Stop routing to a control plane machine as soon as a MachineHealthCheck finds it unhealthy, rather than
+	// when remediation deletes it, and route to it again once it recovers. The configuration is only updated
+	// when the machines in maintenance differ from the unhealthy ones.
+	if util.IsControlPlaneMachine(machine) && dockerMachine.Status.LoadBalancerConfigured {
+		changed, err := externalLoadBalancer.MaintenanceChanged(ctx)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to check the DockerCluster.loadbalancer backends in maintenance")
+		}
+		if changed {
+			result, err := externalLoadBalancer.UpdateConfiguration(ctx)
+			if err != nil {
+				return ctrl.Result{}, errors.Wrap(err, "failed to update DockerCluster.loadbalancer configuration")
+			}
+			if result.Reloaded {
+				logger.Info("Updated the load balancer backends in maintenance for the unhealthy machines", "backends", result.BackendCount)
+			}
+		}
+	}
+
 	// if the machine is already provisioned, return
 	if dockerMachine.Spec.ProviderID != nil {
 		// ensure ready state is set.
@@ -237,9 +277,12 @@ func (r *DockerMachineReconciler) reconcileNormal(ctx context.Context, cluster *
 	// we should only do this once, as reconfiguration more or less ensures
 	// node ref setting fails
 	if util.IsControlPlaneMachine(machine) && !dockerMachine.Status.LoadBalancerConfigured {
-		if err := externalLoadBalancer.UpdateConfiguration(ctx); err != nil {
+		externalLoadBalancer.SetForceRefresh(true)
+		result, err := externalLoadBalancer.UpdateConfiguration(ctx)
+		if err != nil {
 			return ctrl.Result{}, errors.Wrap(err, "failed to update DockerCluster.loadbalancer configuration")
 		}
+		logger.Info("Updated load balancer configuration", "backends", result.BackendCount, "reloaded", result.Reloaded, "changed", result.ConfigChanged)
 		dockerMachine.Status.LoadBalancerConfigured = true
 	}
 
@@ -341,9 +384,12 @@ func (r *DockerMachineReconciler) reconcileDelete(ctx context.Context, cluster *
 
 	// if the deleted machine is a control-plane node, remove it from the load balancer configuration;
 	if util.IsControlPlaneMachine(machine) {
-		if err := externalLoadBalancer.UpdateConfiguration(ctx); err != nil {
+		externalLoadBalancer.SetForceRefresh(true)
+		result, err := externalLoadBalancer.UpdateConfiguration(ctx)
+		if err != nil {
 			return ctrl.Result{}, errors.Wrap(err, "failed to update DockerCluster.loadbalancer configuration")
 		}
+		logger.Info("Updated load balancer configuration", "backends", result.BackendCount, "reloaded", result.Reloaded, "changed", result.ConfigChanged)
 	}
 
 	// Machine is deleted so remove the finalizer.
@@ -0,0 +1,54 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package feature implements feature functionality.
+package feature
+
+import (
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/component-base/featuregate"
+)
+
+const (
+	// Every feature gate should add method here following this template:
+	//
+	// // alpha: v0.X
+	// MyFeature featuregate.Feature = "MyFeature".
+
+	// LoadBalancerBackendPools is a feature gate for routing part of the control plane traffic
+	// to additional named backend pools.
+	//
+	// alpha: v0.1
+	LoadBalancerBackendPools featuregate.Feature = "LoadBalancerBackendPools"
+
+	// LoadBalancerSNIRouting is a feature gate for routing the control plane traffic by TLS server name
+	// and rejecting the connections for other server names.
+	//
+	// alpha: v0.1
+	LoadBalancerSNIRouting featuregate.Feature = "LoadBalancerSNIRouting"
+)
+
+func init() {
+	runtime.Must(MutableGates.Add(defaultFeatureGates))
+}
+
+// defaultFeatureGates consists of all known provider-specific feature keys.
+// To add a new feature, define a key for it above and add it here.
+var defaultFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
+	// Every feature should be initiated here:
+	LoadBalancerBackendPools: {Default: false, PreRelease: featuregate.Alpha},
+	LoadBalancerSNIRouting:   {Default: false, PreRelease: featuregate.Alpha},
+}
@@ -19,6 +19,7 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -36,7 +37,10 @@ import (
 
 	infrastructurev1alpha1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
 	"github.com/beanlearninggo/cluster-api-provider-docker/controllers"
+	"github.com/beanlearninggo/cluster-api-provider-docker/feature"
 	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -57,11 +61,27 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var loadBalancerBaseConfig string
+	daemonRetry := container.DefaultDaemonRetryOptions()
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":9440", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.Func("feature-gates", "A set of key=value pairs that describe feature gates for alpha/experimental features. "+
+		"Options are:\n"+strings.Join(feature.Gates.KnownFeatures(), "\n"), feature.MutableGates.Set)
+	flag.StringVar(&loadBalancerBaseConfig, "loadbalancer-base-config", "",
+		"Path to a file with the load balancer configuration directives layered over the built-in defaults of every cluster, "+
+			"in the HAProxy configuration syntax. The DockerCluster spec takes precedence over them.")
+	flag.IntVar(&daemonRetry.Attempts, "docker-retry-attempts", daemonRetry.Attempts,
+		"How many times a Docker call is attempted while the Docker daemon is unavailable, e.g. restarting. 1 disables the retries.")
+	flag.DurationVar(&daemonRetry.Backoff, "docker-retry-backoff", daemonRetry.Backoff,
+		"The wait before retrying a Docker call the Docker daemon was unavailable for, doubled after each retry.")
+	flag.DurationVar(&daemonRetry.MaxBackoff, "docker-retry-max-backoff", daemonRetry.MaxBackoff,
+		"The longest wait between the retries of a Docker call.")
+	flag.BoolVar(&infrastructurev1alpha1.CheckEndpointReachability, "webhook-check-endpoint-reachability", false,
+		"Dial the control plane endpoint of a DockerCluster on admission and log a warning if it is unreachable. "+
+			"Unreachable endpoints are not rejected.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -70,6 +90,20 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if loadBalancerBaseConfig != "" {
+		data, err := os.ReadFile(loadBalancerBaseConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to read the load balancer base config")
+			os.Exit(1)
+		}
+		base, err := loadbalancer.ParseConfigOverrides(string(data))
+		if err != nil {
+			setupLog.Error(err, "invalid load balancer base config")
+			os.Exit(1)
+		}
+		docker.SetBaseConfig(base)
+	}
+
 	ctx := ctrl.SetupSignalHandler()
 	// Set our runtime client into the context for later use
 	runtimeClient, err := container.NewDockerClient()
@@ -108,6 +142,7 @@ func main() {
 		setupLog.Error(err, "unable to establish container runtime connection", "controller", "reconciler")
 		os.Exit(1)
 	}
+	runtimeClient = container.WithDaemonRetry(runtimeClient, daemonRetry)
 
 	log := ctrl.Log.WithName("remote").WithName("ClusterCacheTracker")
 	tracker, err := remote.NewClusterCacheTracker(
@@ -0,0 +1,86 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package container
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// imageArchiveManifest is the manifest.json entry of an image archive created by docker save.
+type imageArchiveManifest struct {
+	RepoTags []string `json:"RepoTags"`
+}
+
+// ImageArchiveRepoTags returns the repository tags (e.g. "haproxytech/haproxy-alpine:2.4") of the
+// images in an archive created by docker save, optionally gzip compressed.
+func ImageArchiveRepoTags(path string) ([]string, error) {
+	f, err := os.Open(path) //nolint:gosec // No security issue: the path is provided by the cluster administrator.
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open image archive %q", path)
+	}
+	defer f.Close()
+
+	buffered := bufio.NewReader(f)
+	var reader io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decompress image archive %q", path)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.Errorf("image archive %q has no manifest.json", path)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read image archive %q", path)
+		}
+		if strings.TrimPrefix(header.Name, "./") != "manifest.json" {
+			continue
+		}
+
+		var manifests []imageArchiveManifest
+		if err := json.NewDecoder(tr).Decode(&manifests); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode the manifest of image archive %q", path)
+		}
+		tags := []string{}
+		for _, m := range manifests {
+			tags = append(tags, m.RepoTags...)
+		}
+		return tags, nil
+	}
+}
+
+// NormalizeImageReference strips the default registry and library prefixes docker omits when
+// reporting image references, e.g. "docker.io/library/busybox:1.35" becomes "busybox:1.35".
+func NormalizeImageReference(image string) string {
+	image = strings.TrimPrefix(image, "docker.io/")
+	return strings.TrimPrefix(image, "library/")
+}
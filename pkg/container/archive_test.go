@@ -0,0 +1,87 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package container
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func writeImageArchive(g *WithT, path string, compress bool, files map[string]string) {
+	f, err := os.Create(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+
+	var w io.Writer = f
+	if compress {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+	for name, content := range files {
+		g.Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))})).To(Succeed())
+		_, err := tw.Write([]byte(content))
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+}
+
+func TestImageArchiveRepoTags(t *testing.T) {
+	manifest := `[{"Config":"abc.json","RepoTags":["haproxytech/haproxy-alpine:2.4"],"Layers":["abc/layer.tar"]}]`
+
+	for _, compress := range []bool{false, true} {
+		g := NewWithT(t)
+		path := filepath.Join(t.TempDir(), "image.tar")
+		writeImageArchive(g, path, compress, map[string]string{
+			"abc.json":      "{}",
+			"manifest.json": manifest,
+		})
+
+		tags, err := ImageArchiveRepoTags(path)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(tags).To(Equal([]string{"haproxytech/haproxy-alpine:2.4"}))
+	}
+}
+
+func TestImageArchiveRepoTagsErrors(t *testing.T) {
+	g := NewWithT(t)
+	dir := t.TempDir()
+
+	_, err := ImageArchiveRepoTags(filepath.Join(dir, "missing.tar"))
+	g.Expect(err).To(HaveOccurred())
+
+	path := filepath.Join(dir, "no-manifest.tar")
+	writeImageArchive(g, path, false, map[string]string{"abc.json": "{}"})
+	_, err = ImageArchiveRepoTags(path)
+	g.Expect(err).To(MatchError(ContainSubstring("has no manifest.json")))
+}
+
+func TestNormalizeImageReference(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(NormalizeImageReference("docker.io/library/busybox:1.35")).To(Equal("busybox:1.35"))
+	g.Expect(NormalizeImageReference("docker.io/haproxytech/haproxy-alpine:2.4")).To(Equal("haproxytech/haproxy-alpine:2.4"))
+	g.Expect(NormalizeImageReference("quay.io/haproxy/haproxy:2.4")).To(Equal("quay.io/haproxy/haproxy:2.4"))
+}
@@ -21,9 +21,11 @@ package container
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -92,6 +94,39 @@ func (d *dockerRuntime) SaveContainerImage(ctx context.Context, image, dest stri
 	return nil
 }
 
+// LoadContainerImage loads the Docker images in the archive specified by src, e.g. created by docker save.
+func (d *dockerRuntime) LoadContainerImage(ctx context.Context, src string) error {
+	archive, err := os.Open(src) //nolint:gosec // No security issue: src is provided by the cluster administrator.
+	if err != nil {
+		return fmt.Errorf("failed to open image archive %q: %v", src, err)
+	}
+	defer archive.Close()
+
+	resp, err := d.dockerClient.ImageLoad(ctx, archive, true)
+	if err != nil {
+		return fmt.Errorf("failure loading image archive %q: %v", src, err)
+	}
+	defer resp.Body.Close()
+
+	// The response is a stream of JSON messages, which report failures when loading a layer.
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("error while reading image load response: %v", err)
+		}
+		if message.Error != "" {
+			return fmt.Errorf("failure loading image archive %q: %s", src, message.Error)
+		}
+	}
+
+	return nil
+}
+
 // PullContainerImageIfNotExists triggers the Docker engine to pull an image, but only if it doesn't
 // already exist. This is important when we're using locally build images in CI which
 // do not exist remotely.
@@ -298,11 +333,89 @@ func (d *dockerRuntime) DeleteContainer(ctx context.Context, containerName strin
 	})
 }
 
+// StopContainer will stop a running container, killing it if it does not stop within the timeout.
+func (d *dockerRuntime) StopContainer(ctx context.Context, containerName string, timeout time.Duration) error {
+	return d.dockerClient.ContainerStop(ctx, containerName, &timeout)
+}
+
+// RenameContainer will rename a container.
+func (d *dockerRuntime) RenameContainer(ctx context.Context, containerName, newName string) error {
+	return d.dockerClient.ContainerRename(ctx, containerName, newName)
+}
+
 // KillContainer will kill a running container with the specified signal.
 func (d *dockerRuntime) KillContainer(ctx context.Context, containerName, signal string) error {
 	return d.dockerClient.ContainerKill(ctx, containerName, signal)
 }
 
+// InspectContainer returns details about a container.
+func (d *dockerRuntime) InspectContainer(ctx context.Context, containerName string) (*ContainerInfo, error) {
+	containerInfo, err := d.dockerClient.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to inspect container %q", containerName)
+	}
+
+	info := &ContainerInfo{
+		Name:         strings.TrimPrefix(containerInfo.Name, "/"),
+		ImageID:      containerInfo.Image,
+		RestartCount: containerInfo.RestartCount,
+	}
+	if containerInfo.State != nil {
+		info.State = ContainerState{
+			Status:    containerInfo.State.Status,
+			ExitCode:  containerInfo.State.ExitCode,
+			OOMKilled: containerInfo.State.OOMKilled,
+			Error:     containerInfo.State.Error,
+		}
+	}
+	if containerInfo.Node != nil {
+		info.Host = containerInfo.Node.Name
+	}
+	if containerInfo.Config != nil {
+		info.Image = containerInfo.Config.Image
+		info.Labels = containerInfo.Config.Labels
+	}
+	if containerInfo.NetworkSettings != nil {
+		for name, settings := range containerInfo.NetworkSettings.Networks {
+			info.Networks = append(info.Networks, name)
+			if settings != nil && settings.IPAddress != "" {
+				if info.IPAddresses == nil {
+					info.IPAddresses = map[string]string{}
+				}
+				info.IPAddresses[name] = settings.IPAddress
+			}
+		}
+		sort.Strings(info.Networks)
+		for port, bindings := range containerInfo.NetworkSettings.Ports {
+			if len(bindings) == 0 {
+				continue
+			}
+			if info.HostPorts == nil {
+				info.HostPorts = map[string]string{}
+			}
+			info.HostPorts[string(port)] = bindings[0].HostPort
+		}
+	}
+	for _, m := range containerInfo.Mounts {
+		info.Mounts = append(info.Mounts, Mount{
+			Source:   m.Source,
+			Target:   m.Destination,
+			ReadOnly: !m.RW,
+		})
+	}
+	return info, nil
+}
+
+// ImageRepoDigests returns the repository digests (e.g. "haproxytech/haproxy-alpine@sha256:...") of an image.
+// Images that were built or loaded locally and never pushed to or pulled from a registry have none.
+func (d *dockerRuntime) ImageRepoDigests(ctx context.Context, image string) ([]string, error) {
+	imageInfo, _, err := d.dockerClient.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to inspect image %q", image)
+	}
+	return imageInfo.RepoDigests, nil
+}
+
 // GetContainerIPs inspects a container to get its IPv4 and IPv6 IP addresses.
 // Will not error if there is no IP address assigned. Calling code will need to
 // determine whether that is an issue or not.
@@ -312,6 +425,12 @@ func (d *dockerRuntime) GetContainerIPs(ctx context.Context, containerName strin
 		return "", "", errors.Wrap(err, "failed to get container details")
 	}
 
+	// Prefer the network the container was created on over the networks it was connected to later.
+	if containerInfo.HostConfig != nil {
+		if net, ok := containerInfo.NetworkSettings.Networks[string(containerInfo.HostConfig.NetworkMode)]; ok && net != nil {
+			return net.IPAddress, net.GlobalIPv6Address, nil
+		}
+	}
 	for _, net := range containerInfo.NetworkSettings.Networks {
 		return net.IPAddress, net.GlobalIPv6Address, nil
 	}
@@ -354,14 +473,28 @@ func dockerContainerToContainer(container *types.Container) Container {
 		Name:   strings.Trim(container.Names[0], "/"),
 		Image:  container.Image,
 		Status: container.Status,
+		Labels: container.Labels,
+	}
+}
+
+// restartPolicy returns the restart policy of the container, unless-stopped by default so the nodes come
+// back when the host or the daemon restarts.
+func restartPolicy(runConfig *RunContainerInput) string {
+	if runConfig.RestartPolicy != "" {
+		return runConfig.RestartPolicy
 	}
+	return "unless-stopped"
 }
 
 // RunContainer will run a docker container with the given settings and arguments, returning any errors.
 func (d *dockerRuntime) RunContainer(ctx context.Context, runConfig *RunContainerInput, output io.Writer) error {
+	hostname := runConfig.Hostname
+	if hostname == "" {
+		hostname = runConfig.Name // make hostname match container name
+	}
 	containerConfig := dockercontainer.Config{
-		Tty:          true,           // allocate a tty for entrypoint logs
-		Hostname:     runConfig.Name, // make hostname match container name
+		Tty:          true, // allocate a tty for entrypoint logs
+		Hostname:     hostname,
 		Labels:       runConfig.Labels,
 		Image:        runConfig.Image,
 		Cmd:          runConfig.CommandArgs,
@@ -373,17 +506,26 @@ func (d *dockerRuntime) RunContainer(ctx context.Context, runConfig *RunContaine
 	}
 
 	hostConfig := dockercontainer.HostConfig{
-		// Running containers in a container requires privileges.
-		// NOTE: we could try to replicate this with --cap-add, and use less
-		// privileges, but this flag also changes some mounts that are necessary
-		// including some ones docker would otherwise do by default.
-		// for now this is what we want. in the future we may revisit this.
-		Privileged:    true,
-		SecurityOpt:   []string{"seccomp=unconfined"}, // ignore seccomp
-		NetworkMode:   dockercontainer.NetworkMode(runConfig.Network),
-		Tmpfs:         runConfig.Tmpfs,
-		PortBindings:  nat.PortMap{},
-		RestartPolicy: dockercontainer.RestartPolicy{Name: "unless-stopped"},
+		Privileged:     runConfig.Privileged,
+		ReadonlyRootfs: runConfig.ReadOnlyRootFS,
+		SecurityOpt:    []string{"seccomp=unconfined"}, // ignore seccomp
+		NetworkMode:    dockercontainer.NetworkMode(runConfig.Network),
+		Tmpfs:          runConfig.Tmpfs,
+		PortBindings:   nat.PortMap{},
+		RestartPolicy:  dockercontainer.RestartPolicy{Name: restartPolicy(runConfig)},
+		// Capabilities are ignored by the runtime for privileged containers.
+		CapAdd:     runConfig.CapAdd,
+		CapDrop:    runConfig.CapDrop,
+		ExtraHosts: runConfig.ExtraHosts,
+	}
+	if runConfig.LogDriver != "" {
+		if err := d.checkLogDriver(ctx, runConfig.LogDriver); err != nil {
+			return errors.Wrapf(err, "failed to create container %q", runConfig.Name)
+		}
+		hostConfig.LogConfig = dockercontainer.LogConfig{
+			Type:   runConfig.LogDriver,
+			Config: runConfig.LogOptions,
+		}
 	}
 	networkConfig := network.NetworkingConfig{}
 
@@ -393,6 +535,14 @@ func (d *dockerRuntime) RunContainer(ctx context.Context, runConfig *RunContaine
 			"net.ipv6.conf.all.forwarding":   "1",
 		}
 	}
+	if len(runConfig.Sysctls) > 0 {
+		if hostConfig.Sysctls == nil {
+			hostConfig.Sysctls = map[string]string{}
+		}
+		for key, value := range runConfig.Sysctls {
+			hostConfig.Sysctls[key] = value
+		}
+	}
 
 	// mount /dev/mapper if docker storage driver if Btrfs or ZFS
 	// https://github.com/kubernetes-sigs/kind/pull/1464
@@ -426,6 +576,15 @@ func (d *dockerRuntime) RunContainer(ctx context.Context, runConfig *RunContaine
 		hostConfig.UsernsMode = "host"
 	}
 
+	for _, networkName := range runConfig.AdditionalNetworks {
+		if _, err := d.dockerClient.NetworkInspect(ctx, networkName, types.NetworkInspectOptions{}); err != nil {
+			if client.IsErrNotFound(err) {
+				return errors.Errorf("failed to create container %q: network %q does not exist", runConfig.Name, networkName)
+			}
+			return errors.Wrapf(err, "failed to inspect network %q", networkName)
+		}
+	}
+
 	// Make sure we have the image
 	if err := d.PullContainerImageIfNotExists(ctx, runConfig.Image); err != nil {
 		return errors.Wrapf(err, "error pulling container image %s", runConfig.Image)
@@ -441,9 +600,22 @@ func (d *dockerRuntime) RunContainer(ctx context.Context, runConfig *RunContaine
 		runConfig.Name,
 	)
 	if err != nil {
+		if isImageNotFound(err) {
+			return errors.Wrapf(ImageNotFoundError{Image: runConfig.Image}, "error creating container %q", runConfig.Name)
+		}
 		return errors.Wrapf(err, "error creating container %q", runConfig.Name)
 	}
 
+	for _, networkName := range runConfig.AdditionalNetworks {
+		if err := d.dockerClient.NetworkConnect(ctx, networkName, resp.ID, nil); err != nil {
+			// Remove the container, so creating it again does not conflict with its name.
+			if removeErr := d.dockerClient.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true}); removeErr != nil {
+				return errors.Wrapf(removeErr, "failed to remove container %q after connecting it to network %q failed", runConfig.Name, networkName)
+			}
+			return errors.Wrapf(err, "failed to connect container %q to network %q", runConfig.Name, networkName)
+		}
+	}
+
 	var containerOutput types.HijackedResponse
 	if output != nil {
 		// Read out any output from the container
@@ -463,6 +635,14 @@ func (d *dockerRuntime) RunContainer(ctx context.Context, runConfig *RunContaine
 
 	// Actually start the container
 	if err := d.dockerClient.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		if isImageNotFound(err) {
+			// Remove the container that can't start, so creating it again once the image
+			// is back does not conflict with its name.
+			if removeErr := d.dockerClient.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true}); removeErr != nil {
+				return errors.Wrapf(removeErr, "failed to remove container %q after its image was not found", runConfig.Name)
+			}
+			return errors.Wrapf(ImageNotFoundError{Image: runConfig.Image}, "error starting container %q", runConfig.Name)
+		}
 		return errors.Wrapf(err, "error starting container %q", runConfig.Name)
 	}
 
@@ -516,6 +696,21 @@ func (d *dockerRuntime) needsDevMapper(ctx context.Context) (bool, error) {
 	return info.Driver == "btrfs" || info.Driver == "zfs", nil
 }
 
+// checkLogDriver checks the docker engine supports the given log driver, built in or provided by a plugin.
+func (d *dockerRuntime) checkLogDriver(ctx context.Context, driver string) error {
+	info, err := d.dockerClient.Info(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to get Docker engine info")
+	}
+
+	for _, supported := range info.Plugins.Log {
+		if supported == driver {
+			return nil
+		}
+	}
+	return errors.Errorf("log driver %q is not supported by the Docker engine, supported drivers are %v", driver, info.Plugins.Log)
+}
+
 // ownerAndGroup gets the user configuration for the container (user:group).
 func ownerAndGroup(crc *RunContainerInput) string {
 	if crc.User != "" {
@@ -535,7 +730,7 @@ func environmentVariables(crc *RunContainerInput) []string {
 	for key, val := range crc.EnvironmentVars {
 		envVars = append(envVars, fmt.Sprintf("%s=%s", key, val))
 	}
-	return envVars
+	return append(envVars, crc.PlacementConstraints...)
 }
 
 func configureVolumes(crc *RunContainerInput, config *dockercontainer.Config, hostConfig *dockercontainer.HostConfig) {
@@ -645,6 +840,12 @@ func (d *dockerRuntime) usernsRemap(ctx context.Context) bool {
 	return false
 }
 
+// isImageNotFound returns true if the daemon failed because the image is missing. The daemon also
+// reports other missing objects as not found, e.g. the network of the container.
+func isImageNotFound(err error) bool {
+	return client.IsErrNotFound(err) && strings.Contains(strings.ToLower(err.Error()), "no such image")
+}
+
 func isSELinuxEnforcing() bool {
 	dat, err := os.ReadFile("/sys/fs/selinux/enforce")
 	if err != nil {
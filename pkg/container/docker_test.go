@@ -0,0 +1,126 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package container
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/client"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+const testAPIVersion = "1.41"
+
+// fakeDaemon serves the Docker API calls made by RunContainer, failing the container create or start
+// with a not found error.
+type fakeDaemon struct {
+	createErr string
+	startErr  string
+	removed   []string
+}
+
+func (f *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	notFound := func(message string) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message":%q}`, message)
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v"+testAPIVersion)
+	switch {
+	case r.Method == http.MethodGet && path == "/images/json":
+		fmt.Fprint(w, `[{"Id":"sha256:haproxy"}]`)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/networks/"):
+		fmt.Fprint(w, `{"Name":"kind","IPAM":{"Config":[]}}`)
+	case r.Method == http.MethodGet && path == "/info":
+		fmt.Fprint(w, `{}`)
+	case r.Method == http.MethodPost && path == "/containers/create":
+		if f.createErr != "" {
+			notFound(f.createErr)
+			return
+		}
+		fmt.Fprint(w, `{"Id":"test-id"}`)
+	case r.Method == http.MethodPost && path == "/containers/test-id/start":
+		if f.startErr != "" {
+			notFound(f.startErr)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete && path == "/containers/test-id":
+		f.removed = append(f.removed, "test-id")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestDockerRuntimeRunContainerNotFound(t *testing.T) {
+	tests := []struct {
+		name              string
+		createErr         string
+		startErr          string
+		wantImageNotFound bool
+		wantRemoved       []string
+	}{
+		{
+			name:              "missing image on create",
+			createErr:         "No such image: haproxytech/haproxy-alpine:2.4",
+			wantImageNotFound: true,
+		},
+		{
+			name:      "missing network on create",
+			createErr: "network test-network not found",
+		},
+		{
+			name:              "missing image on start",
+			startErr:          "No such image: haproxytech/haproxy-alpine:2.4",
+			wantImageNotFound: true,
+			wantRemoved:       []string{"test-id"},
+		},
+		{
+			name:     "missing network on start",
+			startErr: "network test-network not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			daemon := &fakeDaemon{createErr: tt.createErr, startErr: tt.startErr}
+			server := httptest.NewServer(daemon)
+			defer server.Close()
+			dockerClient, err := client.NewClientWithOpts(client.WithHost(server.URL), client.WithHTTPClient(server.Client()), client.WithVersion(testAPIVersion))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			d := &dockerRuntime{dockerClient: dockerClient}
+			err = d.RunContainer(context.Background(), &RunContainerInput{
+				Name:    "test-cluster-lb",
+				Image:   "haproxytech/haproxy-alpine:2.4",
+				Network: "test-network",
+			}, nil)
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(errors.As(err, &ImageNotFoundError{})).To(Equal(tt.wantImageNotFound))
+			if !tt.wantImageNotFound {
+				g.Expect(err).To(MatchError(ContainSubstring("network test-network not found")))
+			}
+			g.Expect(daemon.removed).To(Equal(tt.wantRemoved))
+		})
+	}
+}
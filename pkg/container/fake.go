@@ -19,12 +19,29 @@ package container
 import (
 	"context"
 	"io"
+	"strings"
+	"time"
 )
 
 var runContainerCallLog []RunContainerArgs
 var deleteContainerCallLog []string
 var killContainerCallLog []KillContainerArgs
+var stopContainerCallLog []StopContainerArgs
+var renameContainerCallLog []RenameContainerArgs
 var execContainerCallLog []ExecContainerArgs
+var listContainersResult []Container
+var listContainersCallLog []FilterBuilder
+var imageRepoDigests map[string][]string
+var execContainerOutputs map[string]string
+var execContainerErrors map[string]error
+var containerNetworks map[string][]string
+var containerRestartCounts map[string]int
+var containerHosts map[string]string
+var containerStates map[string]ContainerState
+var containersWithoutIPs map[string]bool
+var hostPorts map[string]string
+var loadContainerImageCallLog []string
+var pullContainerImageCallLog []string
 
 // RunContainerArgs contains the arguments passed to calls to RunContainer.
 type RunContainerArgs struct {
@@ -38,6 +55,18 @@ type KillContainerArgs struct {
 	Signal    string
 }
 
+// StopContainerArgs contains the arguments passed to calls to StopContainer.
+type StopContainerArgs struct {
+	Container string
+	Timeout   time.Duration
+}
+
+// RenameContainerArgs contains the arguments passed to calls to RenameContainer.
+type RenameContainerArgs struct {
+	Container string
+	NewName   string
+}
+
 // ExecContainerArgs contains the arguments passed to calls to ExecContainer.
 type ExecContainerArgs struct {
 	ContainerName string
@@ -59,6 +88,22 @@ func (f *FakeRuntime) SaveContainerImage(ctx context.Context, image, dest string
 	return nil
 }
 
+// LoadContainerImage loads the Docker images in the archive specified by src.
+func (f *FakeRuntime) LoadContainerImage(ctx context.Context, src string) error {
+	loadContainerImageCallLog = append(loadContainerImageCallLog, src)
+	return nil
+}
+
+// LoadContainerImageCalls returns the archives that have been passed to the LoadContainerImage method.
+func (f *FakeRuntime) LoadContainerImageCalls() []string {
+	return loadContainerImageCallLog
+}
+
+// ResetLoadContainerImageCallLogs clears all existing records of any calls to the LoadContainerImage method.
+func (f *FakeRuntime) ResetLoadContainerImageCallLogs() {
+	loadContainerImageCallLog = []string{}
+}
+
 // PullContainerImageIfNotExists triggers the Docker engine to pull an image, but only if it doesn't
 // already exist. This is important when we're using locally built images in CI which
 // do not exist remotely.
@@ -68,9 +113,20 @@ func (f *FakeRuntime) PullContainerImageIfNotExists(ctx context.Context, image s
 
 // PullContainerImage triggers the Docker engine to pull an image.
 func (f *FakeRuntime) PullContainerImage(ctx context.Context, image string) error {
+	pullContainerImageCallLog = append(pullContainerImageCallLog, image)
 	return nil
 }
 
+// PullContainerImageCalls returns the images that have been passed to the PullContainerImage method.
+func (f *FakeRuntime) PullContainerImageCalls() []string {
+	return pullContainerImageCallLog
+}
+
+// ResetPullContainerImageCallLogs clears all existing records of any calls to the PullContainerImage method.
+func (f *FakeRuntime) ResetPullContainerImageCallLogs() {
+	pullContainerImageCallLog = []string{}
+}
+
 // ImageExistsLocally returns if the specified image exists in local container image cache.
 func (f *FakeRuntime) ImageExistsLocally(ctx context.Context, image string) (bool, error) {
 	return false, nil
@@ -78,7 +134,13 @@ func (f *FakeRuntime) ImageExistsLocally(ctx context.Context, image string) (boo
 
 // GetHostPort looks up the host port bound for the port and protocol (e.g. "6443/tcp").
 func (f *FakeRuntime) GetHostPort(ctx context.Context, containerName, portAndProtocol string) (string, error) {
-	return "", nil
+	return hostPorts[containerName+"/"+portAndProtocol], nil
+}
+
+// SetHostPorts sets the host ports returned by calls to the GetHostPort and InspectContainer methods, keyed by the container
+// name and the port and protocol (e.g. "cluster-cp-1/6443/tcp").
+func (f *FakeRuntime) SetHostPorts(ports map[string]string) {
+	hostPorts = ports
 }
 
 // ExecContainer executes a command in a running container and writes any output to the provided writer.
@@ -89,7 +151,21 @@ func (f *FakeRuntime) ExecContainer(ctx context.Context, containerName string, c
 		Command:       command,
 		Args:          args,
 	})
-	return nil
+	if output, ok := execContainerOutputs[command]; ok && config.OutputBuffer != nil {
+		_, _ = io.WriteString(config.OutputBuffer, output)
+	}
+	return execContainerErrors[strings.Join(append([]string{command}, args...), " ")]
+}
+
+// SetExecContainerErrors sets the errors returned by calls to the ExecContainer method, keyed by the
+// command line (the command and its args joined by spaces).
+func (f *FakeRuntime) SetExecContainerErrors(errs map[string]error) {
+	execContainerErrors = errs
+}
+
+// SetExecContainerOutputs sets the output written by calls to the ExecContainer method, keyed by command.
+func (f *FakeRuntime) SetExecContainerOutputs(outputs map[string]string) {
+	execContainerOutputs = outputs
 }
 
 // ExecContainerCalls returns the set of arguments that have been passed to the ExecContainer method. This can
@@ -104,8 +180,45 @@ func (f *FakeRuntime) ResetExecContainerCallLogs() {
 }
 
 // ListContainers returns a list of all containers.
+// The containers are returned regardless of the filters. A label the filters require with a value is set on
+// the containers that do not have it, as if they matched, so a container only has to set the labels a test is about.
 func (f *FakeRuntime) ListContainers(ctx context.Context, filters FilterBuilder) ([]Container, error) {
-	return []Container{}, nil
+	listContainersCallLog = append(listContainersCallLog, filters)
+	containers := make([]Container, 0, len(listContainersResult))
+	for _, c := range listContainersResult {
+		labels := make(map[string]string, len(c.Labels))
+		for k, v := range c.Labels {
+			labels[k] = v
+		}
+		for name, values := range filters["label"] {
+			if _, ok := labels[name]; ok {
+				continue
+			}
+			for _, v := range values {
+				if v != "" {
+					labels[name] = v
+				}
+			}
+		}
+		c.Labels = labels
+		containers = append(containers, c)
+	}
+	return containers, nil
+}
+
+// ListContainersCalls returns the filters that have been passed to the ListContainers method.
+func (f *FakeRuntime) ListContainersCalls() []FilterBuilder {
+	return listContainersCallLog
+}
+
+// ResetListContainersCallLogs clears all existing records of any calls to the ListContainers method.
+func (f *FakeRuntime) ResetListContainersCallLogs() {
+	listContainersCallLog = []FilterBuilder{}
+}
+
+// SetListContainersResult sets the containers returned by calls to the ListContainers method.
+func (f *FakeRuntime) SetListContainersResult(containers []Container) {
+	listContainersResult = containers
 }
 
 // DeleteContainer will remove a container, forcing removal if still running.
@@ -124,6 +237,44 @@ func (f *FakeRuntime) ResetDeleteContainerCallLogs() {
 	deleteContainerCallLog = []string{}
 }
 
+// StopContainer will stop a running container.
+func (f *FakeRuntime) StopContainer(ctx context.Context, containerName string, timeout time.Duration) error {
+	stopContainerCallLog = append(stopContainerCallLog, StopContainerArgs{
+		Container: containerName,
+		Timeout:   timeout,
+	})
+	return nil
+}
+
+// StopContainerCalls returns the list of arguments passed to calls to StopContainer.
+func (f *FakeRuntime) StopContainerCalls() []StopContainerArgs {
+	return stopContainerCallLog
+}
+
+// ResetStopContainerCallLogs clears all existing records of any calls to the StopContainer method.
+func (f *FakeRuntime) ResetStopContainerCallLogs() {
+	stopContainerCallLog = []StopContainerArgs{}
+}
+
+// RenameContainer will rename a container.
+func (f *FakeRuntime) RenameContainer(ctx context.Context, containerName, newName string) error {
+	renameContainerCallLog = append(renameContainerCallLog, RenameContainerArgs{
+		Container: containerName,
+		NewName:   newName,
+	})
+	return nil
+}
+
+// RenameContainerCalls returns the list of arguments passed to calls to the RenameContainer method.
+func (f *FakeRuntime) RenameContainerCalls() []RenameContainerArgs {
+	return renameContainerCallLog
+}
+
+// ResetRenameContainerCallLogs clears all existing records of any calls to the RenameContainer method.
+func (f *FakeRuntime) ResetRenameContainerCallLogs() {
+	renameContainerCallLog = []RenameContainerArgs{}
+}
+
 // KillContainer will kill a running container with the specified signal.
 func (f *FakeRuntime) KillContainer(ctx context.Context, containerName, signal string) error {
 	killContainerCallLog = append(killContainerCallLog, KillContainerArgs{
@@ -147,9 +298,85 @@ func (f *FakeRuntime) ResetKillContainerCallLogs() {
 // Will not error if there is no IP address assigned. Calling code will need to
 // determine whether that is an issue or not.
 func (f *FakeRuntime) GetContainerIPs(ctx context.Context, containerName string) (string, string, error) {
+	if containersWithoutIPs[containerName] {
+		return "", "", nil
+	}
 	return containerName + "IPv4", containerName + "IPv6", nil
 }
 
+// SetContainersWithoutIPs sets the containers calls to the GetContainerIPs method return no IP addresses for,
+// e.g. because they are stopped.
+func (f *FakeRuntime) SetContainersWithoutIPs(containerNames ...string) {
+	containersWithoutIPs = map[string]bool{}
+	for _, name := range containerNames {
+		containersWithoutIPs[name] = true
+	}
+}
+
+// InspectContainer returns details about a container.
+func (f *FakeRuntime) InspectContainer(ctx context.Context, containerName string) (*ContainerInfo, error) {
+	info := &ContainerInfo{
+		Name:         containerName,
+		ImageID:      containerName + "ImageID",
+		Networks:     containerNetworks[containerName],
+		RestartCount: containerRestartCounts[containerName],
+		Host:         containerHosts[containerName],
+		State:        containerStates[containerName],
+	}
+	for _, network := range info.Networks {
+		if info.IPAddresses == nil {
+			info.IPAddresses = map[string]string{}
+		}
+		info.IPAddresses[network] = containerName + "-" + network + "IPv4"
+	}
+	for _, c := range listContainersResult {
+		if c.Name == containerName {
+			info.Image = c.Image
+			info.Labels = c.Labels
+		}
+	}
+	for key, hostPort := range hostPorts {
+		if port := strings.TrimPrefix(key, containerName+"/"); port != key {
+			if info.HostPorts == nil {
+				info.HostPorts = map[string]string{}
+			}
+			info.HostPorts[port] = hostPort
+		}
+	}
+	return info, nil
+}
+
+// SetContainerNetworks sets the networks returned by calls to the InspectContainer method, keyed by container name.
+// The container IP address on a network is <container>-<network>IPv4.
+func (f *FakeRuntime) SetContainerNetworks(networks map[string][]string) {
+	containerNetworks = networks
+}
+
+// SetContainerRestartCounts sets the restart counts returned by calls to the InspectContainer method, keyed by container name.
+func (f *FakeRuntime) SetContainerRestartCounts(counts map[string]int) {
+	containerRestartCounts = counts
+}
+
+// SetContainerHosts sets the docker hosts returned by calls to the InspectContainer method, keyed by container name.
+func (f *FakeRuntime) SetContainerHosts(hosts map[string]string) {
+	containerHosts = hosts
+}
+
+// SetContainerStates sets the states returned by calls to the InspectContainer method, keyed by container name.
+func (f *FakeRuntime) SetContainerStates(states map[string]ContainerState) {
+	containerStates = states
+}
+
+// ImageRepoDigests returns the repository digests of an image.
+func (f *FakeRuntime) ImageRepoDigests(ctx context.Context, image string) ([]string, error) {
+	return imageRepoDigests[image], nil
+}
+
+// SetImageRepoDigests sets the repository digests returned by calls to the ImageRepoDigests method, keyed by image.
+func (f *FakeRuntime) SetImageRepoDigests(digests map[string][]string) {
+	imageRepoDigests = digests
+}
+
 // ContainerDebugInfo gets the container metadata and logs from the runtime (docker inspect, docker logs).
 func (f *FakeRuntime) ContainerDebugInfo(ctx context.Context, containerName string, w io.Writer) error {
 	return nil
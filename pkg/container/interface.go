@@ -20,6 +20,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
+	"time"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
@@ -30,6 +32,7 @@ type providerKey struct{}
 // Runtime defines the interface for interacting with a container runtime.
 type Runtime interface {
 	SaveContainerImage(ctx context.Context, image, dest string) error
+	LoadContainerImage(ctx context.Context, src string) error
 	PullContainerImageIfNotExists(ctx context.Context, image string) error
 	PullContainerImage(ctx context.Context, image string) error
 	ImageExistsLocally(ctx context.Context, image string) (bool, error)
@@ -40,7 +43,11 @@ type Runtime interface {
 	ListContainers(ctx context.Context, filters FilterBuilder) ([]Container, error)
 	ContainerDebugInfo(ctx context.Context, containerName string, w io.Writer) error
 	DeleteContainer(ctx context.Context, containerName string) error
+	StopContainer(ctx context.Context, containerName string, timeout time.Duration) error
+	RenameContainer(ctx context.Context, containerName, newName string) error
 	KillContainer(ctx context.Context, containerName, signal string) error
+	InspectContainer(ctx context.Context, containerName string) (*ContainerInfo, error)
+	ImageRepoDigests(ctx context.Context, image string) ([]string, error)
 }
 
 // Mount contains mount details.
@@ -71,8 +78,13 @@ type RunContainerInput struct {
 	Image string
 	// Name is the name to set for the container.
 	Name string
+	// Hostname is the hostname to set for the container; defaults to Name when empty.
+	Hostname string
 	// Network is the name of the network to connect to.
 	Network string
+	// AdditionalNetworks are the names of the networks to connect to in addition to Network;
+	// they must exist.
+	AdditionalNetworks []string
 	// User is the user name to run as.
 	User string
 	// Group is the user group to run as.
@@ -95,6 +107,31 @@ type RunContainerInput struct {
 	PortMappings []PortMapping
 	// IPFamily is the IP version to use.
 	IPFamily clusterv1.ClusterIPFamily
+	// Sysctls are the namespaced kernel parameters to set in the container.
+	Sysctls map[string]string
+	// CapAdd are the Linux capabilities to add to the container.
+	CapAdd []string
+	// CapDrop are the Linux capabilities to drop from the container.
+	CapDrop []string
+	// ExtraHosts are additional /etc/hosts entries (docker's "--add-host" arg), in the name:ip format.
+	ExtraHosts []string
+	// Privileged runs the container with all the capabilities and access to the host devices.
+	Privileged bool
+	// ReadOnlyRootFS mounts the root filesystem of the container read-only (docker's "--read-only" arg);
+	// only Volumes, Mounts and Tmpfs are writable.
+	ReadOnlyRootFS bool
+	// LogDriver is the log driver of the container (docker's "--log-driver" arg); the runtime default is used when empty.
+	LogDriver string
+	// LogOptions are the options of LogDriver (docker's "--log-opt" arg).
+	LogOptions map[string]string
+	// RestartPolicy is the restart policy of the container (docker's "--restart" arg); unless-stopped is
+	// used when empty.
+	RestartPolicy string
+
+	// PlacementConstraints are the scheduling filters of multi-host runtimes serving the docker API, e.g.
+	// "constraint:node==host" or "affinity:container!=name" for Docker Swarm standalone. They are passed as
+	// environment variables, which a single docker daemon sets in the container without acting on them.
+	PlacementConstraints []string
 }
 
 // ExecContainerInput contains values for running exec on a container.
@@ -125,6 +162,25 @@ func (f FilterBuilder) AddKeyNameValue(key, name, value string) {
 	f[key][name] = append(f[key][name], value)
 }
 
+// Args returns the filters as sorted docker CLI filter arguments ("key=name" or "key=name=value"),
+// e.g. for logging the filters used to look up containers.
+func (f FilterBuilder) Args() []string {
+	args := []string{}
+	for key, values := range f {
+		for name, subvalues := range values {
+			for _, v := range subvalues {
+				if v == "" {
+					args = append(args, fmt.Sprintf("%s=%s", key, name))
+				} else {
+					args = append(args, fmt.Sprintf("%s=%s=%s", key, name, v))
+				}
+			}
+		}
+	}
+	sort.Strings(args)
+	return args
+}
+
 // Container represents a runtime container.
 type Container struct {
 	// Name is the name of the container
@@ -133,6 +189,65 @@ type Container struct {
 	Image string
 	// Status is the status of the container
 	Status string
+	// Labels are the labels set on the container
+	Labels map[string]string
+}
+
+// ContainerInfo contains details about a runtime container.
+type ContainerInfo struct {
+	// Name is the name of the container
+	Name string
+	// Image is the image reference the container was created from
+	Image string
+	// ImageID is the ID of the image the container is running
+	ImageID string
+	// Networks are the names of the networks the container is attached to
+	Networks []string
+	// IPAddresses are the IPv4 addresses of the container, keyed by network name
+	IPAddresses map[string]string
+	// Labels are the labels set on the container
+	Labels map[string]string
+	// Mounts are the volumes and bind mounts of the container
+	Mounts []Mount
+	// RestartCount is the number of times the runtime restarted the container
+	RestartCount int
+	// State is the state of the container process
+	State ContainerState
+	// HostPorts are the host ports the container ports are published on, keyed by port and
+	// protocol (e.g. "6443/tcp")
+	HostPorts map[string]string
+	// Host is the name of the docker host running the container; only multi-host runtimes, e.g. Docker
+	// Swarm standalone, report it.
+	Host string
+}
+
+// ContainerState contains the state of the process of a runtime container.
+type ContainerState struct {
+	// Status is the status of the container, e.g. "running" or "exited"
+	Status string
+	// ExitCode is the exit code of the last run of the container process
+	ExitCode int
+	// OOMKilled is true if the container process was killed for running out of memory
+	OOMKilled bool
+	// Error is the error the runtime reported, e.g. failing to start the container process
+	Error string
+}
+
+// Exited returns true if the container process is no longer running and will not be restarted
+// by the runtime as is, e.g. because it failed at startup.
+func (s ContainerState) Exited() bool {
+	return s.Status == "exited" || s.Status == "dead"
+}
+
+// ImageNotFoundError is returned when creating or starting a container fails because its image
+// does not exist locally, e.g. after it was removed by pruning the host images.
+type ImageNotFoundError struct {
+	Image string
+}
+
+// Error returns the error string.
+func (e ImageNotFoundError) Error() string {
+	return fmt.Sprintf("image %q not found", e.Image)
 }
 
 // RuntimeFrom is used to extract the container runtime client from a
@@ -41,6 +41,25 @@ func TestFilterBuildKeyNameValue(t *testing.T) {
 	g.Expect(filters).To(Equal(FilterBuilder{"key1": {"name1": []string{"value1"}}}))
 }
 
+func TestFilterBuilderArgs(t *testing.T) {
+	g := NewWithT(t)
+
+	filters := FilterBuilder{}
+	g.Expect(filters.Args()).To(BeEmpty())
+
+	filters.AddKeyNameValue("label", "io.x-k8s.kind.role", "control-plane")
+	filters.AddKeyNameValue("label", "io.x-k8s.kind.cluster", "test-cluster")
+	filters.AddKeyValue("label", "io.x-k8s.kind.cluster")
+	filters.AddKeyValue("name", "^test-cluster-lb$")
+
+	g.Expect(filters.Args()).To(Equal([]string{
+		"label=io.x-k8s.kind.cluster",
+		"label=io.x-k8s.kind.cluster=test-cluster",
+		"label=io.x-k8s.kind.role=control-plane",
+		"name=^test-cluster-lb$",
+	}))
+}
+
 func TestFakeContext(t *testing.T) {
 	g := NewWithT(t)
 	fake := FakeRuntime{}
@@ -0,0 +1,207 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package container
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultDaemonRetryAttempts is how many times a call is attempted by default while the daemon is unavailable.
+	DefaultDaemonRetryAttempts = 5
+	// DefaultDaemonRetryBackoff is the default wait before retrying a call the daemon was unavailable for.
+	DefaultDaemonRetryBackoff = 200 * time.Millisecond
+	// DefaultDaemonRetryMaxBackoff is the default limit of the wait between the retries.
+	DefaultDaemonRetryMaxBackoff = 2 * time.Second
+)
+
+// DaemonRetryOptions configures how the calls are retried while the container runtime daemon is unavailable.
+type DaemonRetryOptions struct {
+	// Attempts is how many times a call is attempted; one or less does not retry.
+	Attempts int
+	// Backoff is the wait before the first retry, doubled after each retry.
+	Backoff time.Duration
+	// MaxBackoff limits the wait between the retries; zero does not limit it.
+	MaxBackoff time.Duration
+}
+
+// DefaultDaemonRetryOptions returns the options retrying a call for about three seconds.
+func DefaultDaemonRetryOptions() DaemonRetryOptions {
+	return DaemonRetryOptions{
+		Attempts:   DefaultDaemonRetryAttempts,
+		Backoff:    DefaultDaemonRetryBackoff,
+		MaxBackoff: DefaultDaemonRetryMaxBackoff,
+	}
+}
+
+// IsDaemonUnavailable returns true if the error is caused by failing to connect to the daemon, e.g. while it
+// restarts. The request did not reach the daemon, so it is safe to send it again.
+func IsDaemonUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	// Some errors of the docker runtime only keep the message of the client error.
+	return client.IsErrConnectionFailed(err) || strings.Contains(err.Error(), "Cannot connect to the Docker daemon")
+}
+
+// WithDaemonRetry returns a runtime retrying the calls of the given runtime that fail because the daemon is
+// unavailable, so a momentary daemon restart does not fail the whole reconcile.
+// RunContainer is not retried: it sends several requests and the container may already exist when a later
+// one fails, so it is left to the next reconcile. Neither is ExecContainer: the command may already have run,
+// e.g. written or reloaded the configuration, when attaching to it or inspecting its exit code fails.
+func WithDaemonRetry(runtime Runtime, options DaemonRetryOptions) Runtime {
+	if options.Attempts <= 1 {
+		return runtime
+	}
+	return &retryingRuntime{Runtime: runtime, options: options}
+}
+
+// retryingRuntime is a Runtime retrying the calls failing because the daemon is unavailable.
+type retryingRuntime struct {
+	Runtime
+	options DaemonRetryOptions
+}
+
+// retry calls fn until it does not fail because the daemon is unavailable, waiting with an exponential
+// backoff between the attempts.
+func (r *retryingRuntime) retry(ctx context.Context, fn func() error) error {
+	backoff := r.options.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !IsDaemonUnavailable(err) || attempt >= r.options.Attempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(err, "interrupted retrying after %d attempts", attempt)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if r.options.MaxBackoff > 0 && backoff > r.options.MaxBackoff {
+			backoff = r.options.MaxBackoff
+		}
+	}
+}
+
+func (r *retryingRuntime) SaveContainerImage(ctx context.Context, image, dest string) error {
+	return r.retry(ctx, func() error {
+		return r.Runtime.SaveContainerImage(ctx, image, dest)
+	})
+}
+
+func (r *retryingRuntime) LoadContainerImage(ctx context.Context, src string) error {
+	return r.retry(ctx, func() error {
+		return r.Runtime.LoadContainerImage(ctx, src)
+	})
+}
+
+func (r *retryingRuntime) PullContainerImageIfNotExists(ctx context.Context, image string) error {
+	return r.retry(ctx, func() error {
+		return r.Runtime.PullContainerImageIfNotExists(ctx, image)
+	})
+}
+
+func (r *retryingRuntime) PullContainerImage(ctx context.Context, image string) error {
+	return r.retry(ctx, func() error {
+		return r.Runtime.PullContainerImage(ctx, image)
+	})
+}
+
+func (r *retryingRuntime) ImageExistsLocally(ctx context.Context, image string) (exists bool, err error) {
+	err = r.retry(ctx, func() error {
+		exists, err = r.Runtime.ImageExistsLocally(ctx, image)
+		return err
+	})
+	return exists, err
+}
+
+func (r *retryingRuntime) GetHostPort(ctx context.Context, containerName, portAndProtocol string) (port string, err error) {
+	err = r.retry(ctx, func() error {
+		port, err = r.Runtime.GetHostPort(ctx, containerName, portAndProtocol)
+		return err
+	})
+	return port, err
+}
+
+func (r *retryingRuntime) GetContainerIPs(ctx context.Context, containerName string) (ipv4, ipv6 string, err error) {
+	err = r.retry(ctx, func() error {
+		ipv4, ipv6, err = r.Runtime.GetContainerIPs(ctx, containerName)
+		return err
+	})
+	return ipv4, ipv6, err
+}
+
+func (r *retryingRuntime) ListContainers(ctx context.Context, filters FilterBuilder) (containers []Container, err error) {
+	err = r.retry(ctx, func() error {
+		containers, err = r.Runtime.ListContainers(ctx, filters)
+		return err
+	})
+	return containers, err
+}
+
+func (r *retryingRuntime) ContainerDebugInfo(ctx context.Context, containerName string, w io.Writer) error {
+	return r.retry(ctx, func() error {
+		return r.Runtime.ContainerDebugInfo(ctx, containerName, w)
+	})
+}
+
+func (r *retryingRuntime) DeleteContainer(ctx context.Context, containerName string) error {
+	return r.retry(ctx, func() error {
+		return r.Runtime.DeleteContainer(ctx, containerName)
+	})
+}
+
+func (r *retryingRuntime) StopContainer(ctx context.Context, containerName string, timeout time.Duration) error {
+	return r.retry(ctx, func() error {
+		return r.Runtime.StopContainer(ctx, containerName, timeout)
+	})
+}
+
+func (r *retryingRuntime) RenameContainer(ctx context.Context, containerName, newName string) error {
+	return r.retry(ctx, func() error {
+		return r.Runtime.RenameContainer(ctx, containerName, newName)
+	})
+}
+
+func (r *retryingRuntime) KillContainer(ctx context.Context, containerName, signal string) error {
+	return r.retry(ctx, func() error {
+		return r.Runtime.KillContainer(ctx, containerName, signal)
+	})
+}
+
+func (r *retryingRuntime) InspectContainer(ctx context.Context, containerName string) (info *ContainerInfo, err error) {
+	err = r.retry(ctx, func() error {
+		info, err = r.Runtime.InspectContainer(ctx, containerName)
+		return err
+	})
+	return info, err
+}
+
+func (r *retryingRuntime) ImageRepoDigests(ctx context.Context, image string) (digests []string, err error) {
+	err = r.retry(ctx, func() error {
+		digests, err = r.Runtime.ImageRepoDigests(ctx, image)
+		return err
+	})
+	return digests, err
+}
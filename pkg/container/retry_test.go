@@ -0,0 +1,115 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package container
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+// flakyRuntime fails the first calls as if the daemon was restarting.
+type flakyRuntime struct {
+	FakeRuntime
+	failures int
+	calls    int
+	err      error
+}
+
+func (f *flakyRuntime) ListContainers(ctx context.Context, filters FilterBuilder) ([]Container, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, f.err
+	}
+	return []Container{{Name: "cluster-lb"}}, nil
+}
+
+func (f *flakyRuntime) ExecContainer(ctx context.Context, containerName string, config *ExecContainerInput, command string, args ...string) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func TestWithDaemonRetry(t *testing.T) {
+	unavailable := errors.Wrap(client.ErrorConnectionFailed("unix:///var/run/docker.sock"), "failed to list containers")
+	options := DaemonRetryOptions{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	t.Run("retries while the daemon is unavailable", func(t *testing.T) {
+		g := NewWithT(t)
+		runtime := &flakyRuntime{failures: 2, err: unavailable}
+		containers, err := WithDaemonRetry(runtime, options).ListContainers(context.Background(), FilterBuilder{})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(containers).To(HaveLen(1))
+		g.Expect(runtime.calls).To(Equal(3))
+	})
+
+	t.Run("gives up after the attempts", func(t *testing.T) {
+		g := NewWithT(t)
+		runtime := &flakyRuntime{failures: 5, err: unavailable}
+		_, err := WithDaemonRetry(runtime, options).ListContainers(context.Background(), FilterBuilder{})
+		g.Expect(IsDaemonUnavailable(err)).To(BeTrue())
+		g.Expect(runtime.calls).To(Equal(3))
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		g := NewWithT(t)
+		runtime := &flakyRuntime{failures: 1, err: errors.New("invalid filter")}
+		_, err := WithDaemonRetry(runtime, options).ListContainers(context.Background(), FilterBuilder{})
+		g.Expect(err).To(MatchError("invalid filter"))
+		g.Expect(runtime.calls).To(Equal(1))
+	})
+
+	t.Run("does not retry a failed exec", func(t *testing.T) {
+		g := NewWithT(t)
+		runtime := &flakyRuntime{failures: 1, err: unavailable}
+		err := WithDaemonRetry(runtime, options).ExecContainer(context.Background(), "cluster-lb", &ExecContainerInput{}, "cp", "/dev/stdin", "/usr/local/etc/haproxy/haproxy.cfg")
+		g.Expect(IsDaemonUnavailable(err)).To(BeTrue())
+		g.Expect(runtime.calls).To(Equal(1))
+	})
+
+	t.Run("stops retrying when the context is done", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		runtime := &flakyRuntime{failures: 5, err: unavailable}
+		_, err := WithDaemonRetry(runtime, DaemonRetryOptions{Attempts: 3, Backoff: time.Hour}).ListContainers(ctx, FilterBuilder{})
+		g.Expect(err).To(MatchError(ContainSubstring("interrupted retrying")))
+		g.Expect(runtime.calls).To(Equal(1))
+	})
+
+	t.Run("does not wrap the runtime with a single attempt", func(t *testing.T) {
+		g := NewWithT(t)
+		runtime := &flakyRuntime{}
+		g.Expect(WithDaemonRetry(runtime, DaemonRetryOptions{Attempts: 1})).To(BeIdenticalTo(runtime))
+	})
+}
+
+func TestIsDaemonUnavailable(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(IsDaemonUnavailable(nil)).To(BeFalse())
+	g.Expect(IsDaemonUnavailable(errors.New("no such container"))).To(BeFalse())
+	g.Expect(IsDaemonUnavailable(client.ErrorConnectionFailed(""))).To(BeTrue())
+	// The message is all that is left when the client error was formatted into another one.
+	g.Expect(IsDaemonUnavailable(errors.Errorf("unable to read image data: %v", client.ErrorConnectionFailed("")))).To(BeTrue())
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"sync"
+	"time"
+)
+
+// MaxBackendCacheTTL bounds how long a cached backend set can be used, so a stale cache
+// cannot keep the load balancer pointing at nodes that no longer exist for long.
+const MaxBackendCacheTTL = time.Minute
+
+// backendCache stores the last known backend servers of each cluster load balancer.
+// A LoadBalancer helper is created on every reconcile, so the cache outlives it.
+type backendCache struct {
+	lock    sync.Mutex
+	entries map[string]backendCacheEntry
+	now     func() time.Time
+}
+
+type backendCacheEntry struct {
+	servers   map[string]string
+	fetchedAt time.Time
+}
+
+// defaultBackendCache is the cache shared by all the load balancers in the process.
+var defaultBackendCache = newBackendCache()
+
+func newBackendCache() *backendCache {
+	return &backendCache{
+		entries: map[string]backendCacheEntry{},
+		now:     time.Now,
+	}
+}
+
+// get returns the backend servers cached for the cluster if they were fetched less than ttl ago.
+func (c *backendCache) get(cluster string, ttl time.Duration) (map[string]string, bool) {
+	if ttl > MaxBackendCacheTTL {
+		ttl = MaxBackendCacheTTL
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[cluster]
+	if !ok || c.now().Sub(entry.fetchedAt) >= ttl {
+		return nil, false
+	}
+	return copyBackendServers(entry.servers), true
+}
+
+// set caches the backend servers for the cluster.
+func (c *backendCache) set(cluster string, servers map[string]string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries[cluster] = backendCacheEntry{
+		servers:   copyBackendServers(servers),
+		fetchedAt: c.now(),
+	}
+}
+
+// invalidate drops the backend servers cached for the cluster.
+func (c *backendCache) invalidate(cluster string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.entries, cluster)
+}
+
+func copyBackendServers(servers map[string]string) map[string]string {
+	out := make(map[string]string, len(servers))
+	for server, address := range servers {
+		out[server] = address
+	}
+	return out
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+)
+
+func TestBackendCache(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newBackendCache()
+	cache.now = func() time.Time { return now }
+
+	_, ok := cache.get("test-cluster", time.Minute)
+	g.Expect(ok).To(BeFalse())
+
+	servers := map[string]string{"cp-1": "10.0.0.1:6443"}
+	cache.set("test-cluster", servers)
+	servers["cp-2"] = "10.0.0.2:6443"
+
+	got, ok := cache.get("test-cluster", 30*time.Second)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(got).To(Equal(map[string]string{"cp-1": "10.0.0.1:6443"}))
+
+	now = now.Add(30 * time.Second)
+	_, ok = cache.get("test-cluster", 30*time.Second)
+	g.Expect(ok).To(BeFalse())
+
+	// The TTL is bounded by MaxBackendCacheTTL.
+	now = now.Add(MaxBackendCacheTTL)
+	_, ok = cache.get("test-cluster", time.Hour)
+	g.Expect(ok).To(BeFalse())
+
+	cache.set("test-cluster", servers)
+	cache.invalidate("test-cluster")
+	_, ok = cache.get("test-cluster", time.Minute)
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestLoadBalancerBackendCache(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer containerRuntime.SetListContainersResult(nil)
+
+	lb := &LoadBalancer{
+		name:            "test-cluster",
+		container:       types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		backendCache:    newBackendCache(),
+		backendCacheTTL: time.Minute,
+	}
+
+	servers, err := lb.backendServers(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(servers).To(HaveKey("test-cluster-cp-1"))
+
+	// A new control plane node is not seen while the cache is fresh...
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}, {Name: "test-cluster-cp-2"}})
+	servers, err = lb.backendServers(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(servers).To(HaveLen(1))
+
+	// ...unless a refresh is forced.
+	lb.SetForceRefresh(true)
+	servers, err = lb.backendServers(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(servers).To(HaveLen(2))
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+)
+
+// BackendDiscoverer lists the control plane nodes the load balancer of a cluster routes to.
+type BackendDiscoverer interface {
+	DiscoverBackends(ctx context.Context, clusterName string) ([]*types.Node, error)
+}
+
+// LabelDiscoverer discovers the control plane nodes of a cluster by their cluster and role labels,
+// skipping the nodes labeled with LoadBalancerExcludeLabelKey. This is the default discoverer.
+type LabelDiscoverer struct{}
+
+// DiscoverBackends returns the control plane nodes of the cluster that are not excluded from the load balancer.
+func (LabelDiscoverer) DiscoverBackends(ctx context.Context, clusterName string) ([]*types.Node, error) {
+	filters := labelFilters(ControlPlaneLabels(clusterName))
+
+	controlPlaneNodes, err := listContainers(ctx, filters)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	nodes := make([]*types.Node, 0, len(controlPlaneNodes))
+	for _, n := range controlPlaneNodes {
+		// The filters already select the cluster nodes; checking their labels again guards the hosts running
+		// many clusters against routing to a foreign node if the filters were ever ignored or mistyped.
+		if cluster := n.Labels[ClusterLabelKey]; cluster != clusterName {
+			return nil, errors.Errorf("container %s discovered as a backend of cluster %q belongs to cluster %q", n.Name, clusterName, cluster)
+		}
+		if role := n.Labels[NodeRoleLabelKey]; role != constants.ControlPlaneNodeRoleValue {
+			return nil, errors.Errorf("container %s discovered as a backend of cluster %q has role %q", n.Name, clusterName, role)
+		}
+		// Docker filters cannot express label negation, so excluded nodes are skipped here.
+		if n.Labels[LoadBalancerExcludeLabelKey] == "true" {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// StaticDiscoverer returns a fixed list of nodes regardless of the cluster, e.g. nodes that are
+// managed outside of Cluster API.
+type StaticDiscoverer struct {
+	// Nodes are the nodes the load balancer routes to.
+	Nodes []*types.Node
+}
+
+// DiscoverBackends returns the configured nodes.
+func (d StaticDiscoverer) DiscoverBackends(_ context.Context, _ string) ([]*types.Node, error) {
+	return append([]*types.Node(nil), d.Nodes...), nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+func TestLabelDiscoverer(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetListContainersCallLogs()
+	containerRuntime.SetListContainersResult([]container.Container{
+		{Name: "test-cluster-cp-1"},
+		{Name: "test-cluster-cp-2", Labels: map[string]string{LoadBalancerExcludeLabelKey: "true"}},
+	})
+	defer containerRuntime.SetListContainersResult(nil)
+
+	nodes, err := LabelDiscoverer{}.DiscoverBackends(ctx, "test-cluster")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(nodes).To(HaveLen(1))
+	g.Expect(nodes[0].Name).To(Equal("test-cluster-cp-1"))
+
+	callLog := containerRuntime.ListContainersCalls()
+	g.Expect(callLog).To(HaveLen(1))
+	g.Expect(callLog[0].Args()).To(ContainElements(
+		"label="+ClusterLabelKey+"=test-cluster",
+		"label="+NodeRoleLabelKey+"="+constants.ControlPlaneNodeRoleValue,
+	))
+}
+
+func TestLoadBalancerClusterIsolation(t *testing.T) {
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	newLoadBalancer := func(name string) *LoadBalancer {
+		return &LoadBalancer{
+			name:      name,
+			config:    getLoadBalancerConfigData(nil),
+			container: types.NewNode(name+"-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		}
+	}
+	controlPlane := func(name, cluster string) container.Container {
+		return container.Container{Name: name, Labels: map[string]string{
+			ClusterLabelKey:  cluster,
+			NodeRoleLabelKey: constants.ControlPlaneNodeRoleValue,
+		}}
+	}
+
+	t.Run("configures each cluster with its own backends", func(t *testing.T) {
+		for _, cluster := range []string{"cluster-a", "cluster-b"} {
+			g := NewWithT(t)
+			containerRuntime.ResetExecContainerCallLogs()
+			containerRuntime.SetListContainersResult([]container.Container{
+				controlPlane(cluster+"-cp-1", cluster),
+				controlPlane(cluster+"-cp-2", cluster),
+			})
+
+			_, err := newLoadBalancer(cluster).UpdateConfiguration(ctx)
+			g.Expect(err).ToNot(HaveOccurred())
+			servers := writtenConfig(g, containerRuntime).Backend(loadbalancer.BackendName).Servers
+			g.Expect(servers).To(HaveLen(2))
+			for _, server := range servers {
+				g.Expect(server.Name).To(HavePrefix(cluster + "-cp-"))
+			}
+		}
+	})
+
+	t.Run("fails on a container of another cluster", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime.ResetExecContainerCallLogs()
+		// The fake runtime ignores the filters, like a runtime the label filters would not reach.
+		containerRuntime.SetListContainersResult([]container.Container{
+			controlPlane("cluster-a-cp-1", "cluster-a"),
+			controlPlane("cluster-b-cp-1", "cluster-b"),
+		})
+		defer containerRuntime.SetListContainersResult(nil)
+
+		_, err := newLoadBalancer("cluster-a").UpdateConfiguration(ctx)
+		g.Expect(err).To(MatchError(ContainSubstring(`container cluster-b-cp-1 discovered as a backend of cluster "cluster-a" belongs to cluster "cluster-b"`)))
+		for _, call := range containerRuntime.ExecContainerCalls() {
+			g.Expect(call.Command).ToNot(Equal("cp"))
+		}
+	})
+
+	t.Run("fails on a container that is not a control plane node", func(t *testing.T) {
+		g := NewWithT(t)
+		worker := controlPlane("cluster-a-worker-1", "cluster-a")
+		worker.Labels[NodeRoleLabelKey] = constants.WorkerNodeRoleValue
+		containerRuntime.SetListContainersResult([]container.Container{controlPlane("cluster-a-cp-1", "cluster-a"), worker})
+		defer containerRuntime.SetListContainersResult(nil)
+
+		_, err := LabelDiscoverer{}.DiscoverBackends(ctx, "cluster-a")
+		g.Expect(err).To(MatchError(ContainSubstring(`container cluster-a-worker-1 discovered as a backend of cluster "cluster-a" has role "worker"`)))
+	})
+}
+
+func TestLoadBalancerBackendDiscoverer(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetExecContainerCallLogs()
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer containerRuntime.SetListContainersResult(nil)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(nil),
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+	lb.SetBackendDiscoverer(StaticDiscoverer{Nodes: []*types.Node{
+		types.NewNode("external-cp-1", "TestImage", constants.ControlPlaneNodeRoleValue),
+		types.NewNode("external-cp-2", "TestImage", constants.ControlPlaneNodeRoleValue),
+	}})
+
+	_, err := lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	servers := writtenConfig(g, containerRuntime).Backend(loadbalancer.BackendName).Servers
+	g.Expect(servers).To(HaveLen(2))
+	g.Expect(servers[0].Name).To(Equal("external-cp-1"))
+	g.Expect(servers[1].Name).To(Equal("external-cp-2"))
+
+	lb.SetBackendDiscoverer(nil)
+	backendServers, err := lb.backendServers(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(backendServers).To(Equal(map[string]string{"test-cluster-cp-1": "test-cluster-cp-1IPv4:6443"}))
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+)
+
+// BackendAddressResolver resolves the address (host:port) the load balancer reaches a control plane node on.
+type BackendAddressResolver interface {
+	ResolveBackendAddress(ctx context.Context, node *types.Node, port int32) (string, error)
+}
+
+// ContainerIPResolver resolves backends to the container IP of the node, which is reachable when the
+// load balancer and the control plane nodes share a docker network. This is the default resolver.
+type ContainerIPResolver struct {
+	// IPTimeout is how long to wait for a node without an IP, e.g. because it was just created.
+	IPTimeout time.Duration
+	// Clock is used to wait for the IP; nil uses the real clock.
+	Clock Clock
+}
+
+// ResolveBackendAddress returns the container IP of the node and the given port.
+func (r ContainerIPResolver) ResolveBackendAddress(ctx context.Context, node *types.Node, port int32) (string, error) {
+	ipv4, err := waitForNodeIP(ctx, node, r.IPTimeout, r.Clock)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ipv4, strconv.Itoa(int(port))), nil
+}
+
+// ContainerNameResolver resolves backends to the container name of the node, which the docker embedded
+// DNS server resolves on the load balancer network. Unlike the container IP the name survives restarts
+// of the node, when used with a configuration re-resolving it (see loadbalancer.ConfigData.Resolvers).
+type ContainerNameResolver struct{}
+
+// ResolveBackendAddress returns the container name of the node and the given port.
+func (ContainerNameResolver) ResolveBackendAddress(_ context.Context, node *types.Node, port int32) (string, error) {
+	return net.JoinHostPort(node.Name, strconv.Itoa(int(port))), nil
+}
+
+// HostPortResolver resolves backends to the host port the given container port is published on,
+// for control plane nodes running on a remote docker host whose container network the load balancer
+// cannot reach.
+type HostPortResolver struct {
+	// Host is the address of the docker host the control plane nodes run on.
+	Host string
+}
+
+// ResolveBackendAddress returns the docker host and the host port the container port of the node is published on.
+func (r HostPortResolver) ResolveBackendAddress(ctx context.Context, node *types.Node, port int32) (string, error) {
+	containerRuntime, err := container.RuntimeFrom(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to connect to container runtime")
+	}
+
+	hostPort, err := containerRuntime.GetHostPort(ctx, node.Name, fmt.Sprintf("%d/tcp", port))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get host port for container %s", node.Name)
+	}
+	if hostPort == "" {
+		return "", errors.Errorf("port %d of container %s is not published on the host", port, node.Name)
+	}
+	return net.JoinHostPort(r.Host, hostPort), nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+func TestHostPortResolver(t *testing.T) {
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetHostPorts(map[string]string{"test-cluster-cp-1/6443/tcp": "32768"})
+	defer containerRuntime.SetHostPorts(nil)
+
+	resolver := HostPortResolver{Host: "192.168.1.10"}
+
+	t.Run("resolves to the published host port", func(t *testing.T) {
+		g := NewWithT(t)
+		node := types.NewNode("test-cluster-cp-1", "TestImage", constants.ControlPlaneNodeRoleValue)
+		g.Expect(resolver.ResolveBackendAddress(ctx, node, 6443)).To(Equal("192.168.1.10:32768"))
+	})
+
+	t.Run("fails if the port is not published", func(t *testing.T) {
+		g := NewWithT(t)
+		node := types.NewNode("test-cluster-cp-2", "TestImage", constants.ControlPlaneNodeRoleValue)
+		_, err := resolver.ResolveBackendAddress(ctx, node, 6443)
+		g.Expect(err).To(MatchError(ContainSubstring("not published on the host")))
+	})
+}
+
+func TestLoadBalancerBackendAddressResolver(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetExecContainerCallLogs()
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer containerRuntime.SetListContainersResult(nil)
+	containerRuntime.SetHostPorts(map[string]string{"test-cluster-cp-1/6443/tcp": "32768"})
+	defer containerRuntime.SetHostPorts(nil)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(nil),
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+
+	backendServers, err := lb.backendServers(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(backendServers).To(Equal(map[string]string{"test-cluster-cp-1": "test-cluster-cp-1IPv4:6443"}))
+
+	lb.SetBackendAddressResolver(HostPortResolver{Host: "192.168.1.10"})
+	_, err = lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	parsed := writtenConfig(g, containerRuntime)
+	g.Expect(parsed.Backend(loadbalancer.BackendName).Servers).To(HaveLen(1))
+	g.Expect(parsed.Backend(loadbalancer.BackendName).Servers[0].Address).To(Equal("192.168.1.10:32768"))
+}
+
+func TestLoadBalancerUseBackendNames(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-lb"}})
+	defer containerRuntime.SetListContainersResult(nil)
+
+	dockerCluster := &infrav1.DockerCluster{Spec: infrav1.DockerClusterSpec{LoadBalancerUseBackendNames: true}}
+	lb, err := NewLoadBalancer(ctx, &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}}, dockerCluster)
+	g.Expect(err).ToNot(HaveOccurred())
+	// The fake runtime does not serve stats, skip waiting for the reload.
+	lb.reloadTimeout = 0
+
+	containerRuntime.ResetExecContainerCallLogs()
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	_, err = lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	parsed := writtenConfig(g, containerRuntime)
+	g.Expect(parsed.Sections).To(ContainElement(loadbalancer.ParsedSection{
+		Kind:       "resolvers",
+		Name:       loadbalancer.DockerResolversName,
+		Directives: []string{"nameserver ns0 " + loadbalancer.DockerDNSAddress, "hold valid 10s"},
+	}))
+	servers := parsed.Backend(loadbalancer.BackendName).Servers
+	g.Expect(servers).To(HaveLen(1))
+	g.Expect(servers[0].Address).To(Equal("test-cluster-cp-1:6443"))
+	g.Expect(servers[0].Options).To(ContainElements("resolvers", loadbalancer.DockerResolversName, "init-addr", loadbalancer.DefaultInitAddr))
+}
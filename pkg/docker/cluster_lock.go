@@ -0,0 +1,52 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"sync"
+)
+
+// clusterLocks serializes the mutations of each cluster load balancer container.
+// A LoadBalancer helper is created on every reconcile, and the DockerCluster and DockerMachine
+// controllers can reconcile the same cluster concurrently, so the locks outlive the helpers.
+type clusterLocks struct {
+	lock  sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// defaultClusterLocks are the locks shared by all the load balancers in the process.
+var defaultClusterLocks = newClusterLocks()
+
+func newClusterLocks() *clusterLocks {
+	return &clusterLocks{
+		locks: map[string]*sync.Mutex{},
+	}
+}
+
+// lockCluster blocks until the lock of the cluster is acquired, and returns the function releasing it.
+func (c *clusterLocks) lockCluster(cluster string) func() {
+	c.lock.Lock()
+	l, ok := c.locks[cluster]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[cluster] = l
+	}
+	c.lock.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
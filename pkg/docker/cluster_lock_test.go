@@ -0,0 +1,86 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+func TestClusterLocks(t *testing.T) {
+	g := NewWithT(t)
+	locks := newClusterLocks()
+
+	unlock := locks.lockCluster("test-cluster")
+
+	// Other clusters are not blocked.
+	locks.lockCluster("other-cluster")()
+
+	acquired := make(chan struct{})
+	go func() {
+		defer locks.lockCluster("test-cluster")()
+		close(acquired)
+	}()
+	g.Consistently(acquired).ShouldNot(BeClosed())
+
+	unlock()
+	g.Eventually(acquired).Should(BeClosed())
+}
+
+func TestLoadBalancerConcurrentReconciles(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetExecContainerCallLogs()
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer containerRuntime.SetListContainersResult(nil)
+
+	// Every reconcile builds its own LoadBalancer; the fake runtime is not safe for concurrent
+	// use, so the race detector catches any mutation running outside of the cluster lock.
+	const reconciles = 8
+	errs := make(chan error, 2*reconciles)
+	var wg sync.WaitGroup
+	for i := 0; i < reconciles; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lb := &LoadBalancer{
+				name:      "test-cluster",
+				config:    getLoadBalancerConfigData(nil),
+				container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+			}
+			_, err := lb.UpdateConfiguration(ctx)
+			errs <- err
+			errs <- lb.Create(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	g.Expect(writtenConfig(g, containerRuntime).Backend(loadbalancer.BackendName).Servers).To(HaveLen(1))
+}
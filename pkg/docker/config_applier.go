@@ -0,0 +1,139 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+// ConfigApplier writes a configuration into a load balancer container and makes the load balancer
+// serve it. It owns the configuration path, validation and reload mechanism of a load balancer
+// implementation, so implementations other than HAProxy can be plugged in.
+type ConfigApplier interface {
+	// Apply validates the configuration, writes it into the container and reloads the load balancer.
+	// An invalid configuration is not written.
+	Apply(ctx context.Context, node *types.Node, config string) error
+	// Reload makes the load balancer load the configuration already written in the container.
+	Reload(ctx context.Context, node *types.Node) error
+}
+
+// stagedConfigPath is where HAProxyApplier stages a configuration in the container while HAProxy validates it.
+const stagedConfigPath = "/tmp/haproxy-staged.cfg"
+
+// configFileOptions returns the permissions the load balancer configuration is written with. An empty
+// owner keeps the user the container runs commands as.
+func configFileOptions(owner string) types.FileOptions {
+	return types.FileOptions{Mode: loadbalancer.ConfigFileMode, Owner: owner}
+}
+
+// configFileOwner returns the owner of the configuration files, as user, user:group or :group, matching
+// the user and group HAProxy drops its privileges to. It is empty when the image defaults are kept.
+func configFileOwner(data loadbalancer.ConfigData) string {
+	if data.RunAsGroup == "" {
+		return data.RunAsUser
+	}
+	return data.RunAsUser + ":" + data.RunAsGroup
+}
+
+// HAProxyApplier is the ConfigApplier used by default. It validates configurations with haproxy -c
+// before writing them to loadbalancer.ConfigPath, and reloads HAProxy with SIGHUP.
+type HAProxyApplier struct {
+	// Owner is the owner the configuration is written with, as user, user:group or :group, so HAProxy
+	// can read it after dropping its privileges; empty keeps the user of the container.
+	Owner string
+}
+
+// Apply validates the configuration, writes it into the container and reloads HAProxy.
+func (a HAProxyApplier) Apply(ctx context.Context, node *types.Node, config string) error {
+	if err := node.WriteFile(ctx, stagedConfigPath, config); err != nil {
+		return errors.Wrap(err, "failed to stage load balancer configuration")
+	}
+	var output bytes.Buffer
+	cmd := node.Commander.Command("haproxy", "-c", "-f", stagedConfigPath)
+	cmd.SetStdout(&output)
+	cmd.SetStderr(&output)
+	if err := cmd.Run(ctx); err != nil {
+		return errors.Wrapf(err, "invalid load balancer configuration: %s", strings.TrimSpace(output.String()))
+	}
+
+	if err := node.WriteFileWithOptions(ctx, loadbalancer.ConfigPath, config, configFileOptions(a.Owner)); err != nil {
+		return errors.WithStack(err)
+	}
+	return a.Reload(ctx, node)
+}
+
+// Reload signals the HAProxy master process to reload its configuration. Custom images may not run
+// HAProxy as PID 1, so the master PID is read from the PID file; if it cannot be resolved, e.g. before
+// the first configuration written by the provider is loaded, the container is signaled instead.
+func (HAProxyApplier) Reload(ctx context.Context, node *types.Node) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	pid, err := haproxyMasterPID(ctx, node)
+	if err != nil || pid == 1 {
+		if err != nil {
+			log.V(4).Info("Unable to resolve the HAProxy master PID, signaling the load balancer container", "error", err.Error())
+		}
+		return errors.WithStack(node.Kill(ctx, "SIGHUP"))
+	}
+
+	var stderr bytes.Buffer
+	cmd := node.Commander.Command("kill", "-HUP", strconv.Itoa(pid))
+	cmd.SetStderr(&stderr)
+	if err := cmd.Run(ctx); err != nil {
+		return errors.Wrapf(err, "failed to signal HAProxy master process %d: %s", pid, stderr.String())
+	}
+	return nil
+}
+
+// haproxyMasterPID returns the PID of the HAProxy master process in the load balancer container.
+func haproxyMasterPID(ctx context.Context, node *types.Node) (int, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := node.Commander.Command("cat", loadbalancer.PIDFilePath)
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(&stderr)
+	if err := cmd.Run(ctx); err != nil {
+		return 0, errors.Wrapf(err, "failed to read %s: %s", loadbalancer.PIDFilePath, stderr.String())
+	}
+
+	fields := strings.Fields(stdout.String())
+	if len(fields) == 0 {
+		return 0, errors.Errorf("%s is empty", loadbalancer.PIDFilePath)
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || pid <= 0 {
+		return 0, errors.Errorf("%s does not contain a valid PID: %q", loadbalancer.PIDFilePath, fields[0])
+	}
+	return pid, nil
+}
+
+// configApplierOrDefault returns applier, or the HAProxy applier writing the configuration with the given
+// owner if it is nil.
+func configApplierOrDefault(applier ConfigApplier, owner string) ConfigApplier {
+	if applier == nil {
+		return HAProxyApplier{Owner: owner}
+	}
+	return applier
+}
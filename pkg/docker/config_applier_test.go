@@ -0,0 +1,157 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+func TestHAProxyApplierApply(t *testing.T) {
+	config := "global\n  log stdout format raw local0 info\n"
+	checkCommand := "haproxy -c -f " + stagedConfigPath
+
+	written := func(g *WithT, containerRuntime *container.FakeRuntime) map[string]string {
+		files := map[string]string{}
+		for _, call := range containerRuntime.ExecContainerCalls() {
+			if call.Command == "cp" {
+				data, err := io.ReadAll(call.Config.InputBuffer)
+				g.Expect(err).ToNot(HaveOccurred())
+				files[call.Args[len(call.Args)-1]] = string(data)
+			}
+		}
+		return files
+	}
+
+	t.Run("validates, writes and reloads the configuration", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime := &container.FakeRuntime{}
+		ctx := container.RuntimeInto(context.Background(), containerRuntime)
+		containerRuntime.ResetExecContainerCallLogs()
+		containerRuntime.ResetKillContainerCallLogs()
+
+		node := types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue)
+		g.Expect(HAProxyApplier{}.Apply(ctx, node, config)).To(Succeed())
+
+		g.Expect(written(g, containerRuntime)).To(Equal(map[string]string{
+			stagedConfigPath:        config,
+			loadbalancer.ConfigPath: config,
+		}))
+		g.Expect(containerRuntime.KillContainerCalls()).To(HaveLen(1))
+	})
+
+	t.Run("writes the configuration with the owner", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime := &container.FakeRuntime{}
+		ctx := container.RuntimeInto(context.Background(), containerRuntime)
+		containerRuntime.ResetExecContainerCallLogs()
+		containerRuntime.ResetKillContainerCallLogs()
+
+		node := types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue)
+		g.Expect(HAProxyApplier{Owner: "haproxy:haproxy"}.Apply(ctx, node, config)).To(Succeed())
+
+		var chowned []string
+		for _, call := range containerRuntime.ExecContainerCalls() {
+			if call.Command == "chown" {
+				chowned = append(chowned, strings.Join(call.Args, " "))
+			}
+		}
+		g.Expect(chowned).To(Equal([]string{"haproxy:haproxy " + loadbalancer.ConfigPath}))
+	})
+
+	t.Run("does not write an invalid configuration", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime := &container.FakeRuntime{}
+		ctx := container.RuntimeInto(context.Background(), containerRuntime)
+		containerRuntime.ResetExecContainerCallLogs()
+		containerRuntime.ResetKillContainerCallLogs()
+		containerRuntime.SetExecContainerErrors(map[string]error{checkCommand: errors.New("exit status 1")})
+		defer containerRuntime.SetExecContainerErrors(nil)
+
+		node := types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue)
+		err := HAProxyApplier{}.Apply(ctx, node, config)
+		g.Expect(err).To(MatchError(ContainSubstring("invalid load balancer configuration")))
+		g.Expect(written(g, containerRuntime)).ToNot(HaveKey(loadbalancer.ConfigPath))
+		g.Expect(containerRuntime.KillContainerCalls()).To(BeEmpty())
+	})
+}
+
+func TestHAProxyApplierReload(t *testing.T) {
+	tests := []struct {
+		name        string
+		pidFile     string
+		wantKillPID string
+	}{
+		{
+			name:        "signals the master process",
+			pidFile:     "42\n",
+			wantKillPID: "42",
+		},
+		{
+			name:    "signals the container when HAProxy is PID 1",
+			pidFile: "1\n",
+		},
+		{
+			name: "signals the container when the PID file is missing",
+		},
+		{
+			name:    "signals the container when the PID file is invalid",
+			pidFile: "not-a-pid\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.ResetExecContainerCallLogs()
+			containerRuntime.ResetKillContainerCallLogs()
+			if tt.pidFile != "" {
+				containerRuntime.SetExecContainerOutputs(map[string]string{"cat": tt.pidFile})
+				defer containerRuntime.SetExecContainerOutputs(nil)
+			}
+
+			node := types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue)
+			g.Expect(HAProxyApplier{}.Reload(ctx, node)).To(Succeed())
+
+			var killArgs [][]string
+			for _, call := range containerRuntime.ExecContainerCalls() {
+				if call.Command == "kill" {
+					killArgs = append(killArgs, call.Args)
+				}
+			}
+			if tt.wantKillPID != "" {
+				g.Expect(killArgs).To(Equal([][]string{{"-HUP", tt.wantKillPID}}))
+				g.Expect(containerRuntime.KillContainerCalls()).To(BeEmpty())
+				return
+			}
+			g.Expect(killArgs).To(BeEmpty())
+			g.Expect(containerRuntime.KillContainerCalls()).To(HaveLen(1))
+			g.Expect(containerRuntime.KillContainerCalls()[0].Signal).To(Equal("SIGHUP"))
+		})
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+// ConfigDataProvider assembles the configuration data rendered into the load balancer of a cluster,
+// with all its frontends and backends.
+type ConfigDataProvider interface {
+	// ConfigData returns the configuration data of the cluster load balancer. controlPlane is the data
+	// routing the control plane frontend to the discovered control plane nodes, as derived from the
+	// DockerCluster spec, for the provider to extend; it can be modified.
+	ConfigData(ctx context.Context, clusterName string, controlPlane loadbalancer.ConfigData) (loadbalancer.ConfigData, error)
+}
+
+// ControlPlaneConfigDataProvider configures the control plane frontend only. This is the default provider.
+type ControlPlaneConfigDataProvider struct{}
+
+// ConfigData returns the control plane configuration data unchanged.
+func (ControlPlaneConfigDataProvider) ConfigData(_ context.Context, _ string, controlPlane loadbalancer.ConfigData) (loadbalancer.ConfigData, error) {
+	return controlPlane, nil
+}
+
+// IngressConfigDataProvider adds fixed backend pools to the control plane configuration, e.g. routing
+// ingress ports to the worker nodes. The pools are served by the control plane frontend, on their port
+// or server name, like the DockerClusterSpec.LoadBalancerBackendPools.
+type IngressConfigDataProvider struct {
+	// Pools are the backend pools to add.
+	Pools []loadbalancer.BackendPool
+}
+
+// ConfigData returns the control plane configuration data with the pools added.
+func (p IngressConfigDataProvider) ConfigData(_ context.Context, _ string, controlPlane loadbalancer.ConfigData) (loadbalancer.ConfigData, error) {
+	pools := make([]loadbalancer.BackendPool, 0, len(controlPlane.BackendPools)+len(p.Pools))
+	controlPlane.BackendPools = append(append(pools, controlPlane.BackendPools...), p.Pools...)
+	return controlPlane, nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+// failingConfigDataProvider is a ConfigDataProvider failing to assemble the configuration data.
+type failingConfigDataProvider struct{}
+
+func (failingConfigDataProvider) ConfigData(_ context.Context, _ string, _ loadbalancer.ConfigData) (loadbalancer.ConfigData, error) {
+	return loadbalancer.ConfigData{}, errors.New("ingress nodes not found")
+}
+
+func TestLoadBalancerConfigDataProvider(t *testing.T) {
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer containerRuntime.SetListContainersResult(nil)
+	ingress := loadbalancer.BackendPool{Name: "ingress", Port: 443, Servers: map[string]string{"test-cluster-md-1": "172.18.0.5:30443"}}
+
+	newLoadBalancer := func() *LoadBalancer {
+		return &LoadBalancer{
+			name:      "test-cluster",
+			config:    getLoadBalancerConfigData(nil),
+			container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		}
+	}
+
+	t.Run("configures the control plane frontend by default", func(t *testing.T) {
+		g := NewWithT(t)
+		data, err := newLoadBalancer().EffectiveConfigData(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(data.BackendServers).To(HaveKey("test-cluster-cp-1"))
+		g.Expect(data.BackendPools).To(BeEmpty())
+	})
+
+	t.Run("renders the data assembled by the provider", func(t *testing.T) {
+		g := NewWithT(t)
+		lb := newLoadBalancer()
+		applier := &fakeConfigApplier{}
+		lb.SetConfigApplier(applier)
+		lb.SetConfigDataProvider(IngressConfigDataProvider{Pools: []loadbalancer.BackendPool{ingress}})
+
+		_, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(applier.applied).To(HaveLen(1))
+		parsed, err := loadbalancer.ParseConfig([]byte(applier.applied[0]))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(parsed.Frontend("control-plane").Binds).To(ContainElement("*:443"))
+		g.Expect(parsed.Backend(loadbalancer.BackendName).Servers).To(HaveLen(1))
+		g.Expect(parsed.Backend("ingress").Servers[0].Address).To(Equal("172.18.0.5:30443"))
+	})
+
+	t.Run("keeps the pools of the spec", func(t *testing.T) {
+		g := NewWithT(t)
+		controlPlane := loadbalancer.ConfigData{BackendPools: []loadbalancer.BackendPool{{Name: "readers", Port: 7443}}}
+		data, err := IngressConfigDataProvider{Pools: []loadbalancer.BackendPool{ingress}}.ConfigData(ctx, "test-cluster", controlPlane)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(data.BackendPools).To(HaveLen(2))
+		g.Expect(data.BackendPools[0].Name).To(Equal("readers"))
+		g.Expect(data.BackendPools[1].Name).To(Equal("ingress"))
+		g.Expect(controlPlane.BackendPools).To(HaveLen(1))
+	})
+
+	t.Run("fails the update when the provider fails", func(t *testing.T) {
+		g := NewWithT(t)
+		lb := newLoadBalancer()
+		lb.SetConfigDataProvider(failingConfigDataProvider{})
+
+		_, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).To(MatchError(ContainSubstring("failed to assemble load balancer configuration data: ingress nodes not found")))
+	})
+}
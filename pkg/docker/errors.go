@@ -27,3 +27,28 @@ type ContainerNotRunningError struct {
 func (cse ContainerNotRunningError) Error() string {
 	return fmt.Sprintf("container with name %q is not running", cse.Name)
 }
+
+// OrphanedLoadBalancerError is returned when creating a load balancer whose container name is taken by a
+// container owned by another DockerCluster, e.g. one left behind by a deleted DockerCluster with the same name.
+type OrphanedLoadBalancerError struct {
+	Name     string
+	OwnerUID string
+}
+
+// Error returns the error string.
+func (e OrphanedLoadBalancerError) Error() string {
+	return fmt.Sprintf("load balancer container %q is owned by DockerCluster with UID %s, remove it to create the load balancer", e.Name, e.OwnerUID)
+}
+
+// ZeroBackendsUnconfirmedError is returned when updating a load balancer configuration would remove all
+// its backends before enough consecutive discoveries confirmed there are none.
+type ZeroBackendsUnconfirmedError struct {
+	Name     string
+	Observed int
+	Required int
+}
+
+// Error returns the error string.
+func (e ZeroBackendsUnconfirmedError) Error() string {
+	return fmt.Sprintf("discovered no control plane nodes for load balancer %q %d of %d times, keeping the current backends", e.Name, e.Observed, e.Required)
+}
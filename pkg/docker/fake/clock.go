@@ -0,0 +1,70 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake implements fakes of the docker package dependencies for unit tests.
+package fake
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a fake implementation of docker.Clock. Waiting on it does not sleep: After advances
+// the time by the requested duration and returns a channel that is already signaled, so code
+// retrying until a deadline runs through all its attempts immediately and deterministically.
+type Clock struct {
+	lock  sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+// NewClock returns a fake clock set to the given time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the fake clock.
+func (c *Clock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// After advances the fake clock by d and returns a channel signaled with the new time.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// Step advances the fake clock by d without recording a wait.
+func (c *Clock) Step(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Waits returns the durations passed to After, in order.
+func (c *Clock) Waits() []time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestClock(t *testing.T) {
+	g := NewWithT(t)
+	start := time.Date(2022, 10, 24, 9, 30, 0, 0, time.UTC)
+	clock := NewClock(start)
+	g.Expect(clock.Now()).To(Equal(start))
+
+	select {
+	case now := <-clock.After(time.Second):
+		g.Expect(now).To(Equal(start.Add(time.Second)))
+	default:
+		t.Fatal("After did not fire immediately")
+	}
+
+	clock.Step(time.Minute)
+	g.Expect(clock.Now()).To(Equal(start.Add(time.Minute + time.Second)))
+	g.Expect(clock.Waits()).To(Equal([]time.Duration{time.Second}))
+}
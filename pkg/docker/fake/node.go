@@ -0,0 +1,163 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+)
+
+// Node is a fake of the container behind a types.Node. types.Node talks to its container through the
+// container runtime of the context, so the fake is a container.Runtime: put it in the context with
+// container.RuntimeInto and the IP, ReadFile, WriteFile and Kill methods of the node it is named after
+// return the programmed results and are recorded. The files written are kept in memory, so they can
+// be read back. Any other call, or a call for another container, is served by container.FakeRuntime.
+type Node struct {
+	container.FakeRuntime
+
+	// Name is the name of the container.
+	Name string
+	// IPv4 and IPv6 are the addresses returned by IP.
+	IPv4 string
+	IPv6 string
+	// IPErr, WriteFileErr and KillErr are returned by the IP, WriteFile and Kill methods when set.
+	IPErr        error
+	WriteFileErr error
+	KillErr      error
+
+	lock   sync.Mutex
+	files  map[string]string
+	writes []string
+	kills  []string
+}
+
+// NewNode returns a fake container with the given name and IPv4 address.
+func NewNode(name, ipv4 string) *Node {
+	return &Node{Name: name, IPv4: ipv4, files: map[string]string{}}
+}
+
+// Node returns a types.Node for the fake container with the given role.
+func (n *Node) Node(role string) *types.Node {
+	return types.NewNode(n.Name, "", role)
+}
+
+// SetFile sets the content of a file in the fake container.
+func (n *Node) SetFile(path, content string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.files[path] = content
+}
+
+// File returns the content of a file in the fake container, and whether it exists.
+func (n *Node) File(path string) (string, bool) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	content, ok := n.files[path]
+	return content, ok
+}
+
+// Writes returns the paths of the files written, in order.
+func (n *Node) Writes() []string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return append([]string(nil), n.writes...)
+}
+
+// Kills returns the signals sent to the container, in order.
+func (n *Node) Kills() []string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return append([]string(nil), n.kills...)
+}
+
+// GetContainerIPs returns the programmed addresses of the fake container.
+func (n *Node) GetContainerIPs(ctx context.Context, containerName string) (string, string, error) {
+	if containerName != n.Name {
+		return n.FakeRuntime.GetContainerIPs(ctx, containerName)
+	}
+	if n.IPErr != nil {
+		return "", "", n.IPErr
+	}
+	return n.IPv4, n.IPv6, nil
+}
+
+// ExecContainer serves the commands types.Node runs to read and write files in the fake container.
+func (n *Node) ExecContainer(ctx context.Context, containerName string, config *container.ExecContainerInput, command string, args ...string) error {
+	if containerName == n.Name {
+		if handled, err := n.execFileCommand(config, command, args); handled {
+			return err
+		}
+	}
+	return n.FakeRuntime.ExecContainer(ctx, containerName, config, command, args...)
+}
+
+// execFileCommand runs the file commands of types.Node against the files of the fake container.
+// It returns false for any other command.
+func (n *Node) execFileCommand(config *container.ExecContainerInput, command string, args []string) (bool, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	switch {
+	case command == "cp" && len(args) == 2 && args[0] == "/dev/stdin":
+		if n.WriteFileErr != nil {
+			return true, n.WriteFileErr
+		}
+		var content bytes.Buffer
+		if config.InputBuffer != nil {
+			if _, err := io.Copy(&content, config.InputBuffer); err != nil {
+				return true, errors.WithStack(err)
+			}
+		}
+		n.files[args[1]] = content.String()
+		n.writes = append(n.writes, args[1])
+		return true, nil
+	case command == "cat" && len(args) == 1:
+		content, ok := n.files[args[0]]
+		if !ok {
+			if config.ErrorBuffer != nil {
+				_, _ = io.WriteString(config.ErrorBuffer, "No such file or directory")
+			}
+			return true, errors.New("exit status 1")
+		}
+		if config.OutputBuffer != nil {
+			_, _ = io.WriteString(config.OutputBuffer, content)
+		}
+		return true, nil
+	case command == "mkdir", command == "chmod", command == "chown":
+		return true, nil
+	}
+	return false, nil
+}
+
+// KillContainer records the signal sent to the fake container.
+func (n *Node) KillContainer(ctx context.Context, containerName, signal string) error {
+	if containerName != n.Name {
+		return n.FakeRuntime.KillContainer(ctx, containerName, signal)
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.kills = append(n.kills, signal)
+	return n.KillErr
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+)
+
+func TestNode(t *testing.T) {
+	t.Run("serves the files, IP and signals of the container", func(t *testing.T) {
+		g := NewWithT(t)
+		fakeNode := NewNode("test-cluster-lb", "172.18.0.2")
+		ctx := container.RuntimeInto(context.Background(), fakeNode)
+		node := fakeNode.Node(constants.ExternalLoadBalancerNodeRoleValue)
+		g.Expect(node.String()).To(Equal("test-cluster-lb"))
+
+		g.Expect(node.IP(ctx)).To(Equal("172.18.0.2"))
+
+		_, err := node.ReadFile(ctx, "/etc/haproxy.cfg")
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(node.WriteFile(ctx, "/etc/haproxy.cfg", "global\n")).To(Succeed())
+		g.Expect(node.ReadFile(ctx, "/etc/haproxy.cfg")).To(Equal("global\n"))
+		g.Expect(fakeNode.Writes()).To(Equal([]string{"/etc/haproxy.cfg"}))
+
+		g.Expect(node.Kill(ctx, "SIGHUP")).To(Succeed())
+		g.Expect(fakeNode.Kills()).To(Equal([]string{"SIGHUP"}))
+	})
+
+	t.Run("returns the programmed errors", func(t *testing.T) {
+		g := NewWithT(t)
+		fakeNode := NewNode("test-cluster-lb", "172.18.0.2")
+		fakeNode.IPErr = errors.New("no such container")
+		fakeNode.WriteFileErr = errors.New("read-only file system")
+		fakeNode.KillErr = errors.New("container is not running")
+		ctx := container.RuntimeInto(context.Background(), fakeNode)
+		node := fakeNode.Node(constants.ExternalLoadBalancerNodeRoleValue)
+
+		_, err := node.IP(ctx)
+		g.Expect(err).To(MatchError(ContainSubstring("no such container")))
+		g.Expect(node.WriteFile(ctx, "/etc/haproxy.cfg", "global\n")).To(MatchError(ContainSubstring("read-only file system")))
+		g.Expect(fakeNode.Writes()).To(BeEmpty())
+		g.Expect(node.Kill(ctx, "SIGHUP")).To(MatchError(ContainSubstring("container is not running")))
+	})
+
+	t.Run("delegates other containers to the fake runtime", func(t *testing.T) {
+		g := NewWithT(t)
+		fakeNode := NewNode("test-cluster-lb", "172.18.0.2")
+		ctx := container.RuntimeInto(context.Background(), fakeNode)
+		fakeNode.ResetKillContainerCallLogs()
+
+		other := NewNode("test-cluster-cp-1", "").Node(constants.ControlPlaneNodeRoleValue)
+		g.Expect(other.IP(ctx)).To(Equal("test-cluster-cp-1IPv4"))
+		g.Expect(other.Kill(ctx, "SIGHUP")).To(Succeed())
+		g.Expect(fakeNode.Kills()).To(BeEmpty())
+		g.Expect(fakeNode.KillContainerCalls()).To(HaveLen(1))
+	})
+}
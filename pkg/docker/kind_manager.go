@@ -26,8 +26,10 @@ import (
 	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
 	"sigs.k8s.io/kind/pkg/cluster/constants"
 
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
 	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
 	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
@@ -35,13 +37,13 @@ import (
 const KubeadmContainerPort = 6443
 
 // ControlPlanePort is the port for accessing the control plane API in the container.
-const ControlPlanePort = 6443
+const ControlPlanePort = loadbalancer.ControlPlanePort
 
 // HAProxyStatusPort is the port for accessing the HAPProxy status in the container.
-const HAProxyStatusPort = 8404
+const HAProxyStatusPort = loadbalancer.StatsPort
 
 // DefaultNetwork is the default network name to use in kind.
-const DefaultNetwork = "kind"
+const DefaultNetwork = infrav1.DefaultNetwork
 
 // Manager is the kind manager type.
 type Manager struct{}
@@ -55,10 +57,81 @@ type nodeCreateOpts struct {
 	PortMappings []v1alpha4.PortMapping
 	Labels       map[string]string
 	IPFamily     clusterv1.ClusterIPFamily
+	Network      string
+	CommandArgs  []string
+	Sysctls      map[string]string
+	CapAdd       []string
+	CapDrop      []string
+	Hostname     string
+	ExtraHosts   []string
+	Privileged   bool
+	LogDriver    string
+	LogOptions   map[string]string
+	Env          map[string]string
+	// ReadOnlyRootFS mounts the root filesystem read-only; Volumes are writable in addition to the
+	// volume and tmpfs mounts of every node.
+	ReadOnlyRootFS bool
+	Volumes        []string
+	// AdditionalNetworks are the networks the container is connected to in addition to Network.
+	AdditionalNetworks []string
+	// RestartPolicy is the restart policy of the container; the runtime uses unless-stopped when empty.
+	RestartPolicy string
+
+	// PlacementConstraints are the scheduling filters of multi-host runtimes placing the container.
+	PlacementConstraints []string
+}
+
+// ExternalLoadBalancerNodeOptions contains the settings for creating a load balancer container.
+type ExternalLoadBalancerNodeOptions struct {
+	Name          string
+	Image         string
+	ClusterName   string
+	ListenAddress string
+	// Port is the host port the load balancer is published on; a random port is used when zero.
+	Port int32
+	// ContainerPort is the port the load balancer frontend binds to; defaults to ControlPlanePort when zero.
+	ContainerPort int32
+	// Network is the docker network the container is attached to; defaults to DefaultNetwork when empty.
+	Network string
+	// AdditionalNetworks are the docker networks the container is connected to in addition to Network,
+	// e.g. a management network; they must exist.
+	AdditionalNetworks []string
+	// CommandArgs overrides the command of the container; the image default is used when empty.
+	CommandArgs []string
+	// Sysctls are the namespaced kernel parameters to set in the container.
+	Sysctls map[string]string
+	// CapAdd and CapDrop are the Linux capabilities to add to and drop from the container;
+	// the runtime defaults are used when empty.
+	CapAdd  []string
+	CapDrop []string
+	// Hostname is the hostname of the container; defaults to Name when empty.
+	Hostname string
+	// ExtraHosts are additional /etc/hosts entries of the container, in the name:ip format.
+	ExtraHosts []string
+	// Privileged runs the container in privileged mode; capabilities are ignored when set.
+	Privileged bool
+	// LogDriver and LogOptions configure the log driver of the container; the runtime default is used when empty.
+	LogDriver  string
+	LogOptions map[string]string
+	// Env are environment variables to set in the container.
+	Env map[string]string
+	// Labels are additional labels to apply to the container.
+	Labels map[string]string
+	// ReadOnlyRootFS mounts the root filesystem of the container read-only, keeping the HAProxy
+	// configuration directory writable on a volume.
+	ReadOnlyRootFS bool
+	// RestartPolicy is the restart policy of the container, one of no, on-failure or unless-stopped;
+	// unless-stopped is used when empty.
+	RestartPolicy string
+
+	// PlacementConstraints are the scheduling filters placing the container on multi-host runtimes,
+	// e.g. Docker Swarm standalone; a single docker daemon ignores them.
+	PlacementConstraints []string
 }
 
-// CreateControlPlaneNode will create a new control plane container.
-func (m *Manager) CreateControlPlaneNode(ctx context.Context, name, image, clusterName, listenAddress string, port int32, mounts []v1alpha4.Mount, portMappings []v1alpha4.PortMapping, labels map[string]string, ipFamily clusterv1.ClusterIPFamily) (*types.Node, error) {
+// CreateControlPlaneNode will create a new control plane container on the given docker network; DefaultNetwork
+// is used when empty.
+func (m *Manager) CreateControlPlaneNode(ctx context.Context, name, image, clusterName, network, listenAddress string, port int32, mounts []v1alpha4.Mount, portMappings []v1alpha4.PortMapping, labels map[string]string, ipFamily clusterv1.ClusterIPFamily) (*types.Node, error) {
 	// gets a random host port for the API server
 	if port == 0 {
 		p, err := getPort()
@@ -84,6 +157,13 @@ func (m *Manager) CreateControlPlaneNode(ctx context.Context, name, image, clust
 		Mounts:       mounts,
 		Labels:       labels,
 		IPFamily:     ipFamily,
+		Network:      network,
+		// Running containers in a container requires privileges.
+		// NOTE: we could try to replicate this with --cap-add, and use less
+		// privileges, but this flag also changes some mounts that are necessary
+		// including some ones docker would otherwise do by default.
+		// for now this is what we want. in the future we may revisit this.
+		Privileged: true,
 	}
 	node, err := createNode(ctx, createOpts)
 	if err != nil {
@@ -93,8 +173,8 @@ func (m *Manager) CreateControlPlaneNode(ctx context.Context, name, image, clust
 	return node, nil
 }
 
-// CreateWorkerNode will create a new worker container.
-func (m *Manager) CreateWorkerNode(ctx context.Context, name, image, clusterName string, mounts []v1alpha4.Mount, portMappings []v1alpha4.PortMapping, labels map[string]string, ipFamily clusterv1.ClusterIPFamily) (*types.Node, error) {
+// CreateWorkerNode will create a new worker container on the given docker network; DefaultNetwork is used when empty.
+func (m *Manager) CreateWorkerNode(ctx context.Context, name, image, clusterName, network string, mounts []v1alpha4.Mount, portMappings []v1alpha4.PortMapping, labels map[string]string, ipFamily clusterv1.ClusterIPFamily) (*types.Node, error) {
 	createOpts := &nodeCreateOpts{
 		Name:         name,
 		Image:        image,
@@ -104,12 +184,17 @@ func (m *Manager) CreateWorkerNode(ctx context.Context, name, image, clusterName
 		Mounts:       mounts,
 		Labels:       labels,
 		IPFamily:     ipFamily,
+		Network:      network,
+		// Running containers in a container requires privileges, see CreateControlPlaneNode.
+		Privileged: true,
 	}
 	return createNode(ctx, createOpts)
 }
 
 // CreateExternalLoadBalancerNode will create a new container to act as the load balancer for external access.
-func (m *Manager) CreateExternalLoadBalancerNode(ctx context.Context, name, image, clusterName, listenAddress string, port int32) (*types.Node, error) {
+func (m *Manager) CreateExternalLoadBalancerNode(ctx context.Context, opts ExternalLoadBalancerNodeOptions) (*types.Node, error) {
+	port, containerPort, listenAddress := opts.Port, opts.ContainerPort, opts.ListenAddress
+
 	// gets a random host port for control-plane load balancer
 	// gets a random host port for the API server
 	if port == 0 {
@@ -119,6 +204,9 @@ func (m *Manager) CreateExternalLoadBalancerNode(ctx context.Context, name, imag
 		}
 		port = p
 	}
+	if containerPort == 0 {
+		containerPort = ControlPlanePort
+	}
 
 	// get a random port for the status page
 	p, err := getPort()
@@ -132,7 +220,7 @@ func (m *Manager) CreateExternalLoadBalancerNode(ctx context.Context, name, imag
 		{
 			ListenAddress: listenAddress,
 			HostPort:      port,
-			ContainerPort: ControlPlanePort,
+			ContainerPort: containerPort,
 			Protocol:      v1alpha4.PortMappingProtocolTCP,
 		},
 		{
@@ -143,11 +231,32 @@ func (m *Manager) CreateExternalLoadBalancerNode(ctx context.Context, name, imag
 		},
 	}
 	createOpts := &nodeCreateOpts{
-		Name:         name,
-		Image:        image,
-		ClusterName:  clusterName,
-		Role:         constants.ExternalLoadBalancerNodeRoleValue,
-		PortMappings: portMappings,
+		Name:                 opts.Name,
+		Image:                opts.Image,
+		ClusterName:          opts.ClusterName,
+		Role:                 constants.ExternalLoadBalancerNodeRoleValue,
+		PortMappings:         portMappings,
+		Network:              opts.Network,
+		AdditionalNetworks:   opts.AdditionalNetworks,
+		CommandArgs:          opts.CommandArgs,
+		Sysctls:              opts.Sysctls,
+		CapAdd:               opts.CapAdd,
+		CapDrop:              opts.CapDrop,
+		Hostname:             opts.Hostname,
+		ExtraHosts:           opts.ExtraHosts,
+		Privileged:           opts.Privileged,
+		LogDriver:            opts.LogDriver,
+		LogOptions:           opts.LogOptions,
+		Env:                  opts.Env,
+		Labels:               opts.Labels,
+		RestartPolicy:        opts.RestartPolicy,
+		PlacementConstraints: opts.PlacementConstraints,
+	}
+	if opts.ReadOnlyRootFS {
+		createOpts.ReadOnlyRootFS = true
+		// The volume is initialized with the content of the image, so HAProxy starts with its default
+		// configuration until the provider writes its own.
+		createOpts.Volumes = []string{loadbalancer.ConfigDir}
 	}
 	node, err := createNode(ctx, createOpts)
 	if err != nil {
@@ -162,13 +271,18 @@ func createNode(ctx context.Context, opts *nodeCreateOpts) (*types.Node, error)
 
 	// Collect the labels to apply to the container
 	containerLabels := map[string]string{
-		clusterLabelKey:  opts.ClusterName,
-		nodeRoleLabelKey: opts.Role,
+		ClusterLabelKey:  opts.ClusterName,
+		NodeRoleLabelKey: opts.Role,
 	}
 	for name, value := range opts.Labels {
 		containerLabels[name] = value
 	}
 
+	network := opts.Network
+	if network == "" {
+		network = DefaultNetwork
+	}
+
 	runOptions := &container.RunContainerInput{
 		Name:   opts.Name, // make hostname match container name
 		Image:  opts.Image,
@@ -178,15 +292,32 @@ func createNode(ctx context.Context, opts *nodeCreateOpts) (*types.Node, error)
 		// filesystem, which is not only better for performance, but allows
 		// running kind in kind for "party tricks"
 		// (please don't depend on doing this though!)
-		Volumes:      map[string]string{"/var": ""},
-		Mounts:       generateMountInfo(opts.Mounts),
-		PortMappings: generatePortMappings(opts.PortMappings),
-		Network:      DefaultNetwork,
+		Volumes:            map[string]string{"/var": ""},
+		Mounts:             generateMountInfo(opts.Mounts),
+		PortMappings:       generatePortMappings(opts.PortMappings),
+		Network:            network,
+		AdditionalNetworks: opts.AdditionalNetworks,
 		Tmpfs: map[string]string{
 			"/tmp": "", // various things depend on working /tmp
 			"/run": "", // systemd wants a writable /run
 		},
-		IPFamily: opts.IPFamily,
+		IPFamily:             opts.IPFamily,
+		CommandArgs:          opts.CommandArgs,
+		Sysctls:              opts.Sysctls,
+		CapAdd:               opts.CapAdd,
+		CapDrop:              opts.CapDrop,
+		Hostname:             opts.Hostname,
+		ExtraHosts:           opts.ExtraHosts,
+		Privileged:           opts.Privileged,
+		LogDriver:            opts.LogDriver,
+		LogOptions:           opts.LogOptions,
+		EnvironmentVars:      opts.Env,
+		ReadOnlyRootFS:       opts.ReadOnlyRootFS,
+		RestartPolicy:        opts.RestartPolicy,
+		PlacementConstraints: opts.PlacementConstraints,
+	}
+	for _, volume := range opts.Volumes {
+		runOptions.Volumes[volume] = ""
 	}
 	log.V(6).Info("Container run options: %+v", runOptions)
 
@@ -200,7 +331,8 @@ func createNode(ctx context.Context, opts *nodeCreateOpts) (*types.Node, error)
 		return nil, err
 	}
 
-	return types.NewNode(opts.Name, opts.Image, opts.Role), nil
+	// Return the labels too, so the new container is not mistaken for one missing them, see LoadBalancer.Adopt.
+	return types.NewNode(opts.Name, opts.Image, opts.Role).WithLabels(containerLabels), nil
 }
 
 // helper used to get a free TCP port for the API server.
@@ -25,6 +25,7 @@ import (
 	"sigs.k8s.io/kind/pkg/cluster/constants"
 
 	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
@@ -76,7 +77,7 @@ func TestCreateControlPlaneNode(t *testing.T) {
 
 	containerRuntime.ResetRunContainerCallLogs()
 	m := Manager{}
-	node, err := m.CreateControlPlaneNode(ctx, "TestName", "TestImage", "TestCluster", "100.100.100.100", 80, []v1alpha4.Mount{}, []v1alpha4.PortMapping{}, make(map[string]string), clusterv1.IPv4IPFamily)
+	node, err := m.CreateControlPlaneNode(ctx, "TestName", "TestImage", "TestCluster", "cluster-network", "100.100.100.100", 80, []v1alpha4.Mount{}, []v1alpha4.PortMapping{}, make(map[string]string), clusterv1.IPv4IPFamily)
 
 	g.Expect(err).ShouldNot(HaveOccurred())
 	g.Expect(node.Role()).Should(Equal(constants.ControlPlaneNodeRoleValue))
@@ -89,6 +90,8 @@ func TestCreateControlPlaneNode(t *testing.T) {
 	g.Expect(runConfig).ToNot(BeNil())
 	g.Expect(runConfig.Labels).To(HaveLen(2))
 	g.Expect(runConfig.Labels["io.x-k8s.kind.role"]).To(Equal(constants.ControlPlaneNodeRoleValue))
+	g.Expect(runConfig.Privileged).To(BeTrue())
+	g.Expect(runConfig.Network).To(Equal("cluster-network"))
 }
 
 func TestCreateWorkerNode(t *testing.T) {
@@ -99,7 +102,7 @@ func TestCreateWorkerNode(t *testing.T) {
 
 	containerRuntime.ResetRunContainerCallLogs()
 	m := Manager{}
-	node, err := m.CreateWorkerNode(ctx, "TestName", "TestImage", "TestCluster", []v1alpha4.Mount{}, []v1alpha4.PortMapping{}, make(map[string]string), clusterv1.IPv4IPFamily)
+	node, err := m.CreateWorkerNode(ctx, "TestName", "TestImage", "TestCluster", "", []v1alpha4.Mount{}, []v1alpha4.PortMapping{}, make(map[string]string), clusterv1.IPv4IPFamily)
 
 	g.Expect(err).ShouldNot(HaveOccurred())
 	g.Expect(node.Role()).Should(Equal(constants.WorkerNodeRoleValue))
@@ -112,6 +115,8 @@ func TestCreateWorkerNode(t *testing.T) {
 	g.Expect(runConfig).ToNot(BeNil())
 	g.Expect(runConfig.Labels).To(HaveLen(2))
 	g.Expect(runConfig.Labels["io.x-k8s.kind.role"]).To(Equal(constants.WorkerNodeRoleValue))
+	g.Expect(runConfig.Privileged).To(BeTrue())
+	g.Expect(runConfig.Network).To(Equal(DefaultNetwork))
 }
 
 func TestCreateExternalLoadBalancerNode(t *testing.T) {
@@ -122,7 +127,12 @@ func TestCreateExternalLoadBalancerNode(t *testing.T) {
 
 	containerRuntime.ResetRunContainerCallLogs()
 	m := Manager{}
-	node, err := m.CreateExternalLoadBalancerNode(ctx, "TestName", "TestImage", "TestCluster", "100.100.100.100", 0)
+	node, err := m.CreateExternalLoadBalancerNode(ctx, ExternalLoadBalancerNodeOptions{
+		Name:          "TestName",
+		Image:         "TestImage",
+		ClusterName:   "TestCluster",
+		ListenAddress: "100.100.100.100",
+	})
 
 	g.Expect(err).ShouldNot(HaveOccurred())
 	g.Expect(node.Role()).Should(Equal(constants.ExternalLoadBalancerNodeRoleValue))
@@ -135,4 +145,87 @@ func TestCreateExternalLoadBalancerNode(t *testing.T) {
 	g.Expect(runConfig).ToNot(BeNil())
 	g.Expect(runConfig.Labels).To(HaveLen(2))
 	g.Expect(runConfig.Labels["io.x-k8s.kind.role"]).To(Equal(constants.ExternalLoadBalancerNodeRoleValue))
+	g.Expect(runConfig.PortMappings).To(HaveLen(2))
+	g.Expect(runConfig.PortMappings[0].ContainerPort).To(Equal(int32(ControlPlanePort)))
+	g.Expect(runConfig.Network).To(Equal(DefaultNetwork))
+	g.Expect(runConfig.Privileged).To(BeFalse())
+	g.Expect(runConfig.ReadOnlyRootFS).To(BeFalse())
+	g.Expect(runConfig.Volumes).ToNot(HaveKey(loadbalancer.ConfigDir))
+}
+
+func TestCreateExternalLoadBalancerNodeOptions(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetRunContainerCallLogs()
+
+	m := Manager{}
+	node, err := m.CreateExternalLoadBalancerNode(ctx, ExternalLoadBalancerNodeOptions{
+		Name:               "TestName",
+		Image:              "TestImage",
+		ClusterName:        "TestCluster",
+		Network:            "test-network",
+		CommandArgs:        []string{"-d"},
+		Sysctls:            map[string]string{"net.core.somaxconn": "4096"},
+		CapAdd:             []string{"NET_BIND_SERVICE"},
+		CapDrop:            []string{"ALL"},
+		Hostname:           "test-lb",
+		ExtraHosts:         []string{"apiserver.test:172.18.0.3"},
+		Privileged:         true,
+		LogDriver:          "fluentd",
+		LogOptions:         map[string]string{"fluentd-address": "localhost:24224"},
+		Env:                map[string]string{"HAPROXY_DEBUG": "1"},
+		Labels:             map[string]string{ownerUIDLabelKey: "uid-1"},
+		ReadOnlyRootFS:     true,
+		AdditionalNetworks: []string{"management"},
+		RestartPolicy:      "on-failure",
+	})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	callLog := containerRuntime.RunContainerCalls()
+	g.Expect(callLog).To(HaveLen(1))
+	g.Expect(callLog[0].RunConfig.Network).To(Equal("test-network"))
+	g.Expect(callLog[0].RunConfig.AdditionalNetworks).To(Equal([]string{"management"}))
+	g.Expect(callLog[0].RunConfig.CommandArgs).To(Equal([]string{"-d"}))
+	g.Expect(callLog[0].RunConfig.Sysctls).To(Equal(map[string]string{"net.core.somaxconn": "4096"}))
+	g.Expect(callLog[0].RunConfig.CapAdd).To(Equal([]string{"NET_BIND_SERVICE"}))
+	g.Expect(callLog[0].RunConfig.CapDrop).To(Equal([]string{"ALL"}))
+	g.Expect(callLog[0].RunConfig.Hostname).To(Equal("test-lb"))
+	g.Expect(callLog[0].RunConfig.ExtraHosts).To(Equal([]string{"apiserver.test:172.18.0.3"}))
+	g.Expect(callLog[0].RunConfig.Privileged).To(BeTrue())
+	g.Expect(callLog[0].RunConfig.LogDriver).To(Equal("fluentd"))
+	g.Expect(callLog[0].RunConfig.LogOptions).To(Equal(map[string]string{"fluentd-address": "localhost:24224"}))
+	g.Expect(callLog[0].RunConfig.EnvironmentVars).To(Equal(map[string]string{"HAPROXY_DEBUG": "1"}))
+	g.Expect(callLog[0].RunConfig.Labels).To(HaveKeyWithValue(ownerUIDLabelKey, "uid-1"))
+	g.Expect(callLog[0].RunConfig.Labels).To(HaveKeyWithValue(ClusterLabelKey, "TestCluster"))
+	g.Expect(callLog[0].RunConfig.ReadOnlyRootFS).To(BeTrue())
+	g.Expect(callLog[0].RunConfig.RestartPolicy).To(Equal("on-failure"))
+	g.Expect(callLog[0].RunConfig.Volumes).To(Equal(map[string]string{"/var": "", loadbalancer.ConfigDir: ""}))
+	g.Expect(node.Labels).To(Equal(callLog[0].RunConfig.Labels))
+}
+
+func TestCreateExternalLoadBalancerNodeFrontendPort(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetRunContainerCallLogs()
+
+	m := Manager{}
+	_, err := m.CreateExternalLoadBalancerNode(ctx, ExternalLoadBalancerNodeOptions{
+		Name:          "TestName",
+		Image:         "TestImage",
+		ClusterName:   "TestCluster",
+		ListenAddress: "100.100.100.100",
+		Port:          32765,
+		ContainerPort: 7443,
+	})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	callLog := containerRuntime.RunContainerCalls()
+	g.Expect(callLog).To(HaveLen(1))
+
+	portMappings := callLog[0].RunConfig.PortMappings
+	g.Expect(portMappings).To(HaveLen(2))
+	g.Expect(portMappings[0].HostPort).To(Equal(int32(32765)))
+	g.Expect(portMappings[0].ContainerPort).To(Equal(int32(7443)))
 }
@@ -17,33 +17,178 @@ limitations under the License.
 package docker
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/cluster-api/util/annotations"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/kind/pkg/cluster/constants"
 
 	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+	"github.com/beanlearninggo/cluster-api-provider-docker/feature"
 	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
 	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
 	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
+// LoadBalancerState is the state of the load balancer container after a reconcile.
+type LoadBalancerState string
+
+const (
+	// LoadBalancerCreated means the load balancer container exists but has no address yet.
+	LoadBalancerCreated LoadBalancerState = "Created"
+	// LoadBalancerUpdating means the load balancer container was recreated and is being reconfigured,
+	// so its address may have changed.
+	LoadBalancerUpdating LoadBalancerState = "Updating"
+	// LoadBalancerReady means the load balancer container is running with an address.
+	LoadBalancerReady LoadBalancerState = "Ready"
+	// LoadBalancerPaused means the load balancer is paused and was left as is, so its state is unknown.
+	LoadBalancerPaused LoadBalancerState = "Paused"
+)
+
+// UpdateResult summarizes a load balancer configuration update.
+type UpdateResult struct {
+	// BackendCount is the number of backend servers in the configuration.
+	BackendCount int
+	// Reloaded is true if HAProxy was signaled to load the configuration.
+	Reloaded bool
+	// ConfigChanged is true if the configuration differs from the one previously written in the container.
+	ConfigChanged bool
+	// Drifted is true if the configuration in the container was changed since it was last written and
+	// the changes were kept, see LoadBalancerConfigDriftObserve.
+	Drifted bool
+	// RolledBack is true if the previous configuration was restored because no backend passed its
+	// health checks with the new one, see DockerClusterSpec.LoadBalancerRollbackUnhealthyConfig.
+	RolledBack bool
+}
+
+// defaultReloadTimeout is how long to wait for HAProxy to serve a new configuration after a reload.
+const defaultReloadTimeout = 10 * time.Second
+
+// reloadPollInterval is how often the stats page is checked while waiting for HAProxy to serve a new configuration.
+const reloadPollInterval = 500 * time.Millisecond
+
+// healthPollInterval is how often the stats page is checked while waiting for the backends to become healthy.
+const healthPollInterval = time.Second
+
+// ipPollInterval is how often the load balancer container is inspected while waiting for its IP address.
+const ipPollInterval = 500 * time.Millisecond
+
+// defaultStopTimeout is how long HAProxy is given to finish serving in-flight connections when
+// the load balancer container is deleted.
+const defaultStopTimeout = 5 * time.Second
+
+// defaultRetries is how many times the load balancer retries a failed connection to an API server by default.
+const defaultRetries = 3
+
+// defaultHardStopAfter is how long the HAProxy processes replaced by a reload keep serving their connections by default.
+const defaultHardStopAfter = 30 * time.Second
+
+// defaultNodeIPTimeout is how long backend discovery waits for a control plane node to get an IP.
+const defaultNodeIPTimeout = 5 * time.Second
+
+// maxNodeIPRetryInterval caps the backoff between attempts to get the IP of a control plane node.
+const maxNodeIPRetryInterval = time.Second
+
 type lbCreator interface {
-	CreateExternalLoadBalancerNode(ctx context.Context, name, image, clusterName, listenAddress string, port int32) (*types.Node, error)
+	CreateExternalLoadBalancerNode(ctx context.Context, opts ExternalLoadBalancerNodeOptions) (*types.Node, error)
 }
 
 // LoadBalancer manages the load balancer for a specific docker cluster.
+// Create, Recreate, UpdateConfiguration and Delete hold a per-cluster lock, so concurrent
+// reconciles using different LoadBalancer instances for the same cluster do not interleave.
 type LoadBalancer struct {
-	name      string
-	image     string
-	container *types.Node
-	lbCreator lbCreator
+	name         string
+	image        string
+	imageArchive string
+	args         []string
+	env          map[string]string
+	sysctls      map[string]string
+	capAdd       []string
+	capDrop      []string
+	hostname     string
+	useDNSName   bool
+	extraHosts   []string
+	privileged   bool
+	readOnly     bool
+	logDriver    string
+	logOptions   map[string]string
+	network      string
+	config       loadbalancer.ConfigData
+	container    *types.Node
+	lbCreator    lbCreator
+	// additionalNetworks are the networks the container is connected to in addition to network.
+	additionalNetworks []string
+	// restartPolicy is the restart policy of the container, see DockerClusterSpec.LoadBalancerRestartPolicy.
+	restartPolicy string
+	// placement are the placement hints of the container on multi-host runtimes, see
+	// DockerClusterSpec.LoadBalancerPlacement; nil lets the runtime place it.
+	placement *infrav1.LoadBalancerPlacement
+	// ownerUID is the UID of the DockerCluster owning the load balancer, labeled on the container it creates;
+	// empty does not label the container.
+	ownerUID string
+	// orphan is the container with the load balancer container name owned by another DockerCluster, if any.
+	orphan *types.Node
+	// reloadTimeout is how long applying a configuration waits for HAProxy to serve it; zero skips the check.
+	reloadTimeout time.Duration
+	// rollbackUnhealthy restores the previous configuration when no backend passes its health checks after
+	// applying a new one, see DockerClusterSpec.LoadBalancerRollbackUnhealthyConfig.
+	rollbackUnhealthy bool
+	// backendCache stores the backend servers across reconciles for backendCacheTTL; nil disables caching.
+	backendCache    *backendCache
+	backendCacheTTL time.Duration
+	forceRefresh    bool
+	// nodeIPTimeout is how long to wait for each control plane node to get an IP; zero does not wait.
+	nodeIPTimeout time.Duration
+	// discoverer lists the backend nodes; nil discovers them by label.
+	discoverer BackendDiscoverer
+	// addressResolver resolves the backend addresses; nil resolves them to the container IPs.
+	addressResolver BackendAddressResolver
+	// configDataProvider assembles the configuration data from the control plane one; nil only configures
+	// the control plane frontend.
+	configDataProvider ConfigDataProvider
+	// retainOnDelete stops and renames the container on Delete instead of removing it.
+	retainOnDelete bool
+	// stopTimeout is how long the container is given to stop gracefully on Delete; zero kills it immediately.
+	stopTimeout time.Duration
+	// hibernate makes the configuration refuse connections when there are no backend servers.
+	hibernate bool
+	// maxBackends limits the backend servers to the first ones sorted by name; zero does not limit them.
+	maxBackends int
+	// unhealthyBackends are the names of the backend servers of the Machines a MachineHealthCheck found
+	// unhealthy; they are put in maintenance, see SetUnhealthyMachines.
+	unhealthyBackends map[string]bool
+	// snapshot is the configuration recorded in the LoadBalancerConfigSnapshotAnnotation, if any.
+	snapshot *loadbalancer.ConfigData
+	// zeroBackendConfirmations is how many consecutive discoveries must find no backend before the
+	// backends are removed; one or less removes them immediately.
+	zeroBackendConfirmations int
+	// clock is used for the waits and timestamps of the load balancer; nil uses the real clock.
+	clock Clock
+	// backendCA is the CA bundle verifying the API server certificates; empty accepts any certificate.
+	backendCA string
+	// applier writes and reloads the configuration; nil uses the HAProxy applier.
+	applier ConfigApplier
+	// configDriftMode is how changes made to the configuration in the container are handled, see
+	// DockerClusterSpec.LoadBalancerConfigDriftMode; empty does not detect them.
+	configDriftMode string
+	// paused is true if the Cluster or the DockerCluster is paused; Create, UpdateConfiguration and Delete
+	// then leave the container untouched.
+	paused bool
 }
 
+// retainedSuffix is appended, with a timestamp, to the name of load balancer containers retained on delete.
+const retainedSuffix = "-retained-"
+
 // NewLoadBalancer returns a new helper for managing a docker loadbalancer with a given name.
 func NewLoadBalancer(ctx context.Context, cluster *clusterv1.Cluster, dockerCluster *infrav1.DockerCluster) (*LoadBalancer, error) {
 	if cluster.Name == "" {
@@ -53,23 +198,137 @@ func NewLoadBalancer(ctx context.Context, cluster *clusterv1.Cluster, dockerClus
 	// Look for the container that is hosting the loadbalancer for the cluster.
 	// Filter based on the label and the roles regardless of whether or not it is running.
 	// If non-running container is chosen, then it will not have an IP address associated with it.
-	filters := container.FilterBuilder{}
-	filters.AddKeyNameValue(filterLabel, clusterLabelKey, cluster.Name)
-	filters.AddKeyNameValue(filterLabel, nodeRoleLabelKey, constants.ExternalLoadBalancerNodeRoleValue)
+	filters := labelFilters(LoadBalancerLabels(cluster.Name))
+	// Container labels can't be changed, so containers retained on delete keep the cluster labels;
+	// match the container name too so a new cluster with the same name does not adopt them.
+	filters.AddKeyValue(filterName, fmt.Sprintf("^%s$", regexp.QuoteMeta(loadBalancerContainerName(cluster.Name))))
 
 	container, err := getContainer(ctx, filters)
 	if err != nil {
 		return nil, err
 	}
 
+	// Only adopt the container if it belongs to this DockerCluster, and not to a deleted one with the same name.
+	var ownerUID string
+	var orphan *types.Node
+	if dockerCluster != nil {
+		ownerUID = string(dockerCluster.UID)
+	}
+	if container != nil && ownerUID != "" {
+		switch containerUID, ok := container.Labels[ownerUIDLabelKey]; {
+		case !ok:
+			// Containers created before the owner label existed are adopted; labels cannot be added to
+			// a container, so it gets labeled when it is recreated.
+			ctrl.LoggerFrom(ctx).V(4).Info("Adopting load balancer container without an owner UID label", "container", container.Name)
+		case containerUID != ownerUID:
+			ctrl.LoggerFrom(ctx).Info("Ignoring load balancer container owned by another DockerCluster",
+				"container", container.Name, "ownerUID", containerUID)
+			orphan, container = container, nil
+		}
+	}
+
+	ipFamily, err := getLoadBalancerIPFamily(cluster)
+	if err != nil {
+		return nil, err
+	}
 	image := getLoadBalancerImage(dockerCluster)
 
-	return &LoadBalancer{
-		name:      cluster.Name,
-		image:     image,
-		container: container,
-		lbCreator: &Manager{},
-	}, nil
+	lb := &LoadBalancer{
+		name:                     cluster.Name,
+		image:                    image,
+		imageArchive:             getLoadBalancerImageArchive(dockerCluster),
+		args:                     getLoadBalancerArgs(dockerCluster),
+		network:                  getLoadBalancerNetwork(dockerCluster),
+		sysctls:                  getLoadBalancerSysctls(dockerCluster),
+		config:                   getLoadBalancerConfigData(dockerCluster),
+		container:                container,
+		lbCreator:                &Manager{},
+		ownerUID:                 ownerUID,
+		orphan:                   orphan,
+		reloadTimeout:            getLoadBalancerReloadTimeout(dockerCluster),
+		rollbackUnhealthy:        getLoadBalancerRollbackUnhealthyConfig(dockerCluster),
+		nodeIPTimeout:            getLoadBalancerNodeIPTimeout(dockerCluster),
+		stopTimeout:              getLoadBalancerStopTimeout(dockerCluster),
+		restartPolicy:            getLoadBalancerRestartPolicy(dockerCluster),
+		zeroBackendConfirmations: getLoadBalancerZeroBackendConfirmations(dockerCluster),
+	}
+	lb.config.IPFamily = ipFamily
+	lb.paused = cluster.Spec.Paused
+	if dockerCluster != nil {
+		lb.paused = annotations.IsPaused(cluster, dockerCluster)
+		lb.retainOnDelete = dockerCluster.Spec.LoadBalancerRetainOnDelete
+		_, lb.hibernate = dockerCluster.Annotations[infrav1.HibernateLoadBalancerAnnotation]
+		lb.maxBackends = int(dockerCluster.Spec.LoadBalancerMaxBackends)
+		if dockerCluster.Spec.LoadBalancerUseBackendNames {
+			lb.addressResolver = ContainerNameResolver{}
+		}
+		lb.capAdd = append([]string(nil), dockerCluster.Spec.LoadBalancerCapAdd...)
+		lb.capDrop = append([]string(nil), dockerCluster.Spec.LoadBalancerCapDrop...)
+		lb.hostname = dockerCluster.Spec.LoadBalancerHostname
+		lb.useDNSName = dockerCluster.Spec.LoadBalancerUseDNSName
+		lb.extraHosts = append([]string(nil), dockerCluster.Spec.LoadBalancerExtraHosts...)
+		lb.privileged = dockerCluster.Spec.LoadBalancerPrivileged
+		lb.readOnly = dockerCluster.Spec.LoadBalancerReadOnlyRootFS
+		lb.additionalNetworks = dockerCluster.Spec.LoadBalancerNetworks
+		lb.placement = dockerCluster.Spec.LoadBalancerPlacement.DeepCopy()
+		lb.configDriftMode = dockerCluster.Spec.LoadBalancerConfigDriftMode
+		if len(dockerCluster.Spec.LoadBalancerEnv) > 0 {
+			lb.env = make(map[string]string, len(dockerCluster.Spec.LoadBalancerEnv))
+			for key, value := range dockerCluster.Spec.LoadBalancerEnv {
+				lb.env[key] = value
+			}
+		}
+		lb.logDriver = dockerCluster.Spec.LoadBalancerLogDriver
+		if len(dockerCluster.Spec.LoadBalancerLogOptions) > 0 {
+			lb.logOptions = make(map[string]string, len(dockerCluster.Spec.LoadBalancerLogOptions))
+			for key, value := range dockerCluster.Spec.LoadBalancerLogOptions {
+				lb.logOptions[key] = value
+			}
+		}
+		if lb.config.ConfigPatch, err = loadbalancer.ParseConfigOverrides(dockerCluster.Spec.LoadBalancerConfigPatch); err != nil {
+			return nil, errors.Wrap(err, "invalid load balancer config patch")
+		}
+		if snapshot, ok := dockerCluster.Annotations[infrav1.LoadBalancerConfigSnapshotAnnotation]; ok {
+			// A snapshot that cannot be restored is replaced when the configuration is next observed.
+			if lb.snapshot, err = loadbalancer.ImportConfig(snapshot); err != nil {
+				ctrl.LoggerFrom(ctx).Info("Ignoring invalid load balancer configuration snapshot", "error", err.Error())
+			}
+		}
+	}
+	if dockerCluster != nil && dockerCluster.Spec.LoadBalancerBackendCacheTTL != nil && dockerCluster.Spec.LoadBalancerBackendCacheTTL.Duration > 0 {
+		lb.backendCache = defaultBackendCache
+		lb.backendCacheTTL = dockerCluster.Spec.LoadBalancerBackendCacheTTL.Duration
+	}
+	return lb, nil
+}
+
+// getLoadBalancerIPFamily returns the IP family the load balancer frontends accept connections on, the IP
+// family of the cluster networks.
+func getLoadBalancerIPFamily(cluster *clusterv1.Cluster) (string, error) {
+	ipFamily, err := cluster.GetIPFamily()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get the IP family of the cluster")
+	}
+	switch ipFamily {
+	case clusterv1.IPv6IPFamily:
+		return loadbalancer.IPFamilyIPv6, nil
+	case clusterv1.DualStackIPFamily:
+		return loadbalancer.IPFamilyDualStack, nil
+	default:
+		return loadbalancer.IPFamilyIPv4, nil
+	}
+}
+
+// defaultRestartPolicy is the restart policy of the load balancer container by default, bringing it back
+// when the host or the docker daemon restarts while leaving the containers stopped on purpose stopped.
+const defaultRestartPolicy = "unless-stopped"
+
+// getLoadBalancerRestartPolicy returns the restart policy of the load balancer container.
+func getLoadBalancerRestartPolicy(dockerCluster *infrav1.DockerCluster) string {
+	if dockerCluster != nil && dockerCluster.Spec.LoadBalancerRestartPolicy != "" {
+		return dockerCluster.Spec.LoadBalancerRestartPolicy
+	}
+	return defaultRestartPolicy
 }
 
 // getLoadBalancerImage will return the image (e.g. "kindest/haproxy:2.1.1-alpine") to use for
@@ -89,13 +348,292 @@ func getLoadBalancerImage(dockerCluster *infrav1.DockerCluster) string {
 	return fmt.Sprintf("%s/%s:%s", imageRepo, image, imageTag)
 }
 
+// getLoadBalancerArgs returns the command of the load balancer container, or nil to use the image default.
+// The configuration file managed by the provider is added unless the args already load it.
+func getLoadBalancerArgs(dockerCluster *infrav1.DockerCluster) []string {
+	if dockerCluster == nil || len(dockerCluster.Spec.LoadBalancerArgs) == 0 {
+		return nil
+	}
+
+	args := append([]string{}, dockerCluster.Spec.LoadBalancerArgs...)
+	for _, arg := range args {
+		if arg == "-f" {
+			return args
+		}
+	}
+	return append(args, "-f", loadbalancer.ConfigPath)
+}
+
+// getLoadBalancerSysctls returns the kernel parameters to set in the load balancer container.
+func getLoadBalancerSysctls(dockerCluster *infrav1.DockerCluster) map[string]string {
+	if dockerCluster == nil || len(dockerCluster.Spec.LoadBalancerSysctls) == 0 {
+		return nil
+	}
+
+	sysctls := make(map[string]string, len(dockerCluster.Spec.LoadBalancerSysctls))
+	for key, value := range dockerCluster.Spec.LoadBalancerSysctls {
+		sysctls[key] = value
+	}
+	return sysctls
+}
+
+// getLoadBalancerNetwork returns the docker network the load balancer container is attached to.
+func getLoadBalancerNetwork(dockerCluster *infrav1.DockerCluster) string {
+	if dockerCluster != nil && dockerCluster.Spec.Network != "" {
+		return dockerCluster.Spec.Network
+	}
+	return DefaultNetwork
+}
+
+// getLoadBalancerImageArchive returns the path of the archive to load the load balancer image from, if any.
+func getLoadBalancerImageArchive(dockerCluster *infrav1.DockerCluster) string {
+	if dockerCluster == nil {
+		return ""
+	}
+	return dockerCluster.Spec.LoadBalancerImageArchive
+}
+
+// imageDigest returns the digest an image reference is pinned to (e.g. "sha256:..."), if any.
+func imageDigest(image string) string {
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	return ""
+}
+
+// baseConfig are the configuration overrides layered under the spec of every load balancer.
+var baseConfig loadbalancer.ConfigOverrides
+
+// SetBaseConfig sets the configuration overrides layered over the built-in defaults of every load balancer
+// configuration, e.g. the timeouts a platform standardizes on. The DockerCluster spec, including its
+// config patch, takes precedence over them.
+func SetBaseConfig(base loadbalancer.ConfigOverrides) {
+	baseConfig = base
+}
+
+// getLoadBalancerConfigData returns the load balancer configuration settings derived from the
+// DockerCluster spec. Backend servers are not included, they are discovered when the configuration is applied.
+func getLoadBalancerConfigData(dockerCluster *infrav1.DockerCluster) loadbalancer.ConfigData {
+	retries := defaultRetries
+	data := loadbalancer.ConfigData{
+		ControlPlanePort: ControlPlanePort,
+		FrontendPort:     ControlPlanePort,
+		EnableStats:      true,
+		Retries:          &retries,
+		Redispatch:       true,
+		HardStopAfter:    defaultHardStopAfter,
+		BaseConfig:       baseConfig,
+	}
+	if dockerCluster == nil {
+		return data
+	}
+
+	spec := dockerCluster.Spec
+	if spec.LoadBalancerFrontendPort != 0 {
+		data.FrontendPort = int(spec.LoadBalancerFrontendPort)
+	}
+	data.Nbproc = int(spec.LoadBalancerProcesses)
+	data.Nbthread = int(spec.LoadBalancerThreads)
+	data.MaxConn = int(spec.LoadBalancerMaxConn)
+	data.FrontendMaxConn = int(spec.LoadBalancerFrontendMaxConn)
+	if spec.LoadBalancerHardStopAfter != nil {
+		data.HardStopAfter = spec.LoadBalancerHardStopAfter.Duration
+	}
+	data.RunAsUser = spec.LoadBalancerRunAsUser
+	data.RunAsGroup = spec.LoadBalancerRunAsGroup
+	data.Mode = spec.LoadBalancerMode
+	data.CheckPort = int(spec.LoadBalancerCheckPort)
+	data.PreserveDestinationPort = spec.LoadBalancerPreserveDestinationPort
+	data.HealthCheck = spec.LoadBalancerHealthCheckType
+	if spec.LoadBalancerUseBackendNames {
+		data.Resolvers = &loadbalancer.Resolvers{
+			Name:        loadbalancer.DockerResolversName,
+			Nameservers: []string{loadbalancer.DockerDNSAddress},
+		}
+		data.InitAddr = loadbalancer.DefaultInitAddr
+	}
+	if spec.LoadBalancerInitAddr != "" {
+		data.InitAddr = spec.LoadBalancerInitAddr
+	}
+	data.BackendSource = spec.LoadBalancerBackendSource
+	data.ShutdownSessionsOnDown = spec.LoadBalancerShutdownSessionsOnDown
+	if len(spec.LoadBalancerDefaultsOverride) > 0 {
+		data.DefaultsOverride = make(map[string]string, len(spec.LoadBalancerDefaultsOverride))
+		for key, value := range spec.LoadBalancerDefaultsOverride {
+			data.DefaultsOverride[key] = value
+		}
+	}
+	if spec.LoadBalancerRetries != nil {
+		retries = int(*spec.LoadBalancerRetries)
+	}
+	if spec.LoadBalancerRedispatch != nil {
+		data.Redispatch = *spec.LoadBalancerRedispatch
+	}
+	data.TCPSmartConnect = spec.LoadBalancerTCPSmartConnect
+	data.HTTPReuse = spec.LoadBalancerHTTPReuse
+	data.LogFormat = spec.LoadBalancerLogFormat
+	if spec.LoadBalancerLogFormat == infrav1.LoadBalancerLogFormatJSON {
+		data.LogFormat = loadbalancer.JSONLogFormat
+	}
+	if spec.LoadBalancerEnableMetrics {
+		data.MetricsPort = loadbalancer.DefaultMetricsPort
+		if spec.LoadBalancerMetricsPort != 0 {
+			data.MetricsPort = int(spec.LoadBalancerMetricsPort)
+		}
+		data.MetricsAllowedSources = append([]string(nil), spec.LoadBalancerMetricsAllowedSources...)
+	}
+	if feature.Gates.Enabled(feature.LoadBalancerBackendPools) {
+		for _, pool := range spec.LoadBalancerBackendPools {
+			data.BackendPools = append(data.BackendPools, loadbalancer.BackendPool{
+				Name:     pool.Name,
+				Port:     int(pool.Port),
+				SNI:      pool.SNI,
+				Protocol: pool.Protocol,
+			})
+		}
+	}
+
+	if value, ok := dockerCluster.Annotations[infrav1.SNIRoutesAnnotation]; ok && feature.Gates.Enabled(feature.LoadBalancerSNIRouting) {
+		// Invalid routes are rejected by the webhook.
+		if routes, err := loadbalancer.ParseSNIRoutes(value); err == nil {
+			data.SNIRoutes = routes
+		}
+	}
+
+	return data
+}
+
+// getLoadBalancerReloadTimeout returns how long to wait for HAProxy to serve a new configuration after a reload.
+func getLoadBalancerReloadTimeout(dockerCluster *infrav1.DockerCluster) time.Duration {
+	if dockerCluster != nil && dockerCluster.Spec.LoadBalancerReloadTimeout != nil {
+		return dockerCluster.Spec.LoadBalancerReloadTimeout.Duration
+	}
+	return defaultReloadTimeout
+}
+
+// getLoadBalancerNodeIPTimeout returns how long backend discovery waits for a control plane node to get an IP.
+func getLoadBalancerNodeIPTimeout(dockerCluster *infrav1.DockerCluster) time.Duration {
+	if dockerCluster != nil && dockerCluster.Spec.LoadBalancerNodeIPTimeout != nil {
+		return dockerCluster.Spec.LoadBalancerNodeIPTimeout.Duration
+	}
+	return defaultNodeIPTimeout
+}
+
+// getLoadBalancerStopTimeout returns how long the load balancer container is given to stop gracefully.
+func getLoadBalancerStopTimeout(dockerCluster *infrav1.DockerCluster) time.Duration {
+	if dockerCluster != nil && dockerCluster.Spec.LoadBalancerStopTimeout != nil {
+		return dockerCluster.Spec.LoadBalancerStopTimeout.Duration
+	}
+	return defaultStopTimeout
+}
+
+// getLoadBalancerZeroBackendConfirmations returns how many consecutive discoveries must find no backend
+// before the backends are removed from the load balancer configuration.
+func getLoadBalancerZeroBackendConfirmations(dockerCluster *infrav1.DockerCluster) int {
+	if dockerCluster != nil && dockerCluster.Spec.LoadBalancerZeroBackendConfirmations > 0 {
+		return int(dockerCluster.Spec.LoadBalancerZeroBackendConfirmations)
+	}
+	return DefaultZeroBackendConfirmations
+}
+
 // ContainerName is the name of the docker container with the load balancer.
 func (s *LoadBalancer) containerName() string {
-	return fmt.Sprintf("%s-lb", s.name)
+	return loadBalancerContainerName(s.name)
+}
+
+// loadBalancerContainerName returns the name of the load balancer container for the cluster.
+func loadBalancerContainerName(clusterName string) string {
+	return fmt.Sprintf("%s-lb", clusterName)
+}
+
+// NetworkName returns the name of the docker network the load balancer container is attached to.
+func (s *LoadBalancer) NetworkName() string {
+	if s.network == "" {
+		return DefaultNetwork
+	}
+	return s.network
+}
+
+// NetworkChanged returns true if the load balancer container exists but is not attached to the
+// desired networks, e.g. because DockerCluster.Spec.Network changed after it was created.
+// Such a container is unreachable from nodes created on the new network and must be recreated.
+func (s *LoadBalancer) NetworkChanged(ctx context.Context) (bool, error) {
+	if s.container == nil {
+		return false, nil
+	}
+
+	containerRuntime, err := container.RuntimeFrom(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to connect to container runtime")
+	}
+
+	info, err := containerRuntime.InspectContainer(ctx, s.containerName())
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	attached := make(map[string]bool, len(info.Networks))
+	for _, network := range info.Networks {
+		attached[network] = true
+	}
+	for _, network := range append([]string{s.NetworkName()}, s.additionalNetworks...) {
+		if !attached[network] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DNSName returns the name other containers on the load balancer network can use to reach it.
+// Unlike the IP, the name is stable across restarts of the container.
+func (s *LoadBalancer) DNSName() string {
+	return s.containerName()
+}
+
+// ValidateDNSName checks that DNSName is resolvable on the load balancer network.
+func (s *LoadBalancer) ValidateDNSName(ctx context.Context) error {
+	name := s.DNSName()
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return errors.Errorf("load balancer DNS name %q is invalid: %s", name, strings.Join(errs, ", "))
+	}
+	if s.container == nil {
+		return errors.New("unable to resolve load balancer DNS name: load balancer container does not exists")
+	}
+
+	// Resolving from within the container goes through the network's embedded DNS server.
+	var stdout bytes.Buffer
+	cmd := s.container.Commander.Command("nslookup", name)
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(&stdout)
+	if err := cmd.Run(ctx); err != nil {
+		return errors.Wrapf(err, "load balancer DNS name %q is not resolvable on network %s: %s", name, s.NetworkName(), stdout.String())
+	}
+	return nil
 }
 
 // Create creates a docker container hosting a load balancer for the cluster.
 func (s *LoadBalancer) Create(ctx context.Context) error {
+	if s.skipPaused(ctx, "create") {
+		return nil
+	}
+	defer defaultClusterLocks.lockCluster(s.name)()
+	return s.create(ctx)
+}
+
+// Paused returns true if the Cluster or the DockerCluster is paused with the Cluster API paused annotation
+// or the Cluster spec, in which case the load balancer is not reconciled.
+func (s *LoadBalancer) Paused() bool {
+	return s.paused
+}
+
+// skipPaused returns true, and logs it, if the operation is skipped because the load balancer is paused.
+func (s *LoadBalancer) skipPaused(ctx context.Context, operation string) bool {
+	if s.paused {
+		ctrl.LoggerFrom(ctx).Info("Load balancer reconciliation is paused, skipping", "operation", operation, "loadbalancer", s.name)
+	}
+	return s.paused
+}
+
+func (s *LoadBalancer) create(ctx context.Context) error {
 	log := ctrl.LoggerFrom(ctx)
 	log = log.WithValues("loadbalancer", s.name)
 
@@ -103,89 +641,1364 @@ func (s *LoadBalancer) Create(ctx context.Context) error {
 
 	// Create if not exists.
 	if s.container == nil {
-		var err error
+		if s.orphan != nil {
+			return OrphanedLoadBalancerError{Name: s.orphan.Name, OwnerUID: s.orphan.Labels[ownerUIDLabelKey]}
+		}
+		if err := s.loadImageArchive(ctx); err != nil {
+			return err
+		}
+
+		var labels map[string]string
+		if s.ownerUID != "" {
+			labels = map[string]string{ownerUIDLabelKey: s.ownerUID}
+		}
+
+		placementConstraints, err := s.placementConstraints(ctx)
+		if err != nil {
+			return err
+		}
+
 		log.Info("Creating load balancer container")
-		s.container, err = s.lbCreator.CreateExternalLoadBalancerNode(
-			ctx,
-			s.containerName(),
-			s.image,
-			s.name,
-			listenAddr,
-			0,
-		)
+		opts := ExternalLoadBalancerNodeOptions{
+			Name:                 s.containerName(),
+			Image:                s.image,
+			ClusterName:          s.name,
+			ListenAddress:        listenAddr,
+			ContainerPort:        int32(s.config.FrontendPort),
+			Network:              s.NetworkName(),
+			AdditionalNetworks:   s.additionalNetworks,
+			CommandArgs:          s.args,
+			Sysctls:              s.sysctls,
+			CapAdd:               s.capAdd,
+			CapDrop:              s.capDrop,
+			Hostname:             s.hostname,
+			ExtraHosts:           s.extraHosts,
+			Privileged:           s.privileged,
+			LogDriver:            s.logDriver,
+			LogOptions:           s.logOptions,
+			Env:                  s.env,
+			Labels:               labels,
+			ReadOnlyRootFS:       s.readOnly,
+			RestartPolicy:        s.restartPolicy,
+			PlacementConstraints: placementConstraints,
+		}
+		s.container, err = s.lbCreator.CreateExternalLoadBalancerNode(ctx, opts)
+		if errors.As(err, &container.ImageNotFoundError{}) {
+			// The image can disappear between checking for it and creating the container,
+			// e.g. when the host images are pruned; restore it and try once more.
+			log.Info("Load balancer image not found, restoring it", "image", s.image)
+			if err := s.restoreImage(ctx); err != nil {
+				return err
+			}
+			s.container, err = s.lbCreator.CreateExternalLoadBalancerNode(ctx, opts)
+		}
 		if err != nil {
 			return errors.WithStack(err)
 		}
 	}
 
+	// Make sure the container runs the exact image that was requested.
+	if imageDigest(s.image) != "" {
+		if _, err := s.ImageDigest(ctx); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// UpdateConfiguration updates the external load balancer configuration with new control plane nodes.
-func (s *LoadBalancer) UpdateConfiguration(ctx context.Context) error {
+// Reconcile makes sure the docker container hosting the load balancer exists on the desired network
+// and reports whether it is ready to serve the control plane endpoint. A paused load balancer is
+// left as is and reports LoadBalancerPaused.
+func (s *LoadBalancer) Reconcile(ctx context.Context) (LoadBalancerState, error) {
+	if s.skipPaused(ctx, "reconcile") {
+		return LoadBalancerPaused, nil
+	}
 	log := ctrl.LoggerFrom(ctx)
 
-	if s.container == nil {
-		return errors.New("unable to configure load balancer: load balancer container does not exists")
+	// Recreate the container if it is attached to a different network than the desired one,
+	// otherwise it is unreachable from nodes created on the new network.
+	networkChanged, err := s.NetworkChanged(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to check the load balancer network")
+	}
+	if networkChanged {
+		log.Info("Load balancer network changed, recreating the load balancer", "network", s.NetworkName())
+		if err := s.Recreate(ctx); err != nil {
+			return "", errors.Wrap(err, "failed to recreate load balancer")
+		}
+		if _, err := s.UpdateConfiguration(ctx); err != nil {
+			return "", errors.Wrap(err, "failed to update load balancer configuration")
+		}
+		return LoadBalancerUpdating, nil
 	}
 
-	// collect info about the existing controlplane nodes
-	filters := container.FilterBuilder{}
-	filters.AddKeyNameValue(filterLabel, clusterLabelKey, s.name)
-	filters.AddKeyNameValue(filterLabel, nodeRoleLabelKey, constants.ControlPlaneNodeRoleValue)
+	if err := s.Create(ctx); err != nil {
+		return "", err
+	}
 
-	controlPlaneNodes, err := listContainers(ctx, filters)
+	lbIP, err := s.containerIP(ctx)
 	if err != nil {
-		return errors.WithStack(err)
+		return "", errors.WithStack(err)
+	}
+	if lbIP == "" {
+		return LoadBalancerCreated, nil
 	}
+	return LoadBalancerReady, nil
+}
 
-	var backendServers = map[string]string{}
-	for _, n := range controlPlaneNodes {
-		controlPlaneIPv4, err := n.IP(ctx)
-		if err != nil {
-			return errors.Wrapf(err, "failed to get IP for container %s", n.String())
+// EnsureReady brings the load balancer up in a single pass, instead of across several reconciles: it
+// makes sure the container exists, waits for its IP address, applies the configuration and waits for
+// HAProxy to report at least minBackends healthy backend servers, then returns the control plane
+// endpoint. The waits share the timeout. A minBackends of zero skips the health check, e.g. before
+// the first control plane machine exists; otherwise the stats page must be enabled.
+func (s *LoadBalancer) EnsureReady(ctx context.Context, minBackends int, timeout time.Duration) (clusterv1.APIEndpoint, error) {
+	clock := clockOrDefault(s.clock)
+	deadline := clock.Now().Add(timeout)
+
+	state, err := s.Reconcile(ctx)
+	if err != nil {
+		return clusterv1.APIEndpoint{}, errors.Wrap(err, "failed to reconcile load balancer")
+	}
+	if state == LoadBalancerPaused {
+		return clusterv1.APIEndpoint{}, errors.New("unable to make the load balancer ready: load balancer is paused")
+	}
+
+	host, err := s.WaitForIP(ctx, deadline.Sub(clock.Now()))
+	if err != nil {
+		return clusterv1.APIEndpoint{}, err
+	}
+	if s.useDNSName {
+		if err := s.ValidateDNSName(ctx); err != nil {
+			return clusterv1.APIEndpoint{}, err
+		}
+		host = s.DNSName()
+	}
+
+	if _, err := s.UpdateConfiguration(ctx); err != nil {
+		return clusterv1.APIEndpoint{}, errors.Wrap(err, "failed to update load balancer configuration")
+	}
+
+	if minBackends > 0 {
+		if err := s.WaitForHealthyBackends(ctx, minBackends, deadline.Sub(clock.Now())); err != nil {
+			return clusterv1.APIEndpoint{}, err
 		}
+	}
 
-		backendServers[n.String()] = net.JoinHostPort(controlPlaneIPv4, "6443")
+	return clusterv1.APIEndpoint{Host: host, Port: int32(s.config.ControlPlanePort)}, nil
+}
 
+// loadImageArchive loads the load balancer image from the image archive, if one is configured and
+// the image is not present yet.
+func (s *LoadBalancer) loadImageArchive(ctx context.Context) error {
+	if s.imageArchive == "" {
+		return nil
 	}
 
-	loadBalancerConfig, err := loadbalancer.Config(&loadbalancer.ConfigData{
-		ControlPlanePort: 6443,
-		BackendServers:   backendServers,
-		EnableStats:      true,
-	})
+	containerRuntime, err := container.RuntimeFrom(ctx)
 	if err != nil {
-		return errors.WithStack(err)
+		return errors.Wrap(err, "failed to connect to container runtime")
 	}
 
-	log.Info("Updating load balancer configuration")
-	if err := s.container.WriteFile(ctx, loadbalancer.ConfigPath, loadBalancerConfig); err != nil {
+	exists, err := containerRuntime.ImageExistsLocally(ctx, s.image)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if the load balancer image %s exists", s.image)
+	}
+	if exists {
+		return nil
+	}
+
+	tags, err := container.ImageArchiveRepoTags(s.imageArchive)
+	if err != nil {
 		return errors.WithStack(err)
 	}
+	found := false
+	for _, tag := range tags {
+		if container.NormalizeImageReference(tag) == container.NormalizeImageReference(s.image) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return errors.Errorf("image archive %s does not contain the load balancer image %s, got %v", s.imageArchive, s.image, tags)
+	}
 
-	return errors.WithStack(s.container.Kill(ctx, "SIGHUP"))
+	ctrl.LoggerFrom(ctx).Info("Loading load balancer image archive", "archive", s.imageArchive, "image", s.image)
+	if err := containerRuntime.LoadContainerImage(ctx, s.imageArchive); err != nil {
+		return errors.Wrapf(err, "failed to load image archive %s", s.imageArchive)
+	}
+	return nil
 }
 
-// IP returns the load balancer IP address.
-func (s *LoadBalancer) IP(ctx context.Context) (string, error) {
-	lbIP, err := s.container.IP(ctx)
+// restoreImage makes the load balancer image available again after it went missing, from the
+// image archive if one is configured, otherwise by pulling it.
+func (s *LoadBalancer) restoreImage(ctx context.Context) error {
+	if s.imageArchive != "" {
+		return s.loadImageArchive(ctx)
+	}
+
+	containerRuntime, err := container.RuntimeFrom(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to container runtime")
+	}
+	if err := containerRuntime.PullContainerImage(ctx, s.image); err != nil {
+		return errors.Wrapf(err, "failed to pull the load balancer image %s", s.image)
+	}
+	return nil
+}
+
+// ImageDigest returns the digest (e.g. "sha256:...") of the image the load balancer container runs.
+// If the load balancer image is pinned by digest, it returns an error when the container runs a different one.
+// An image without a registry digest, e.g. loaded from LoadBalancerImageArchive or built locally, is
+// identified by its image ID instead.
+func (s *LoadBalancer) ImageDigest(ctx context.Context) (string, error) {
+	if s.container == nil {
+		return "", errors.New("unable to get load balancer image digest: load balancer container does not exists")
+	}
+
+	containerRuntime, err := container.RuntimeFrom(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to connect to container runtime")
+	}
+
+	info, err := containerRuntime.InspectContainer(ctx, s.containerName())
 	if err != nil {
 		return "", errors.WithStack(err)
 	}
-	if lbIP == "" {
-		// if there is a load balancer container with the same name exists but is stopped, it may not have IP address associated with it.
-		return "", errors.Errorf("load balancer IP cannot be empty: container %s does not have an associated IP address", s.containerName())
+	repoDigests, err := containerRuntime.ImageRepoDigests(ctx, info.ImageID)
+	if err != nil {
+		return "", errors.WithStack(err)
 	}
-	return lbIP, nil
+
+	digests := make([]string, 0, len(repoDigests))
+	for _, repoDigest := range repoDigests {
+		if digest := imageDigest(repoDigest); digest != "" {
+			digests = append(digests, digest)
+		}
+	}
+
+	if pinned := imageDigest(s.image); pinned != "" {
+		for _, digest := range digests {
+			if digest == pinned {
+				return digest, nil
+			}
+		}
+		return "", errors.Errorf("load balancer container %s does not run the requested image digest %s, got %v", s.containerName(), pinned, digests)
+	}
+
+	if len(digests) == 0 {
+		return info.ImageID, nil
+	}
+	return digests[0], nil
 }
 
-// Delete the docker container hosting the cluster load balancer.
-func (s *LoadBalancer) Delete(ctx context.Context) error {
+// Recreate deletes the docker container hosting the load balancer, if any, and creates a new one.
+// The new container has no backends configured, so callers should run UpdateConfiguration afterwards.
+func (s *LoadBalancer) Recreate(ctx context.Context) error {
+	if s.skipPaused(ctx, "recreate") {
+		return nil
+	}
+	defer defaultClusterLocks.lockCluster(s.name)()
 	log := ctrl.LoggerFrom(ctx)
+	log.Info("Recreating load balancer container", "loadbalancer", s.name)
 
-	if s.container != nil {
-		log.Info("Deleting load balancer container")
+	if err := s.deleteContainer(ctx); err != nil {
+		return errors.Wrap(err, "failed to delete load balancer container")
+	}
+	return s.create(ctx)
+}
+
+// UpdateConfiguration updates the external load balancer configuration with new control plane nodes.
+// HAProxy is only reloaded if the configuration changed or is not served yet. Removing all the backends
+// fails with a ZeroBackendsUnconfirmedError until enough consecutive updates discovered none.
+func (s *LoadBalancer) UpdateConfiguration(ctx context.Context) (UpdateResult, error) {
+	if s.skipPaused(ctx, "update configuration") {
+		return UpdateResult{}, nil
+	}
+	defer defaultClusterLocks.lockCluster(s.name)()
+	if s.container == nil {
+		return UpdateResult{}, errors.New("unable to configure load balancer: load balancer container does not exists")
+	}
+
+	backendServers, err := s.backendServers(ctx)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	configData, err := s.desiredConfiguration(ctx, backendServers)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	s.restoreSnapshotBackends(ctx, &configData)
+	if err := s.confirmZeroBackends(ctx, &configData); err != nil {
+		return UpdateResult{}, err
+	}
+	return s.applyConfiguration(ctx, &configData, false)
+}
+
+// ForceReconfigure re-discovers the backends, bypassing the backend cache, then writes the configuration
+// and reloads HAProxy even if the configuration in the container looks up to date.
+// It is the recovery path when the configuration in the container is suspected to be corrupt.
+func (s *LoadBalancer) ForceReconfigure(ctx context.Context) error {
+	if s.skipPaused(ctx, "force reconfigure") {
+		return nil
+	}
+	defer defaultClusterLocks.lockCluster(s.name)()
+	if s.container == nil {
+		return errors.New("unable to configure load balancer: load balancer container does not exists")
+	}
+
+	// Only this discovery bypasses the backend cache, later updates use it again.
+	defer func(forceRefresh bool) { s.forceRefresh = forceRefresh }(s.forceRefresh)
+	s.forceRefresh = true
+	backendServers, err := s.backendServers(ctx)
+	if err != nil {
+		return err
+	}
+	configData, err := s.desiredConfiguration(ctx, backendServers)
+	if err != nil {
+		return err
+	}
+	ctrl.LoggerFrom(ctx).Info("Forcing load balancer reconfiguration")
+	_, err = s.applyConfiguration(ctx, &configData, true)
+	return err
+}
+
+// EffectiveConfigData discovers the backends and returns the configuration data UpdateConfiguration would
+// render for them, e.g. to check how the spec was resolved. It writes nothing and reloads nothing, and it
+// reads the backend cache without filling it, so it does not change what the next UpdateConfiguration sees.
+// It fails if the load balancer is paused, as UpdateConfiguration then renders nothing. It does not
+// account for the backends UpdateConfiguration keeps while their removal is unconfirmed, see
+// DockerClusterSpec.LoadBalancerZeroBackendConfirmations, or restores from the config snapshot.
+func (s *LoadBalancer) EffectiveConfigData(ctx context.Context) (*loadbalancer.ConfigData, error) {
+	if s.paused {
+		return nil, errors.New("unable to compute the load balancer configuration: load balancer is paused")
+	}
+	backendServers, err := s.peekBackendServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configData, err := s.desiredConfiguration(ctx, backendServers)
+	if err != nil {
+		return nil, err
+	}
+	return &configData, nil
+}
+
+// desiredConfiguration returns the configuration routing to the given backends, as assembled by
+// the ConfigDataProvider.
+func (s *LoadBalancer) desiredConfiguration(ctx context.Context, backendServers map[string]string) (loadbalancer.ConfigData, error) {
+	var err error
+	configData := s.config
+	configData.BackendServers = backendServers
+	configData.DisabledServers = s.disabledUnhealthyServers(ctx, backendServers)
+	// Zero backends on a hibernated cluster are intended, see infrav1.HibernateLoadBalancerAnnotation.
+	configData.Hibernated = s.hibernate && len(backendServers) == 0
+	if len(s.config.BackendPools) > 0 {
+		if configData.BackendPools, err = s.backendPools(ctx, backendServers); err != nil {
+			return loadbalancer.ConfigData{}, err
+		}
+	}
+	if configData.Peers, err = s.peers(ctx); err != nil {
+		return loadbalancer.ConfigData{}, err
+	}
+
+	provider := s.configDataProvider
+	if provider == nil {
+		provider = ControlPlaneConfigDataProvider{}
+	}
+	if configData, err = provider.ConfigData(ctx, s.name, configData); err != nil {
+		return loadbalancer.ConfigData{}, errors.Wrap(err, "failed to assemble load balancer configuration data")
+	}
+	return configData, nil
+}
+
+// Drain rewrites the load balancer configuration putting all the backends in maintenance, so the
+// control plane endpoint stops routing traffic while the container keeps running.
+// Normal routing is restored by Undrain, or by any later call to UpdateConfiguration.
+func (s *LoadBalancer) Drain(ctx context.Context) error {
+	if s.skipPaused(ctx, "drain") {
+		return nil
+	}
+	defer defaultClusterLocks.lockCluster(s.name)()
+	log := ctrl.LoggerFrom(ctx)
+
+	if s.container == nil {
+		return errors.New("unable to drain load balancer: load balancer container does not exists")
+	}
+
+	backendServers, err := s.backendServers(ctx)
+	if err != nil {
+		return err
+	}
+
+	configData := s.config
+	configData.BackendServers = backendServers
+	configData.DisabledServers = make(map[string]bool, len(backendServers))
+	for server := range backendServers {
+		configData.DisabledServers[server] = true
+	}
+
+	log.Info("Draining load balancer backends")
+	_, err = s.applyConfiguration(ctx, &configData, false)
+	return err
+}
+
+// Undrain restores normal routing to all the backends after Drain.
+func (s *LoadBalancer) Undrain(ctx context.Context) error {
+	_, err := s.UpdateConfiguration(ctx)
+	return err
+}
+
+// probeTimeoutSeconds is how long ProbeBackends waits for each backend to accept a connection.
+const probeTimeoutSeconds = 2
+
+// ProbeBackends checks whether the load balancer container can open a TCP connection to each of the
+// backends UpdateConfiguration would configure, which helps telling network issues apart from backends
+// failing their health check. It returns the probe error for each backend, nil if it is reachable.
+func (s *LoadBalancer) ProbeBackends(ctx context.Context) (map[string]error, error) {
+	if s.container == nil {
+		return nil, errors.New("unable to probe load balancer backends: load balancer container does not exists")
+	}
+
+	backendServers, err := s.backendServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]error, len(backendServers))
+	for server, address := range backendServers {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			results[server] = errors.Wrapf(err, "invalid backend address %q", address)
+			continue
+		}
+
+		var stderr bytes.Buffer
+		cmd := s.container.Commander.Command("nc", "-z", "-w", strconv.Itoa(probeTimeoutSeconds), host, port)
+		cmd.SetStderr(&stderr)
+		if err := cmd.Run(ctx); err != nil {
+			results[server] = errors.Wrapf(err, "backend %s is not reachable from the load balancer: %s", address, stderr.String())
+			continue
+		}
+		results[server] = nil
+	}
+	return results, nil
+}
+
+// SetClock overrides the clock the load balancer waits with, e.g. with a fake clock in tests.
+// A nil clock restores the real clock.
+func (s *LoadBalancer) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// SetBackendDiscoverer overrides how the control plane nodes the load balancer routes to are discovered.
+// A nil discoverer restores the default label based discovery.
+func (s *LoadBalancer) SetBackendDiscoverer(discoverer BackendDiscoverer) {
+	s.discoverer = discoverer
+}
+
+// SetBackendAddressResolver overrides how the addresses of the control plane nodes are resolved, e.g. for
+// nodes running on a remote docker host. A nil resolver restores the default container IP resolution.
+func (s *LoadBalancer) SetBackendAddressResolver(resolver BackendAddressResolver) {
+	s.addressResolver = resolver
+}
+
+// SetConfigDataProvider sets how the configuration data of the load balancer is assembled, e.g. to add
+// ingress frontends. A nil provider restores the default, configuring the control plane frontend only.
+func (s *LoadBalancer) SetConfigDataProvider(provider ConfigDataProvider) {
+	s.configDataProvider = provider
+}
+
+// SetForceRefresh makes the load balancer discover the backends from the container runtime instead
+// of using the cached ones, e.g. because control plane nodes were just created or deleted.
+func (s *LoadBalancer) SetForceRefresh(force bool) {
+	s.forceRefresh = force
+}
+
+// SetConfigApplier sets how the configuration is written into the container and reloaded,
+// e.g. for load balancers other than HAProxy. Defaults to HAProxyApplier.
+func (s *LoadBalancer) SetConfigApplier(applier ConfigApplier) {
+	s.applier = applier
+}
+
+// SetUnhealthyMachines puts the backend servers of the given control plane Machines in maintenance, e.g. the
+// Machines a MachineHealthCheck found unhealthy, so the load balancer stops routing to their API servers
+// before remediation removes their containers. They are routed to again once no longer set.
+func (s *LoadBalancer) SetUnhealthyMachines(machineNames ...string) {
+	s.unhealthyBackends = nil
+	for _, machine := range machineNames {
+		if s.unhealthyBackends == nil {
+			s.unhealthyBackends = make(map[string]bool, len(machineNames))
+		}
+		s.unhealthyBackends[machineContainerName(s.name, machine)] = true
+	}
+}
+
+// disabledUnhealthyServers returns the backend servers to put in maintenance because their Machines are
+// unhealthy, in addition to the ones the configuration disables. When none of the backends would be left
+// routing, they are all kept: an unhealthy API server may still serve requests, a missing one cannot.
+func (s *LoadBalancer) disabledUnhealthyServers(ctx context.Context, backendServers map[string]string) map[string]bool {
+	unhealthy := make([]string, 0, len(s.unhealthyBackends))
+	for server := range backendServers {
+		if s.unhealthyBackends[server] && !s.config.DisabledServers[server] {
+			unhealthy = append(unhealthy, server)
+		}
+	}
+	if len(unhealthy) == 0 {
+		return s.config.DisabledServers
+	}
+	sort.Strings(unhealthy)
+
+	enabled := 0
+	for server := range backendServers {
+		if !s.unhealthyBackends[server] && !s.config.DisabledServers[server] {
+			enabled++
+		}
+	}
+	if enabled == 0 {
+		ctrl.LoggerFrom(ctx).Info("Not putting the unhealthy load balancer backends in maintenance, no other backend would be left",
+			"unhealthy", unhealthy)
+		return s.config.DisabledServers
+	}
+
+	disabled := make(map[string]bool, len(s.config.DisabledServers)+len(unhealthy))
+	for server, value := range s.config.DisabledServers {
+		disabled[server] = value
+	}
+	for _, server := range unhealthy {
+		disabled[server] = true
+	}
+	ctrl.LoggerFrom(ctx).V(2).Info("Putting the unhealthy load balancer backends in maintenance", "unhealthy", unhealthy)
+	return disabled
+}
+
+// MaintenanceChanged returns true if the backend servers in maintenance in the configuration written in the
+// load balancer container differ from the ones SetUnhealthyMachines and the configuration put in maintenance,
+// e.g. when a Machine failed its health check or recovered, so the configuration must be updated.
+func (s *LoadBalancer) MaintenanceChanged(ctx context.Context) (bool, error) {
+	if s.container == nil {
+		return false, nil
+	}
+
+	content, err := s.container.ReadFile(ctx, loadbalancer.ConfigPath)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read load balancer configuration")
+	}
+	parsed, err := loadbalancer.ParseConfig([]byte(content))
+	if err != nil {
+		return false, errors.Wrap(err, "failed to parse load balancer configuration")
+	}
+
+	backend := parsed.Backend(loadbalancer.BackendName)
+	if backend == nil {
+		return false, nil
+	}
+	served := make(map[string]string, len(backend.Servers))
+	for _, server := range backend.Servers {
+		served[server.Name] = server.Address
+	}
+	disabled := s.disabledUnhealthyServers(ctx, served)
+	for _, server := range backend.Servers {
+		inMaintenance := false
+		for _, option := range server.Options {
+			if option == "disabled" {
+				inMaintenance = true
+				break
+			}
+		}
+		if inMaintenance != disabled[server.Name] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetBackendCA makes the load balancer verify the API server certificates against the given CA bundle,
+// e.g. the cluster CA; an empty bundle accepts any certificate.
+func (s *LoadBalancer) SetBackendCA(ca []byte) {
+	s.backendCA = string(ca)
+	s.config.BackendCAFile = ""
+	if len(ca) > 0 {
+		s.config.BackendCAFile = loadbalancer.BackendCAPath
+	}
+}
+
+// SetMetricsAuth makes the Prometheus exporter require the given basic auth credentials;
+// an empty username does not require any.
+func (s *LoadBalancer) SetMetricsAuth(username, password string) {
+	s.config.MetricsAuth = nil
+	if username != "" {
+		s.config.MetricsAuth = &loadbalancer.MetricsAuth{Username: username, Password: password}
+	}
+}
+
+// backendServers returns the load balancer backends for the existing control plane nodes,
+// from the backend cache when enabled and fresh.
+func (s *LoadBalancer) backendServers(ctx context.Context) (map[string]string, error) {
+	if s.backendCache == nil {
+		return s.discoverBackendServers(ctx)
+	}
+
+	if !s.forceRefresh {
+		if servers, ok := s.backendCache.get(s.name, s.backendCacheTTL); ok {
+			return servers, nil
+		}
+	}
+
+	servers, err := s.discoverBackendServers(ctx)
+	if err != nil {
+		s.backendCache.invalidate(s.name)
+		return nil, err
+	}
+	s.backendCache.set(s.name, servers)
+	return servers, nil
+}
+
+// peekBackendServers is backendServers without writing the backend cache: a fresh entry is used, but
+// backends discovered on a miss are not cached, nor is the entry dropped on a failure.
+func (s *LoadBalancer) peekBackendServers(ctx context.Context) (map[string]string, error) {
+	if s.backendCache != nil && !s.forceRefresh {
+		if servers, ok := s.backendCache.get(s.name, s.backendCacheTTL); ok {
+			return servers, nil
+		}
+	}
+	return s.discoverBackendServers(ctx)
+}
+
+// discoverBackendServers returns the load balancer backends for the existing control plane nodes.
+func (s *LoadBalancer) discoverBackendServers(ctx context.Context) (map[string]string, error) {
+	discoverer := s.discoverer
+	if discoverer == nil {
+		discoverer = LabelDiscoverer{}
+	}
+	nodes, err := discoverer.DiscoverBackends(ctx, s.name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to discover load balancer backends")
+	}
+
+	if s.maxBackends > 0 && len(nodes) > s.maxBackends {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+		ignored := make([]string, 0, len(nodes)-s.maxBackends)
+		for _, n := range nodes[s.maxBackends:] {
+			ignored = append(ignored, n.Name)
+		}
+		ctrl.LoggerFrom(ctx).Info("Ignoring control plane nodes above the maximum number of load balancer backends",
+			"maxBackends", s.maxBackends, "ignored", ignored)
+		nodes = nodes[:s.maxBackends]
+	}
+
+	resolver := s.addressResolver
+	if resolver == nil {
+		resolver = ContainerIPResolver{IPTimeout: s.nodeIPTimeout, Clock: s.clock}
+	}
+	backendServers, err := ResolveBackendServers(ctx, nodes, KubeadmContainerPort, resolver)
+	if err != nil {
+		return nil, err
+	}
+	return dedupeBackendServers(ctx, backendServers), nil
+}
+
+// dedupeBackendServers drops the backend servers whose address is already used by
+// another server, keeping the first one by name so the result is stable.
+func dedupeBackendServers(ctx context.Context, backendServers map[string]string) map[string]string {
+	owners := map[string]string{}
+	for _, name := range serverNames(backendServers) {
+		address := backendServers[name]
+		if owner, ok := owners[address]; ok {
+			ctrl.LoggerFrom(ctx).Info("Ignoring control plane node with a duplicate load balancer backend address",
+				"address", address, "node", name, "kept", owner)
+			delete(backendServers, name)
+			continue
+		}
+		owners[address] = name
+	}
+	return backendServers
+}
+
+// backendPools returns the configured backend pools, populated with the backend servers
+// whose containers list the pool in their LoadBalancerPoolLabelKey label.
+func (s *LoadBalancer) backendPools(ctx context.Context, backendServers map[string]string) ([]loadbalancer.BackendPool, error) {
+	filters := labelFilters(ControlPlaneLabels(s.name))
+	filters.AddKeyValue(filterLabel, LoadBalancerPoolLabelKey)
+
+	nodes, err := listContainers(ctx, filters)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	members := map[string]map[string]string{}
+	for _, n := range nodes {
+		// Only backend servers can join a pool, so excluded nodes stay out of the pools too.
+		address, ok := backendServers[n.String()]
+		if !ok {
+			continue
+		}
+		for _, pool := range strings.Split(n.Labels[LoadBalancerPoolLabelKey], ",") {
+			pool = strings.TrimSpace(pool)
+			if members[pool] == nil {
+				members[pool] = map[string]string{}
+			}
+			members[pool][n.String()] = address
+		}
+	}
+
+	pools := make([]loadbalancer.BackendPool, 0, len(s.config.BackendPools))
+	for _, pool := range s.config.BackendPools {
+		pool.Servers = members[pool.Name]
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// peers returns the load balancers of the cluster synchronizing their stick tables, or nil if
+// the cluster has a single load balancer.
+func (s *LoadBalancer) peers(ctx context.Context) ([]loadbalancer.PeerEntry, error) {
+	filters := labelFilters(LoadBalancerLabels(s.name))
+
+	nodes, err := listContainers(ctx, filters)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	lbs := make([]*types.Node, 0, len(nodes))
+	for _, n := range nodes {
+		// Retained load balancers keep the cluster labels but are stopped and no longer serve the cluster.
+		if strings.Contains(n.Name, retainedSuffix) {
+			continue
+		}
+		lbs = append(lbs, n)
+	}
+	if len(lbs) < 2 {
+		return nil, nil
+	}
+
+	addresses, err := BuildBackendServers(ctx, lbs, loadbalancer.PeerPort, 0)
+	if err != nil {
+		return nil, err
+	}
+	peers := make([]loadbalancer.PeerEntry, 0, len(addresses))
+	for _, name := range serverNames(addresses) {
+		peers = append(peers, loadbalancer.PeerEntry{Name: name, Address: addresses[name]})
+	}
+	return peers, nil
+}
+
+// BuildBackendServers returns the load balancer backends for the given nodes, mapping each node
+// name to its IP address and the given port. Nodes that do not have an IP yet, e.g. because they
+// were just created, are retried with a backoff for up to ipTimeout each.
+func BuildBackendServers(ctx context.Context, nodes []*types.Node, port int32, ipTimeout time.Duration) (map[string]string, error) {
+	return ResolveBackendServers(ctx, nodes, port, ContainerIPResolver{IPTimeout: ipTimeout})
+}
+
+// ResolveBackendServers returns the load balancer backends for the given nodes, mapping each node
+// name to the address the resolver returns for it and the given port.
+func ResolveBackendServers(ctx context.Context, nodes []*types.Node, port int32, resolver BackendAddressResolver) (map[string]string, error) {
+	var backendServers = map[string]string{}
+	for _, n := range nodes {
+		address, err := resolver.ResolveBackendAddress(ctx, n, port)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve backend address for container %s", n.String())
+		}
+
+		backendServers[n.String()] = address
+	}
+	return backendServers, nil
+}
+
+// waitForNodeIP returns the IP of the node, retrying with an exponential backoff until the node
+// has one or the timeout expires.
+func waitForNodeIP(ctx context.Context, n *types.Node, timeout time.Duration, clock Clock) (string, error) {
+	clock = clockOrDefault(clock)
+	deadline := clock.Now().Add(timeout)
+	interval := 100 * time.Millisecond
+	for {
+		ipv4, err := n.IP(ctx)
+		if err == nil && ipv4 == "" {
+			err = errors.New("container has no IP address")
+		}
+		if err == nil {
+			return ipv4, nil
+		}
+
+		remaining := deadline.Sub(clock.Now())
+		if remaining <= 0 {
+			if timeout > 0 {
+				return "", errors.Wrapf(err, "no IP address after %s", timeout)
+			}
+			return "", err
+		}
+		if interval > remaining {
+			interval = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return "", errors.Wrap(ctx.Err(), "interrupted waiting for the container IP address")
+		case <-clock.After(interval):
+		}
+		interval *= 2
+		if interval > maxNodeIPRetryInterval {
+			interval = maxNodeIPRetryInterval
+		}
+	}
+}
+
+// applyConfiguration renders the load balancer configuration, writes it into the container and reloads HAProxy.
+// Unless forced, writing and reloading are skipped if the container already has the configuration and HAProxy serves it.
+func (s *LoadBalancer) applyConfiguration(ctx context.Context, configData *loadbalancer.ConfigData, force bool) (UpdateResult, error) {
+	log := ctrl.LoggerFrom(ctx)
+	result := UpdateResult{BackendCount: len(configData.BackendServers)}
+
+	loadBalancerConfig, err := loadbalancer.Config(configData)
+	if err != nil {
+		return result, errors.WithStack(err)
+	}
+
+	caChanged, err := s.writeBackendCA(ctx, configData, force)
+	if err != nil {
+		return result, err
+	}
+
+	// A configuration that cannot be read, e.g. because the container was just created, is always written.
+	current, err := s.container.ReadFile(ctx, loadbalancer.ConfigPath)
+	result.ConfigChanged = err != nil || current != loadBalancerConfig
+
+	if result.ConfigChanged && err == nil && !force && s.configDriftMode != "" {
+		if s.drifted(ctx, current) {
+			if s.configDriftMode == infrav1.LoadBalancerConfigDriftObserve {
+				log.Info("Load balancer configuration was changed in the container, keeping the changes")
+				result.Drifted = true
+				return result, nil
+			}
+			log.Info("Load balancer configuration was changed in the container, reverting the changes")
+		}
+	}
+
+	if !force && !result.ConfigChanged && !caChanged && s.serves(ctx, configData) {
+		log.V(4).Info("Load balancer configuration is up to date")
+		return result, nil
+	}
+
+	// The configuration in the container is only rolled back to if it has healthy backends to return to.
+	var previous string
+	if result.ConfigChanged && err == nil && s.rollbackUnhealthy && configData.EnableStats && len(configData.BackendServers) > 0 {
+		if passing, err := s.passingBackends(ctx); err == nil && passing > 0 {
+			previous = current
+		}
+	}
+
+	applier := configApplierOrDefault(s.applier, configFileOwner(*configData))
+	if result.ConfigChanged || force {
+		log.Info("Updating load balancer configuration")
+		if err == nil && log.V(4).Enabled() {
+			log.V(4).Info("Load balancer configuration changes", "diff",
+				loadbalancer.UnifiedDiff("live", "desired", loadbalancer.RedactConfig(current), loadbalancer.RedactConfig(loadBalancerConfig)))
+		}
+		if err := applier.Apply(ctx, s.container, loadBalancerConfig); err != nil {
+			return result, err
+		}
+		if s.configDriftMode != "" {
+			if err := s.container.WriteFile(ctx, loadbalancer.LastAppliedConfigPath, loadBalancerConfig); err != nil {
+				return result, errors.Wrap(err, "failed to record the applied load balancer configuration")
+			}
+		}
+	} else if err := applier.Reload(ctx, s.container); err != nil {
+		return result, err
+	}
+	result.Reloaded = true
+
+	if err := s.waitForReload(ctx, configData); err != nil {
+		return result, err
+	}
+	if previous != "" {
+		return result, s.rollbackIfUnhealthy(ctx, applier, previous, &result)
+	}
+	return result, nil
+}
+
+// ConfigDrift returns true if the configuration in the container was changed since the provider last wrote it.
+// Changes can only be detected with a LoadBalancerConfigDriftMode, which records the written configuration.
+func (s *LoadBalancer) ConfigDrift(ctx context.Context) (bool, error) {
+	if s.container == nil || s.configDriftMode == "" {
+		return false, nil
+	}
+	current, err := s.container.ReadFile(ctx, loadbalancer.ConfigPath)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read load balancer configuration")
+	}
+	return s.drifted(ctx, current), nil
+}
+
+// drifted returns true if the given configuration differs from the one last written by the provider.
+// Without a record, e.g. before the first configuration is written with drift detection, there is no drift.
+func (s *LoadBalancer) drifted(ctx context.Context, current string) bool {
+	lastApplied, err := s.container.ReadFile(ctx, loadbalancer.LastAppliedConfigPath)
+	if err != nil {
+		return false
+	}
+	return current != lastApplied
+}
+
+// writeBackendCA writes the CA bundle the configuration verifies the backends against, if any.
+// Unless forced, an up to date bundle is not written again. It returns true if the bundle was written,
+// as HAProxy only loads it on reload.
+func (s *LoadBalancer) writeBackendCA(ctx context.Context, configData *loadbalancer.ConfigData, force bool) (bool, error) {
+	if configData.BackendCAFile == "" {
+		return false, nil
+	}
+	if !force {
+		if current, err := s.container.ReadFile(ctx, configData.BackendCAFile); err == nil && current == s.backendCA {
+			return false, nil
+		}
+	}
+	if err := s.container.WriteFileWithOptions(ctx, configData.BackendCAFile, s.backendCA, configFileOptions(configFileOwner(*configData))); err != nil {
+		return false, errors.Wrap(err, "failed to write the backend CA bundle")
+	}
+	return true, nil
+}
+
+// ApplyRawConfig validates a hand-written configuration, writes it into the load balancer container
+// and reloads the load balancer with the ConfigApplier, bypassing the rendering of the provider configuration.
+// It is an escape hatch for debugging: the configuration is overwritten by the next UpdateConfiguration,
+// e.g. when a control plane machine is reconciled.
+func (s *LoadBalancer) ApplyRawConfig(ctx context.Context, config []byte) error {
+	if s.skipPaused(ctx, "apply raw configuration") {
+		return nil
+	}
+	defer defaultClusterLocks.lockCluster(s.name)()
+	if s.container == nil {
+		return errors.New("unable to apply load balancer configuration: load balancer container does not exists")
+	}
+	if err := configApplierOrDefault(s.applier, configFileOwner(s.config)).Apply(ctx, s.container, string(config)); err != nil {
+		return err
+	}
+	ctrl.LoggerFrom(ctx).Info("Applied a raw load balancer configuration; it will be overwritten by the next configuration update")
+	return nil
+}
+
+// serves returns true if HAProxy serves the backend servers of the given configuration. Without the stats
+// page this cannot be observed, and the configuration written in the container is assumed to be loaded.
+func (s *LoadBalancer) serves(ctx context.Context, configData *loadbalancer.ConfigData) bool {
+	if !configData.EnableStats {
+		return true
+	}
+	served, err := s.servedServers(ctx)
+	if err != nil {
+		return false
+	}
+	return servedDifference(served, configData.BackendServers) == nil
+}
+
+// waitForReload waits until HAProxy serves the given configuration, by comparing the servers reported
+// on the stats page with the rendered backend servers. It is a no-op if the stats page is disabled or
+// the reload timeout is zero.
+func (s *LoadBalancer) waitForReload(ctx context.Context, configData *loadbalancer.ConfigData) error {
+	if !configData.EnableStats || s.reloadTimeout <= 0 {
+		return nil
+	}
+
+	clock := clockOrDefault(s.clock)
+	deadline := clock.Now().Add(s.reloadTimeout)
+	for {
+		observed, err := s.servedServers(ctx)
+		// The stats page is briefly unavailable while the new process takes over.
+		if err == nil {
+			if err = servedDifference(observed, configData.BackendServers); err == nil {
+				return nil
+			}
+		}
+
+		if !clock.Now().Before(deadline) {
+			return errors.Wrapf(err, "load balancer configuration was not reloaded within %s", s.reloadTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "interrupted waiting for the load balancer configuration to be reloaded")
+		case <-clock.After(reloadPollInterval):
+		}
+	}
+}
+
+// ReadyForTraffic returns true if the configuration written in the load balancer container routes to
+// at least one backend server that is not in maintenance, so the control plane endpoint can be served.
+func (s *LoadBalancer) ReadyForTraffic(ctx context.Context) (bool, error) {
+	if s.container == nil {
+		return false, nil
+	}
+
+	content, err := s.container.ReadFile(ctx, loadbalancer.ConfigPath)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read load balancer configuration")
+	}
+	parsed, err := loadbalancer.ParseConfig([]byte(content))
+	if err != nil {
+		return false, errors.Wrap(err, "failed to parse load balancer configuration")
+	}
+
+	backend := parsed.Backend(loadbalancer.BackendName)
+	if backend == nil {
+		return false, nil
+	}
+	for _, server := range backend.Servers {
+		disabled := false
+		for _, option := range server.Options {
+			if option == "disabled" {
+				disabled = true
+			}
+		}
+		if !disabled {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// WaitForHealthyBackends polls the stats page until HAProxy reports at least minHealthy backend
+// servers up, e.g. before declaring the control plane endpoint ready in tests. It requires the stats
+// page, and fails listing the servers that are not up when the timeout elapses.
+func (s *LoadBalancer) WaitForHealthyBackends(ctx context.Context, minHealthy int, timeout time.Duration) error {
+	if s.container == nil {
+		return errors.New("unable to check load balancer backends: load balancer container does not exists")
+	}
+	if !s.config.EnableStats {
+		return errors.New("unable to check load balancer backends: the load balancer stats page is not enabled")
+	}
+
+	clock := clockOrDefault(s.clock)
+	deadline := clock.Now().Add(timeout)
+	for {
+		stats, err := s.stats(ctx)
+		// The stats page is briefly unavailable while HAProxy reloads.
+		var up, down []string
+		if err == nil {
+			for _, server := range loadbalancer.Servers(stats, loadbalancer.BackendName) {
+				if server.IsUp() {
+					up = append(up, server.ServiceName)
+				} else {
+					down = append(down, fmt.Sprintf("%s (%s)", server.ServiceName, server.Status))
+				}
+			}
+			if len(up) >= minHealthy {
+				return nil
+			}
+		}
+
+		if !clock.Now().Before(deadline) {
+			if err != nil {
+				return errors.Wrapf(err, "load balancer backends were not healthy within %s", timeout)
+			}
+			return errors.Errorf("load balancer backends were not healthy within %s: %d servers up, expected at least %d, not up: %v", timeout, len(up), minHealthy, down)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "interrupted waiting for the load balancer backends to be healthy")
+		case <-clock.After(healthPollInterval):
+		}
+	}
+}
+
+// Hibernated returns true if the load balancer serves a configuration refusing connections
+// because the control plane of a hibernated cluster was scaled to zero.
+func (s *LoadBalancer) Hibernated(ctx context.Context) (bool, error) {
+	if s.container == nil {
+		return false, nil
+	}
+
+	content, err := s.container.ReadFile(ctx, loadbalancer.ConfigPath)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read load balancer configuration")
+	}
+	parsed, err := loadbalancer.ParseConfig([]byte(content))
+	if err != nil {
+		return false, errors.Wrap(err, "failed to parse load balancer configuration")
+	}
+	return parsed.Hibernated(), nil
+}
+
+// CurrentBackends returns the control plane backend servers of the configuration written in the load
+// balancer container, mapping their names to their addresses. Unlike the backends the provider discovers,
+// they reflect the live state of the load balancer, e.g. to verify it in tests.
+func (s *LoadBalancer) CurrentBackends(ctx context.Context) (map[string]string, error) {
+	if s.container == nil {
+		return nil, errors.New("unable to read load balancer backends: load balancer container does not exists")
+	}
+
+	content, err := s.container.ReadFile(ctx, loadbalancer.ConfigPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read load balancer backends: the load balancer has no configuration yet")
+	}
+	parsed, err := loadbalancer.ParseConfig([]byte(content))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse load balancer configuration")
+	}
+
+	backends := map[string]string{}
+	if backend := parsed.Backend(loadbalancer.BackendName); backend != nil {
+		for _, server := range backend.Servers {
+			backends[server.Name] = server.Address
+		}
+	}
+	return backends, nil
+}
+
+// ConfigApplied checks that HAProxy serves the configuration for the current control plane nodes,
+// returning an error describing the difference if it does not.
+func (s *LoadBalancer) ConfigApplied(ctx context.Context) error {
+	if s.container == nil {
+		return errors.New("unable to check load balancer configuration: load balancer container does not exists")
+	}
+
+	backendServers, err := s.backendServers(ctx)
+	if err != nil {
+		return err
+	}
+	expected := serverNames(backendServers)
+
+	served, err := s.servedServers(ctx)
+	if err != nil {
+		return err
+	}
+	if observed := serverNames(served); strings.Join(observed, ",") != strings.Join(expected, ",") {
+		return errors.Errorf("load balancer serves servers %v, expected %v", observed, expected)
+	}
+	if err := servedDifference(served, backendServers); err != nil {
+		return errors.Wrap(err, "load balancer serves outdated server addresses")
+	}
+	return nil
+}
+
+// servedServers returns the addresses of the control plane servers HAProxy currently serves, by name.
+// The address is empty if the stats page does not report it.
+func (s *LoadBalancer) servedServers(ctx context.Context) (map[string]string, error) {
+	stats, err := s.stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := map[string]string{}
+	for _, server := range loadbalancer.Servers(stats, loadbalancer.BackendName) {
+		servers[server.ServiceName] = server.Address
+	}
+	return servers, nil
+}
+
+// servedDifference returns an error describing how the served servers differ from the backend servers
+// of a configuration, nil if HAProxy serves them. Servers are compared by name and address, so a control
+// plane node keeping its name but getting a new IP, e.g. after a network reconnect, is not reported as
+// served. Addresses the stats page does not report, or that are host names, are not compared: HAProxy
+// reports the resolved IP of a host name.
+func servedDifference(served, backendServers map[string]string) error {
+	observed, expected := serverNames(served), serverNames(backendServers)
+	if strings.Join(observed, ",") != strings.Join(expected, ",") {
+		return errors.Errorf("expected servers %v, got %v", expected, observed)
+	}
+	for _, name := range expected {
+		if address := served[name]; address != "" && !sameIPAddress(address, backendServers[name]) {
+			return errors.Errorf("expected server %s at %s, got %s", name, backendServers[name], address)
+		}
+	}
+	return nil
+}
+
+// sameIPAddress returns false if both addresses are IP:port addresses for a different IP or port.
+func sameIPAddress(served, configured string) bool {
+	configuredHost, configuredPort, err := net.SplitHostPort(configured)
+	configuredIP := net.ParseIP(configuredHost)
+	if err != nil || configuredIP == nil {
+		return true
+	}
+	// HAProxy may report IPv6 addresses without brackets.
+	i := strings.LastIndex(served, ":")
+	if i < 0 {
+		return true
+	}
+	servedIP := net.ParseIP(strings.Trim(served[:i], "[]"))
+	// Servers rendered without a port, see loadbalancer.ConfigData.PreserveDestinationPort, are reported with port 0.
+	servedPort := served[i+1:]
+	return servedIP == nil || (servedIP.Equal(configuredIP) && (servedPort == configuredPort || servedPort == "0"))
+}
+
+// serverNames returns the sorted names of the given backend servers.
+func serverNames(backendServers map[string]string) []string {
+	names := make([]string, 0, len(backendServers))
+	for server := range backendServers {
+		names = append(names, server)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stats returns the statistics reported on the load balancer stats page.
+func (s *LoadBalancer) stats(ctx context.Context) ([]loadbalancer.Stat, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := s.container.Commander.Command("wget", "-q", "-O", "-", loadbalancer.StatsCSVURL)
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(&stderr)
+	if err := cmd.Run(ctx); err != nil {
+		return nil, errors.Wrapf(err, "failed to read load balancer stats: %s", stderr.String())
+	}
+	return loadbalancer.ParseStats(stdout.Bytes())
+}
+
+// IP returns the load balancer IP address: its IPv6 address for IPv6 clusters, its IPv4 address otherwise,
+// including for dual-stack clusters, which accept IPv4 and IPv6 connections.
+func (s *LoadBalancer) IP(ctx context.Context) (string, error) {
+	lbIP, err := s.containerIP(ctx)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if lbIP == "" {
+		// if there is a load balancer container with the same name exists but is stopped, it may not have IP address associated with it.
+		return "", errors.Errorf("load balancer IP cannot be empty: container %s does not have an associated IP address", s.containerName())
+	}
+	return lbIP, nil
+}
+
+// NetworkIP returns the IPv4 address of the load balancer container on the given network, e.g. one of
+// the DockerClusterSpec.LoadBalancerNetworks; IP returns the address on the cluster network.
+func (s *LoadBalancer) NetworkIP(ctx context.Context, network string) (string, error) {
+	if s.container == nil {
+		return "", errors.New("unable to get load balancer IP: load balancer container does not exists")
+	}
+
+	containerRuntime, err := container.RuntimeFrom(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to connect to container runtime")
+	}
+	info, err := containerRuntime.InspectContainer(ctx, s.containerName())
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	lbIP, ok := info.IPAddresses[network]
+	if !ok {
+		return "", errors.Errorf("load balancer container %s does not have an IP address on network %s", s.containerName(), network)
+	}
+	return lbIP, nil
+}
+
+// containerIP returns the address of the load balancer container in the IP family of the endpoint it reports.
+func (s *LoadBalancer) containerIP(ctx context.Context) (string, error) {
+	ipv4, ipv6, err := s.container.IPs(ctx)
+	if s.config.IPFamily == loadbalancer.IPFamilyIPv6 {
+		return ipv6, err
+	}
+	return ipv4, err
+}
+
+// WaitForIP polls the load balancer container until it has an IP address, e.g. right after it is
+// created, and returns the address. It fails without waiting out the timeout if the container exited,
+// e.g. because HAProxy rejected its configuration at startup.
+func (s *LoadBalancer) WaitForIP(ctx context.Context, timeout time.Duration) (string, error) {
+	if s.container == nil {
+		return "", errors.New("unable to get load balancer IP: load balancer container does not exists")
+	}
+
+	clock := clockOrDefault(s.clock)
+	deadline := clock.Now().Add(timeout)
+	for {
+		lbIP, err := s.containerIP(ctx)
+		if err == nil && lbIP != "" {
+			return lbIP, nil
+		}
+		if err := s.exitedError(ctx); err != nil {
+			return "", err
+		}
+
+		if !clock.Now().Before(deadline) {
+			if err != nil {
+				return "", errors.Wrapf(err, "load balancer container %s did not get an IP address within %s", s.containerName(), timeout)
+			}
+			return "", errors.Errorf("load balancer container %s did not get an IP address within %s", s.containerName(), timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return "", errors.Wrap(ctx.Err(), "interrupted waiting for the load balancer IP address")
+		case <-clock.After(ipPollInterval):
+		}
+	}
+}
+
+// exitedError returns an error describing why the load balancer container exited, or nil if it did not
+// or its state cannot be inspected.
+func (s *LoadBalancer) exitedError(ctx context.Context) error {
+	containerRuntime, err := container.RuntimeFrom(ctx)
+	if err != nil {
+		return nil
+	}
+	info, err := containerRuntime.InspectContainer(ctx, s.containerName())
+	if err != nil || !info.State.Exited() {
+		return nil
+	}
+
+	reason := fmt.Sprintf("exit code %d", info.State.ExitCode)
+	if info.State.OOMKilled {
+		reason += ", killed for running out of memory"
+	}
+	if info.State.Error != "" {
+		reason += ": " + info.State.Error
+	}
+	return errors.Errorf("load balancer container %s exited (%s) before getting an IP address, see docker logs %s",
+		s.containerName(), reason, s.containerName())
+}
+
+// MetricsEndpoint returns the URL the load balancer Prometheus metrics can be scraped from on the
+// load balancer network, or an empty string if metrics are not enabled.
+func (s *LoadBalancer) MetricsEndpoint(ctx context.Context) (string, error) {
+	if s.config.MetricsPort == 0 {
+		return "", nil
+	}
+
+	lbIP, err := s.IP(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("http://%s%s", net.JoinHostPort(lbIP, strconv.Itoa(s.config.MetricsPort)), loadbalancer.MetricsPath), nil
+}
+
+// Delete the docker container hosting the cluster load balancer.
+// If the load balancer is retained on delete, the container is stopped and renamed to
+// <cluster>-lb-retained-<timestamp> instead, and must be removed manually with docker rm.
+func (s *LoadBalancer) Delete(ctx context.Context) error {
+	if s.skipPaused(ctx, "delete") {
+		return nil
+	}
+	defer defaultClusterLocks.lockCluster(s.name)()
+	if s.retainOnDelete {
+		return s.retain(ctx)
+	}
+	return s.deleteContainer(ctx)
+}
+
+// retain stops the load balancer container and renames it so it is no longer managed for the cluster.
+func (s *LoadBalancer) retain(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	if s.container == nil {
+		return nil
+	}
+
+	retainedName := s.containerName() + retainedSuffix + clockOrDefault(s.clock).Now().UTC().Format("20060102150405")
+	log.Info("Retaining load balancer container", "container", retainedName)
+	if err := s.container.Stop(ctx, s.stopTimeout); err != nil {
+		return err
+	}
+	if err := s.container.Rename(ctx, retainedName); err != nil {
+		return err
+	}
+	s.container = nil
+	return nil
+}
+
+func (s *LoadBalancer) deleteContainer(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	if s.container != nil {
+		log.Info("Deleting load balancer container")
+		// Let HAProxy finish serving in-flight connections, the removal below kills it anyway.
+		if s.stopTimeout > 0 {
+			if err := s.container.Stop(ctx, s.stopTimeout); err != nil {
+				return err
+			}
+		}
 		if err := s.container.Delete(ctx); err != nil {
 			return err
 		}
@@ -0,0 +1,65 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Adopt migrates a load balancer container created by an earlier version of the provider to the current
+// labeling scheme, so it is discovered like the containers created since. Docker cannot change the labels
+// of a container, so it is recreated and reconfigured: the control plane endpoint flaps to the new container
+// IP, as with the RecreateLoadBalancerAnnotation. It returns true if the container was migrated.
+func (s *LoadBalancer) Adopt(ctx context.Context) (bool, error) {
+	if s.container == nil || s.paused {
+		return false, nil
+	}
+	missing := s.missingLabels()
+	if len(missing) == 0 {
+		return false, nil
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Migrating load balancer container created by an earlier provider version to the current labels",
+		"container", s.container.Name, "missingLabels", missing)
+	if err := s.Recreate(ctx); err != nil {
+		return false, errors.Wrap(err, "failed to recreate load balancer container with the current labels")
+	}
+	if _, err := s.UpdateConfiguration(ctx); err != nil {
+		return true, errors.Wrap(err, "failed to update load balancer configuration")
+	}
+	return true, nil
+}
+
+// missingLabels returns the keys of the labels the provider sets on the load balancer containers it creates
+// that the current container does not have, e.g. the owner UID label added after the container was created.
+func (s *LoadBalancer) missingLabels() []string {
+	keys := []string{ClusterLabelKey, NodeRoleLabelKey}
+	if s.ownerUID != "" {
+		keys = append(keys, ownerUIDLabelKey)
+	}
+
+	var missing []string
+	for _, key := range keys {
+		if _, ok := s.container.Labels[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
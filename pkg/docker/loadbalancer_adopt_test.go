@@ -0,0 +1,99 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+)
+
+func TestLoadBalancerAdopt(t *testing.T) {
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}}
+
+	tests := []struct {
+		name         string
+		ownerUID     string
+		labels       map[string]string
+		paused       bool
+		wantMigrated bool
+	}{
+		{
+			name:         "migrates a container created before the owner label",
+			ownerUID:     "uid-1",
+			labels:       map[string]string{},
+			wantMigrated: true,
+		},
+		{
+			name:     "keeps a container with the current labels",
+			ownerUID: "uid-1",
+			labels:   map[string]string{ownerUIDLabelKey: "uid-1"},
+		},
+		{
+			name:   "keeps a container without an owner to label",
+			labels: map[string]string{},
+		},
+		{
+			name:     "keeps the container while paused",
+			ownerUID: "uid-1",
+			labels:   map[string]string{},
+			paused:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-lb", Image: "TestImage", Labels: tt.labels}})
+			defer containerRuntime.SetListContainersResult(nil)
+			containerRuntime.ResetDeleteContainerCallLogs()
+
+			dockerCluster := &infrav1.DockerCluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", UID: types.UID(tt.ownerUID)}}
+			if tt.paused {
+				dockerCluster.Annotations = map[string]string{clusterv1.PausedAnnotation: ""}
+			}
+			lb, err := NewLoadBalancer(ctx, cluster, dockerCluster)
+			g.Expect(err).ToNot(HaveOccurred())
+			creator := &fakeLBCreator{}
+			lb.lbCreator = creator
+			lb.reloadTimeout = 0
+			applier := &fakeConfigApplier{}
+			lb.SetConfigApplier(applier)
+
+			migrated, err := lb.Adopt(ctx)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(migrated).To(Equal(tt.wantMigrated))
+			if !tt.wantMigrated {
+				g.Expect(creator.calls).To(BeEmpty())
+				g.Expect(containerRuntime.DeleteContainerCalls()).To(BeEmpty())
+				return
+			}
+			g.Expect(containerRuntime.DeleteContainerCalls()).To(Equal([]string{"test-cluster-lb"}))
+			g.Expect(creator.opts).To(HaveLen(1))
+			g.Expect(creator.opts[0].Labels).To(HaveKeyWithValue(ownerUIDLabelKey, "uid-1"))
+			g.Expect(applier.applied).To(HaveLen(1))
+		})
+	}
+}
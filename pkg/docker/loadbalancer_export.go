@@ -0,0 +1,110 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+// LBExport is a snapshot of the load balancer container and of the HAProxy configuration it serves,
+// e.g. for attaching to bug reports. Credentials in the configuration are redacted.
+type LBExport struct {
+	// Name is the name of the load balancer container.
+	Name string `json:"name"`
+	// Image is the image reference the container was created from.
+	Image string `json:"image"`
+	// ImageID is the ID of the image the container is running.
+	ImageID string `json:"imageID"`
+	// Labels are the labels set on the container.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Networks are the names of the networks the container is attached to.
+	Networks []string `json:"networks,omitempty"`
+	// Mounts are the volumes and bind mounts of the container.
+	Mounts []container.Mount `json:"mounts,omitempty"`
+	// Config is the HAProxy configuration read from the container, with credentials redacted.
+	Config string `json:"config"`
+}
+
+// Export gathers the runtime details of the load balancer container and its live HAProxy configuration.
+func (s *LoadBalancer) Export(ctx context.Context) (*LBExport, error) {
+	if s.container == nil {
+		return nil, errors.New("unable to export load balancer: load balancer container does not exists")
+	}
+
+	containerRuntime, err := container.RuntimeFrom(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to container runtime")
+	}
+
+	info, err := containerRuntime.InspectContainer(ctx, s.containerName())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	config, err := s.RedactedConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LBExport{
+		Name:     info.Name,
+		Image:    info.Image,
+		ImageID:  info.ImageID,
+		Labels:   info.Labels,
+		Networks: info.Networks,
+		Mounts:   info.Mounts,
+		Config:   config,
+	}, nil
+}
+
+// RedactedConfig returns the HAProxy configuration read from the load balancer container, with credentials redacted.
+func (s *LoadBalancer) RedactedConfig(ctx context.Context) (string, error) {
+	if s.container == nil {
+		return "", errors.New("unable to read load balancer configuration: load balancer container does not exists")
+	}
+
+	config, err := s.container.ReadFile(ctx, loadbalancer.ConfigPath)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read load balancer configuration")
+	}
+	return loadbalancer.RedactConfig(config), nil
+}
+
+// ConfigDiff returns the changes UpdateConfiguration would make to the HAProxy configuration in the load
+// balancer container, as a unified diff from the live configuration to the desired one, with credentials
+// redacted. It is empty if the configuration is up to date. Like EffectiveConfigData, it does not fill the
+// backend cache, fails if the load balancer is paused, and does not account for the backends kept while
+// their removal is unconfirmed or restored from the config snapshot.
+func (s *LoadBalancer) ConfigDiff(ctx context.Context) (string, error) {
+	live, err := s.RedactedConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	configData, err := s.EffectiveConfigData(ctx)
+	if err != nil {
+		return "", err
+	}
+	desired, err := loadbalancer.Config(configData)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return loadbalancer.UnifiedDiff("live", "desired", live, loadbalancer.RedactConfig(desired)), nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/fake"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+func TestLoadBalancerExport(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	labels := map[string]string{
+		ClusterLabelKey:  "test-cluster",
+		NodeRoleLabelKey: constants.ExternalLoadBalancerNodeRoleValue,
+	}
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-lb", Image: "TestImage", Labels: labels}})
+	defer containerRuntime.SetListContainersResult(nil)
+	containerRuntime.SetContainerNetworks(map[string][]string{"test-cluster-lb": {"kind"}})
+	defer containerRuntime.SetContainerNetworks(nil)
+	containerRuntime.SetExecContainerOutputs(map[string]string{"cat": "frontend stats\n  stats auth admin:s3cr3t\n"})
+	defer containerRuntime.SetExecContainerOutputs(nil)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+
+	export, err := lb.Export(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(export).To(Equal(&LBExport{
+		Name:     "test-cluster-lb",
+		Image:    "TestImage",
+		ImageID:  "test-cluster-lbImageID",
+		Labels:   labels,
+		Networks: []string{"kind"},
+		Config:   "frontend stats\n  stats auth admin:<redacted>\n",
+	}))
+
+	data, err := json.Marshal(export)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).ToNot(ContainSubstring("s3cr3t"))
+
+	config, err := lb.RedactedConfig(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(config).To(Equal(export.Config))
+
+	t.Run("no container", func(t *testing.T) {
+		g := NewWithT(t)
+		lb := &LoadBalancer{name: "test-cluster"}
+		_, err := lb.Export(ctx)
+		g.Expect(err).To(HaveOccurred())
+		_, err = lb.RedactedConfig(ctx)
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestLoadBalancerConfigDiff(t *testing.T) {
+	g := NewWithT(t)
+	lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+	ctx := container.RuntimeInto(context.Background(), lbNode)
+	lbNode.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer lbNode.SetListContainersResult(nil)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(nil),
+		container: lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+	}
+	lb.config.MetricsPort = loadbalancer.DefaultMetricsPort
+	lb.SetMetricsAuth("admin", "s3cr3t")
+	desired, err := lb.EffectiveConfigData(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	live, err := loadbalancer.Config(desired)
+	g.Expect(err).ToNot(HaveOccurred())
+	lbNode.SetFile(loadbalancer.ConfigPath, live)
+
+	diff, err := lb.ConfigDiff(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(diff).To(BeEmpty())
+
+	lbNode.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}, {Name: "test-cluster-cp-2"}})
+	lb.SetMetricsAuth("admin", "n3wp4ss")
+	diff, err = lb.ConfigDiff(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(diff).To(HavePrefix("--- live\n+++ desired\n@@ "))
+	g.Expect(diff).To(ContainSubstring("\n+  server test-cluster-cp-2 test-cluster-cp-2IPv4:6443 check"))
+	g.Expect(diff).ToNot(ContainSubstring("-  server test-cluster-cp-1"))
+	// The credentials are redacted on both sides, so their change does not show.
+	g.Expect(diff).ToNot(ContainSubstring("s3cr3t"))
+	g.Expect(diff).ToNot(ContainSubstring("n3wp4ss"))
+	g.Expect(diff).ToNot(ContainSubstring("insecure-password"))
+
+	t.Run("no container", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := (&LoadBalancer{name: "test-cluster"}).ConfigDiff(ctx)
+		g.Expect(err).To(HaveOccurred())
+	})
+}
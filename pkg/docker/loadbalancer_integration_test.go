@@ -0,0 +1,151 @@
+//go:build integration
+// +build integration
+
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+// The tests in this file run against the docker daemon of the environment, with the real Manager and
+// HAProxyApplier, to catch the rendering and reload regressions the fakes cannot. Run them with:
+//
+//	go test -tags integration ./pkg/docker/ -run Integration
+//
+// They are skipped when the daemon is unavailable.
+
+// fixedAddressResolver resolves the backend nodes to fixed addresses, as the fake backends are not containers.
+type fixedAddressResolver map[string]string
+
+func (r fixedAddressResolver) ResolveBackendAddress(_ context.Context, node *types.Node, _ int32) (string, error) {
+	address, ok := r[node.Name]
+	if !ok {
+		return "", errors.Errorf("no address for backend %s", node.Name)
+	}
+	return address, nil
+}
+
+// dockerRuntimeOrSkip returns a context with the docker runtime of the environment, skipping the test
+// if the daemon is unavailable.
+func dockerRuntimeOrSkip(t *testing.T) context.Context {
+	t.Helper()
+	runtime, err := container.NewDockerClient()
+	if err != nil {
+		t.Skipf("Docker is unavailable: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := runtime.ListContainers(ctx, container.FilterBuilder{}); err != nil {
+		t.Skipf("Docker is unavailable: %v", err)
+	}
+	return container.RuntimeInto(ctx, runtime)
+}
+
+func TestLoadBalancerIntegration(t *testing.T) {
+	g := NewWithT(t)
+	ctx := dockerRuntimeOrSkip(t)
+
+	name := fmt.Sprintf("capd-integration-%d", time.Now().Unix())
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	// The default bridge network always exists, unlike the kind network.
+	dockerCluster := &infrav1.DockerCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, UID: "integration"},
+		Spec:       infrav1.DockerClusterSpec{Network: "bridge"},
+	}
+	lb, err := NewLoadBalancer(ctx, cluster, dockerCluster)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(lb.Create(ctx)).To(Succeed())
+	t.Cleanup(func() {
+		if err := lb.Delete(ctx); err != nil {
+			t.Errorf("failed to delete load balancer container %s: %v", lb.containerName(), err)
+		}
+	})
+	_, err = lb.WaitForIP(ctx, 30*time.Second)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Nothing listens on the backend addresses: HAProxy serves the servers, their health checks fail.
+	backends := func(names ...string) {
+		nodes := make([]*types.Node, 0, len(names))
+		addresses := fixedAddressResolver{}
+		for i, node := range names {
+			nodes = append(nodes, types.NewNode(node, "", constants.ControlPlaneNodeRoleValue))
+			addresses[node] = fmt.Sprintf("192.0.2.%d:6443", i+1)
+		}
+		lb.SetBackendDiscoverer(StaticDiscoverer{Nodes: nodes})
+		lb.SetBackendAddressResolver(addresses)
+	}
+	served := func() map[string]string {
+		stats, err := lb.stats(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		servers := map[string]string{}
+		for _, server := range loadbalancer.Servers(stats, loadbalancer.BackendName) {
+			servers[server.ServiceName] = server.Address
+		}
+		return servers
+	}
+
+	t.Run("serves the configured backends", func(t *testing.T) {
+		g := NewWithT(t)
+		backends(name+"-cp-1", name+"-cp-2")
+		result, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Reloaded).To(BeTrue())
+		g.Expect(lb.ConfigApplied(ctx)).To(Succeed())
+		g.Expect(served()).To(Equal(map[string]string{
+			name + "-cp-1": "192.0.2.1:6443",
+			name + "-cp-2": "192.0.2.2:6443",
+		}))
+	})
+
+	t.Run("reloads when the backends change", func(t *testing.T) {
+		g := NewWithT(t)
+		backends(name + "-cp-2")
+		result, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.ConfigChanged).To(BeTrue())
+		g.Expect(served()).To(Equal(map[string]string{name + "-cp-2": "192.0.2.1:6443"}))
+	})
+
+	t.Run("does not reload an up to date configuration", func(t *testing.T) {
+		g := NewWithT(t)
+		result, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Reloaded).To(BeFalse())
+	})
+
+	t.Run("rejects a configuration HAProxy cannot load", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(lb.ApplyRawConfig(ctx, []byte("global\n  no-such-directive\n"))).To(MatchError(ContainSubstring("invalid load balancer configuration")))
+		g.Expect(lb.ConfigApplied(ctx)).To(Succeed())
+	})
+}
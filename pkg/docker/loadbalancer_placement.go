@@ -0,0 +1,114 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+)
+
+// placementConstraints returns the scheduling filters placing the load balancer container as requested
+// by its placement hints. They are only acted on by multi-host runtimes, e.g. Docker Swarm standalone.
+func (s *LoadBalancer) placementConstraints(ctx context.Context) ([]string, error) {
+	if s.placement == nil {
+		return nil, nil
+	}
+	if s.placement.HostSelector != "" {
+		return []string{"constraint:node==" + s.placement.HostSelector}, nil
+	}
+
+	switch s.placement.ControlPlaneAffinity {
+	case infrav1.LoadBalancerColocateWithControlPlane:
+		host, err := s.controlPlaneMajorityHost(ctx)
+		if err != nil || host == "" {
+			return nil, err
+		}
+		return []string{"constraint:node==" + host}, nil
+	case infrav1.LoadBalancerSpreadFromControlPlane:
+		nodes, err := s.controlPlaneNodeNames(ctx)
+		if err != nil {
+			return nil, err
+		}
+		constraints := make([]string, 0, len(nodes))
+		for _, name := range nodes {
+			constraints = append(constraints, "affinity:container!="+name)
+		}
+		return constraints, nil
+	}
+	return nil, nil
+}
+
+// controlPlaneNodeNames returns the sorted names of the control plane nodes the load balancer routes to.
+func (s *LoadBalancer) controlPlaneNodeNames(ctx context.Context) ([]string, error) {
+	discoverer := s.discoverer
+	if discoverer == nil {
+		discoverer = LabelDiscoverer{}
+	}
+	nodes, err := discoverer.DiscoverBackends(ctx, s.name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to discover the control plane nodes placing the load balancer")
+	}
+	names := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		names = append(names, n.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// controlPlaneMajorityHost returns the docker host running most of the control plane nodes, the first one
+// by name on a tie. It returns an empty host if the runtime does not report the hosts of the containers,
+// e.g. a single docker daemon, or if there is no control plane node yet.
+func (s *LoadBalancer) controlPlaneMajorityHost(ctx context.Context) (string, error) {
+	names, err := s.controlPlaneNodeNames(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	containerRuntime, err := container.RuntimeFrom(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to connect to container runtime")
+	}
+	counts := map[string]int{}
+	for _, name := range names {
+		info, err := containerRuntime.InspectContainer(ctx, name)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if info.Host != "" {
+			counts[info.Host]++
+		}
+	}
+
+	majority := ""
+	for host, count := range counts {
+		if count > counts[majority] || (count == counts[majority] && host < majority) {
+			majority = host
+		}
+	}
+	if majority == "" {
+		ctrl.LoggerFrom(ctx).Info("Not colocating the load balancer with the control plane, the container runtime does not report the hosts of the control plane nodes",
+			"controlPlaneNodes", names)
+	}
+	return majority, nil
+}
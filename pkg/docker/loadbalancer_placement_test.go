@@ -0,0 +1,107 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+)
+
+func TestLoadBalancerPlacement(t *testing.T) {
+	controlPlane := StaticDiscoverer{Nodes: []*types.Node{
+		types.NewNode("test-cluster-cp-3", "", constants.ControlPlaneNodeRoleValue),
+		types.NewNode("test-cluster-cp-1", "", constants.ControlPlaneNodeRoleValue),
+		types.NewNode("test-cluster-cp-2", "", constants.ControlPlaneNodeRoleValue),
+	}}
+
+	tests := []struct {
+		name      string
+		placement *infrav1.LoadBalancerPlacement
+		hosts     map[string]string
+		want      []string
+	}{
+		{
+			name: "lets the runtime place the container by default",
+		},
+		{
+			name:      "pins the container to the selected host",
+			placement: &infrav1.LoadBalancerPlacement{HostSelector: "docker-host-2"},
+			want:      []string{"constraint:node==docker-host-2"},
+		},
+		{
+			name:      "colocates the container with most of the control plane nodes",
+			placement: &infrav1.LoadBalancerPlacement{ControlPlaneAffinity: infrav1.LoadBalancerColocateWithControlPlane},
+			hosts: map[string]string{
+				"test-cluster-cp-1": "docker-host-2",
+				"test-cluster-cp-2": "docker-host-1",
+				"test-cluster-cp-3": "docker-host-2",
+			},
+			want: []string{"constraint:node==docker-host-2"},
+		},
+		{
+			name:      "colocates the container with the first host by name on a tie",
+			placement: &infrav1.LoadBalancerPlacement{ControlPlaneAffinity: infrav1.LoadBalancerColocateWithControlPlane},
+			hosts: map[string]string{
+				"test-cluster-cp-1": "docker-host-3",
+				"test-cluster-cp-2": "docker-host-1",
+			},
+			want: []string{"constraint:node==docker-host-1"},
+		},
+		{
+			name:      "does not colocate the container when the runtime does not report the hosts",
+			placement: &infrav1.LoadBalancerPlacement{ControlPlaneAffinity: infrav1.LoadBalancerColocateWithControlPlane},
+		},
+		{
+			name:      "spreads the container from the control plane nodes",
+			placement: &infrav1.LoadBalancerPlacement{ControlPlaneAffinity: infrav1.LoadBalancerSpreadFromControlPlane},
+			want: []string{
+				"affinity:container!=test-cluster-cp-1",
+				"affinity:container!=test-cluster-cp-2",
+				"affinity:container!=test-cluster-cp-3",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.SetContainerHosts(tt.hosts)
+			defer containerRuntime.SetContainerHosts(nil)
+
+			creator := &fakeLBCreator{}
+			lb := &LoadBalancer{
+				name:       "test-cluster",
+				image:      "TestImage",
+				lbCreator:  creator,
+				discoverer: controlPlane,
+				placement:  tt.placement,
+			}
+			g.Expect(lb.Create(ctx)).To(Succeed())
+
+			g.Expect(creator.opts).To(HaveLen(1))
+			g.Expect(creator.opts[0].PlacementConstraints).To(Equal(tt.want))
+		})
+	}
+}
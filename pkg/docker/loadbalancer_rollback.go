@@ -0,0 +1,92 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+// rollbackTimeout is how long the backends are given to pass their health checks after a configuration
+// update before the previous configuration is restored. It spans several check intervals, as the checks
+// of a new configuration only complete after the reload.
+const rollbackTimeout = 20 * time.Second
+
+// getLoadBalancerRollbackUnhealthyConfig returns whether a configuration leaving no backend healthy is rolled back.
+func getLoadBalancerRollbackUnhealthyConfig(dockerCluster *infrav1.DockerCluster) bool {
+	if dockerCluster != nil && dockerCluster.Spec.LoadBalancerRollbackUnhealthyConfig != nil {
+		return *dockerCluster.Spec.LoadBalancerRollbackUnhealthyConfig
+	}
+	return true
+}
+
+// passingBackends returns the number of backend servers that are up and passed their last health check.
+func (s *LoadBalancer) passingBackends(ctx context.Context) (int, error) {
+	stats, err := s.stats(ctx)
+	if err != nil {
+		return 0, err
+	}
+	passing := 0
+	for _, server := range loadbalancer.Servers(stats, loadbalancer.BackendName) {
+		if server.IsUp() && server.CheckPassed() {
+			passing++
+		}
+	}
+	return passing, nil
+}
+
+// rollbackIfUnhealthy waits for a backend to pass its health checks with the configuration just applied,
+// and applies the previous configuration if none does within rollbackTimeout, so a broken update does not
+// sever the control plane endpoint. It returns an error once rolled back, to report the update as failed.
+func (s *LoadBalancer) rollbackIfUnhealthy(ctx context.Context, applier ConfigApplier, previous string, result *UpdateResult) error {
+	clock := clockOrDefault(s.clock)
+	deadline := clock.Now().Add(rollbackTimeout)
+	for {
+		// The stats page is briefly unavailable while HAProxy reloads.
+		if passing, err := s.passingBackends(ctx); err == nil && passing > 0 {
+			return nil
+		}
+		if !clock.Now().Before(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "interrupted waiting for the load balancer backends to pass their health checks")
+		case <-clock.After(healthPollInterval):
+		}
+	}
+
+	ctrl.LoggerFrom(ctx).Info("No load balancer backend passed its health checks after the configuration update, rolling back",
+		"timeout", rollbackTimeout)
+	if err := applier.Apply(ctx, s.container, previous); err != nil {
+		return errors.Wrap(err, "failed to roll back the load balancer configuration leaving no backend healthy")
+	}
+	if s.configDriftMode != "" {
+		if err := s.container.WriteFile(ctx, loadbalancer.LastAppliedConfigPath, previous); err != nil {
+			return errors.Wrap(err, "failed to record the rolled back load balancer configuration")
+		}
+	}
+	result.RolledBack = true
+	return errors.Errorf("no load balancer backend passed its health checks within %s after the configuration update, "+
+		"rolled back to the previous configuration", rollbackTimeout)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/fake"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+// statsAfterApply is a ConfigApplier replacing the stats the container reports once a configuration is applied.
+type statsAfterApply struct {
+	fakeConfigApplier
+	runtime *fake.Node
+	stats   string
+}
+
+func (a *statsAfterApply) Apply(ctx context.Context, node *types.Node, config string) error {
+	if len(a.applied) == 0 {
+		a.runtime.SetExecContainerOutputs(map[string]string{"wget": a.stats})
+	}
+	return a.fakeConfigApplier.Apply(ctx, node, config)
+}
+
+func TestLoadBalancerRollbackUnhealthyConfig(t *testing.T) {
+	passing := "# pxname,svname,status,check_status,addr\n" +
+		"kube-apiservers,test-cluster-cp-1,UP,L7OK,172.18.0.3:6443\n"
+	failing := "# pxname,svname,status,check_status,addr\n" +
+		"kube-apiservers,test-cluster-cp-1,DOWN,L7STS,172.18.0.3:6443\n"
+
+	tests := []struct {
+		name           string
+		disabled       bool
+		statsBefore    string
+		statsAfter     string
+		wantRolledBack bool
+	}{
+		{
+			name:        "keeps a configuration the backends pass the checks of",
+			statsBefore: passing,
+			statsAfter:  passing,
+		},
+		{
+			name:           "rolls back a configuration leaving no backend healthy",
+			statsBefore:    passing,
+			statsAfter:     failing,
+			wantRolledBack: true,
+		},
+		{
+			name:        "keeps the configuration when no backend was healthy before",
+			statsBefore: failing,
+			statsAfter:  failing,
+		},
+		{
+			name:        "keeps the configuration when disabled",
+			disabled:    true,
+			statsBefore: passing,
+			statsAfter:  failing,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+			ctx := container.RuntimeInto(context.Background(), lbNode)
+			lbNode.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+			defer lbNode.SetListContainersResult(nil)
+			lbNode.SetExecContainerOutputs(map[string]string{"wget": tt.statsBefore})
+			defer lbNode.SetExecContainerOutputs(nil)
+			lbNode.SetFile(loadbalancer.ConfigPath, "# previous configuration\n")
+
+			rollback := !tt.disabled
+			lb := &LoadBalancer{
+				name:              "test-cluster",
+				config:            getLoadBalancerConfigData(&infrav1.DockerCluster{}),
+				container:         lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+				rollbackUnhealthy: getLoadBalancerRollbackUnhealthyConfig(&infrav1.DockerCluster{Spec: infrav1.DockerClusterSpec{LoadBalancerRollbackUnhealthyConfig: &rollback}}),
+			}
+			clock := fake.NewClock(time.Now())
+			lb.SetClock(clock)
+			applier := &statsAfterApply{runtime: lbNode, stats: tt.statsAfter}
+			lb.SetConfigApplier(applier)
+
+			result, err := lb.UpdateConfiguration(ctx)
+			g.Expect(result.RolledBack).To(Equal(tt.wantRolledBack))
+			if !tt.wantRolledBack {
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(applier.applied).To(HaveLen(1))
+				return
+			}
+			g.Expect(err).To(MatchError(ContainSubstring("rolled back to the previous configuration")))
+			g.Expect(applier.applied).To(HaveLen(2))
+			g.Expect(applier.applied[1]).To(Equal("# previous configuration\n"))
+			g.Expect(clock.Waits()).ToNot(BeEmpty())
+		})
+	}
+}
+
+func TestGetLoadBalancerRollbackUnhealthyConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	disabled := false
+	g.Expect(getLoadBalancerRollbackUnhealthyConfig(nil)).To(BeTrue())
+	g.Expect(getLoadBalancerRollbackUnhealthyConfig(&infrav1.DockerCluster{})).To(BeTrue())
+	g.Expect(getLoadBalancerRollbackUnhealthyConfig(&infrav1.DockerCluster{Spec: infrav1.DockerClusterSpec{
+		LoadBalancerRollbackUnhealthyConfig: &disabled,
+	}})).To(BeFalse())
+}
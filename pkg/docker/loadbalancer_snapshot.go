@@ -0,0 +1,55 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+// ConfigSnapshot serializes the configuration data the load balancer serves, with the backend servers
+// read from the configuration in the container, to be recorded in the LoadBalancerConfigSnapshotAnnotation.
+func (s *LoadBalancer) ConfigSnapshot(ctx context.Context) (string, error) {
+	backends, err := s.CurrentBackends(ctx)
+	if err != nil {
+		return "", err
+	}
+	configData := s.config
+	configData.BackendServers = backends
+	return loadbalancer.ExportConfig(&configData)
+}
+
+// restoreSnapshotBackends makes the configuration route to the backends of the snapshot when the discovery
+// found none and the configuration in the container has none to preserve either, e.g. right after the
+// container was recreated by a provider that just restarted. Removing all the backends still works once
+// confirmed, because the restored backends are then preserved by confirmZeroBackends like any other.
+func (s *LoadBalancer) restoreSnapshotBackends(ctx context.Context, configData *loadbalancer.ConfigData) {
+	if s.snapshot == nil || len(s.snapshot.BackendServers) == 0 ||
+		len(configData.BackendServers) > 0 || configData.Hibernated || s.zeroBackendConfirmations <= 1 {
+		return
+	}
+	if current, err := s.CurrentBackends(ctx); err == nil && len(current) > 0 {
+		return
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Discovered no control plane nodes, restoring the load balancer backends from the snapshot",
+		"backends", len(s.snapshot.BackendServers))
+	configData.BackendServers = s.snapshot.BackendServers
+}
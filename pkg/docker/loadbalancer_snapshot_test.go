@@ -0,0 +1,100 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/fake"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+func TestLoadBalancerConfigSnapshot(t *testing.T) {
+	g := NewWithT(t)
+	defer defaultZeroBackendCounts.reset("test-cluster")
+
+	// Record the backends of a load balancer.
+	lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+	ctx := container.RuntimeInto(context.Background(), lbNode)
+	lbNode.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer lbNode.SetListContainersResult(nil)
+
+	lb := &LoadBalancer{
+		name:                     "test-cluster",
+		config:                   getLoadBalancerConfigData(nil),
+		container:                lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+		zeroBackendConfirmations: DefaultZeroBackendConfirmations,
+	}
+	_, err := lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	snapshot, err := lb.ConfigSnapshot(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	restored, err := loadbalancer.ImportConfig(snapshot)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(restored.BackendServers).To(Equal(map[string]string{"test-cluster-cp-1": "test-cluster-cp-1IPv4:6443"}))
+
+	backends := func(node *fake.Node) []loadbalancer.ParsedServer {
+		config, ok := node.File(loadbalancer.ConfigPath)
+		g.Expect(ok).To(BeTrue())
+		parsed, err := loadbalancer.ParseConfig([]byte(config))
+		g.Expect(err).ToNot(HaveOccurred())
+		return parsed.Backend(loadbalancer.BackendName).Servers
+	}
+
+	// A recreated container without configuration gets the backends of the snapshot when none are discovered.
+	lbNode = fake.NewNode("test-cluster-lb", "172.18.0.5")
+	ctx = container.RuntimeInto(context.Background(), lbNode)
+	lbNode.SetListContainersResult(nil)
+	lb = &LoadBalancer{
+		name:                     "test-cluster",
+		config:                   getLoadBalancerConfigData(nil),
+		container:                lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+		zeroBackendConfirmations: DefaultZeroBackendConfirmations,
+		snapshot:                 restored,
+	}
+	result, err := lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.BackendCount).To(Equal(1))
+	g.Expect(backends(lbNode)).To(HaveLen(1))
+
+	// The restored backends are removed once no control plane node is confirmed.
+	_, err = lb.UpdateConfiguration(ctx)
+	g.Expect(err).To(BeAssignableToTypeOf(ZeroBackendsUnconfirmedError{}))
+	_, err = lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(backends(lbNode)).To(BeEmpty())
+
+	// Without glitch protection the snapshot is not used.
+	lbNode = fake.NewNode("test-cluster-lb", "172.18.0.6")
+	ctx = container.RuntimeInto(context.Background(), lbNode)
+	lb = &LoadBalancer{
+		name:                     "test-cluster",
+		config:                   getLoadBalancerConfigData(nil),
+		container:                lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+		zeroBackendConfirmations: 1,
+		snapshot:                 restored,
+	}
+	_, err = lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(backends(lbNode)).To(BeEmpty())
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"reflect"
+	"strings"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+)
+
+// loadBalancerSpecFieldPrefix is the prefix of the DockerClusterSpec fields configuring the load balancer.
+const loadBalancerSpecFieldPrefix = "LoadBalancer"
+
+// loadBalancerSpecFields are the indexes of the DockerClusterSpec fields affecting the load balancer:
+// the fields named with loadBalancerSpecFieldPrefix, so new load balancer fields are picked up
+// without changes here, and the network the load balancer is attached to.
+var loadBalancerSpecFields = func() []int {
+	fields := []int{}
+	specType := reflect.TypeOf(infrav1.DockerClusterSpec{})
+	for i := 0; i < specType.NumField(); i++ {
+		name := specType.Field(i).Name
+		if strings.HasPrefix(name, loadBalancerSpecFieldPrefix) || name == "Network" {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}()
+
+// LBSpecChanged returns true if the spec fields affecting the load balancer differ between the two
+// DockerClusters, so reconciles triggered by other changes, e.g. the control plane endpoint the
+// controller publishes, can skip the load balancer. A nil DockerCluster only equals another nil one.
+func LBSpecChanged(oldCluster, newCluster *infrav1.DockerCluster) bool {
+	if oldCluster == nil || newCluster == nil {
+		return oldCluster != newCluster
+	}
+
+	oldSpec := reflect.ValueOf(oldCluster.Spec)
+	newSpec := reflect.ValueOf(newCluster.Spec)
+	for _, i := range loadBalancerSpecFields {
+		if !reflect.DeepEqual(oldSpec.Field(i).Interface(), newSpec.Field(i).Interface()) {
+			return true
+		}
+	}
+	return false
+}
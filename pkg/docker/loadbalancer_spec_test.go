@@ -0,0 +1,109 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+)
+
+func TestLBSpecChanged(t *testing.T) {
+	retries, moreRetries := int32(3), int32(5)
+	base := infrav1.DockerClusterSpec{
+		LoadBalancerImage:     "kindest/haproxy:v20210715-a6da3463",
+		LoadBalancerSysctls:   map[string]string{"net.core.somaxconn": "4096"},
+		LoadBalancerRetries:   &retries,
+		LoadBalancerCapAdd:    []string{"NET_BIND_SERVICE"},
+		LoadBalancerCheckPort: 6443,
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(spec *infrav1.DockerClusterSpec)
+		want   bool
+	}{
+		{
+			name:   "no change",
+			mutate: func(spec *infrav1.DockerClusterSpec) {},
+			want:   false,
+		},
+		{
+			name: "control plane endpoint",
+			mutate: func(spec *infrav1.DockerClusterSpec) {
+				spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{Host: "172.18.0.2", Port: 6443}
+			},
+			want: false,
+		},
+		{
+			name:   "image",
+			mutate: func(spec *infrav1.DockerClusterSpec) { spec.LoadBalancerImage = "haproxy:2.6" },
+			want:   true,
+		},
+		{
+			name:   "sysctl value",
+			mutate: func(spec *infrav1.DockerClusterSpec) { spec.LoadBalancerSysctls["net.core.somaxconn"] = "8192" },
+			want:   true,
+		},
+		{
+			name:   "retries",
+			mutate: func(spec *infrav1.DockerClusterSpec) { spec.LoadBalancerRetries = &moreRetries },
+			want:   true,
+		},
+		{
+			name: "timeout",
+			mutate: func(spec *infrav1.DockerClusterSpec) {
+				spec.LoadBalancerReloadTimeout = &metav1.Duration{Duration: 10 * time.Second}
+			},
+			want: true,
+		},
+		{
+			name: "backend pools",
+			mutate: func(spec *infrav1.DockerClusterSpec) {
+				spec.LoadBalancerBackendPools = []infrav1.LoadBalancerBackendPool{{Name: "readers", Port: 7443}}
+			},
+			want: true,
+		},
+		{
+			name:   "network",
+			mutate: func(spec *infrav1.DockerClusterSpec) { spec.Network = "test-network" },
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			oldCluster := &infrav1.DockerCluster{Spec: *base.DeepCopy()}
+			newCluster := &infrav1.DockerCluster{Spec: *base.DeepCopy()}
+			tt.mutate(&newCluster.Spec)
+			g.Expect(LBSpecChanged(oldCluster, newCluster)).To(Equal(tt.want))
+			g.Expect(LBSpecChanged(newCluster, oldCluster)).To(Equal(tt.want))
+		})
+	}
+
+	t.Run("nil clusters", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(LBSpecChanged(nil, nil)).To(BeFalse())
+		g.Expect(LBSpecChanged(nil, &infrav1.DockerCluster{})).To(BeTrue())
+		g.Expect(LBSpecChanged(&infrav1.DockerCluster{}, nil)).To(BeTrue())
+	})
+}
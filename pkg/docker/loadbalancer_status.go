@@ -0,0 +1,110 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+)
+
+// LoadBalancerRestartWindow is the time window restarts of the load balancer container are counted in.
+const LoadBalancerRestartWindow = 10 * time.Minute
+
+// DefaultLoadBalancerRestartThreshold is how many restarts within LoadBalancerRestartWindow are tolerated by default.
+const DefaultLoadBalancerRestartThreshold = 3
+
+// LoadBalancerStatus is the runtime status of the load balancer container.
+type LoadBalancerStatus struct {
+	// Exists is true if the load balancer container exists.
+	Exists bool
+	// Running is true if the load balancer container is running.
+	Running bool
+	// RestartCount is the number of times the runtime restarted the container, e.g. after HAProxy crashed.
+	RestartCount int
+	// HostPort is the host port the load balancer frontend is published on, or 0 if it is not published.
+	HostPort int32
+}
+
+// Status returns the runtime status of the load balancer container.
+func (s *LoadBalancer) Status(ctx context.Context) (LoadBalancerStatus, error) {
+	if s.container == nil {
+		return LoadBalancerStatus{}, nil
+	}
+
+	containerRuntime, err := container.RuntimeFrom(ctx)
+	if err != nil {
+		return LoadBalancerStatus{}, errors.Wrap(err, "failed to connect to container runtime")
+	}
+
+	info, err := containerRuntime.InspectContainer(ctx, s.containerName())
+	if err != nil {
+		return LoadBalancerStatus{}, errors.WithStack(err)
+	}
+	status := LoadBalancerStatus{
+		Exists:       true,
+		Running:      s.container.IsRunning(),
+		RestartCount: info.RestartCount,
+	}
+	if hostPort, ok := info.HostPorts[fmt.Sprintf("%d/tcp", s.config.FrontendPort)]; ok && hostPort != "" {
+		port, err := strconv.ParseInt(hostPort, 10, 32)
+		if err != nil {
+			return LoadBalancerStatus{}, errors.Wrapf(err, "invalid host port %q for load balancer %s", hostPort, s.containerName())
+		}
+		status.HostPort = int32(port)
+	}
+	return status, nil
+}
+
+// HostPort returns the host port the load balancer frontend is published on, so the control plane
+// endpoint can be reached from the docker host, e.g. by test harnesses.
+func (s *LoadBalancer) HostPort(ctx context.Context) (int32, error) {
+	status, err := s.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !status.Exists {
+		return 0, errors.Errorf("load balancer container %s does not exist", s.containerName())
+	}
+	if status.HostPort == 0 {
+		return 0, errors.Errorf("port %d of load balancer %s is not published on the host", s.config.FrontendPort, s.containerName())
+	}
+	return status.HostPort, nil
+}
+
+// ObserveRestarts records the restart count of the load balancer container in the tumbling window tracked
+// by restarts and returns the number of restarts in the current window. A new window starts when the
+// previous one is older than LoadBalancerRestartWindow, or when the count went down because the container
+// was recreated.
+func ObserveRestarts(restarts *infrav1.LoadBalancerRestartStatus, count int, now time.Time) (*infrav1.LoadBalancerRestartStatus, int) {
+	observed := &infrav1.LoadBalancerRestartStatus{Count: int32(count)}
+	if restarts == nil || int32(count) < restarts.WindowStartCount || now.Sub(restarts.WindowStart.Time) >= LoadBalancerRestartWindow {
+		observed.WindowStart = metav1.NewTime(now)
+		observed.WindowStartCount = int32(count)
+		return observed, 0
+	}
+	observed.WindowStart = restarts.WindowStart
+	observed.WindowStartCount = restarts.WindowStartCount
+	return observed, count - int(restarts.WindowStartCount)
+}
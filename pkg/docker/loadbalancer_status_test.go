@@ -0,0 +1,126 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+)
+
+func TestLoadBalancerStatus(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetContainerRestartCounts(map[string]int{"test-cluster-lb": 4})
+	defer containerRuntime.SetContainerRestartCounts(nil)
+	containerRuntime.SetHostPorts(map[string]string{"test-cluster-lb/6443/tcp": "32768"})
+	defer containerRuntime.SetHostPorts(nil)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(nil),
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue).WithStatus("Up 3 seconds"),
+	}
+	g.Expect(lb.Status(ctx)).To(Equal(LoadBalancerStatus{Exists: true, Running: true, RestartCount: 4, HostPort: 32768}))
+
+	g.Expect((&LoadBalancer{name: "test-cluster"}).Status(ctx)).To(Equal(LoadBalancerStatus{}))
+}
+
+func TestLoadBalancerHostPort(t *testing.T) {
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(nil),
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+
+	t.Run("returns the published host port", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime.SetHostPorts(map[string]string{"test-cluster-lb/6443/tcp": "32768"})
+		defer containerRuntime.SetHostPorts(nil)
+
+		g.Expect(lb.HostPort(ctx)).To(Equal(int32(32768)))
+	})
+
+	t.Run("fails if the frontend port is not published", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := lb.HostPort(ctx)
+		g.Expect(err).To(MatchError(ContainSubstring("port 6443 of load balancer test-cluster-lb is not published on the host")))
+	})
+}
+
+func TestObserveRestarts(t *testing.T) {
+	start := time.Date(2022, 10, 24, 9, 30, 0, 0, time.UTC)
+	window := &infrav1.LoadBalancerRestartStatus{Count: 2, WindowStart: metav1.NewTime(start), WindowStartCount: 1}
+
+	tests := []struct {
+		name         string
+		restarts     *infrav1.LoadBalancerRestartStatus
+		count        int
+		now          time.Time
+		want         *infrav1.LoadBalancerRestartStatus
+		wantInWindow int
+	}{
+		{
+			name:  "first observation starts a window",
+			count: 5,
+			now:   start,
+			want:  &infrav1.LoadBalancerRestartStatus{Count: 5, WindowStart: metav1.NewTime(start), WindowStartCount: 5},
+		},
+		{
+			name:         "counts the restarts in the window",
+			restarts:     window,
+			count:        6,
+			now:          start.Add(5 * time.Minute),
+			want:         &infrav1.LoadBalancerRestartStatus{Count: 6, WindowStart: metav1.NewTime(start), WindowStartCount: 1},
+			wantInWindow: 5,
+		},
+		{
+			name:     "starts a new window once expired",
+			restarts: window,
+			count:    6,
+			now:      start.Add(LoadBalancerRestartWindow),
+			want:     &infrav1.LoadBalancerRestartStatus{Count: 6, WindowStart: metav1.NewTime(start.Add(LoadBalancerRestartWindow)), WindowStartCount: 6},
+		},
+		{
+			name:     "starts a new window when the container was recreated",
+			restarts: window,
+			count:    0,
+			now:      start.Add(time.Minute),
+			want:     &infrav1.LoadBalancerRestartStatus{Count: 0, WindowStart: metav1.NewTime(start.Add(time.Minute)), WindowStartCount: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			got, inWindow := ObserveRestarts(tt.restarts, tt.count, tt.now)
+			g.Expect(got).To(Equal(tt.want))
+			g.Expect(inWindow).To(Equal(tt.wantInWindow))
+		})
+	}
+}
@@ -0,0 +1,2494 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	infrav1 "github.com/beanlearninggo/cluster-api-provider-docker/api/v1alpha1"
+	"github.com/beanlearninggo/cluster-api-provider-docker/feature"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/fake"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+type fakeLBCreator struct {
+	calls []string
+	opts  []ExternalLoadBalancerNodeOptions
+	// errs are returned by the first calls, in order.
+	errs []error
+}
+
+func (f *fakeLBCreator) CreateExternalLoadBalancerNode(ctx context.Context, opts ExternalLoadBalancerNodeOptions) (*types.Node, error) {
+	f.calls = append(f.calls, opts.Name)
+	f.opts = append(f.opts, opts)
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return types.NewNode(opts.Name, opts.Image, constants.ExternalLoadBalancerNodeRoleValue), nil
+}
+
+// fakeConfigApplier records the configurations applied and the reloads instead of touching the container.
+type fakeConfigApplier struct {
+	applied []string
+	reloads int
+}
+
+func (f *fakeConfigApplier) Apply(ctx context.Context, node *types.Node, config string) error {
+	f.applied = append(f.applied, config)
+	return nil
+}
+
+func (f *fakeConfigApplier) Reload(ctx context.Context, node *types.Node) error {
+	f.reloads++
+	return nil
+}
+
+func TestLoadBalancerRecreate(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetDeleteContainerCallLogs()
+
+	creator := &fakeLBCreator{}
+	lb := &LoadBalancer{
+		name:      "TestCluster",
+		image:     "TestImage",
+		container: types.NewNode("TestCluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		lbCreator: creator,
+	}
+
+	g.Expect(lb.Recreate(ctx)).To(Succeed())
+
+	g.Expect(containerRuntime.DeleteContainerCalls()).To(Equal([]string{"TestCluster-lb"}))
+	g.Expect(creator.calls).To(Equal([]string{"TestCluster-lb"}))
+	g.Expect(lb.container).ToNot(BeNil())
+}
+
+func TestLoadBalancerDelete(t *testing.T) {
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+
+	t.Run("removes the container", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime.ResetDeleteContainerCallLogs()
+		containerRuntime.ResetStopContainerCallLogs()
+
+		lb := &LoadBalancer{
+			name:      "test-cluster",
+			container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		}
+		g.Expect(lb.Delete(ctx)).To(Succeed())
+		g.Expect(containerRuntime.DeleteContainerCalls()).To(Equal([]string{"test-cluster-lb"}))
+		g.Expect(containerRuntime.StopContainerCalls()).To(BeEmpty())
+		g.Expect(lb.container).To(BeNil())
+	})
+
+	t.Run("stops the container gracefully before removing it", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime.ResetDeleteContainerCallLogs()
+		containerRuntime.ResetStopContainerCallLogs()
+
+		lb := &LoadBalancer{
+			name:        "test-cluster",
+			container:   types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+			stopTimeout: defaultStopTimeout,
+		}
+		g.Expect(lb.Delete(ctx)).To(Succeed())
+		g.Expect(containerRuntime.StopContainerCalls()).To(Equal([]container.StopContainerArgs{
+			{Container: "test-cluster-lb", Timeout: defaultStopTimeout},
+		}))
+		g.Expect(containerRuntime.DeleteContainerCalls()).To(Equal([]string{"test-cluster-lb"}))
+	})
+
+	t.Run("stops and renames the container when retained", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime.ResetDeleteContainerCallLogs()
+		containerRuntime.ResetStopContainerCallLogs()
+		containerRuntime.ResetRenameContainerCallLogs()
+
+		lb := &LoadBalancer{
+			name:           "test-cluster",
+			container:      types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+			retainOnDelete: true,
+			stopTimeout:    time.Minute,
+		}
+		lb.SetClock(fake.NewClock(time.Date(2022, 10, 24, 9, 30, 0, 0, time.UTC)))
+		g.Expect(lb.Delete(ctx)).To(Succeed())
+		g.Expect(containerRuntime.DeleteContainerCalls()).To(BeEmpty())
+		g.Expect(containerRuntime.StopContainerCalls()).To(Equal([]container.StopContainerArgs{
+			{Container: "test-cluster-lb", Timeout: time.Minute},
+		}))
+		g.Expect(containerRuntime.RenameContainerCalls()).To(Equal([]container.RenameContainerArgs{
+			{Container: "test-cluster-lb", NewName: "test-cluster-lb-retained-20221024093000"},
+		}))
+		g.Expect(lb.container).To(BeNil())
+	})
+
+	t.Run("recreate removes a retained container", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime.ResetDeleteContainerCallLogs()
+		containerRuntime.ResetStopContainerCallLogs()
+
+		lb := &LoadBalancer{
+			name:           "test-cluster",
+			container:      types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+			lbCreator:      &fakeLBCreator{},
+			retainOnDelete: true,
+		}
+		g.Expect(lb.Recreate(ctx)).To(Succeed())
+		g.Expect(containerRuntime.DeleteContainerCalls()).To(Equal([]string{"test-cluster-lb"}))
+		g.Expect(containerRuntime.StopContainerCalls()).To(BeEmpty())
+	})
+}
+
+func TestNewLoadBalancerIgnoresRetainedContainers(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetListContainersCallLogs()
+	containerRuntime.SetListContainersResult(nil)
+
+	_, err := NewLoadBalancer(ctx, &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}}, &infrav1.DockerCluster{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	callLog := containerRuntime.ListContainersCalls()
+	g.Expect(callLog).To(HaveLen(1))
+	g.Expect(callLog[0].Args()).To(ContainElement("name=^test-cluster-lb$"))
+}
+
+func TestNewLoadBalancerOwnerUID(t *testing.T) {
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}}
+	dockerCluster := &infrav1.DockerCluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", UID: "uid-1"}}
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		wantAdopted bool
+	}{
+		{
+			name:        "adopts the container of the same DockerCluster",
+			labels:      map[string]string{ownerUIDLabelKey: "uid-1"},
+			wantAdopted: true,
+		},
+		{
+			name:        "adopts a container created before the owner label",
+			labels:      map[string]string{},
+			wantAdopted: true,
+		},
+		{
+			name:   "ignores the container of another DockerCluster",
+			labels: map[string]string{ownerUIDLabelKey: "uid-0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-lb", Image: "TestImage", Labels: tt.labels}})
+			defer containerRuntime.SetListContainersResult(nil)
+
+			lb, err := NewLoadBalancer(ctx, cluster, dockerCluster)
+			g.Expect(err).ToNot(HaveOccurred())
+			if tt.wantAdopted {
+				g.Expect(lb.container).ToNot(BeNil())
+				return
+			}
+			g.Expect(lb.container).To(BeNil())
+
+			creator := &fakeLBCreator{}
+			lb.lbCreator = creator
+			g.Expect(lb.Create(ctx)).To(MatchError(OrphanedLoadBalancerError{Name: "test-cluster-lb", OwnerUID: "uid-0"}))
+			g.Expect(creator.calls).To(BeEmpty())
+		})
+	}
+
+	t.Run("labels the containers it creates", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime := &container.FakeRuntime{}
+		ctx := container.RuntimeInto(context.Background(), containerRuntime)
+		containerRuntime.SetListContainersResult(nil)
+
+		lb, err := NewLoadBalancer(ctx, cluster, dockerCluster)
+		g.Expect(err).ToNot(HaveOccurred())
+		creator := &fakeLBCreator{}
+		lb.lbCreator = creator
+		g.Expect(lb.Create(ctx)).To(Succeed())
+		g.Expect(creator.opts).To(HaveLen(1))
+		g.Expect(creator.opts[0].Labels).To(Equal(map[string]string{ownerUIDLabelKey: "uid-1"}))
+	})
+}
+
+func TestNewLoadBalancerConfigLayers(t *testing.T) {
+	g := NewWithT(t)
+	ctx := container.RuntimeInto(context.Background(), &container.FakeRuntime{})
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}}
+
+	base, err := loadbalancer.ParseConfigOverrides("defaults\n  timeout client 30s\n  timeout server 30s\n")
+	g.Expect(err).ToNot(HaveOccurred())
+	SetBaseConfig(base)
+	defer SetBaseConfig(nil)
+
+	lb, err := NewLoadBalancer(ctx, cluster, &infrav1.DockerCluster{Spec: infrav1.DockerClusterSpec{
+		LoadBalancerConfigPatch: "defaults\n  timeout client 1m\n",
+	}})
+	g.Expect(err).ToNot(HaveOccurred())
+	config, err := loadbalancer.Config(&lb.config)
+	g.Expect(err).ToNot(HaveOccurred())
+	parsed, err := loadbalancer.ParseConfig([]byte(config))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(parsed.Defaults).To(ContainElements("timeout client 1m", "timeout server 30s"))
+
+	_, err = NewLoadBalancer(ctx, cluster, &infrav1.DockerCluster{Spec: infrav1.DockerClusterSpec{
+		LoadBalancerConfigPatch: "timeout client 1m\n",
+	}})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid load balancer config patch")))
+}
+
+func TestLoadBalancerDNSName(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetExecContainerCallLogs()
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+
+	g.Expect(lb.DNSName()).To(Equal("test-cluster-lb"))
+	g.Expect(lb.NetworkName()).To(Equal(DefaultNetwork))
+	g.Expect(lb.ValidateDNSName(ctx)).To(Succeed())
+
+	callLog := containerRuntime.ExecContainerCalls()
+	g.Expect(callLog).To(HaveLen(1))
+	g.Expect(callLog[0].ContainerName).To(Equal("test-cluster-lb"))
+	g.Expect(callLog[0].Command).To(Equal("nslookup"))
+	g.Expect(callLog[0].Args).To(Equal([]string{"test-cluster-lb"}))
+}
+
+func TestLoadBalancerValidateDNSNameInvalid(t *testing.T) {
+	g := NewWithT(t)
+	ctx := container.RuntimeInto(context.Background(), &container.FakeRuntime{})
+
+	lb := &LoadBalancer{
+		name:      "Test_Cluster",
+		container: types.NewNode("Test_Cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+
+	g.Expect(lb.ValidateDNSName(ctx)).ToNot(Succeed())
+}
+
+func TestLoadBalancerDrain(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetExecContainerCallLogs()
+	containerRuntime.ResetKillContainerCallLogs()
+	containerRuntime.SetListContainersResult([]container.Container{
+		{Name: "test-cluster-cp-1"},
+		{Name: "test-cluster-cp-2"},
+	})
+	defer containerRuntime.SetListContainersResult(nil)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(nil),
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+
+	g.Expect(lb.Drain(ctx)).To(Succeed())
+
+	parsed := writtenConfig(g, containerRuntime)
+	servers := parsed.Backend("kube-apiservers").Servers
+	g.Expect(servers).To(HaveLen(2))
+	for _, server := range servers {
+		g.Expect(server.Options).To(ContainElement("disabled"))
+	}
+	g.Expect(containerRuntime.KillContainerCalls()).To(HaveLen(1))
+
+	containerRuntime.ResetExecContainerCallLogs()
+	g.Expect(lb.Undrain(ctx)).To(Succeed())
+
+	parsed = writtenConfig(g, containerRuntime)
+	servers = parsed.Backend("kube-apiservers").Servers
+	g.Expect(servers).To(HaveLen(2))
+	for _, server := range servers {
+		g.Expect(server.Options).ToNot(ContainElement("disabled"))
+	}
+}
+
+// writtenConfig returns the parsed load balancer config written by the last WriteFile call.
+func writtenConfig(g *WithT, containerRuntime *container.FakeRuntime) *loadbalancer.ParsedConfig {
+	var content []byte
+	for _, call := range containerRuntime.ExecContainerCalls() {
+		if call.Command == "cp" && call.Config.InputBuffer != nil {
+			data, err := io.ReadAll(call.Config.InputBuffer)
+			g.Expect(err).ShouldNot(HaveOccurred())
+			content = data
+		}
+	}
+	g.Expect(content).ToNot(BeEmpty())
+
+	parsed, err := loadbalancer.ParseConfig(content)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	return parsed
+}
+
+func TestLoadBalancerImageDigest(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	otherDigest := "sha256:" + strings.Repeat("b", 64)
+
+	tests := []struct {
+		name        string
+		image       string
+		repoDigests []string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "tag with a resolvable digest",
+			image:       "haproxytech/haproxy-alpine:2.4",
+			repoDigests: []string{"haproxytech/haproxy-alpine@" + digest},
+			want:        digest,
+		},
+		{
+			name:  "tag without a resolvable digest falls back to the image ID",
+			image: "haproxytech/haproxy-alpine:2.4",
+			want:  "test-cluster-lbImageID",
+		},
+		{
+			name:        "pinned digest matching the container image",
+			image:       "haproxytech/haproxy-alpine@" + digest,
+			repoDigests: []string{"mirror/haproxy@" + otherDigest, "haproxytech/haproxy-alpine@" + digest},
+			want:        digest,
+		},
+		{
+			name:        "pinned digest not matching the container image",
+			image:       "haproxytech/haproxy-alpine@" + digest,
+			repoDigests: []string{"haproxytech/haproxy-alpine@" + otherDigest},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.SetImageRepoDigests(map[string][]string{"test-cluster-lbImageID": tt.repoDigests})
+			defer containerRuntime.SetImageRepoDigests(nil)
+
+			lb := &LoadBalancer{
+				name:      "test-cluster",
+				image:     tt.image,
+				container: types.NewNode("test-cluster-lb", tt.image, constants.ExternalLoadBalancerNodeRoleValue),
+			}
+
+			got, err := lb.ImageDigest(ctx)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestLoadBalancerUpdateConfigurationWaitsForReload(t *testing.T) {
+	stats := "# pxname,svname,status,check_status,addr\n" +
+		"kube-apiservers,test-cluster-cp-1,UP,L7OK,test-cluster-cp-1IPv4:6443\n" +
+		"kube-apiservers,BACKEND,UP,,\n"
+
+	tests := []struct {
+		name    string
+		stats   string
+		wantErr bool
+	}{
+		{
+			name:  "new configuration is served",
+			stats: stats,
+		},
+		{
+			name:    "new configuration is not served",
+			stats:   "# pxname,svname,status,check_status,addr\nkube-apiservers,BACKEND,UP,,\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.ResetExecContainerCallLogs()
+			containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+			defer containerRuntime.SetListContainersResult(nil)
+			containerRuntime.SetExecContainerOutputs(map[string]string{"wget": tt.stats})
+			defer containerRuntime.SetExecContainerOutputs(nil)
+
+			clock := fake.NewClock(time.Now())
+			lb := &LoadBalancer{
+				name:          "test-cluster",
+				config:        getLoadBalancerConfigData(nil),
+				container:     types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+				reloadTimeout: time.Second,
+				clock:         clock,
+			}
+
+			_, err := lb.UpdateConfiguration(ctx)
+			if tt.wantErr {
+				g.Expect(err).To(MatchError(ContainSubstring("expected servers [test-cluster-cp-1], got []")))
+				g.Expect(clock.Waits()).To(Equal([]time.Duration{reloadPollInterval, reloadPollInterval}))
+				return
+			}
+			g.Expect(clock.Waits()).To(BeEmpty())
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var wgetCalls int
+			for _, call := range containerRuntime.ExecContainerCalls() {
+				if call.Command == "wget" {
+					g.Expect(call.Args).To(ContainElement(loadbalancer.StatsCSVURL))
+					wgetCalls++
+				}
+			}
+			g.Expect(wgetCalls).To(Equal(1))
+		})
+	}
+}
+
+// reconnectedRuntime is a fake load balancer container whose control plane node test-cluster-cp-1 keeps its
+// name but can get a new IP, as after a network reconnect. HAProxy serves the node at the IP it had when
+// the configuration was last applied or reloaded.
+type reconnectedRuntime struct {
+	*fake.Node
+	fakeConfigApplier
+	nodeIP string
+}
+
+func (r *reconnectedRuntime) GetContainerIPs(ctx context.Context, containerName string) (string, string, error) {
+	if containerName == "test-cluster-cp-1" {
+		return r.nodeIP, "", nil
+	}
+	return r.Node.GetContainerIPs(ctx, containerName)
+}
+
+func (r *reconnectedRuntime) serve() {
+	r.SetExecContainerOutputs(map[string]string{"wget": "# pxname,svname,status,check_status,addr\n" +
+		"kube-apiservers,test-cluster-cp-1,UP,L7OK," + r.nodeIP + ":6443\n" +
+		"kube-apiservers,BACKEND,UP,,\n"})
+}
+
+func (r *reconnectedRuntime) Apply(ctx context.Context, node *types.Node, config string) error {
+	r.serve()
+	return r.fakeConfigApplier.Apply(ctx, node, config)
+}
+
+func (r *reconnectedRuntime) Reload(ctx context.Context, node *types.Node) error {
+	r.serve()
+	return r.fakeConfigApplier.Reload(ctx, node)
+}
+
+func TestLoadBalancerUpdateConfigurationNodeIPChange(t *testing.T) {
+	g := NewWithT(t)
+	runtime := &reconnectedRuntime{Node: fake.NewNode("test-cluster-lb", "172.18.0.2"), nodeIP: "172.18.0.3"}
+	ctx := container.RuntimeInto(context.Background(), runtime)
+	runtime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer runtime.SetListContainersResult(nil)
+	defer runtime.SetExecContainerOutputs(nil)
+
+	lb := &LoadBalancer{
+		name:          "test-cluster",
+		config:        getLoadBalancerConfigData(nil),
+		container:     runtime.Node.Node(constants.ExternalLoadBalancerNodeRoleValue),
+		applier:       runtime,
+		reloadTimeout: time.Second,
+		clock:         fake.NewClock(time.Now()),
+	}
+	servedAddress := func() string {
+		config, ok := runtime.File(loadbalancer.ConfigPath)
+		g.Expect(ok).To(BeTrue())
+		parsed, err := loadbalancer.ParseConfig([]byte(config))
+		g.Expect(err).ToNot(HaveOccurred())
+		return parsed.Backend(loadbalancer.BackendName).Servers[0].Address
+	}
+
+	// The fake applier does not write the configuration, it is written here as HAProxyApplier would.
+	result, err := lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.ConfigChanged).To(BeTrue())
+	runtime.SetFile(loadbalancer.ConfigPath, runtime.applied[0])
+	g.Expect(servedAddress()).To(Equal("172.18.0.3:6443"))
+
+	t.Run("does not reload while the node keeps its IP", func(t *testing.T) {
+		g := NewWithT(t)
+		result, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Reloaded).To(BeFalse())
+	})
+
+	t.Run("updates the configuration when the node gets a new IP", func(t *testing.T) {
+		g := NewWithT(t)
+		runtime.nodeIP = "172.18.0.4"
+		result, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.ConfigChanged).To(BeTrue())
+		g.Expect(runtime.applied).To(HaveLen(2))
+		runtime.SetFile(loadbalancer.ConfigPath, runtime.applied[1])
+		g.Expect(servedAddress()).To(Equal("172.18.0.4:6443"))
+	})
+
+	t.Run("reloads a configuration served with the previous IP", func(t *testing.T) {
+		g := NewWithT(t)
+		// The configuration is up to date, but HAProxy was not reloaded after the previous IP was served.
+		runtime.nodeIP = "172.18.0.3"
+		runtime.serve()
+		runtime.nodeIP = "172.18.0.4"
+		result, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.ConfigChanged).To(BeFalse())
+		g.Expect(result.Reloaded).To(BeTrue())
+		g.Expect(runtime.reloads).To(Equal(1))
+	})
+
+	t.Run("reports the previous IP as not applied", func(t *testing.T) {
+		g := NewWithT(t)
+		runtime.nodeIP = "172.18.0.3"
+		runtime.serve()
+		runtime.nodeIP = "172.18.0.4"
+		g.Expect(lb.ConfigApplied(ctx)).To(MatchError(ContainSubstring(
+			"expected server test-cluster-cp-1 at 172.18.0.4:6443, got 172.18.0.3:6443")))
+	})
+}
+
+func TestLoadBalancerUpdateConfigurationFileMode(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetExecContainerCallLogs()
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer containerRuntime.SetListContainersResult(nil)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(nil),
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+	_, err := lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var chmods [][]string
+	for _, call := range containerRuntime.ExecContainerCalls() {
+		if call.Command == "chmod" {
+			chmods = append(chmods, call.Args)
+		}
+	}
+	g.Expect(chmods).To(Equal([][]string{{"644", loadbalancer.ConfigPath}}))
+}
+
+func TestLoadBalancerConfigDrift(t *testing.T) {
+	const edited = "# edited in the container\n"
+
+	newLB := func(lbNode *fake.Node, mode string) *LoadBalancer {
+		return &LoadBalancer{
+			name:            "test-cluster",
+			config:          getLoadBalancerConfigData(nil),
+			container:       lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+			configDriftMode: mode,
+		}
+	}
+	configIn := func(lbNode *fake.Node) string {
+		config, _ := lbNode.File(loadbalancer.ConfigPath)
+		return config
+	}
+
+	t.Run("keeps and reports the changes in observe mode", func(t *testing.T) {
+		g := NewWithT(t)
+		lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+		ctx := container.RuntimeInto(context.Background(), lbNode)
+		lb := newLB(lbNode, infrav1.LoadBalancerConfigDriftObserve)
+
+		_, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		written := configIn(lbNode)
+		lastApplied, ok := lbNode.File(loadbalancer.LastAppliedConfigPath)
+		g.Expect(ok).To(BeTrue())
+		g.Expect(lastApplied).To(Equal(written))
+		g.Expect(lb.ConfigDrift(ctx)).To(BeFalse())
+
+		lbNode.SetFile(loadbalancer.ConfigPath, edited)
+		g.Expect(lb.ConfigDrift(ctx)).To(BeTrue())
+		result, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Drifted).To(BeTrue())
+		g.Expect(configIn(lbNode)).To(Equal(edited))
+
+		// Reconfiguring overrides the changes.
+		g.Expect(lb.ForceReconfigure(ctx)).To(Succeed())
+		g.Expect(configIn(lbNode)).To(Equal(written))
+		g.Expect(lb.ConfigDrift(ctx)).To(BeFalse())
+	})
+
+	t.Run("reverts the changes in enforce mode", func(t *testing.T) {
+		g := NewWithT(t)
+		lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+		ctx := container.RuntimeInto(context.Background(), lbNode)
+		lb := newLB(lbNode, infrav1.LoadBalancerConfigDriftEnforce)
+
+		_, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		written := configIn(lbNode)
+
+		lbNode.SetFile(loadbalancer.ConfigPath, edited)
+		result, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Drifted).To(BeFalse())
+		g.Expect(configIn(lbNode)).To(Equal(written))
+		g.Expect(lb.ConfigDrift(ctx)).To(BeFalse())
+	})
+
+	t.Run("does not record the configuration without a mode", func(t *testing.T) {
+		g := NewWithT(t)
+		lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+		ctx := container.RuntimeInto(context.Background(), lbNode)
+		lb := newLB(lbNode, "")
+
+		_, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		_, ok := lbNode.File(loadbalancer.LastAppliedConfigPath)
+		g.Expect(ok).To(BeFalse())
+		lbNode.SetFile(loadbalancer.ConfigPath, edited)
+		g.Expect(lb.ConfigDrift(ctx)).To(BeFalse())
+	})
+}
+
+func TestLoadBalancerBackendCA(t *testing.T) {
+	ca := "-----BEGIN CERTIFICATE-----\ncluster-ca\n-----END CERTIFICATE-----\n"
+
+	tests := []struct {
+		name      string
+		currentCA string
+		wantWrite bool
+	}{
+		{
+			name:      "writes a missing CA bundle",
+			currentCA: "",
+			wantWrite: true,
+		},
+		{
+			name:      "keeps an up to date CA bundle",
+			currentCA: ca,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.ResetExecContainerCallLogs()
+			containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+			defer containerRuntime.SetListContainersResult(nil)
+			containerRuntime.SetExecContainerOutputs(map[string]string{"cat": tt.currentCA})
+			defer containerRuntime.SetExecContainerOutputs(nil)
+
+			// The CA bundle verifies the TLS health checks.
+			dockerCluster := &infrav1.DockerCluster{Spec: infrav1.DockerClusterSpec{LoadBalancerHealthCheckType: loadbalancer.HealthCheckSSL}}
+			lb := &LoadBalancer{
+				name:      "test-cluster",
+				config:    getLoadBalancerConfigData(dockerCluster),
+				container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+			}
+			lb.SetBackendCA([]byte(ca))
+			_, err := lb.UpdateConfiguration(ctx)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var caWrites []string
+			for _, call := range containerRuntime.ExecContainerCalls() {
+				if call.Command == "cp" && call.Args[1] == loadbalancer.BackendCAPath {
+					data, err := io.ReadAll(call.Config.InputBuffer)
+					g.Expect(err).ToNot(HaveOccurred())
+					caWrites = append(caWrites, string(data))
+				}
+			}
+			if tt.wantWrite {
+				g.Expect(caWrites).To(Equal([]string{ca}))
+			} else {
+				g.Expect(caWrites).To(BeEmpty())
+			}
+
+			servers := writtenConfig(g, containerRuntime).Backend(loadbalancer.BackendName).Servers
+			g.Expect(servers).To(HaveLen(1))
+			g.Expect(servers[0].Options).To(ContainElements("verify", "required", "ca-file", loadbalancer.BackendCAPath))
+		})
+	}
+}
+
+func TestLoadBalancerUpdateConfigurationResult(t *testing.T) {
+	configData := getLoadBalancerConfigData(nil)
+	configData.BackendServers = map[string]string{"test-cluster-cp-1": "test-cluster-cp-1IPv4:6443"}
+	current, err := loadbalancer.Config(&configData)
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+
+	served := "# pxname,svname,status,check_status,addr\n" +
+		"kube-apiservers,test-cluster-cp-1,UP,L7OK,test-cluster-cp-1IPv4:6443\n"
+	notServed := "# pxname,svname,status,check_status,addr\n"
+
+	tests := []struct {
+		name      string
+		current   string
+		stats     string
+		want      UpdateResult
+		wantWrite bool
+	}{
+		{
+			name:      "configuration changed",
+			current:   "",
+			stats:     served,
+			want:      UpdateResult{BackendCount: 1, Reloaded: true, ConfigChanged: true},
+			wantWrite: true,
+		},
+		{
+			name:    "configuration up to date and served",
+			current: current,
+			stats:   served,
+			want:    UpdateResult{BackendCount: 1},
+		},
+		{
+			name:    "configuration up to date but not served",
+			current: current,
+			stats:   notServed,
+			want:    UpdateResult{BackendCount: 1, Reloaded: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.ResetExecContainerCallLogs()
+			containerRuntime.ResetKillContainerCallLogs()
+			containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+			defer containerRuntime.SetListContainersResult(nil)
+			containerRuntime.SetExecContainerOutputs(map[string]string{"cat": tt.current, "wget": tt.stats})
+			defer containerRuntime.SetExecContainerOutputs(nil)
+
+			lb := &LoadBalancer{
+				name:      "test-cluster",
+				config:    getLoadBalancerConfigData(nil),
+				container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+			}
+
+			result, err := lb.UpdateConfiguration(ctx)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result).To(Equal(tt.want))
+
+			var writes int
+			for _, call := range containerRuntime.ExecContainerCalls() {
+				if call.Command == "cp" {
+					writes++
+				}
+			}
+			g.Expect(writes > 0).To(Equal(tt.wantWrite))
+			// The current configuration is not a PID file, so reloads fall back to signaling the container.
+			if tt.want.Reloaded {
+				g.Expect(containerRuntime.KillContainerCalls()).To(HaveLen(1))
+			} else {
+				g.Expect(containerRuntime.KillContainerCalls()).To(BeEmpty())
+			}
+		})
+	}
+}
+
+func TestLoadBalancerUpdateConfigurationConfigApplier(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetExecContainerCallLogs()
+	containerRuntime.ResetKillContainerCallLogs()
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer containerRuntime.SetListContainersResult(nil)
+
+	applier := &fakeConfigApplier{}
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(nil),
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+	lb.SetConfigApplier(applier)
+	result, err := lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Reloaded).To(BeTrue())
+
+	g.Expect(applier.applied).To(HaveLen(1))
+	parsed, err := loadbalancer.ParseConfig([]byte(applier.applied[0]))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(parsed.Backend(loadbalancer.BackendName).Servers).To(HaveLen(1))
+	g.Expect(containerRuntime.KillContainerCalls()).To(BeEmpty())
+}
+
+func TestLoadBalancerUpdateConfigurationFakeNode(t *testing.T) {
+	tests := []struct {
+		name         string
+		writeFileErr error
+		killErr      error
+		wantErr      string
+		wantKills    []string
+	}{
+		{
+			name:      "writes the configuration and reloads HAProxy",
+			wantKills: []string{"SIGHUP"},
+		},
+		{
+			name:         "fails to write the configuration",
+			writeFileErr: errors.New("read-only file system"),
+			wantErr:      "read-only file system",
+		},
+		{
+			name:      "fails to reload HAProxy",
+			killErr:   errors.New("container is not running"),
+			wantErr:   "container is not running",
+			wantKills: []string{"SIGHUP"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+			lbNode.WriteFileErr = tt.writeFileErr
+			lbNode.KillErr = tt.killErr
+			ctx := container.RuntimeInto(context.Background(), lbNode)
+			lbNode.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+			defer lbNode.SetListContainersResult(nil)
+
+			lb := &LoadBalancer{
+				name:      "test-cluster",
+				config:    getLoadBalancerConfigData(nil),
+				container: lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+			}
+			_, err := lb.UpdateConfiguration(ctx)
+			g.Expect(lbNode.Kills()).To(Equal(tt.wantKills))
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+
+			config, ok := lbNode.File(loadbalancer.ConfigPath)
+			g.Expect(ok).To(BeTrue())
+			parsed, err := loadbalancer.ParseConfig([]byte(config))
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(parsed.Backend(loadbalancer.BackendName).Servers).To(Equal([]loadbalancer.ParsedServer{
+				{Name: "test-cluster-cp-1", Address: "test-cluster-cp-1IPv4:6443", Options: []string{"check"}},
+			}))
+		})
+	}
+}
+
+func TestLoadBalancerIPFakeNode(t *testing.T) {
+	g := NewWithT(t)
+	lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+	ctx := container.RuntimeInto(context.Background(), lbNode)
+	lb := &LoadBalancer{name: "test-cluster", container: lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue)}
+
+	g.Expect(lb.IP(ctx)).To(Equal("172.18.0.2"))
+
+	lbNode.IPv4 = ""
+	_, err := lb.IP(ctx)
+	g.Expect(err).To(MatchError(ContainSubstring("does not have an associated IP address")))
+
+	lbNode.IPErr = errors.New("no such container")
+	_, err = lb.IP(ctx)
+	g.Expect(err).To(MatchError(ContainSubstring("no such container")))
+}
+
+func TestLoadBalancerForceReconfigure(t *testing.T) {
+	g := NewWithT(t)
+	configData := getLoadBalancerConfigData(nil)
+	configData.BackendServers = map[string]string{"test-cluster-cp-1": "test-cluster-cp-1IPv4:6443"}
+	current, err := loadbalancer.Config(&configData)
+	g.Expect(err).ToNot(HaveOccurred())
+	served := "# pxname,svname,status,check_status,addr\n" +
+		"kube-apiservers,test-cluster-cp-1,UP,L7OK,test-cluster-cp-1IPv4:6443\n"
+
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetExecContainerCallLogs()
+	containerRuntime.ResetKillContainerCallLogs()
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer containerRuntime.SetListContainersResult(nil)
+	containerRuntime.SetExecContainerOutputs(map[string]string{"cat": current, "wget": served})
+	defer containerRuntime.SetExecContainerOutputs(nil)
+
+	// A cached backend that is gone must not survive the forced reconfiguration.
+	cache := newBackendCache()
+	cache.set("test-cluster", map[string]string{"test-cluster-cp-0": "test-cluster-cp-0IPv4:6443"})
+	lb := &LoadBalancer{
+		name:            "test-cluster",
+		config:          getLoadBalancerConfigData(nil),
+		container:       types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		backendCache:    cache,
+		backendCacheTTL: time.Minute,
+	}
+	g.Expect(lb.ForceReconfigure(ctx)).To(Succeed())
+	g.Expect(lb.forceRefresh).To(BeFalse())
+
+	g.Expect(writtenConfig(g, containerRuntime).Backend(loadbalancer.BackendName).Servers).To(Equal([]loadbalancer.ParsedServer{
+		{Name: "test-cluster-cp-1", Address: "test-cluster-cp-1IPv4:6443", Options: []string{"check"}},
+	}))
+	g.Expect(containerRuntime.KillContainerCalls()).To(HaveLen(1))
+}
+
+func TestLoadBalancerEffectiveConfigData(t *testing.T) {
+	g := NewWithT(t)
+	lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+	ctx := container.RuntimeInto(context.Background(), lbNode)
+	lbNode.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer lbNode.SetListContainersResult(nil)
+
+	retries := int32(2)
+	dockerCluster := &infrav1.DockerCluster{Spec: infrav1.DockerClusterSpec{
+		LoadBalancerFrontendPort: 7443,
+		LoadBalancerRetries:      &retries,
+	}}
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(dockerCluster),
+		container: lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+	}
+	configData, err := lb.EffectiveConfigData(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(configData.FrontendPort).To(Equal(7443))
+	g.Expect(configData.Retries).To(HaveValue(Equal(2)))
+	g.Expect(configData.BackendServers).To(Equal(map[string]string{"test-cluster-cp-1": "test-cluster-cp-1IPv4:6443"}))
+	g.Expect(configData.Hibernated).To(BeFalse())
+
+	// Nothing is written into the container nor reloaded.
+	g.Expect(lbNode.Writes()).To(BeEmpty())
+	g.Expect(lbNode.Kills()).To(BeEmpty())
+	g.Expect(lb.config.BackendServers).To(BeEmpty())
+}
+
+func TestLoadBalancerEffectiveConfigDataBackendCache(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	defer containerRuntime.SetListContainersResult(nil)
+
+	lb := &LoadBalancer{
+		name:            "test-cluster",
+		config:          getLoadBalancerConfigData(nil),
+		container:       types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		backendCache:    newBackendCache(),
+		backendCacheTTL: time.Minute,
+	}
+
+	// The backends discovered on a cache miss are not cached...
+	configData, err := lb.EffectiveConfigData(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(configData.BackendServers).To(HaveKey("test-cluster-cp-1"))
+	_, ok := lb.backendCache.get("test-cluster", time.Minute)
+	g.Expect(ok).To(BeFalse())
+
+	// ...but a fresh cache entry is used, as UpdateConfiguration would.
+	servers, err := lb.backendServers(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(servers).To(HaveLen(1))
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}, {Name: "test-cluster-cp-2"}})
+	configData, err = lb.EffectiveConfigData(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(configData.BackendServers).To(HaveLen(1))
+	cached, ok := lb.backendCache.get("test-cluster", time.Minute)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(cached).To(Equal(servers))
+}
+
+func TestLoadBalancerEffectiveConfigDataPaused(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetListContainersCallLogs()
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(nil),
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		paused:    true,
+	}
+	_, err := lb.EffectiveConfigData(ctx)
+	g.Expect(err).To(MatchError(ContainSubstring("load balancer is paused")))
+	g.Expect(containerRuntime.ListContainersCalls()).To(BeEmpty())
+}
+
+func TestLoadBalancerUpdateConfigurationExcludedNodes(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetExecContainerCallLogs()
+	containerRuntime.SetListContainersResult([]container.Container{
+		{Name: "test-cluster-cp-1"},
+		{Name: "test-cluster-cp-2", Labels: map[string]string{LoadBalancerExcludeLabelKey: "true"}},
+		{Name: "test-cluster-cp-3", Labels: map[string]string{LoadBalancerExcludeLabelKey: "false"}},
+	})
+	defer containerRuntime.SetListContainersResult(nil)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(nil),
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+
+	_, err := lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	parsed := writtenConfig(g, containerRuntime)
+	servers := parsed.Backend(loadbalancer.BackendName).Servers
+	g.Expect(servers).To(HaveLen(2))
+	g.Expect(servers[0].Name).To(Equal("test-cluster-cp-1"))
+	g.Expect(servers[1].Name).To(Equal("test-cluster-cp-3"))
+}
+
+func TestLoadBalancerMaxBackends(t *testing.T) {
+	nodes := []container.Container{
+		{Name: "test-cluster-cp-3"},
+		{Name: "test-cluster-cp-1"},
+		{Name: "test-cluster-cp-2"},
+	}
+	tests := []struct {
+		name        string
+		maxBackends int
+		want        []string
+	}{
+		{
+			name: "unbounded by default",
+			want: []string{"test-cluster-cp-1", "test-cluster-cp-2", "test-cluster-cp-3"},
+		},
+		{
+			name:        "keeps the first backends sorted by name",
+			maxBackends: 2,
+			want:        []string{"test-cluster-cp-1", "test-cluster-cp-2"},
+		},
+		{
+			name:        "maximum above the number of backends",
+			maxBackends: 5,
+			want:        []string{"test-cluster-cp-1", "test-cluster-cp-2", "test-cluster-cp-3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.ResetExecContainerCallLogs()
+			containerRuntime.SetListContainersResult(nodes)
+			defer containerRuntime.SetListContainersResult(nil)
+
+			lb := &LoadBalancer{
+				name:        "test-cluster",
+				config:      getLoadBalancerConfigData(nil),
+				container:   types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+				maxBackends: tt.maxBackends,
+			}
+
+			result, err := lb.UpdateConfiguration(ctx)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.BackendCount).To(Equal(len(tt.want)))
+
+			var got []string
+			for _, server := range writtenConfig(g, containerRuntime).Backend(loadbalancer.BackendName).Servers {
+				got = append(got, server.Name)
+			}
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestLoadBalancerDuplicateBackendAddresses(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetExecContainerCallLogs()
+	containerRuntime.SetListContainersResult([]container.Container{
+		{Name: "test-cluster-cp-2"},
+		{Name: "test-cluster-cp-1"},
+	})
+	defer containerRuntime.SetListContainersResult(nil)
+	// Both nodes resolve to the same address, e.g. after a stale container kept its published port.
+	containerRuntime.SetHostPorts(map[string]string{
+		"test-cluster-cp-1/6443/tcp": "32768",
+		"test-cluster-cp-2/6443/tcp": "32768",
+	})
+	defer containerRuntime.SetHostPorts(nil)
+
+	lb := &LoadBalancer{
+		name:            "test-cluster",
+		config:          getLoadBalancerConfigData(nil),
+		container:       types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		addressResolver: HostPortResolver{Host: "192.168.1.10"},
+	}
+
+	result, err := lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.BackendCount).To(Equal(1))
+
+	servers := writtenConfig(g, containerRuntime).Backend(loadbalancer.BackendName).Servers
+	g.Expect(servers).To(HaveLen(1))
+	g.Expect(servers[0].Name).To(Equal("test-cluster-cp-1"))
+	g.Expect(servers[0].Address).To(Equal("192.168.1.10:32768"))
+}
+
+func TestLoadBalancerApplyRawConfig(t *testing.T) {
+	config := []byte("global\n  log stdout format raw local0 info\n")
+	checkCommand := "haproxy -c -f " + stagedConfigPath
+
+	writtenPaths := func(containerRuntime *container.FakeRuntime) []string {
+		var paths []string
+		for _, call := range containerRuntime.ExecContainerCalls() {
+			if call.Command == "cp" {
+				paths = append(paths, call.Args[len(call.Args)-1])
+			}
+		}
+		return paths
+	}
+
+	t.Run("validates, writes and reloads the configuration", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime := &container.FakeRuntime{}
+		ctx := container.RuntimeInto(context.Background(), containerRuntime)
+		containerRuntime.ResetExecContainerCallLogs()
+		containerRuntime.ResetKillContainerCallLogs()
+
+		lb := &LoadBalancer{
+			name:      "test-cluster",
+			container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		}
+		g.Expect(lb.ApplyRawConfig(ctx, config)).To(Succeed())
+
+		var commands []string
+		for _, call := range containerRuntime.ExecContainerCalls() {
+			commands = append(commands, strings.Join(append([]string{call.Command}, call.Args...), " "))
+		}
+		g.Expect(commands).To(ContainElement(checkCommand))
+		g.Expect(writtenPaths(containerRuntime)).To(Equal([]string{stagedConfigPath, loadbalancer.ConfigPath}))
+		g.Expect(containerRuntime.KillContainerCalls()).ToNot(BeEmpty())
+	})
+
+	t.Run("rejects an invalid configuration", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime := &container.FakeRuntime{}
+		ctx := container.RuntimeInto(context.Background(), containerRuntime)
+		containerRuntime.ResetExecContainerCallLogs()
+		containerRuntime.SetExecContainerErrors(map[string]error{checkCommand: errors.New("exit status 1")})
+		defer containerRuntime.SetExecContainerErrors(nil)
+
+		lb := &LoadBalancer{
+			name:      "test-cluster",
+			container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		}
+		err := lb.ApplyRawConfig(ctx, config)
+		g.Expect(err).To(MatchError(ContainSubstring("invalid load balancer configuration")))
+		g.Expect(writtenPaths(containerRuntime)).To(Equal([]string{stagedConfigPath}))
+	})
+}
+
+func TestLoadBalancerUpdateConfigurationHibernated(t *testing.T) {
+	tests := []struct {
+		name           string
+		hibernate      bool
+		backends       []container.Container
+		wantHibernated bool
+	}{
+		{
+			name: "no backends without the annotation",
+		},
+		{
+			name:           "no backends with the annotation",
+			hibernate:      true,
+			wantHibernated: true,
+		},
+		{
+			name:      "backends with the annotation",
+			hibernate: true,
+			backends:  []container.Container{{Name: "test-cluster-cp-1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.ResetExecContainerCallLogs()
+			containerRuntime.SetListContainersResult(tt.backends)
+			defer containerRuntime.SetListContainersResult(nil)
+
+			lb := &LoadBalancer{
+				name:      "test-cluster",
+				config:    getLoadBalancerConfigData(nil),
+				container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+				hibernate: tt.hibernate,
+			}
+
+			_, err := lb.UpdateConfiguration(ctx)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(writtenConfig(g, containerRuntime).Hibernated()).To(Equal(tt.wantHibernated))
+		})
+	}
+}
+
+func TestLoadBalancerHibernated(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetExecContainerOutputs(map[string]string{"cat": "frontend control-plane\n  bind *:6443\n  tcp-request connection reject\n  default_backend kube-apiservers\n"})
+	defer containerRuntime.SetExecContainerOutputs(nil)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+	g.Expect(lb.Hibernated(ctx)).To(BeTrue())
+
+	g.Expect((&LoadBalancer{name: "test-cluster"}).Hibernated(ctx)).To(BeFalse())
+}
+
+func TestLoadBalancerCurrentBackends(t *testing.T) {
+	g := NewWithT(t)
+	lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+	ctx := container.RuntimeInto(context.Background(), lbNode)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		container: lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+	}
+	_, err := lb.CurrentBackends(ctx)
+	g.Expect(err).To(MatchError(ContainSubstring("no configuration yet")))
+
+	config, err := loadbalancer.Config(&loadbalancer.ConfigData{
+		ControlPlanePort: 6443,
+		BackendServers: map[string]string{
+			"test-cluster-cp-1": "172.18.0.3:6443",
+			"test-cluster-cp-2": "172.18.0.4:6443",
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	lbNode.SetFile(loadbalancer.ConfigPath, config)
+	g.Expect(lb.CurrentBackends(ctx)).To(Equal(map[string]string{
+		"test-cluster-cp-1": "172.18.0.3:6443",
+		"test-cluster-cp-2": "172.18.0.4:6443",
+	}))
+
+	_, err = (&LoadBalancer{name: "test-cluster"}).CurrentBackends(ctx)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestNewLoadBalancerHibernateAnnotation(t *testing.T) {
+	g := NewWithT(t)
+	ctx := container.RuntimeInto(context.Background(), &container.FakeRuntime{})
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}}
+	dockerCluster := &infrav1.DockerCluster{}
+
+	lb, err := NewLoadBalancer(ctx, cluster, dockerCluster)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lb.hibernate).To(BeFalse())
+
+	dockerCluster.Annotations = map[string]string{infrav1.HibernateLoadBalancerAnnotation: ""}
+	lb, err = NewLoadBalancer(ctx, cluster, dockerCluster)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lb.hibernate).To(BeTrue())
+}
+
+func TestLoadBalancerUpdateConfigurationBackendPools(t *testing.T) {
+	dockerCluster := &infrav1.DockerCluster{Spec: infrav1.DockerClusterSpec{
+		LoadBalancerBackendPools: []infrav1.LoadBalancerBackendPool{
+			{Name: "readers", Port: 7443},
+			{Name: "writers", SNI: "write.example.com"},
+		},
+	}}
+
+	t.Run("ignores pools without the feature gate", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(getLoadBalancerConfigData(dockerCluster).BackendPools).To(BeEmpty())
+	})
+
+	t.Run("populates pools from node labels", func(t *testing.T) {
+		defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.LoadBalancerBackendPools, true)()
+
+		g := NewWithT(t)
+		containerRuntime := &container.FakeRuntime{}
+		ctx := container.RuntimeInto(context.Background(), containerRuntime)
+		containerRuntime.ResetExecContainerCallLogs()
+		containerRuntime.SetListContainersResult([]container.Container{
+			{Name: "test-cluster-cp-1", Labels: map[string]string{LoadBalancerPoolLabelKey: "writers"}},
+			{Name: "test-cluster-cp-2", Labels: map[string]string{LoadBalancerPoolLabelKey: "readers, writers"}},
+			{Name: "test-cluster-cp-3", Labels: map[string]string{LoadBalancerPoolLabelKey: "readers", LoadBalancerExcludeLabelKey: "true"}},
+		})
+		defer containerRuntime.SetListContainersResult(nil)
+
+		lb := &LoadBalancer{
+			name:      "test-cluster",
+			config:    getLoadBalancerConfigData(dockerCluster),
+			container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		}
+		_, err := lb.UpdateConfiguration(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		parsed := writtenConfig(g, containerRuntime)
+		serverNames := func(backend string) []string {
+			names := []string{}
+			for _, server := range parsed.Backend(backend).Servers {
+				names = append(names, server.Name)
+			}
+			return names
+		}
+		g.Expect(serverNames(loadbalancer.BackendName)).To(Equal([]string{"test-cluster-cp-1", "test-cluster-cp-2"}))
+		g.Expect(serverNames("readers")).To(Equal([]string{"test-cluster-cp-2"}))
+		g.Expect(serverNames("writers")).To(Equal([]string{"test-cluster-cp-1", "test-cluster-cp-2"}))
+		g.Expect(lb.config.BackendPools[0].Servers).To(BeNil())
+	})
+}
+
+func TestLoadBalancerPeers(t *testing.T) {
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	defer containerRuntime.SetListContainersResult(nil)
+
+	lb := &LoadBalancer{name: "test-cluster"}
+
+	t.Run("single load balancer", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime.SetListContainersResult([]container.Container{
+			{Name: "test-cluster-lb"},
+			{Name: "test-cluster-lb-retained-20220101000000"},
+		})
+
+		peers, err := lb.peers(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(peers).To(BeEmpty())
+	})
+
+	t.Run("multiple load balancers", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime.ResetListContainersCallLogs()
+		containerRuntime.SetListContainersResult([]container.Container{
+			{Name: "test-cluster-lb-2"},
+			{Name: "test-cluster-lb"},
+		})
+
+		peers, err := lb.peers(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(peers).To(Equal([]loadbalancer.PeerEntry{
+			{Name: "test-cluster-lb", Address: "test-cluster-lbIPv4:10000"},
+			{Name: "test-cluster-lb-2", Address: "test-cluster-lb-2IPv4:10000"},
+		}))
+
+		callLog := containerRuntime.ListContainersCalls()
+		g.Expect(callLog).To(HaveLen(1))
+		g.Expect(callLog[0].Args()).To(ContainElement("label=io.x-k8s.kind.role=" + constants.ExternalLoadBalancerNodeRoleValue))
+	})
+}
+
+func TestLoadBalancerMetricsEndpoint(t *testing.T) {
+	g := NewWithT(t)
+	ctx := container.RuntimeInto(context.Background(), &container.FakeRuntime{})
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(nil),
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+	endpoint, err := lb.MetricsEndpoint(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(endpoint).To(BeEmpty())
+
+	dockerCluster := &infrav1.DockerCluster{}
+	dockerCluster.Spec.LoadBalancerEnableMetrics = true
+	lb.config = getLoadBalancerConfigData(dockerCluster)
+	endpoint, err = lb.MetricsEndpoint(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(endpoint).To(Equal("http://test-cluster-lbIPv4:8405/metrics"))
+}
+
+func TestLoadBalancerNetworkChanged(t *testing.T) {
+	tests := []struct {
+		name               string
+		network            string
+		additionalNetworks []string
+		networks           []string
+		want               bool
+	}{
+		{
+			name:     "attached to the default network",
+			networks: []string{DefaultNetwork},
+		},
+		{
+			name:     "attached to the desired network",
+			network:  "test-network",
+			networks: []string{DefaultNetwork, "test-network"},
+		},
+		{
+			name:     "attached to a different network",
+			network:  "test-network",
+			networks: []string{DefaultNetwork},
+			want:     true,
+		},
+		{
+			name:               "attached to the additional networks",
+			additionalNetworks: []string{"management"},
+			networks:           []string{DefaultNetwork, "management"},
+		},
+		{
+			name:               "not attached to an additional network",
+			additionalNetworks: []string{"management"},
+			networks:           []string{DefaultNetwork},
+			want:               true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.SetContainerNetworks(map[string][]string{"test-cluster-lb": tt.networks})
+			defer containerRuntime.SetContainerNetworks(nil)
+
+			creator := &fakeLBCreator{}
+			lb := &LoadBalancer{
+				name:               "test-cluster",
+				network:            tt.network,
+				additionalNetworks: tt.additionalNetworks,
+				container:          types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+				lbCreator:          creator,
+			}
+
+			changed, err := lb.NetworkChanged(ctx)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(changed).To(Equal(tt.want))
+
+			g.Expect(lb.Recreate(ctx)).To(Succeed())
+			g.Expect(creator.opts).To(HaveLen(1))
+			g.Expect(creator.opts[0].Network).To(Equal(lb.NetworkName()))
+			g.Expect(creator.opts[0].AdditionalNetworks).To(Equal(tt.additionalNetworks))
+		})
+	}
+}
+
+func TestLoadBalancerNetworkIP(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetContainerNetworks(map[string][]string{"test-cluster-lb": {DefaultNetwork, "management"}})
+	defer containerRuntime.SetContainerNetworks(nil)
+
+	lb := &LoadBalancer{
+		name:               "test-cluster",
+		additionalNetworks: []string{"management"},
+		container:          types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+	g.Expect(lb.NetworkIP(ctx, "management")).To(Equal("test-cluster-lb-managementIPv4"))
+	g.Expect(lb.NetworkIP(ctx, DefaultNetwork)).To(Equal("test-cluster-lb-kindIPv4"))
+	_, err := lb.NetworkIP(ctx, "other")
+	g.Expect(err).To(MatchError(ContainSubstring("does not have an IP address on network other")))
+
+	_, err = (&LoadBalancer{name: "test-cluster"}).NetworkIP(ctx, "management")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestBuildBackendServers(t *testing.T) {
+	g := NewWithT(t)
+	ctx := container.RuntimeInto(context.Background(), &container.FakeRuntime{})
+
+	nodes := []*types.Node{
+		types.NewNode("test-cluster-cp-1", "TestImage", constants.ControlPlaneNodeRoleValue),
+		types.NewNode("test-cluster-cp-2", "TestImage", constants.ControlPlaneNodeRoleValue),
+	}
+
+	backendServers, err := BuildBackendServers(ctx, nodes, 7443, 0)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(backendServers).To(Equal(map[string]string{
+		"test-cluster-cp-1": "test-cluster-cp-1IPv4:7443",
+		"test-cluster-cp-2": "test-cluster-cp-2IPv4:7443",
+	}))
+
+	backendServers, err = BuildBackendServers(ctx, nil, 7443, 0)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(backendServers).To(BeEmpty())
+}
+
+func TestBuildBackendServersWaitsForIP(t *testing.T) {
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetContainersWithoutIPs("test-cluster-cp-2")
+	defer containerRuntime.SetContainersWithoutIPs()
+
+	nodes := []*types.Node{
+		types.NewNode("test-cluster-cp-1", "TestImage", constants.ControlPlaneNodeRoleValue),
+		types.NewNode("test-cluster-cp-2", "TestImage", constants.ControlPlaneNodeRoleValue),
+	}
+
+	t.Run("fails immediately without a timeout", func(t *testing.T) {
+		g := NewWithT(t)
+		start := time.Now()
+		_, err := BuildBackendServers(ctx, nodes, 6443, 0)
+		g.Expect(err).To(MatchError(ContainSubstring("failed to resolve backend address for container test-cluster-cp-2")))
+		g.Expect(time.Since(start)).To(BeNumerically("<", 100*time.Millisecond))
+	})
+
+	t.Run("fails once the timeout expires", func(t *testing.T) {
+		g := NewWithT(t)
+		start := time.Now()
+		_, err := BuildBackendServers(ctx, nodes, 6443, 300*time.Millisecond)
+		g.Expect(err).To(MatchError(ContainSubstring("no IP address after 300ms")))
+		g.Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+	})
+
+	t.Run("stops waiting when the context is done", func(t *testing.T) {
+		g := NewWithT(t)
+		cancelCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := BuildBackendServers(cancelCtx, nodes, 6443, time.Minute)
+		g.Expect(err).To(MatchError(ContainSubstring("interrupted")))
+	})
+
+	t.Run("backs off exponentially", func(t *testing.T) {
+		g := NewWithT(t)
+		clock := fake.NewClock(time.Now())
+		_, err := ResolveBackendServers(ctx, nodes, 6443, ContainerIPResolver{IPTimeout: 3 * time.Second, Clock: clock})
+		g.Expect(err).To(MatchError(ContainSubstring("no IP address after 3s")))
+		g.Expect(clock.Waits()).To(Equal([]time.Duration{
+			100 * time.Millisecond,
+			200 * time.Millisecond,
+			400 * time.Millisecond,
+			800 * time.Millisecond,
+			time.Second,
+			500 * time.Millisecond,
+		}))
+	})
+}
+
+func TestGetLoadBalancerArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "image default",
+		},
+		{
+			name: "extra args load the managed config",
+			args: []string{"-d"},
+			want: []string{"-d", "-f", loadbalancer.ConfigPath},
+		},
+		{
+			name: "args already loading the managed config",
+			args: []string{"haproxy", "-f", loadbalancer.ConfigPath, "-d"},
+			want: []string{"haproxy", "-f", loadbalancer.ConfigPath, "-d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			dockerCluster := &infrav1.DockerCluster{}
+			dockerCluster.Spec.LoadBalancerArgs = tt.args
+
+			g.Expect(getLoadBalancerArgs(dockerCluster)).To(Equal(tt.want))
+			// The spec must not be modified.
+			g.Expect(dockerCluster.Spec.LoadBalancerArgs).To(Equal(tt.args))
+		})
+	}
+}
+
+func TestLoadBalancerCreateOptions(t *testing.T) {
+	g := NewWithT(t)
+	ctx := container.RuntimeInto(context.Background(), &container.FakeRuntime{})
+
+	creator := &fakeLBCreator{}
+	lb := &LoadBalancer{
+		name:       "test-cluster",
+		image:      "TestImage",
+		args:       []string{"-d", "-f", loadbalancer.ConfigPath},
+		env:        map[string]string{"HAPROXY_DEBUG": "1"},
+		sysctls:    map[string]string{"net.core.somaxconn": "4096"},
+		capAdd:     []string{"NET_BIND_SERVICE"},
+		hostname:   "test-lb",
+		extraHosts: []string{"apiserver.test:172.18.0.3"},
+		logDriver:  "journald",
+		logOptions: map[string]string{"tag": "test-cluster-lb"},
+		readOnly:   true,
+		lbCreator:  creator,
+	}
+	lb.restartPolicy = "no"
+
+	g.Expect(lb.Create(ctx)).To(Succeed())
+	g.Expect(creator.opts).To(HaveLen(1))
+	g.Expect(creator.opts[0].CommandArgs).To(Equal([]string{"-d", "-f", loadbalancer.ConfigPath}))
+	g.Expect(creator.opts[0].Sysctls).To(Equal(map[string]string{"net.core.somaxconn": "4096"}))
+	g.Expect(creator.opts[0].CapAdd).To(Equal([]string{"NET_BIND_SERVICE"}))
+	g.Expect(creator.opts[0].CapDrop).To(BeEmpty())
+	g.Expect(creator.opts[0].Hostname).To(Equal("test-lb"))
+	g.Expect(creator.opts[0].ExtraHosts).To(Equal([]string{"apiserver.test:172.18.0.3"}))
+	g.Expect(creator.opts[0].LogDriver).To(Equal("journald"))
+	g.Expect(creator.opts[0].LogOptions).To(Equal(map[string]string{"tag": "test-cluster-lb"}))
+	g.Expect(creator.opts[0].Env).To(Equal(map[string]string{"HAPROXY_DEBUG": "1"}))
+	g.Expect(creator.opts[0].ReadOnlyRootFS).To(BeTrue())
+	g.Expect(creator.opts[0].RestartPolicy).To(Equal("no"))
+}
+
+func TestGetLoadBalancerRestartPolicy(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(getLoadBalancerRestartPolicy(nil)).To(Equal("unless-stopped"))
+	g.Expect(getLoadBalancerRestartPolicy(&infrav1.DockerCluster{})).To(Equal("unless-stopped"))
+	g.Expect(getLoadBalancerRestartPolicy(&infrav1.DockerCluster{Spec: infrav1.DockerClusterSpec{
+		LoadBalancerRestartPolicy: "on-failure",
+	}})).To(Equal("on-failure"))
+}
+
+func TestLoadBalancerProbeBackends(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetExecContainerCallLogs()
+	containerRuntime.SetListContainersResult([]container.Container{
+		{Name: "test-cluster-cp-1"},
+		{Name: "test-cluster-cp-2"},
+	})
+	defer containerRuntime.SetListContainersResult(nil)
+	containerRuntime.SetExecContainerErrors(map[string]error{
+		"nc -z -w 2 test-cluster-cp-2IPv4 6443": errors.New("exit status 1"),
+	})
+	defer containerRuntime.SetExecContainerErrors(nil)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+
+	results, err := lb.ProbeBackends(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(results).To(HaveLen(2))
+	g.Expect(results["test-cluster-cp-1"]).ToNot(HaveOccurred())
+	g.Expect(results["test-cluster-cp-2"]).To(MatchError(ContainSubstring("test-cluster-cp-2IPv4:6443 is not reachable")))
+	for _, call := range containerRuntime.ExecContainerCalls() {
+		g.Expect(call.ContainerName).To(Equal("test-cluster-lb"))
+	}
+}
+
+func TestLoadBalancerBackendServersFilters(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetListContainersCallLogs()
+
+	lb := &LoadBalancer{name: "test-cluster"}
+	_, err := lb.backendServers(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	callLog := containerRuntime.ListContainersCalls()
+	g.Expect(callLog).To(HaveLen(1))
+	g.Expect(callLog[0].Args()).To(Equal([]string{
+		"label=io.x-k8s.kind.cluster",
+		"label=io.x-k8s.kind.cluster=test-cluster",
+		"label=io.x-k8s.kind.role=control-plane",
+	}))
+}
+
+func TestLoadBalancerReconcile(t *testing.T) {
+	tests := []struct {
+		name        string
+		exists      bool
+		networks    []string
+		withoutIP   bool
+		want        LoadBalancerState
+		wantCreates int
+	}{
+		{
+			name:        "creates a missing container",
+			want:        LoadBalancerReady,
+			wantCreates: 1,
+		},
+		{
+			name:     "existing container with an address",
+			exists:   true,
+			networks: []string{DefaultNetwork},
+			want:     LoadBalancerReady,
+		},
+		{
+			name:      "existing container without an address",
+			exists:    true,
+			networks:  []string{DefaultNetwork},
+			withoutIP: true,
+			want:      LoadBalancerCreated,
+		},
+		{
+			name:        "existing container on a different network",
+			exists:      true,
+			networks:    []string{"other-network"},
+			want:        LoadBalancerUpdating,
+			wantCreates: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.SetContainerNetworks(map[string][]string{"test-cluster-lb": tt.networks})
+			defer containerRuntime.SetContainerNetworks(nil)
+			if tt.withoutIP {
+				containerRuntime.SetContainersWithoutIPs("test-cluster-lb")
+				defer containerRuntime.SetContainersWithoutIPs()
+			}
+
+			creator := &fakeLBCreator{}
+			lb := &LoadBalancer{
+				name:      "test-cluster",
+				image:     "TestImage",
+				config:    getLoadBalancerConfigData(nil),
+				lbCreator: creator,
+			}
+			if tt.exists {
+				lb.container = types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue)
+			}
+
+			state, err := lb.Reconcile(ctx)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(state).To(Equal(tt.want))
+			g.Expect(creator.calls).To(HaveLen(tt.wantCreates))
+		})
+	}
+}
+
+func TestLoadBalancerCreateLoadsImageArchive(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetLoadContainerImageCallLogs()
+
+	archive := filepath.Join(t.TempDir(), "haproxy.tar")
+	f, err := os.Create(archive)
+	g.Expect(err).ToNot(HaveOccurred())
+	manifest := `[{"Config":"abc.json","RepoTags":["haproxytech/haproxy-alpine:2.4"],"Layers":[]}]`
+	tw := tar.NewWriter(f)
+	g.Expect(tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0600, Size: int64(len(manifest))})).To(Succeed())
+	_, err = tw.Write([]byte(manifest))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(tw.Close()).To(Succeed())
+	g.Expect(f.Close()).To(Succeed())
+
+	lb := &LoadBalancer{
+		name:         "test-cluster",
+		image:        "docker.io/haproxytech/haproxy-alpine:2.4",
+		imageArchive: archive,
+		lbCreator:    &fakeLBCreator{},
+	}
+	g.Expect(lb.Create(ctx)).To(Succeed())
+	g.Expect(containerRuntime.LoadContainerImageCalls()).To(Equal([]string{archive}))
+
+	containerRuntime.ResetLoadContainerImageCallLogs()
+	lb = &LoadBalancer{
+		name:         "test-cluster",
+		image:        "haproxytech/haproxy-alpine:2.6",
+		imageArchive: archive,
+		lbCreator:    &fakeLBCreator{},
+	}
+	g.Expect(lb.Create(ctx)).To(MatchError(ContainSubstring("does not contain the load balancer image")))
+	g.Expect(containerRuntime.LoadContainerImageCalls()).To(BeEmpty())
+}
+
+func TestLoadBalancerCreateRestoresMissingImage(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.ResetPullContainerImageCallLogs()
+
+	imageNotFound := errors.Wrap(container.ImageNotFoundError{Image: "haproxytech/haproxy-alpine:2.4"}, "error creating container")
+
+	creator := &fakeLBCreator{errs: []error{imageNotFound}}
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		image:     "haproxytech/haproxy-alpine:2.4",
+		lbCreator: creator,
+	}
+	g.Expect(lb.Create(ctx)).To(Succeed())
+	g.Expect(creator.calls).To(HaveLen(2))
+	g.Expect(containerRuntime.PullContainerImageCalls()).To(Equal([]string{"haproxytech/haproxy-alpine:2.4"}))
+	g.Expect(lb.container).ToNot(BeNil())
+
+	// Only retry once, and keep the error recognizable for the caller.
+	containerRuntime.ResetPullContainerImageCallLogs()
+	creator = &fakeLBCreator{errs: []error{imageNotFound, imageNotFound}}
+	lb = &LoadBalancer{
+		name:      "test-cluster",
+		image:     "haproxytech/haproxy-alpine:2.4",
+		lbCreator: creator,
+	}
+	err := lb.Create(ctx)
+	g.Expect(errors.As(err, &container.ImageNotFoundError{})).To(BeTrue())
+	g.Expect(creator.calls).To(HaveLen(2))
+	g.Expect(containerRuntime.PullContainerImageCalls()).To(HaveLen(1))
+
+	// Other errors are not retried.
+	containerRuntime.ResetPullContainerImageCallLogs()
+	creator = &fakeLBCreator{errs: []error{errors.New("port is already allocated")}}
+	lb = &LoadBalancer{
+		name:      "test-cluster",
+		image:     "haproxytech/haproxy-alpine:2.4",
+		lbCreator: creator,
+	}
+	g.Expect(lb.Create(ctx)).To(MatchError(ContainSubstring("port is already allocated")))
+	g.Expect(creator.calls).To(HaveLen(1))
+	g.Expect(containerRuntime.PullContainerImageCalls()).To(BeEmpty())
+}
+
+func TestLoadBalancerReadyForTraffic(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		readErr bool
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "backends configured",
+			config: "backend kube-apiservers\n  server test-cluster-cp-1 10.0.0.1:6443 check\n",
+			want:   true,
+		},
+		{
+			name:   "no backends",
+			config: "frontend control-plane\n  bind *:6443\n  default_backend kube-apiservers\n\nbackend kube-apiservers\n  option httpchk GET /healthz\n",
+		},
+		{
+			name:   "all backends in maintenance",
+			config: "backend kube-apiservers\n  server test-cluster-cp-1 10.0.0.1:6443 check disabled\n",
+		},
+		{
+			name:   "image default configuration",
+			config: "global\n  log stdout format raw local0\n",
+		},
+		{
+			name:    "configuration not readable",
+			readErr: true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			containerRuntime := &container.FakeRuntime{}
+			ctx := container.RuntimeInto(context.Background(), containerRuntime)
+			containerRuntime.SetExecContainerOutputs(map[string]string{"cat": tt.config})
+			defer containerRuntime.SetExecContainerOutputs(nil)
+			if tt.readErr {
+				containerRuntime.SetExecContainerErrors(map[string]error{"cat " + loadbalancer.ConfigPath: errors.New("no such file")})
+				defer containerRuntime.SetExecContainerErrors(nil)
+			}
+
+			lb := &LoadBalancer{
+				name:      "test-cluster",
+				container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+			}
+
+			got, err := lb.ReadyForTraffic(ctx)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+
+	t.Run("no container", func(t *testing.T) {
+		g := NewWithT(t)
+		lb := &LoadBalancer{name: "test-cluster"}
+		g.Expect(lb.ReadyForTraffic(context.Background())).To(BeFalse())
+	})
+}
+
+func TestLoadBalancerConfigApplied(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}, {Name: "test-cluster-cp-2"}})
+	defer containerRuntime.SetListContainersResult(nil)
+	containerRuntime.SetExecContainerOutputs(map[string]string{"wget": "# pxname,svname,status\n" +
+		"kube-apiservers,test-cluster-cp-1,UP\n" +
+		"kube-apiservers,BACKEND,UP\n"})
+	defer containerRuntime.SetExecContainerOutputs(nil)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+	}
+	g.Expect(lb.ConfigApplied(ctx)).To(MatchError(ContainSubstring("serves servers [test-cluster-cp-1], expected [test-cluster-cp-1 test-cluster-cp-2]")))
+
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+	g.Expect(lb.ConfigApplied(ctx)).To(Succeed())
+}
+
+func TestLoadBalancerWaitForHealthyBackends(t *testing.T) {
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetExecContainerOutputs(map[string]string{"wget": "# pxname,svname,status\n" +
+		"kube-apiservers,test-cluster-cp-1,UP\n" +
+		"kube-apiservers,test-cluster-cp-2,DOWN\n" +
+		"kube-apiservers,test-cluster-cp-3,UP 1/3\n" +
+		"kube-apiservers,BACKEND,UP\n"})
+	defer containerRuntime.SetExecContainerOutputs(nil)
+
+	newLB := func() *LoadBalancer {
+		return &LoadBalancer{
+			name:      "test-cluster",
+			config:    getLoadBalancerConfigData(nil),
+			container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		}
+	}
+
+	t.Run("returns once enough backends are up", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(newLB().WaitForHealthyBackends(ctx, 2, time.Minute)).To(Succeed())
+	})
+
+	t.Run("lists the backends still down on timeout", func(t *testing.T) {
+		g := NewWithT(t)
+		lb := newLB()
+		clock := fake.NewClock(time.Now())
+		lb.SetClock(clock)
+
+		err := lb.WaitForHealthyBackends(ctx, 3, 3*time.Second)
+		g.Expect(err).To(MatchError(ContainSubstring("not healthy within 3s: 2 servers up, expected at least 3, not up: [test-cluster-cp-2 (DOWN)]")))
+		g.Expect(clock.Waits()).To(Equal([]time.Duration{time.Second, time.Second, time.Second}))
+	})
+
+	t.Run("requires the stats page", func(t *testing.T) {
+		g := NewWithT(t)
+		lb := newLB()
+		lb.config.EnableStats = false
+		g.Expect(lb.WaitForHealthyBackends(ctx, 1, time.Minute)).To(MatchError(ContainSubstring("stats page is not enabled")))
+	})
+}
+
+func TestLoadBalancerPaused(t *testing.T) {
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+
+	tests := []struct {
+		name          string
+		cluster       *clusterv1.Cluster
+		dockerCluster *infrav1.DockerCluster
+		existing      bool
+	}{
+		{
+			name:          "DockerCluster paused with the annotation",
+			cluster:       &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}},
+			dockerCluster: &infrav1.DockerCluster{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{clusterv1.PausedAnnotation: ""}}},
+			existing:      true,
+		},
+		{
+			name:          "Cluster paused",
+			cluster:       &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}, Spec: clusterv1.ClusterSpec{Paused: true}},
+			dockerCluster: &infrav1.DockerCluster{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			if tt.existing {
+				containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-lb", Image: "TestImage"}})
+				defer containerRuntime.SetListContainersResult(nil)
+			}
+			lb, err := NewLoadBalancer(ctx, tt.cluster, tt.dockerCluster)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(lb.Paused()).To(BeTrue())
+			lb.lbCreator = &fakeLBCreator{}
+
+			containerRuntime.ResetListContainersCallLogs()
+			containerRuntime.ResetExecContainerCallLogs()
+			containerRuntime.ResetRunContainerCallLogs()
+			containerRuntime.ResetDeleteContainerCallLogs()
+			containerRuntime.ResetStopContainerCallLogs()
+			containerRuntime.ResetKillContainerCallLogs()
+
+			g.Expect(lb.Create(ctx)).To(Succeed())
+			state, err := lb.Reconcile(ctx)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(state).To(Equal(LoadBalancerPaused))
+			_, err = lb.EnsureReady(ctx, 0, time.Second)
+			g.Expect(err).To(MatchError(ContainSubstring("load balancer is paused")))
+			g.Expect(lb.Recreate(ctx)).To(Succeed())
+			g.Expect(lb.Drain(ctx)).To(Succeed())
+			g.Expect(lb.ApplyRawConfig(ctx, []byte("global\n"))).To(Succeed())
+			result, err := lb.UpdateConfiguration(ctx)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result).To(Equal(UpdateResult{}))
+			g.Expect(lb.ForceReconfigure(ctx)).To(Succeed())
+			g.Expect(lb.Delete(ctx)).To(Succeed())
+
+			g.Expect(lb.lbCreator.(*fakeLBCreator).calls).To(BeEmpty())
+			g.Expect(containerRuntime.ListContainersCalls()).To(BeEmpty())
+			g.Expect(containerRuntime.ExecContainerCalls()).To(BeEmpty())
+			g.Expect(containerRuntime.RunContainerCalls()).To(BeEmpty())
+			g.Expect(containerRuntime.DeleteContainerCalls()).To(BeEmpty())
+			g.Expect(containerRuntime.StopContainerCalls()).To(BeEmpty())
+			g.Expect(containerRuntime.KillContainerCalls()).To(BeEmpty())
+		})
+	}
+
+	t.Run("not paused", func(t *testing.T) {
+		g := NewWithT(t)
+		lb, err := NewLoadBalancer(ctx, &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}}, &infrav1.DockerCluster{})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(lb.Paused()).To(BeFalse())
+	})
+}
+
+func TestLoadBalancerIPFamily(t *testing.T) {
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-lb", Image: "TestImage"}})
+	defer containerRuntime.SetListContainersResult(nil)
+
+	tests := []struct {
+		name         string
+		pods         []string
+		wantIPFamily string
+		wantIP       string
+	}{
+		{
+			name:         "IPv4 without cluster networks",
+			wantIPFamily: loadbalancer.IPFamilyIPv4,
+			wantIP:       "test-cluster-lbIPv4",
+		},
+		{
+			name:         "IPv6",
+			pods:         []string{"fd00:100::/64"},
+			wantIPFamily: loadbalancer.IPFamilyIPv6,
+			wantIP:       "test-cluster-lbIPv6",
+		},
+		{
+			name:         "dual-stack reports the IPv4 address",
+			pods:         []string{"10.244.0.0/16", "fd00:100::/64"},
+			wantIPFamily: loadbalancer.IPFamilyDualStack,
+			wantIP:       "test-cluster-lbIPv4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}}
+			if tt.pods != nil {
+				cluster.Spec.ClusterNetwork = &clusterv1.ClusterNetwork{Pods: &clusterv1.NetworkRanges{CIDRBlocks: tt.pods}}
+			}
+
+			lb, err := NewLoadBalancer(ctx, cluster, &infrav1.DockerCluster{})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(lb.config.IPFamily).To(Equal(tt.wantIPFamily))
+			g.Expect(lb.IP(ctx)).To(Equal(tt.wantIP))
+			g.Expect(lb.WaitForIP(ctx, time.Second)).To(Equal(tt.wantIP))
+		})
+	}
+
+	t.Run("fails on invalid cluster networks", func(t *testing.T) {
+		g := NewWithT(t)
+		cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}}
+		cluster.Spec.ClusterNetwork = &clusterv1.ClusterNetwork{Pods: &clusterv1.NetworkRanges{CIDRBlocks: []string{"not-a-cidr"}}}
+		_, err := NewLoadBalancer(ctx, cluster, &infrav1.DockerCluster{})
+		g.Expect(err).To(MatchError(ContainSubstring("failed to get the IP family of the cluster")))
+	})
+}
+
+func TestLoadBalancerWaitForIP(t *testing.T) {
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+
+	newLB := func() *LoadBalancer {
+		return &LoadBalancer{
+			name:      "test-cluster",
+			container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+		}
+	}
+
+	t.Run("returns the address", func(t *testing.T) {
+		g := NewWithT(t)
+		lbIP, err := newLB().WaitForIP(ctx, time.Minute)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(lbIP).To(Equal("test-cluster-lbIPv4"))
+	})
+
+	t.Run("times out without an address", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime.SetContainersWithoutIPs("test-cluster-lb")
+		defer containerRuntime.SetContainersWithoutIPs()
+		lb := newLB()
+		clock := fake.NewClock(time.Now())
+		lb.SetClock(clock)
+
+		_, err := lb.WaitForIP(ctx, time.Second)
+		g.Expect(err).To(MatchError(ContainSubstring("test-cluster-lb did not get an IP address within 1s")))
+		g.Expect(clock.Waits()).To(Equal([]time.Duration{ipPollInterval, ipPollInterval}))
+	})
+
+	t.Run("fails fast when the container exited", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime.SetContainersWithoutIPs("test-cluster-lb")
+		defer containerRuntime.SetContainersWithoutIPs()
+		containerRuntime.SetContainerStates(map[string]container.ContainerState{
+			"test-cluster-lb": {Status: "exited", ExitCode: 1, Error: "haproxy: parsing [haproxy.cfg:12]"},
+		})
+		defer containerRuntime.SetContainerStates(nil)
+		lb := newLB()
+		clock := fake.NewClock(time.Now())
+		lb.SetClock(clock)
+
+		_, err := lb.WaitForIP(ctx, time.Minute)
+		g.Expect(err).To(MatchError(ContainSubstring("test-cluster-lb exited (exit code 1: haproxy: parsing [haproxy.cfg:12])")))
+		g.Expect(clock.Waits()).To(BeEmpty())
+	})
+
+	t.Run("keeps waiting while the container runs", func(t *testing.T) {
+		g := NewWithT(t)
+		containerRuntime.SetContainersWithoutIPs("test-cluster-lb")
+		defer containerRuntime.SetContainersWithoutIPs()
+		containerRuntime.SetContainerStates(map[string]container.ContainerState{"test-cluster-lb": {Status: "running"}})
+		defer containerRuntime.SetContainerStates(nil)
+		lb := newLB()
+		clock := fake.NewClock(time.Now())
+		lb.SetClock(clock)
+
+		_, err := lb.WaitForIP(ctx, time.Second)
+		g.Expect(err).To(MatchError(ContainSubstring("did not get an IP address within 1s")))
+		g.Expect(clock.Waits()).ToNot(BeEmpty())
+	})
+
+	t.Run("requires a container", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := (&LoadBalancer{name: "test-cluster"}).WaitForIP(ctx, time.Minute)
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestLoadBalancerEnsureReady(t *testing.T) {
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+	containerRuntime.SetContainerNetworks(map[string][]string{"test-cluster-lb": {DefaultNetwork}})
+	defer containerRuntime.SetContainerNetworks(nil)
+	containerRuntime.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}, {Name: "test-cluster-cp-2"}})
+	defer containerRuntime.SetListContainersResult(nil)
+	containerRuntime.SetExecContainerOutputs(map[string]string{"wget": "# pxname,svname,status\n" +
+		"kube-apiservers,test-cluster-cp-1,UP\n" +
+		"kube-apiservers,test-cluster-cp-2,DOWN\n"})
+	defer containerRuntime.SetExecContainerOutputs(nil)
+
+	newLB := func() *LoadBalancer {
+		return &LoadBalancer{
+			name:      "test-cluster",
+			image:     "TestImage",
+			config:    getLoadBalancerConfigData(nil),
+			container: types.NewNode("test-cluster-lb", "TestImage", constants.ExternalLoadBalancerNodeRoleValue),
+			lbCreator: &fakeLBCreator{},
+		}
+	}
+
+	t.Run("returns the endpoint once the backends are healthy", func(t *testing.T) {
+		g := NewWithT(t)
+		lb := newLB()
+		endpoint, err := lb.EnsureReady(ctx, 1, time.Minute)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(endpoint).To(Equal(clusterv1.APIEndpoint{Host: "test-cluster-lbIPv4", Port: ControlPlanePort}))
+
+		config := writtenConfig(g, containerRuntime)
+		g.Expect(config.Backend(loadbalancer.BackendName).Servers).To(HaveLen(2))
+	})
+
+	t.Run("fails when not enough backends are healthy", func(t *testing.T) {
+		g := NewWithT(t)
+		lb := newLB()
+		lb.SetClock(fake.NewClock(time.Now()))
+		_, err := lb.EnsureReady(ctx, 2, time.Second)
+		g.Expect(err).To(MatchError(ContainSubstring("1 servers up, expected at least 2")))
+	})
+
+	t.Run("does not wait for backends when none is required", func(t *testing.T) {
+		g := NewWithT(t)
+		lb := newLB()
+		lb.config.EnableStats = false
+		_, err := lb.EnsureReady(ctx, 0, time.Minute)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+}
+
+func TestGetLoadBalancerConfigDataRetries(t *testing.T) {
+	g := NewWithT(t)
+
+	data := getLoadBalancerConfigData(nil)
+	g.Expect(data.Retries).To(HaveValue(Equal(defaultRetries)))
+	g.Expect(data.Redispatch).To(BeTrue())
+
+	retries := int32(0)
+	redispatch := false
+	dockerCluster := &infrav1.DockerCluster{}
+	dockerCluster.Spec.LoadBalancerRetries = &retries
+	dockerCluster.Spec.LoadBalancerRedispatch = &redispatch
+
+	data = getLoadBalancerConfigData(dockerCluster)
+	g.Expect(data.Retries).To(HaveValue(BeZero()))
+	g.Expect(data.Redispatch).To(BeFalse())
+}
+
+func TestGetLoadBalancerConfigDataHardStopAfter(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(getLoadBalancerConfigData(nil).HardStopAfter).To(Equal(defaultHardStopAfter))
+
+	dockerCluster := &infrav1.DockerCluster{}
+	dockerCluster.Spec.LoadBalancerHardStopAfter = &metav1.Duration{Duration: time.Minute}
+	g.Expect(getLoadBalancerConfigData(dockerCluster).HardStopAfter).To(Equal(time.Minute))
+}
+
+func TestGetLoadBalancerConfigDataMaxConn(t *testing.T) {
+	g := NewWithT(t)
+
+	data := getLoadBalancerConfigData(&infrav1.DockerCluster{})
+	g.Expect(data.MaxConn).To(BeZero())
+	g.Expect(data.FrontendMaxConn).To(BeZero())
+
+	dockerCluster := &infrav1.DockerCluster{}
+	dockerCluster.Spec.LoadBalancerMaxConn = 4096
+	dockerCluster.Spec.LoadBalancerFrontendMaxConn = 4000
+	data = getLoadBalancerConfigData(dockerCluster)
+	g.Expect(data.MaxConn).To(Equal(4096))
+	g.Expect(data.FrontendMaxConn).To(Equal(4000))
+}
+
+func TestGetLoadBalancerConfigDataPreserveDestinationPort(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(getLoadBalancerConfigData(&infrav1.DockerCluster{}).PreserveDestinationPort).To(BeFalse())
+
+	dockerCluster := &infrav1.DockerCluster{}
+	dockerCluster.Spec.LoadBalancerPreserveDestinationPort = true
+	g.Expect(getLoadBalancerConfigData(dockerCluster).PreserveDestinationPort).To(BeTrue())
+}
+
+func TestGetLoadBalancerConfigDataConnectionTuning(t *testing.T) {
+	g := NewWithT(t)
+
+	data := getLoadBalancerConfigData(&infrav1.DockerCluster{})
+	g.Expect(data.TCPSmartConnect).To(BeFalse())
+	g.Expect(data.HTTPReuse).To(BeEmpty())
+
+	dockerCluster := &infrav1.DockerCluster{}
+	dockerCluster.Spec.LoadBalancerMode = loadbalancer.ModeHTTP
+	dockerCluster.Spec.LoadBalancerTCPSmartConnect = true
+	dockerCluster.Spec.LoadBalancerHTTPReuse = loadbalancer.HTTPReuseAggressive
+	data = getLoadBalancerConfigData(dockerCluster)
+	g.Expect(data.TCPSmartConnect).To(BeTrue())
+	g.Expect(data.HTTPReuse).To(Equal(loadbalancer.HTTPReuseAggressive))
+}
+
+func TestServedDifference(t *testing.T) {
+	backendServers := map[string]string{"cp-1": "172.18.0.3:6443"}
+
+	tests := []struct {
+		name    string
+		served  map[string]string
+		wantErr string
+	}{
+		{name: "same address", served: map[string]string{"cp-1": "172.18.0.3:6443"}},
+		{name: "address not reported", served: map[string]string{"cp-1": ""}},
+		{name: "server without a port", served: map[string]string{"cp-1": "172.18.0.3:0"}},
+		{name: "other servers", served: map[string]string{"cp-2": "172.18.0.3:6443"}, wantErr: "expected servers [cp-1], got [cp-2]"},
+		{name: "other IP", served: map[string]string{"cp-1": "172.18.0.4:6443"}, wantErr: "expected server cp-1 at 172.18.0.3:6443, got 172.18.0.4:6443"},
+		{name: "other port", served: map[string]string{"cp-1": "172.18.0.3:7443"}, wantErr: "expected server cp-1 at 172.18.0.3:6443, got 172.18.0.3:7443"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := servedDifference(tt.served, backendServers)
+			if tt.wantErr == "" {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(MatchError(tt.wantErr))
+		})
+	}
+}
+
+func TestGetLoadBalancerConfigDataLogFormat(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(getLoadBalancerConfigData(&infrav1.DockerCluster{}).LogFormat).To(BeEmpty())
+
+	dockerCluster := &infrav1.DockerCluster{}
+	dockerCluster.Spec.LoadBalancerLogFormat = infrav1.LoadBalancerLogFormatJSON
+	g.Expect(getLoadBalancerConfigData(dockerCluster).LogFormat).To(Equal(loadbalancer.JSONLogFormat))
+
+	dockerCluster.Spec.LoadBalancerLogFormat = "%ci %b/%s"
+	g.Expect(getLoadBalancerConfigData(dockerCluster).LogFormat).To(Equal("%ci %b/%s"))
+}
+
+func TestGetLoadBalancerConfigDataRunAs(t *testing.T) {
+	g := NewWithT(t)
+
+	data := getLoadBalancerConfigData(&infrav1.DockerCluster{})
+	g.Expect(data.RunAsUser).To(BeEmpty())
+	g.Expect(configFileOwner(data)).To(BeEmpty())
+
+	dockerCluster := &infrav1.DockerCluster{}
+	dockerCluster.Spec.LoadBalancerRunAsUser = "haproxy"
+	g.Expect(configFileOwner(getLoadBalancerConfigData(dockerCluster))).To(Equal("haproxy"))
+	dockerCluster.Spec.LoadBalancerRunAsGroup = "99"
+	data = getLoadBalancerConfigData(dockerCluster)
+	g.Expect(data.RunAsGroup).To(Equal("99"))
+	g.Expect(configFileOwner(data)).To(Equal("haproxy:99"))
+	dockerCluster.Spec.LoadBalancerRunAsUser = ""
+	g.Expect(configFileOwner(getLoadBalancerConfigData(dockerCluster))).To(Equal(":99"))
+}
+
+func TestGetLoadBalancerConfigDataSNIRoutes(t *testing.T) {
+	g := NewWithT(t)
+
+	dockerCluster := &infrav1.DockerCluster{}
+	dockerCluster.Annotations = map[string]string{infrav1.SNIRoutesAnnotation: "a.example.com=kube-apiservers"}
+	g.Expect(getLoadBalancerConfigData(dockerCluster).SNIRoutes).To(BeEmpty())
+
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.LoadBalancerSNIRouting, true)()
+	g.Expect(getLoadBalancerConfigData(dockerCluster).SNIRoutes).To(Equal([]loadbalancer.SNIRoute{
+		{ServerName: "a.example.com", Backend: "kube-apiservers"},
+	}))
+}
+
+func TestLoadBalancerUpdateConfigurationUnhealthyMachines(t *testing.T) {
+	lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+	ctx := container.RuntimeInto(context.Background(), lbNode)
+	lbNode.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}, {Name: "test-cluster-cp-2"}})
+	defer lbNode.SetListContainersResult(nil)
+
+	disabledServers := func(g *WithT, config string) []string {
+		parsed, err := loadbalancer.ParseConfig([]byte(config))
+		g.Expect(err).ToNot(HaveOccurred())
+		disabled := []string{}
+		for _, server := range parsed.Backend(loadbalancer.BackendName).Servers {
+			for _, option := range server.Options {
+				if option == "disabled" {
+					disabled = append(disabled, server.Name)
+				}
+			}
+		}
+		return disabled
+	}
+
+	tests := []struct {
+		name         string
+		unhealthy    []string
+		wantDisabled []string
+	}{
+		{
+			name:         "routes to all the healthy machines",
+			wantDisabled: []string{},
+		},
+		{
+			name:         "puts an unhealthy machine in maintenance",
+			unhealthy:    []string{"cp-1"},
+			wantDisabled: []string{"test-cluster-cp-1"},
+		},
+		{
+			name:         "ignores unhealthy machines that are not backends",
+			unhealthy:    []string{"cp-3"},
+			wantDisabled: []string{},
+		},
+		{
+			name:         "keeps routing when all the machines are unhealthy",
+			unhealthy:    []string{"cp-1", "test-cluster-cp-2"},
+			wantDisabled: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			applier := &fakeConfigApplier{}
+			lb := &LoadBalancer{
+				name:      "test-cluster",
+				config:    getLoadBalancerConfigData(nil),
+				container: lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+				applier:   applier,
+			}
+			lb.SetUnhealthyMachines(tt.unhealthy...)
+
+			_, err := lb.UpdateConfiguration(ctx)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(applier.applied).To(HaveLen(1))
+			g.Expect(disabledServers(g, applier.applied[0])).To(Equal(tt.wantDisabled))
+		})
+	}
+}
+
+func TestLoadBalancerMaintenanceChanged(t *testing.T) {
+	g := NewWithT(t)
+	lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+	ctx := container.RuntimeInto(context.Background(), lbNode)
+	lbNode.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}, {Name: "test-cluster-cp-2"}})
+	defer lbNode.SetListContainersResult(nil)
+
+	lb := &LoadBalancer{
+		name:      "test-cluster",
+		config:    getLoadBalancerConfigData(nil),
+		container: lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+	}
+	_, err := lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lb.MaintenanceChanged(ctx)).To(BeFalse())
+
+	// The machine fails its health check.
+	lb.SetUnhealthyMachines("cp-1")
+	g.Expect(lb.MaintenanceChanged(ctx)).To(BeTrue())
+	_, err = lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lb.MaintenanceChanged(ctx)).To(BeFalse())
+
+	// The machine recovers.
+	lb.SetUnhealthyMachines()
+	g.Expect(lb.MaintenanceChanged(ctx)).To(BeTrue())
+	_, err = lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lb.MaintenanceChanged(ctx)).To(BeFalse())
+	backends, err := lb.CurrentBackends(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(backends).To(HaveLen(2))
+	config, _ := lbNode.File(loadbalancer.ConfigPath)
+	g.Expect(config).ToNot(ContainSubstring(" disabled"))
+}
@@ -55,8 +55,8 @@ const (
 )
 
 type nodeCreator interface {
-	CreateControlPlaneNode(ctx context.Context, name, image, clusterName, listenAddress string, port int32, mounts []v1alpha4.Mount, portMappings []v1alpha4.PortMapping, labels map[string]string, ipFamily clusterv1.ClusterIPFamily) (node *types.Node, err error)
-	CreateWorkerNode(ctx context.Context, name, image, clusterName string, mounts []v1alpha4.Mount, portMappings []v1alpha4.PortMapping, labels map[string]string, ipFamily clusterv1.ClusterIPFamily) (node *types.Node, err error)
+	CreateControlPlaneNode(ctx context.Context, name, image, clusterName, network, listenAddress string, port int32, mounts []v1alpha4.Mount, portMappings []v1alpha4.PortMapping, labels map[string]string, ipFamily clusterv1.ClusterIPFamily) (node *types.Node, err error)
+	CreateWorkerNode(ctx context.Context, name, image, clusterName, network string, mounts []v1alpha4.Mount, portMappings []v1alpha4.PortMapping, labels map[string]string, ipFamily clusterv1.ClusterIPFamily) (node *types.Node, err error)
 }
 
 // Machine implement a service for managing the docker containers hosting a kubernetes nodes.
@@ -65,6 +65,8 @@ type Machine struct {
 	machine   string
 	ipFamily  clusterv1.ClusterIPFamily
 	container *types.Node
+	// network is the docker network the container is created on; empty uses DefaultNetwork.
+	network string
 
 	nodeCreator nodeCreator
 }
@@ -82,7 +84,7 @@ func NewMachine(ctx context.Context, cluster *clusterv1.Cluster, machine string,
 	}
 
 	filters := container.FilterBuilder{}
-	filters.AddKeyNameValue(filterLabel, clusterLabelKey, cluster.Name)
+	filters.AddKeyNameValue(filterLabel, ClusterLabelKey, cluster.Name)
 	filters.AddKeyValue(filterName, fmt.Sprintf("^%s$", machineContainerName(cluster.Name, machine)))
 	for key, val := range filterLabels {
 		filters.AddKeyNameValue(filterLabel, key, val)
@@ -107,6 +109,13 @@ func NewMachine(ctx context.Context, cluster *clusterv1.Cluster, machine string,
 	}, nil
 }
 
+// SetNetwork sets the docker network the machine container is created on, the cluster network the load
+// balancer routes to the control plane on; an empty network uses DefaultNetwork. It does not move an
+// existing container.
+func (m *Machine) SetNetwork(network string) {
+	m.network = network
+}
+
 // ListMachinesByCluster will retrieve a list of all machines that are part of the given cluster.
 func ListMachinesByCluster(ctx context.Context, cluster *clusterv1.Cluster, labels map[string]string) ([]*Machine, error) {
 	if cluster == nil {
@@ -117,7 +126,7 @@ func ListMachinesByCluster(ctx context.Context, cluster *clusterv1.Cluster, labe
 	}
 
 	filters := container.FilterBuilder{}
-	filters.AddKeyNameValue(filterLabel, clusterLabelKey, cluster.Name)
+	filters.AddKeyNameValue(filterLabel, ClusterLabelKey, cluster.Name)
 	for key, val := range labels {
 		filters.AddKeyNameValue(filterLabel, key, val)
 	}
@@ -222,6 +231,7 @@ func (m *Machine) Create(ctx context.Context, image string, role string, version
 				m.ContainerName(),
 				machineImage,
 				m.cluster,
+				m.network,
 				"127.0.0.1",
 				0,
 				kindMounts(mounts),
@@ -239,6 +249,7 @@ func (m *Machine) Create(ctx context.Context, image string, role string, version
 				m.ContainerName(),
 				machineImage,
 				m.cluster,
+				m.network,
 				kindMounts(mounts),
 				nil,
 				labels,
@@ -427,7 +438,7 @@ func (m *Machine) SetNodeProviderID(ctx context.Context, c client.Client) error
 func (m *Machine) getKubectlNode(ctx context.Context) (*types.Node, error) {
 	// collect info about the existing nodes
 	filters := container.FilterBuilder{}
-	filters.AddKeyNameValue(filterLabel, clusterLabelKey, m.cluster)
+	filters.AddKeyNameValue(filterLabel, ClusterLabelKey, m.cluster)
 
 	kubectlNodes, err := listContainers(ctx, filters)
 	if err != nil {
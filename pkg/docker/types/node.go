@@ -20,7 +20,9 @@ package types
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -38,6 +40,7 @@ type Node struct {
 	ClusterRole string
 	InternalIP  string
 	Image       string
+	Labels      map[string]string
 	status      string
 	Commander   *ContainerCmder
 }
@@ -63,6 +66,12 @@ func (n Node) String() string {
 	return n.Name
 }
 
+// WithLabels sets the labels of the container and returns the node.
+func (n *Node) WithLabels(labels map[string]string) *Node {
+	n.Labels = labels
+	return n
+}
+
 // Role returns the role of the node.
 func (n *Node) Role() (string, error) {
 	return n.ClusterRole, nil
@@ -85,6 +94,20 @@ func (n *Node) IP(ctx context.Context) (ipv4 string, err error) {
 	return ipv4, nil
 }
 
+// IPs returns the IPv4 and IPv6 addresses of the node; either is empty if the node does not have one.
+func (n *Node) IPs(ctx context.Context) (ipv4, ipv6 string, err error) {
+	containerRuntime, err := container.RuntimeFrom(ctx)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to connect to container runtime")
+	}
+
+	ipv4, ipv6, err = containerRuntime.GetContainerIPs(ctx, n.Name)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to get node IPs from runtime")
+	}
+	return ipv4, ipv6, nil
+}
+
 // IsRunning returns if the container is running.
 func (n *Node) IsRunning() bool {
 	return strings.HasPrefix(n.status, "Up")
@@ -120,8 +143,62 @@ func (n *Node) Delete(ctx context.Context) error {
 	return nil
 }
 
+// Stop stops the container, keeping it and its filesystem around. The container is killed if it
+// does not exit within the timeout.
+func (n *Node) Stop(ctx context.Context, timeout time.Duration) error {
+	containerRuntime, err := container.RuntimeFrom(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to container runtime")
+	}
+
+	if err := containerRuntime.StopContainer(ctx, n.Name, timeout); err != nil {
+		return errors.Wrapf(err, "failed to stop container %q", n.Name)
+	}
+	return nil
+}
+
+// Rename renames the container.
+func (n *Node) Rename(ctx context.Context, newName string) error {
+	containerRuntime, err := container.RuntimeFrom(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to container runtime")
+	}
+
+	if err := containerRuntime.RenameContainer(ctx, n.Name, newName); err != nil {
+		return errors.Wrapf(err, "failed to rename container %q to %q", n.Name, newName)
+	}
+	n.Name = newName
+	return nil
+}
+
+// ReadFile returns the content of a file inside a running container.
+func (n *Node) ReadFile(ctx context.Context, path string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := n.Commander.Command("cat", path)
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(&stderr)
+	if err := cmd.Run(ctx); err != nil {
+		return "", errors.Wrapf(err, "failed to read %s: %s", path, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// FileOptions are the permissions and ownership of a file written inside a container.
+type FileOptions struct {
+	// Mode is the permission bits of the file; zero keeps the mode given by the umask of the container.
+	Mode os.FileMode
+	// Owner is the owner of the file, as user or user:group; empty keeps the user the command runs as.
+	Owner string
+}
+
 // WriteFile puts a file inside a running container.
 func (n *Node) WriteFile(ctx context.Context, dest, content string) error {
+	return n.WriteFileWithOptions(ctx, dest, content, FileOptions{})
+}
+
+// WriteFileWithOptions puts a file inside a running container with the given permissions and ownership,
+// e.g. so that a process running as a non-root user can read it.
+func (n *Node) WriteFileWithOptions(ctx context.Context, dest, content string, opts FileOptions) error {
 	// create destination directory
 	cmd := n.Commander.Command("mkdir", "-p", filepath.Dir(dest))
 	if err := cmd.Run(ctx); err != nil {
@@ -130,7 +207,22 @@ func (n *Node) WriteFile(ctx context.Context, dest, content string) error {
 
 	command := n.Commander.Command("cp", "/dev/stdin", dest)
 	command.SetStdin(strings.NewReader(content))
-	return command.Run(ctx)
+	if err := command.Run(ctx); err != nil {
+		return err
+	}
+
+	if opts.Mode != 0 {
+		mode := fmt.Sprintf("%o", opts.Mode.Perm())
+		if err := n.Commander.Command("chmod", mode, dest).Run(ctx); err != nil {
+			return errors.Wrapf(err, "failed to set mode %s on %s", mode, dest)
+		}
+	}
+	if opts.Owner != "" {
+		if err := n.Commander.Command("chown", opts.Owner, dest).Run(ctx); err != nil {
+			return errors.Wrapf(err, "failed to set owner %s on %s", opts.Owner, dest)
+		}
+	}
+	return nil
 }
 
 // Kill sends the named signal to the container.
@@ -127,3 +127,46 @@ func TestWriteFile(t *testing.T) {
 	g.Expect(err).ShouldNot(HaveOccurred())
 	g.Expect(data).To(Equal("testcontent"))
 }
+
+func TestWriteFileWithOptions(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+
+	containerRuntime.ResetExecContainerCallLogs()
+
+	node := NewNode("TestContainer", "TestImage", "testing")
+	err := node.WriteFileWithOptions(ctx, "/tmp/test123", "testcontent", FileOptions{Mode: 0o640, Owner: "haproxy:haproxy"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	callLog := containerRuntime.ExecContainerCalls()
+	g.Expect(callLog).To(HaveLen(4))
+	g.Expect(callLog[1].Command).To(Equal("cp"))
+	g.Expect(callLog[2].Command).To(Equal("chmod"))
+	g.Expect(callLog[2].Args).To(Equal([]string{"640", "/tmp/test123"}))
+	g.Expect(callLog[3].Command).To(Equal("chown"))
+	g.Expect(callLog[3].Args).To(Equal([]string{"haproxy:haproxy", "/tmp/test123"}))
+}
+
+func TestReadFile(t *testing.T) {
+	g := NewWithT(t)
+	containerRuntime := &container.FakeRuntime{}
+	ctx := container.RuntimeInto(context.Background(), containerRuntime)
+
+	node := NewNode("TestNode", "TestImage", "TestRole")
+
+	containerRuntime.ResetExecContainerCallLogs()
+	containerRuntime.SetExecContainerOutputs(map[string]string{"cat": "content"})
+	defer containerRuntime.SetExecContainerOutputs(nil)
+
+	content, err := node.ReadFile(ctx, "/etc/test")
+
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(content).To(Equal("content"))
+
+	callLog := containerRuntime.ExecContainerCalls()
+	g.Expect(callLog).To(HaveLen(1))
+	g.Expect(callLog[0].ContainerName).To(Equal("TestNode"))
+	g.Expect(callLog[0].Command).To(Equal("cat"))
+	g.Expect(callLog[0].Args).To(Equal([]string{"/etc/test"}))
+}
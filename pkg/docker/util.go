@@ -22,20 +22,70 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
 
 	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
 	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/types"
 )
 
 const (
-	clusterLabelKey  = "io.x-k8s.kind.cluster"
-	nodeRoleLabelKey = "io.x-k8s.kind.role"
-	filterLabel      = "label"
-	filterName       = "name"
+	// ClusterLabelKey is the docker label holding the name of the cluster a container belongs to.
+	ClusterLabelKey = "io.x-k8s.kind.cluster"
+	// NodeRoleLabelKey is the docker label holding the role of a container in its cluster, e.g.
+	// LoadBalancerNodeRoleValue or ControlPlaneNodeRoleValue.
+	NodeRoleLabelKey = "io.x-k8s.kind.role"
+
+	// LoadBalancerNodeRoleValue is the NodeRoleLabelKey value of the load balancer containers.
+	LoadBalancerNodeRoleValue = constants.ExternalLoadBalancerNodeRoleValue
+	// ControlPlaneNodeRoleValue is the NodeRoleLabelKey value of the control plane containers.
+	ControlPlaneNodeRoleValue = constants.ControlPlaneNodeRoleValue
+
+	filterLabel = "label"
+	filterName  = "name"
 
 	failureDomainLabelKey = "io.x-k8s.cluster.failureDomain"
+
+	// ownerUIDLabelKey is the docker label holding the UID of the DockerCluster owning a load balancer
+	// container, so a DockerCluster recreated with the same name does not adopt a stale container.
+	ownerUIDLabelKey = "io.x-k8s.capd.owner-uid"
+
+	// LoadBalancerExcludeLabelKey is the docker label that, when set to "true" on a control plane
+	// container, keeps it out of the load balancer backends while the container keeps running.
+	LoadBalancerExcludeLabelKey = "io.x-k8s.capd.lb-exclude"
+
+	// LoadBalancerPoolLabelKey is the docker label listing, comma separated, the load balancer backend
+	// pools a control plane container belongs to, in addition to the default backend.
+	LoadBalancerPoolLabelKey = "io.x-k8s.capd.lb-pool"
 )
 
+// LoadBalancerLabels returns the docker labels identifying the load balancer containers of a cluster.
+// External tools can find the containers by filtering on all of them.
+func LoadBalancerLabels(clusterName string) map[string]string {
+	return map[string]string{
+		ClusterLabelKey:  clusterName,
+		NodeRoleLabelKey: LoadBalancerNodeRoleValue,
+	}
+}
+
+// ControlPlaneLabels returns the docker labels identifying the control plane containers of a cluster,
+// which the load balancer discovers as its backends unless they are labeled with LoadBalancerExcludeLabelKey.
+func ControlPlaneLabels(clusterName string) map[string]string {
+	return map[string]string{
+		ClusterLabelKey:  clusterName,
+		NodeRoleLabelKey: ControlPlaneNodeRoleValue,
+	}
+}
+
+// labelFilters returns the filters selecting the containers with all the given labels.
+func labelFilters(labels map[string]string) container.FilterBuilder {
+	filters := container.FilterBuilder{}
+	for key, value := range labels {
+		filters.AddKeyNameValue(filterLabel, key, value)
+	}
+	return filters
+}
+
 // FailureDomainLabel returns a map with the docker label for the given failure domain.
 func FailureDomainLabel(failureDomain *string) map[string]string {
 	if failureDomain != nil && *failureDomain != "" {
@@ -100,7 +150,9 @@ func list(ctx context.Context, visit func(context.Context, string, *types.Node),
 	}
 
 	// We also need our cluster label key to the list of filter
-	filters.AddKeyValue("label", clusterLabelKey)
+	filters.AddKeyValue("label", ClusterLabelKey)
+
+	ctrl.LoggerFrom(ctx).V(5).Info("Listing containers", "filters", filters.Args())
 
 	containers, err := containerRuntime.ListContainers(ctx, filters)
 	if err != nil {
@@ -109,11 +161,11 @@ func list(ctx context.Context, visit func(context.Context, string, *types.Node),
 
 	for _, cntr := range containers {
 		name := cntr.Name
-		cluster := clusterLabelKey
+		cluster := ClusterLabelKey
 		image := cntr.Image
 		status := cntr.Status
 
-		visit(ctx, cluster, types.NewNode(name, image, "undetermined").WithStatus(status))
+		visit(ctx, cluster, types.NewNode(name, image, "undetermined").WithStatus(status).WithLabels(cntr.Labels))
 	}
 
 	return nil
@@ -0,0 +1,46 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+)
+
+func TestContainerLabels(t *testing.T) {
+	g := NewWithT(t)
+
+	// The labels are a contract with external tools, so their values are spelled out.
+	g.Expect(LoadBalancerLabels("test-cluster")).To(Equal(map[string]string{
+		"io.x-k8s.kind.cluster": "test-cluster",
+		"io.x-k8s.kind.role":    "external-load-balancer",
+	}))
+	g.Expect(ControlPlaneLabels("test-cluster")).To(Equal(map[string]string{
+		"io.x-k8s.kind.cluster": "test-cluster",
+		"io.x-k8s.kind.role":    "control-plane",
+	}))
+
+	g.Expect(labelFilters(ControlPlaneLabels("test-cluster"))).To(Equal(container.FilterBuilder{
+		"label": {
+			"io.x-k8s.kind.cluster": {"test-cluster"},
+			"io.x-k8s.kind.role":    {"control-plane"},
+		},
+	}))
+}
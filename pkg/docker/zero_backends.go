@@ -0,0 +1,100 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"sync"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+// DefaultZeroBackendConfirmations is how many consecutive discoveries must find no backend
+// before the backends are removed from the load balancer configuration by default.
+const DefaultZeroBackendConfirmations = 2
+
+// zeroBackendCounts counts the consecutive discoveries that found no backend for each cluster.
+// A LoadBalancer helper is created on every reconcile, so the counts outlive it.
+type zeroBackendCounts struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+// defaultZeroBackendCounts are the counts shared by all the load balancers in the process.
+var defaultZeroBackendCounts = newZeroBackendCounts()
+
+func newZeroBackendCounts() *zeroBackendCounts {
+	return &zeroBackendCounts{
+		counts: map[string]int{},
+	}
+}
+
+// observe records a discovery that found no backend for the cluster and returns the number of
+// consecutive ones.
+func (c *zeroBackendCounts) observe(cluster string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.counts[cluster]++
+	return c.counts[cluster]
+}
+
+// reset forgets the discoveries that found no backend for the cluster.
+func (c *zeroBackendCounts) reset(cluster string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.counts, cluster)
+}
+
+// confirmZeroBackends returns a ZeroBackendsUnconfirmedError if the configuration would remove all the
+// backends written in the container before zeroBackendConfirmations consecutive discoveries found none,
+// so the last configuration is kept on a discovery glitch.
+func (s *LoadBalancer) confirmZeroBackends(ctx context.Context, configData *loadbalancer.ConfigData) error {
+	if len(configData.BackendServers) > 0 || configData.Hibernated || s.zeroBackendConfirmations <= 1 {
+		defaultZeroBackendCounts.reset(s.name)
+		return nil
+	}
+
+	// There is nothing to preserve if the configuration in the container has no backends.
+	current, err := s.container.ReadFile(ctx, loadbalancer.ConfigPath)
+	if err != nil {
+		return nil
+	}
+	parsed, err := loadbalancer.ParseConfig([]byte(current))
+	if err != nil {
+		return nil
+	}
+	if backend := parsed.Backend(loadbalancer.BackendName); backend == nil || len(backend.Servers) == 0 {
+		return nil
+	}
+
+	observed := defaultZeroBackendCounts.observe(s.name)
+	if observed < s.zeroBackendConfirmations {
+		ctrl.LoggerFrom(ctx).Info("Discovered no control plane nodes, keeping the load balancer backends until confirmed",
+			"observed", observed, "required", s.zeroBackendConfirmations)
+		// The next update must discover the backends again to confirm them.
+		if s.backendCache != nil {
+			s.backendCache.invalidate(s.name)
+		}
+		return ZeroBackendsUnconfirmedError{Name: s.name, Observed: observed, Required: s.zeroBackendConfirmations}
+	}
+	defaultZeroBackendCounts.reset(s.name)
+	return nil
+}
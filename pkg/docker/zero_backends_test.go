@@ -0,0 +1,122 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/container"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/docker/fake"
+	"github.com/beanlearninggo/cluster-api-provider-docker/pkg/loadbalancer"
+)
+
+func TestLoadBalancerZeroBackendConfirmations(t *testing.T) {
+	tests := []struct {
+		name          string
+		confirmations int
+		// discoveries lists the number of control plane nodes found by each update after the first one.
+		discoveries []int
+		wantErrs    []bool
+		wantServers int
+	}{
+		{
+			name:          "removes the backends after two empty discoveries",
+			confirmations: DefaultZeroBackendConfirmations,
+			discoveries:   []int{0, 0},
+			wantErrs:      []bool{true, false},
+			wantServers:   0,
+		},
+		{
+			name:          "keeps the backends after a single empty discovery",
+			confirmations: DefaultZeroBackendConfirmations,
+			discoveries:   []int{0, 1, 0},
+			wantErrs:      []bool{true, false, true},
+			wantServers:   1,
+		},
+		{
+			name:          "removes the backends immediately with one confirmation",
+			confirmations: 1,
+			discoveries:   []int{0},
+			wantErrs:      []bool{false},
+			wantServers:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			defer defaultZeroBackendCounts.reset("test-cluster")
+			lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+			ctx := container.RuntimeInto(context.Background(), lbNode)
+			lbNode.SetListContainersResult([]container.Container{{Name: "test-cluster-cp-1"}})
+			defer lbNode.SetListContainersResult(nil)
+
+			lb := &LoadBalancer{
+				name:                     "test-cluster",
+				config:                   getLoadBalancerConfigData(nil),
+				container:                lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+				zeroBackendConfirmations: tt.confirmations,
+			}
+			_, err := lb.UpdateConfiguration(ctx)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			for i, discovered := range tt.discoveries {
+				var nodes []container.Container
+				if discovered > 0 {
+					nodes = []container.Container{{Name: "test-cluster-cp-1"}}
+				}
+				lbNode.SetListContainersResult(nodes)
+
+				_, err := lb.UpdateConfiguration(ctx)
+				if tt.wantErrs[i] {
+					g.Expect(err).To(BeAssignableToTypeOf(ZeroBackendsUnconfirmedError{}))
+				} else {
+					g.Expect(err).ToNot(HaveOccurred())
+				}
+			}
+
+			config, ok := lbNode.File(loadbalancer.ConfigPath)
+			g.Expect(ok).To(BeTrue())
+			parsed, err := loadbalancer.ParseConfig([]byte(config))
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(parsed.Backend(loadbalancer.BackendName).Servers).To(HaveLen(tt.wantServers))
+		})
+	}
+}
+
+func TestLoadBalancerZeroBackendsWithoutBackendsWritten(t *testing.T) {
+	g := NewWithT(t)
+	defer defaultZeroBackendCounts.reset("test-cluster")
+	lbNode := fake.NewNode("test-cluster-lb", "172.18.0.2")
+	ctx := container.RuntimeInto(context.Background(), lbNode)
+
+	// A new load balancer has no backends to preserve.
+	lb := &LoadBalancer{
+		name:                     "test-cluster",
+		config:                   getLoadBalancerConfigData(nil),
+		container:                lbNode.Node(constants.ExternalLoadBalancerNodeRoleValue),
+		zeroBackendConfirmations: DefaultZeroBackendConfirmations,
+	}
+	result, err := lb.UpdateConfiguration(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.BackendCount).To(BeZero())
+	_, ok := lbNode.File(loadbalancer.ConfigPath)
+	g.Expect(ok).To(BeTrue())
+}
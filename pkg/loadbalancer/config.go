@@ -3,50 +3,412 @@ package loadbalancer
 import (
 	"bytes"
 	"html/template"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 type ConfigData struct {
 	ControlPlanePort int
-	BackendServers   map[string]string
-	EnableStats      bool
+	// FrontendPort is the port the control plane frontend binds to inside the container.
+	// Defaults to ControlPlanePort when unset.
+	FrontendPort int
+	// FrontendMaxConn is the maximum number of concurrent connections of the control plane frontend,
+	// e.g. to keep some of MaxConn for the stats frontend; unset leaves the HAProxy default.
+	// It cannot be greater than MaxConn when both are set.
+	FrontendMaxConn int
+	BackendServers  map[string]string
+	// DisabledServers contains the names of the backend servers to put in maintenance;
+	// they stay in the configuration but do not receive any traffic.
+	DisabledServers map[string]bool
+	EnableStats     bool
+	// Nbproc is the number of HAProxy processes to start; unset leaves the HAProxy default.
+	Nbproc int
+	// Nbthread is the number of threads per HAProxy process; unset leaves the HAProxy default.
+	Nbthread int
+	// MaxConn is the maximum number of concurrent connections of each HAProxy process (global maxconn);
+	// unset leaves the HAProxy default, derived from the file descriptor limit of the container.
+	MaxConn int
+	// HardStopAfter is how long the processes replaced by a reload keep serving their connections before
+	// they are stopped (hard-stop-after); unset lets them run until their last connection closes.
+	HardStopAfter time.Duration
+	// RunAsUser is the user, as a name or a numeric ID, HAProxy drops its privileges to; unset keeps the
+	// user of the image.
+	RunAsUser string
+	// RunAsGroup is the group, as a name or a numeric ID, HAProxy drops its privileges to; unset keeps the
+	// group of the image.
+	RunAsGroup string
+	// MetricsPort is the port of the Prometheus exporter frontend; unset disables the exporter.
+	MetricsPort int
+	// MetricsAuth are the credentials the Prometheus exporter frontend requires; nil does not require any.
+	// They are never serialized, so they are left out of the exported configuration data.
+	MetricsAuth *MetricsAuth `json:"-"`
+	// MetricsAllowedSources are the addresses or CIDRs allowed to connect to the Prometheus exporter frontend.
+	// When neither MetricsAuth nor MetricsAllowedSources is set, only localhost is allowed.
+	MetricsAllowedSources []string
+	// CheckPort is the port the backend health checks connect to; unset checks the traffic port, or
+	// ControlPlanePort with PreserveDestinationPort.
+	CheckPort int
+	// PreserveDestinationPort renders the backend servers without a port, so HAProxy connects to each of
+	// them on the port the client connected to, e.g. for backends listening on the BackendPools ports.
+	// Servers without a port cannot be health checked on their traffic port, so the checks connect to
+	// CheckPort. The load balancer container only receives the connections of the ports it publishes.
+	// Defaults to false, connecting to the port of each server address.
+	PreserveDestinationPort bool
+	// HealthCheck is how the backend servers are health checked, one of HealthCheckTCP, HealthCheckSSL,
+	// HealthCheckHTTPHealthz or HealthCheckHTTPReadyz. The checks other than HealthCheckTCP use TLS,
+	// verified against BackendCAFile. Defaults to HealthCheckTCP when unset.
+	HealthCheck string
+	// Retries is the number of times a failed connection to a backend server is retried; nil leaves
+	// the HAProxy default.
+	Retries *int
+	// Redispatch retries the last connection attempt on another backend server, e.g. one that is not draining.
+	Redispatch bool
+	// TCPSmartConnect delays the connection to a backend server until the client sent its first data
+	// (option tcp-smart-connect), saving a packet on each of the short-lived control plane connections.
+	TCPSmartConnect bool
+	// HTTPReuse is how ModeHTTP shares the idle connections to the backend servers between requests
+	// (http-reuse), one of HTTPReuseNever, HTTPReuseSafe, HTTPReuseAggressive or HTTPReuseAlways; unset
+	// leaves the HAProxy default. It only applies in ModeHTTP, tcp mode does not see the requests.
+	HTTPReuse string
+	// LogFormat is the format of the connection logs (log-format), e.g. JSONLogFormat; unset logs in the
+	// standard HAProxy format of the mode. It must be on a single line.
+	LogFormat string
+	// Hibernated makes the control plane frontend refuse connections instead of routing them, for clusters
+	// whose control plane is intentionally scaled to zero: it answers 503 in http mode and closes connections in tcp mode.
+	Hibernated bool
+	// IPFamily is the IP family the control plane frontends accept connections on, IPFamilyIPv4,
+	// IPFamilyIPv6 or IPFamilyDualStack. Defaults to IPFamilyIPv4 when unset.
+	IPFamily string
+	// Mode is the proxy mode, ModeTCP or ModeHTTP. Defaults to ModeTCP when unset.
+	// In ModeHTTP the frontend terminates TLS with the certificates in TLSCertificatePath.
+	Mode string
+	// BackendPools are additional backends receiving the control plane traffic selected by their ACLs;
+	// any other traffic goes to BackendServers.
+	BackendPools []BackendPool
+	// SNIRoutes route the TLS connections by the server name the client requests. When set the
+	// frontend requires TLS: plaintext connections and connections for a server name without a route,
+	// including those routed by BackendPools ports only, are rejected.
+	SNIRoutes []SNIRoute
+	// Peers are the load balancers, including this one, synchronizing their stick tables;
+	// the peers section is only rendered when there are multiple load balancers.
+	Peers []PeerEntry
+	// Resolvers re-resolves backend server addresses given as host names at runtime, so backends
+	// survive IP changes without a reload; nil uses the addresses as resolved when the configuration loads.
+	Resolvers *Resolvers
+	// InitAddr is the comma separated list of methods (last, libc, none or an IP address) HAProxy tries
+	// in order to resolve the backend servers when it starts; HAProxy refuses to start if it cannot resolve
+	// a server and none is not listed. Unset renders InitAddrNone when Resolvers is set, and leaves the
+	// HAProxy default (last,libc) otherwise.
+	InitAddr string
+	// BackendSource is the local address HAProxy connects to the backend servers from, e.g. on a
+	// multi-homed host whose other addresses the API servers reject; unset lets the kernel pick it.
+	BackendSource string
+	// ShutdownSessionsOnDown closes the sessions of a backend server as soon as its health checks mark
+	// it down (on-marked-down shutdown-sessions), instead of letting them run on the failing server.
+	ShutdownSessionsOnDown bool
+	// BackendCAFile is the path, in the load balancer container, of the CA bundle the backend server
+	// certificates are verified against; unset accepts any certificate (verify none).
+	BackendCAFile string
+	// BaseConfig are overrides layered over the built-in defaults of the configuration, e.g. the timeouts
+	// a platform standardizes on. They do not override the directives rendered from the other fields.
+	// The precedence is: built-in defaults < BaseConfig < the other fields < DefaultsOverride < ConfigPatch.
+	BaseConfig ConfigOverrides
+	// DefaultsOverride sets directives of the defaults section, see DefaultsOverride for the keys it accepts.
+	DefaultsOverride map[string]string
+	// ConfigPatch are overrides layered over the whole configuration, e.g. the tweaks of a single cluster.
+	// Only the directives the provider relies on, the pidfile and the stats socket, cannot be patched.
+	ConfigPatch ConfigOverrides
+}
+
+// MetricsAuth are the basic auth credentials of the Prometheus exporter frontend.
+type MetricsAuth struct {
+	// Username is the name of the user allowed to scrape the metrics.
+	Username string
+	// Password is the password of the user, rendered as an insecure-password of the userlist.
+	Password string
+}
+
+// Resolvers is a resolvers section of the configuration.
+type Resolvers struct {
+	// Name is the name of the section servers refer to.
+	Name string
+	// Nameservers are the addresses (host:port) of the DNS servers to query.
+	Nameservers []string
+}
+
+// PeerEntry is a load balancer in the peers section. Its name must match the hostname of the
+// load balancer container, so HAProxy can recognize the local peer.
+type PeerEntry struct {
+	// Name is the name of the peer.
+	Name string
+	// Address is the address (host:port) the peer listens on for peer connections.
+	Address string
+}
+
+// BackendPool is an additional named backend for a subset of the control plane nodes.
+type BackendPool struct {
+	// Name is the name of the backend; it must not collide with BackendName.
+	Name string
+	// Port routes the connections to this port of the load balancer to the pool; unset disables port routing.
+	Port int
+	// SNI routes the TLS connections for this server name to the pool; unset disables SNI routing.
+	SNI string
+	// Protocol is the transport protocol of Port, ProtocolTCP when unset. Only ProtocolTCP is supported.
+	Protocol string
+	// Servers maps the names of the pool servers to their addresses.
+	Servers map[string]string
 }
 
 const configTemplate = `# Created for kubecon
 global
   stats socket /var/run/api.sock user haproxy group haproxy mode 660 level admin expose-fd listeners
   log stdout format raw local0 info
+  pidfile ` + PIDFilePath + `
+{{- if .Nbproc }}
+  nbproc {{ .Nbproc }}
+{{- end }}
+{{- if .Nbthread }}
+  nbthread {{ .Nbthread }}
+{{- end }}
+{{- if .MaxConn }}
+  maxconn {{ .MaxConn }}
+{{- end }}
+{{- if .HardStopAfter }}
+  hard-stop-after {{ duration .HardStopAfter }}
+{{- end }}
+{{- if .RunAsUser }}
+  user {{ .RunAsUser }}
+{{- end }}
+{{- if .RunAsGroup }}
+  group {{ .RunAsGroup }}
+{{- end }}
+
+{{ if .Peers -}}
+peers ` + PeersName + `
+  {{- range .Peers }}
+  peer {{ .Name }} {{ .Address }}
+  {{- end }}
+
+{{ end -}}
+{{ with .Resolvers -}}
+resolvers {{ .Name }}
+  {{- range $i, $nameserver := .Nameservers }}
+  nameserver ns{{ $i }} {{ $nameserver }}
+  {{- end }}
+  hold valid 10s
 
+{{ end -}}
 defaults
-  mode tcp
+  mode {{ .Mode }}
+{{- if eq .Mode "http" }}
+  option httplog
+{{- end }}
+{{- if .Retries }}
+  retries {{ .Retries }}
+{{- end }}
+{{- if .Redispatch }}
+  option redispatch
+{{- end }}
+{{- if .TCPSmartConnect }}
+  option tcp-smart-connect
+{{- end }}
+{{- with .HTTPReuse }}
+  http-reuse {{ . }}
+{{- end }}
   timeout client 10s
   timeout connect 5s
   timeout server 10s
   timeout http-request 10s
   log global
+{{- with .LogFormat }}
+  log-format {{ logFormat . }}
+{{- end }}
 
 {{ if .EnableStats -}}
 frontend stats
-  bind *:8404
+  bind *:{{ statsPort }}
   stats enable
   stats uri /
   stats refresh 10s
 {{- end }}
 
+{{ if .MetricsPort -}}
+{{ with .MetricsAuth -}}
+userlist ` + MetricsUserlistName + `
+  user {{ .Username }} insecure-password {{ .Password }}
+
+{{ end -}}
+frontend prometheus
+  mode http
+  bind *:{{ .MetricsPort }}
+  {{- with .MetricsAllowedSources }}
+  acl metrics_sources src{{ range . }} {{ . }}{{ end }}
+  http-request deny unless metrics_sources
+  {{- end }}
+  {{- if .MetricsAuth }}
+  http-request auth realm metrics unless { http_auth(` + MetricsUserlistName + `) }
+  {{- end }}
+  http-request use-service prometheus-exporter if { path /metrics }
+  no log
+{{- end }}
+
 frontend control-plane
-  bind *:{{ .ControlPlanePort }}
+  bind {{ bind .IPFamily .FrontendPort }}{{ if eq .Mode "http" }} ssl crt ` + TLSCertificatePath + `{{ end }}
+  {{- if .FrontendMaxConn }}
+  maxconn {{ .FrontendMaxConn }}
+  {{- end }}
+  {{- if .Hibernated }}
+  {{ if eq .Mode "http" }}` + HibernatedHTTPDirective + `{{ else }}` + HibernatedTCPDirective + `{{ end }}
+  {{- end }}
+  {{- $sni := false }}
+  {{- range .BackendPools }}
+  {{- if .Port }}
+  bind {{ bind $.IPFamily .Port }}{{ if eq $.Mode "http" }} ssl crt ` + TLSCertificatePath + `{{ end }}
+  {{- end }}
+  {{- if .SNI }}{{ $sni = true }}{{ end }}
+  {{- end }}
+  {{- $fetch := "req_ssl_sni" }}{{ if eq .Mode "http" }}{{ $fetch = "ssl_fc_sni" }}{{ end }}
+  {{- if and (or $sni .SNIRoutes) (ne .Mode "http") }}
+  tcp-request inspect-delay 5s
+  {{- if .SNIRoutes }}
+  tcp-request content reject unless { req_ssl_hello_type 1 }
+  {{- else }}
+  tcp-request content accept if { req_ssl_hello_type 1 }
+  {{- end }}
+  {{- end }}
+  {{- if .SNIRoutes }}
+  {{ if eq .Mode "http" }}http-request deny{{ else }}tcp-request content reject{{ end }} unless { {{ $fetch }} -i {{ range .SNIRoutes }}{{ .ServerName }} {{ end }}{{ range .BackendPools }}{{ with .SNI }}{{ . }} {{ end }}{{ end }}}
+  {{- end }}
+  {{- range .BackendPools }}
+  {{- if .Port }}
+  use_backend {{ .Name }} if { dst_port {{ .Port }} }
+  {{- end }}
+  {{- if .SNI }}
+  use_backend {{ .Name }} if { {{ $fetch }} -i {{ .SNI }} }
+  {{- end }}
+  {{- end }}
+  {{- range .SNIRoutes }}
+  use_backend {{ .Backend }} if { {{ $fetch }} -i {{ .ServerName }} }
+  {{- end }}
   default_backend kube-apiservers
 
 backend kube-apiservers
-  option httpchk GET /healthz
+  {{- template "http-check" . }}
   {{- range $server, $address := .BackendServers}}
-  server {{ $server }} {{ $address }} check check-ssl verify none
+  server {{ $server }} {{ serverAddress $.PreserveDestinationPort $address }} check{{ template "server-tls" $ }}{{ if $.CheckPort }} port {{ $.CheckPort }}{{ end }}{{ with $.Resolvers }} resolvers {{ .Name }}{{ end }}{{ with $.InitAddr }} init-addr {{ . }}{{ end }}{{ with $.BackendSource }} source {{ . }}{{ end }}{{ if $.ShutdownSessionsOnDown }} on-marked-down shutdown-sessions{{ end }}{{ if index $.DisabledServers $server }} disabled{{ end }}
   {{- end}}
+{{- range $pool := .BackendPools }}
+
+backend {{ $pool.Name }}
+  {{- template "http-check" $ }}
+  {{- range $server, $address := $pool.Servers }}
+  server {{ $server }} {{ serverAddress $.PreserveDestinationPort $address }} check{{ template "server-tls" $ }}{{ if $.CheckPort }} port {{ $.CheckPort }}{{ end }}{{ with $.Resolvers }} resolvers {{ .Name }}{{ end }}{{ with $.InitAddr }} init-addr {{ . }}{{ end }}{{ with $.BackendSource }} source {{ . }}{{ end }}{{ if $.ShutdownSessionsOnDown }} on-marked-down shutdown-sessions{{ end }}{{ if index $.DisabledServers $server }} disabled{{ end }}
+  {{- end }}
+{{- end }}
+{{- define "http-check" }}
+  {{- if eq .HealthCheck "` + HealthCheckHTTPReadyz + `" }}
+  option httpchk GET /readyz
+  http-check expect status 200
+  {{- else if eq .HealthCheck "` + HealthCheckHTTPHealthz + `" }}
+  option httpchk GET /healthz
+  {{- end }}
+{{- end }}
+{{- define "server-tls" }}
+  {{- /* Traffic is TLS in http mode; in tcp mode only the checks other than tcp are. */}}
+  {{- $tls := or (eq .Mode "http") (ne .HealthCheck "` + HealthCheckTCP + `") }}
+  {{- if eq .Mode "http" }} ssl{{ else if $tls }} check-ssl{{ end }}
+  {{- if $tls }} verify {{ with .BackendCAFile }}required ca-file {{ . }}{{ else }}none{{ end }}{{ end }}
+{{- end }}
 `
 
+// GenerateConfig returns the HAProxy configuration the provider writes into the load balancer
+// container for the given data. It has no side effects and does not need a container runtime,
+// so it can be used to assert the configuration produced for a set of backends.
+func GenerateConfig(data *ConfigData) (string, error) {
+	return Config(data)
+}
+
 func Config(data *ConfigData) (config string, err error) {
-	t, err := template.New("loadbalancer-config").Parse(configTemplate)
+	localMetrics := data.MetricsPort != 0 && data.MetricsAuth == nil && len(data.MetricsAllowedSources) == 0
+	defaultCheckPort := data.PreserveDestinationPort && data.CheckPort == 0
+	if data.FrontendPort == 0 || data.Mode == "" || data.HealthCheck == "" || (data.InitAddr == "" && data.Resolvers != nil) || localMetrics || defaultCheckPort {
+		defaulted := *data
+		if defaulted.FrontendPort == 0 {
+			defaulted.FrontendPort = defaulted.ControlPlanePort
+		}
+		if defaulted.Mode == "" {
+			defaulted.Mode = ModeTCP
+		}
+		if defaulted.HealthCheck == "" {
+			defaulted.HealthCheck = HealthCheckTCP
+		}
+		if defaulted.InitAddr == "" && defaulted.Resolvers != nil {
+			defaulted.InitAddr = InitAddrNone
+		}
+		if localMetrics {
+			defaulted.MetricsAllowedSources = localhostSources
+		}
+		if defaultCheckPort {
+			defaulted.CheckPort = defaulted.ControlPlanePort
+		}
+		data = &defaulted
+	}
+	if data.MetricsAuth != nil {
+		if err := validateMetricsCredential("username", data.MetricsAuth.Username); err != nil {
+			return "", err
+		}
+		if err := validateMetricsCredential("password", data.MetricsAuth.Password); err != nil {
+			return "", err
+		}
+	}
+	if strings.ContainsAny(data.LogFormat, "\r\n") {
+		return "", errors.New("log format must be on a single line")
+	}
+	switch data.IPFamily {
+	case "", IPFamilyIPv4, IPFamilyIPv6, IPFamilyDualStack:
+	default:
+		return "", errors.Errorf("IP family %s is not supported, must be one of %s, %s or %s",
+			data.IPFamily, IPFamilyIPv4, IPFamilyIPv6, IPFamilyDualStack)
+	}
+	switch data.HTTPReuse {
+	case "":
+	case HTTPReuseNever, HTTPReuseSafe, HTTPReuseAggressive, HTTPReuseAlways:
+		if data.Mode != ModeHTTP {
+			return "", errors.Errorf("http-reuse requires the %s mode, the %s mode does not see the requests", ModeHTTP, data.Mode)
+		}
+	default:
+		return "", errors.Errorf("http-reuse %s is not supported, must be one of %s, %s, %s or %s",
+			data.HTTPReuse, HTTPReuseNever, HTTPReuseSafe, HTTPReuseAggressive, HTTPReuseAlways)
+	}
+	if data.MaxConn != 0 && data.FrontendMaxConn > data.MaxConn {
+		return "", errors.Errorf("frontend maxconn %d must not be greater than the global maxconn %d",
+			data.FrontendMaxConn, data.MaxConn)
+	}
+	backends := map[string]bool{BackendName: true}
+	for _, pool := range data.BackendPools {
+		if pool.Protocol != "" && pool.Protocol != ProtocolTCP {
+			return "", errors.Errorf("backend pool %s: protocol %s is not supported, HAProxy only proxies %s",
+				pool.Name, pool.Protocol, ProtocolTCP)
+		}
+		backends[pool.Name] = true
+	}
+	for _, route := range data.SNIRoutes {
+		if !backends[route.Backend] {
+			return "", errors.Errorf("SNI route %s: backend %s does not exist", route.ServerName, route.Backend)
+		}
+	}
+
+	t, err := template.New("loadbalancer-config").Funcs(template.FuncMap{
+		"duration":      haproxyDuration,
+		"bind":          bindAddress,
+		"logFormat":     quoteLogFormat,
+		"serverAddress": serverAddress,
+		"statsPort":     func() int { return StatsPort },
+	}).Parse(configTemplate)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to parse config template")
 	}
@@ -56,5 +418,71 @@ func Config(data *ConfigData) (config string, err error) {
 	if err != nil {
 		return "", errors.Wrap(err, "error executing config template")
 	}
+	if len(data.BaseConfig) > 0 || len(data.DefaultsOverride) > 0 || len(data.ConfigPatch) > 0 {
+		return layerConfig(buff.String(), data)
+	}
 	return buff.String(), nil
 }
+
+// localhostSources are the sources allowed to connect to the Prometheus exporter frontend by default.
+var localhostSources = []string{"127.0.0.1", "::1"}
+
+// validateMetricsCredential checks that a metrics credential renders as a single token of the userlist.
+func validateMetricsCredential(name, value string) error {
+	if value == "" {
+		return errors.Errorf("metrics %s must not be empty", name)
+	}
+	if strings.ContainsAny(value, " \t\r\n#\"'\\&<>") {
+		return errors.Errorf("metrics %s must not contain whitespace, quotes, backslashes or any of #&<>", name)
+	}
+	return nil
+}
+
+// bindAddress returns the address of a bind line listening on port for the IP family. IPv6 addresses are
+// bound with v6only or v4v6, so the family does not depend on the net.ipv6.bindv6only sysctl of the container.
+func bindAddress(ipFamily string, port int) string {
+	switch ipFamily {
+	case IPFamilyIPv6:
+		return ":::" + strconv.Itoa(port) + " v6only"
+	case IPFamilyDualStack:
+		return ":::" + strconv.Itoa(port) + " v4v6"
+	default:
+		return "*:" + strconv.Itoa(port)
+	}
+}
+
+// serverAddress returns the address of a server line, without its port if the destination port is preserved.
+func serverAddress(preserveDestinationPort bool, address string) string {
+	if !preserveDestinationPort {
+		return address
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	// A trailing group of an IPv6 address without brackets would be read as the port.
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// quoteLogFormat returns a log format as a double quoted argument, escaping its backslashes and quotes.
+// It is not HTML escaped, as the JSON quotes must be rendered as is.
+func quoteLogFormat(format string) template.HTML {
+	return template.HTML(`"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(format) + `"`) //nolint:gosec // The configuration is not HTML.
+}
+
+// haproxyDuration formats a duration in the largest HAProxy time unit it is a whole number of, as HAProxy
+// does not accept the compound durations of time.Duration.String (e.g. 1m30s).
+func haproxyDuration(d time.Duration) string {
+	for _, unit := range []struct {
+		suffix   string
+		duration time.Duration
+	}{{"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}, {"ms", time.Millisecond}} {
+		if d%unit.duration == 0 {
+			return strconv.FormatInt(int64(d/unit.duration), 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(d.Microseconds(), 10) + "us"
+}
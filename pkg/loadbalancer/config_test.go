@@ -0,0 +1,780 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func parseRendered(g *WithT, data *ConfigData) *ParsedConfig {
+	config, err := Config(data)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	parsed, err := ParseConfig([]byte(config))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	return parsed
+}
+
+func TestConfigFrontendPort(t *testing.T) {
+	t.Run("defaults to the control plane port", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443})
+		g.Expect(parsed.Frontend("control-plane").Binds).To(Equal([]string{"*:6443"}))
+	})
+
+	t.Run("binds to the frontend port when set", func(t *testing.T) {
+		g := NewWithT(t)
+		data := &ConfigData{ControlPlanePort: 6443, FrontendPort: 7443}
+		parsed := parseRendered(g, data)
+		g.Expect(parsed.Frontend("control-plane").Binds).To(Equal([]string{"*:7443"}))
+	})
+
+	t.Run("does not modify the input", func(t *testing.T) {
+		g := NewWithT(t)
+		data := &ConfigData{ControlPlanePort: 6443}
+		_ = parseRendered(g, data)
+		g.Expect(data.FrontendPort).To(BeZero())
+	})
+}
+
+func TestConfigProcessesAndThreads(t *testing.T) {
+	t.Run("renders no directives by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443})
+		g.Expect(parsed.Global).ToNot(ContainElement(HavePrefix("nbproc")))
+		g.Expect(parsed.Global).ToNot(ContainElement(HavePrefix("nbthread")))
+	})
+
+	t.Run("renders nbproc and nbthread when set", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, Nbproc: 1, Nbthread: 4})
+		g.Expect(parsed.Global).To(ContainElements("nbproc 1", "nbthread 4"))
+	})
+}
+
+func TestConfigHardStopAfter(t *testing.T) {
+	t.Run("renders no directive by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443})
+		g.Expect(parsed.Global).ToNot(ContainElement(HavePrefix("hard-stop-after")))
+	})
+
+	t.Run("renders hard-stop-after in HAProxy time units", func(t *testing.T) {
+		for duration, want := range map[time.Duration]string{
+			30 * time.Second:        "hard-stop-after 30s",
+			90 * time.Second:        "hard-stop-after 90s",
+			2 * time.Minute:         "hard-stop-after 2m",
+			time.Hour:               "hard-stop-after 1h",
+			1500 * time.Millisecond: "hard-stop-after 1500ms",
+			1500 * time.Microsecond: "hard-stop-after 1500us",
+		} {
+			g := NewWithT(t)
+			parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, HardStopAfter: duration})
+			g.Expect(parsed.Global).To(ContainElement(want))
+		}
+	})
+}
+
+func TestConfigMaxConn(t *testing.T) {
+	t.Run("leaves the HAProxy defaults by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443})
+		g.Expect(parsed.Global).ToNot(ContainElement(HavePrefix("maxconn")))
+		g.Expect(parsed.Frontend("control-plane").Directives).ToNot(ContainElement(HavePrefix("maxconn")))
+	})
+
+	t.Run("renders the global and frontend maxconn", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, MaxConn: 4096, FrontendMaxConn: 4000})
+		g.Expect(parsed.Global).To(ContainElement("maxconn 4096"))
+		g.Expect(parsed.Frontend("control-plane").Directives).To(ContainElement("maxconn 4000"))
+	})
+
+	t.Run("renders the frontend maxconn without a global one", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, FrontendMaxConn: 1000})
+		g.Expect(parsed.Frontend("control-plane").Directives).To(ContainElement("maxconn 1000"))
+	})
+
+	t.Run("rejects a frontend maxconn greater than the global one", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := Config(&ConfigData{ControlPlanePort: 6443, MaxConn: 1000, FrontendMaxConn: 2000})
+		g.Expect(err).To(MatchError(ContainSubstring("must not be greater than the global maxconn 1000")))
+	})
+}
+
+func TestConfigLogFormat(t *testing.T) {
+	t.Run("logs in the standard format by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443})
+		g.Expect(parsed.Defaults).ToNot(ContainElement(HavePrefix("log-format")))
+	})
+
+	t.Run("renders the JSON format quoted", func(t *testing.T) {
+		g := NewWithT(t)
+		config, err := Config(&ConfigData{ControlPlanePort: 6443, LogFormat: JSONLogFormat})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(config).To(ContainSubstring(`  log-format "{\"time\":\"%t\",\"client\":\"%ci:%cp\",`))
+		g.Expect(config).ToNot(ContainSubstring("&#34;"))
+	})
+
+	t.Run("escapes the backslashes of a custom format", func(t *testing.T) {
+		g := NewWithT(t)
+		config, err := Config(&ConfigData{ControlPlanePort: 6443, LogFormat: `%ci\t%b <%s>`})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(config).To(ContainSubstring(`  log-format "%ci\\t%b <%s>"`))
+	})
+
+	t.Run("rejects a format on several lines", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := Config(&ConfigData{ControlPlanePort: 6443, LogFormat: "%ci\nbackend evil"})
+		g.Expect(err).To(MatchError(ContainSubstring("log format must be on a single line")))
+	})
+}
+
+func TestConfigRunAs(t *testing.T) {
+	t.Run("keeps the user of the image by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443})
+		g.Expect(parsed.Global).ToNot(ContainElement(HavePrefix("user ")))
+		g.Expect(parsed.Global).ToNot(ContainElement(HavePrefix("group ")))
+	})
+
+	t.Run("renders user and group when set", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, RunAsUser: "haproxy", RunAsGroup: "99"})
+		g.Expect(parsed.Global).To(ContainElements("user haproxy", "group 99"))
+	})
+}
+
+func TestConfigMetrics(t *testing.T) {
+	t.Run("renders no exporter by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443})
+		g.Expect(parsed.Frontend("prometheus")).To(BeNil())
+	})
+
+	t.Run("renders the exporter frontend when enabled", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, MetricsPort: 8405})
+		frontend := parsed.Frontend("prometheus")
+		g.Expect(frontend).ToNot(BeNil())
+		g.Expect(frontend.Binds).To(Equal([]string{"*:8405"}))
+		g.Expect(frontend.Directives).To(ContainElements(
+			"mode http",
+			"http-request use-service prometheus-exporter if { path "+MetricsPath+" }",
+		))
+	})
+
+	t.Run("only allows localhost without auth or sources", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, MetricsPort: 8405})
+		g.Expect(parsed.Frontend("prometheus").Directives).To(ContainElements(
+			"acl metrics_sources src 127.0.0.1 ::1",
+			"http-request deny unless metrics_sources",
+		))
+		g.Expect(parsed.Sections).To(BeEmpty())
+	})
+
+	t.Run("requires the credentials and allowed sources", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort:      6443,
+			MetricsPort:           8405,
+			MetricsAuth:           &MetricsAuth{Username: "prometheus", Password: "s3cret"},
+			MetricsAllowedSources: []string{"172.18.0.0/16"},
+		})
+		g.Expect(parsed.Sections).To(Equal([]ParsedSection{{
+			Kind:       "userlist",
+			Name:       MetricsUserlistName,
+			Directives: []string{"user prometheus insecure-password s3cret"},
+		}}))
+		g.Expect(parsed.Frontend("prometheus").Directives).To(Equal([]string{
+			"mode http",
+			"acl metrics_sources src 172.18.0.0/16",
+			"http-request deny unless metrics_sources",
+			"http-request auth realm metrics unless { http_auth(" + MetricsUserlistName + ") }",
+			"http-request use-service prometheus-exporter if { path " + MetricsPath + " }",
+			"no log",
+		}))
+	})
+
+	t.Run("allows any source with auth only", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort: 6443,
+			MetricsPort:      8405,
+			MetricsAuth:      &MetricsAuth{Username: "prometheus", Password: "s3cret"},
+		})
+		g.Expect(parsed.Frontend("prometheus").Directives).ToNot(ContainElement(HavePrefix("acl metrics_sources")))
+		g.Expect(parsed.Frontend("prometheus").Directives).To(ContainElement(
+			"http-request auth realm metrics unless { http_auth(" + MetricsUserlistName + ") }"))
+	})
+
+	t.Run("rejects credentials that do not render as a single token", func(t *testing.T) {
+		g := NewWithT(t)
+		for _, auth := range []MetricsAuth{
+			{Username: "", Password: "s3cret"},
+			{Username: "prometheus", Password: "two words"},
+			{Username: "prometheus", Password: "s3cret#comment"},
+			{Username: "prom&theus", Password: "s3cret"},
+		} {
+			auth := auth
+			_, err := Config(&ConfigData{ControlPlanePort: 6443, MetricsPort: 8405, MetricsAuth: &auth})
+			g.Expect(err).To(HaveOccurred(), "credentials %q", auth.Username)
+		}
+	})
+
+	t.Run("redacts the password", func(t *testing.T) {
+		g := NewWithT(t)
+		config, err := Config(&ConfigData{
+			ControlPlanePort: 6443,
+			MetricsPort:      8405,
+			MetricsAuth:      &MetricsAuth{Username: "prometheus", Password: "s3cret"},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		redacted := RedactConfig(config)
+		g.Expect(redacted).ToNot(ContainSubstring("s3cret"))
+		g.Expect(redacted).To(ContainSubstring("user prometheus insecure-password " + Redacted))
+	})
+}
+
+func TestConfigIPFamily(t *testing.T) {
+	pools := []BackendPool{{Name: "readers", Port: 7443}}
+
+	tests := []struct {
+		name   string
+		family string
+		want   []string
+	}{
+		{name: "binds IPv4 by default", want: []string{"*:6443", "*:7443"}},
+		{name: "binds IPv4", family: IPFamilyIPv4, want: []string{"*:6443", "*:7443"}},
+		{name: "binds IPv6 only", family: IPFamilyIPv6, want: []string{":::6443 v6only", ":::7443 v6only"}},
+		{name: "binds IPv4 and IPv6 for dual-stack", family: IPFamilyDualStack, want: []string{":::6443 v4v6", ":::7443 v4v6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, IPFamily: tt.family, BackendPools: pools})
+			g.Expect(parsed.Frontend("control-plane").Binds).To(Equal(tt.want))
+		})
+	}
+
+	t.Run("rejects an unknown IP family", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := Config(&ConfigData{ControlPlanePort: 6443, IPFamily: "IPv5"})
+		g.Expect(err).To(MatchError(ContainSubstring("IP family IPv5 is not supported")))
+	})
+}
+
+func TestConfigMode(t *testing.T) {
+	backendServers := map[string]string{"cp-1": "10.0.0.1:6443"}
+
+	t.Run("defaults to tcp passthrough", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers})
+		g.Expect(parsed.Defaults).To(ContainElement("mode tcp"))
+		g.Expect(parsed.Defaults).ToNot(ContainElement("option httplog"))
+		g.Expect(parsed.Frontend("control-plane").Binds).To(Equal([]string{"*:6443"}))
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).To(Equal([]string{"check"}))
+	})
+
+	t.Run("terminates TLS in http mode", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, Mode: ModeHTTP})
+		g.Expect(parsed.Defaults).To(ContainElements("mode http", "option httplog"))
+		g.Expect(parsed.Frontend("control-plane").Binds).To(Equal([]string{"*:6443 ssl crt " + TLSCertificatePath}))
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).To(Equal([]string{"check", "ssl", "verify", "none"}))
+	})
+}
+
+func TestConfigCheckPort(t *testing.T) {
+	backendServers := map[string]string{"cp-1": "10.0.0.1:6443"}
+
+	t.Run("checks the traffic port by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers})
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).ToNot(ContainElement("port"))
+	})
+
+	t.Run("checks the check port when set", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, CheckPort: 10256})
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).To(Equal([]string{"check", "port", "10256"}))
+	})
+}
+
+func TestConfigPreserveDestinationPort(t *testing.T) {
+	backendServers := map[string]string{"cp-1": "10.0.0.1:6443", "cp-2": "[fd00::2]:6443", "cp-3": "test-cluster-cp-3:6443"}
+	pools := []BackendPool{{Name: "ingress", Port: 443, Servers: map[string]string{"md-1": "10.0.0.5:6443"}}}
+
+	t.Run("connects to the port of the server addresses by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers})
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Address).To(Equal("10.0.0.1:6443"))
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).ToNot(ContainElement("port"))
+	})
+
+	t.Run("renders the servers without a port", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, BackendPools: pools, PreserveDestinationPort: true})
+		servers := parsed.Backend("kube-apiservers").Servers
+		g.Expect(servers).To(HaveLen(3))
+		g.Expect(servers[0].Address).To(Equal("10.0.0.1"))
+		g.Expect(servers[1].Address).To(Equal("[fd00::2]"))
+		g.Expect(servers[2].Address).To(Equal("test-cluster-cp-3"))
+		g.Expect(parsed.Backend("ingress").Servers[0].Address).To(Equal("10.0.0.5"))
+	})
+
+	t.Run("checks the control plane port", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, PreserveDestinationPort: true})
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).To(ContainElements("port", "6443"))
+	})
+
+	t.Run("checks the check port when set", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, PreserveDestinationPort: true, CheckPort: 10256})
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).To(ContainElements("port", "10256"))
+	})
+}
+
+func TestConfigBackendPools(t *testing.T) {
+	backendServers := map[string]string{
+		"cp-1": "10.0.0.1:6443",
+		"cp-2": "10.0.0.2:6443",
+	}
+	pools := []BackendPool{
+		{Name: "readers", Port: 7443, Servers: map[string]string{"cp-2": "10.0.0.2:6443"}},
+		{Name: "writers", SNI: "write.example.com", Servers: map[string]string{"cp-1": "10.0.0.1:6443"}},
+	}
+
+	t.Run("renders a single pool by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers})
+		g.Expect(parsed.Backends).To(HaveLen(1))
+		g.Expect(parsed.Frontend("control-plane").Directives).To(BeEmpty())
+	})
+
+	t.Run("routes pools by port and SNI in tcp mode", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, BackendPools: pools})
+
+		frontend := parsed.Frontend("control-plane")
+		g.Expect(frontend.Binds).To(Equal([]string{"*:6443", "*:7443"}))
+		g.Expect(frontend.DefaultBackend).To(Equal("kube-apiservers"))
+		g.Expect(frontend.Directives).To(Equal([]string{
+			"tcp-request inspect-delay 5s",
+			"tcp-request content accept if { req_ssl_hello_type 1 }",
+			"use_backend readers if { dst_port 7443 }",
+			"use_backend writers if { req_ssl_sni -i write.example.com }",
+		}))
+
+		g.Expect(parsed.Backend("kube-apiservers").Servers).To(HaveLen(2))
+		g.Expect(parsed.Backend("readers").Servers).To(Equal([]ParsedServer{
+			{Name: "cp-2", Address: "10.0.0.2:6443", Options: []string{"check"}},
+		}))
+		g.Expect(parsed.Backend("writers").Servers).To(Equal([]ParsedServer{
+			{Name: "cp-1", Address: "10.0.0.1:6443", Options: []string{"check"}},
+		}))
+	})
+
+	t.Run("routes SNI on the terminated connection in http mode", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, BackendPools: pools, Mode: ModeHTTP})
+
+		frontend := parsed.Frontend("control-plane")
+		g.Expect(frontend.Binds).To(ContainElement("*:7443 ssl crt " + TLSCertificatePath))
+		g.Expect(frontend.Directives).To(Equal([]string{
+			"use_backend readers if { dst_port 7443 }",
+			"use_backend writers if { ssl_fc_sni -i write.example.com }",
+		}))
+	})
+
+	t.Run("rejects udp pools", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := Config(&ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, BackendPools: []BackendPool{
+			{Name: "dns", Port: 53, Protocol: ProtocolUDP, Servers: backendServers},
+		}})
+		g.Expect(err).To(MatchError(ContainSubstring("protocol udp is not supported")))
+	})
+}
+
+func TestConfigPeers(t *testing.T) {
+	t.Run("renders no peers section by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443})
+		g.Expect(parsed.Sections).To(BeEmpty())
+	})
+
+	t.Run("renders the peers section", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, Peers: []PeerEntry{
+			{Name: "cluster-lb", Address: "172.18.0.2:10000"},
+			{Name: "cluster-lb-2", Address: "172.18.0.3:10000"},
+		}})
+		g.Expect(parsed.Sections).To(Equal([]ParsedSection{{
+			Kind: "peers",
+			Name: PeersName,
+			Directives: []string{
+				"peer cluster-lb 172.18.0.2:10000",
+				"peer cluster-lb-2 172.18.0.3:10000",
+			},
+		}}))
+		g.Expect(parsed.Defaults).To(ContainElement("mode tcp"))
+	})
+}
+
+func TestConfigResolvers(t *testing.T) {
+	backendServers := map[string]string{"cp-1": "cluster-cp-1:6443"}
+
+	t.Run("renders no resolvers section by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers})
+		g.Expect(parsed.Sections).To(BeEmpty())
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).To(Equal([]string{"check"}))
+	})
+
+	t.Run("re-resolves servers with the resolvers section", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort: 6443,
+			BackendServers:   backendServers,
+			BackendPools:     []BackendPool{{Name: "readers", Port: 7443, Servers: backendServers}},
+			Resolvers:        &Resolvers{Name: DockerResolversName, Nameservers: []string{DockerDNSAddress}},
+		})
+		g.Expect(parsed.Sections).To(Equal([]ParsedSection{{
+			Kind:       "resolvers",
+			Name:       "docker",
+			Directives: []string{"nameserver ns0 127.0.0.11:53", "hold valid 10s"},
+		}}))
+		options := []string{"check", "resolvers", "docker", "init-addr", "none"}
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).To(Equal(options))
+		g.Expect(parsed.Backend("readers").Servers[0].Options).To(Equal(options))
+	})
+}
+
+func TestConfigBackendCAFile(t *testing.T) {
+	g := NewWithT(t)
+	backendServers := map[string]string{"cp-1": "10.0.0.1:6443"}
+
+	parsed := parseRendered(g, &ConfigData{
+		ControlPlanePort: 6443,
+		BackendServers:   backendServers,
+		BackendPools:     []BackendPool{{Name: "readers", Port: 7443, Servers: backendServers}},
+		BackendCAFile:    BackendCAPath,
+		HealthCheck:      HealthCheckSSL,
+	})
+	options := []string{"check", "check-ssl", "verify", "required", "ca-file", BackendCAPath}
+	g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).To(Equal(options))
+	g.Expect(parsed.Backend("readers").Servers[0].Options).To(Equal(options))
+}
+
+func TestConfigInitAddr(t *testing.T) {
+	backendServers := map[string]string{"cp-1": "cluster-cp-1:6443"}
+
+	t.Run("renders no init-addr by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers})
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).ToNot(ContainElement("init-addr"))
+	})
+
+	t.Run("renders the init-addr methods", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort: 6443,
+			BackendServers:   backendServers,
+			BackendPools:     []BackendPool{{Name: "readers", Port: 7443, Servers: backendServers}},
+			InitAddr:         DefaultInitAddr,
+		})
+		options := []string{"check", "init-addr", "last,libc,none"}
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).To(Equal(options))
+		g.Expect(parsed.Backend("readers").Servers[0].Options).To(Equal(options))
+	})
+}
+
+func TestConfigSNIRoutes(t *testing.T) {
+	backendServers := map[string]string{"cp-1": "10.0.0.1:6443"}
+	pools := []BackendPool{
+		{Name: "writers", SNI: "write.example.com", Servers: backendServers},
+	}
+	routes := []SNIRoute{
+		{ServerName: "a.example.com", Backend: "kube-apiservers"},
+		{ServerName: "b.example.com", Backend: "writers"},
+	}
+
+	t.Run("requires TLS for a routed server name in tcp mode", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, BackendPools: pools, SNIRoutes: routes})
+
+		frontend := parsed.Frontend("control-plane")
+		g.Expect(frontend.DefaultBackend).To(Equal("kube-apiservers"))
+		g.Expect(frontend.Directives).To(Equal([]string{
+			"tcp-request inspect-delay 5s",
+			"tcp-request content reject unless { req_ssl_hello_type 1 }",
+			"tcp-request content reject unless { req_ssl_sni -i a.example.com b.example.com write.example.com }",
+			"use_backend writers if { req_ssl_sni -i write.example.com }",
+			"use_backend kube-apiservers if { req_ssl_sni -i a.example.com }",
+			"use_backend writers if { req_ssl_sni -i b.example.com }",
+		}))
+	})
+
+	t.Run("denies other server names on the terminated connection in http mode", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, SNIRoutes: routes[:1], Mode: ModeHTTP})
+
+		g.Expect(parsed.Frontend("control-plane").Directives).To(Equal([]string{
+			"http-request deny unless { ssl_fc_sni -i a.example.com }",
+			"use_backend kube-apiservers if { ssl_fc_sni -i a.example.com }",
+		}))
+	})
+
+	t.Run("rejects routes to unknown backends", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := Config(&ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, SNIRoutes: routes})
+		g.Expect(err).To(MatchError(ContainSubstring("backend writers does not exist")))
+	})
+}
+
+func TestConfigBackendSource(t *testing.T) {
+	backendServers := map[string]string{"cp-1": "172.18.0.3:6443"}
+
+	t.Run("renders no source by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers})
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).ToNot(ContainElement("source"))
+	})
+
+	t.Run("renders the source address", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort: 6443,
+			BackendServers:   backendServers,
+			BackendPools:     []BackendPool{{Name: "readers", Port: 7443, Servers: backendServers}},
+			BackendSource:    "172.18.0.100",
+		})
+		options := []string{"check", "source", "172.18.0.100"}
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).To(Equal(options))
+		g.Expect(parsed.Backend("readers").Servers[0].Options).To(Equal(options))
+	})
+}
+
+func TestConfigShutdownSessionsOnDown(t *testing.T) {
+	backendServers := map[string]string{"cp-1": "172.18.0.3:6443"}
+
+	t.Run("keeps the sessions by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers})
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).ToNot(ContainElement("on-marked-down"))
+	})
+
+	t.Run("shuts down the sessions of servers marked down", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort:       6443,
+			BackendServers:         backendServers,
+			BackendPools:           []BackendPool{{Name: "readers", Port: 7443, Servers: backendServers}},
+			ShutdownSessionsOnDown: true,
+		})
+		options := []string{"check", "on-marked-down", "shutdown-sessions"}
+		g.Expect(parsed.Backend("kube-apiservers").Servers[0].Options).To(Equal(options))
+		g.Expect(parsed.Backend("readers").Servers[0].Options).To(Equal(options))
+	})
+}
+
+func TestConfigHealthCheck(t *testing.T) {
+	backendServers := map[string]string{"cp-1": "172.18.0.3:6443"}
+	pools := []BackendPool{{Name: "readers", Port: 7443, Servers: backendServers}}
+
+	tests := []struct {
+		name           string
+		healthCheck    string
+		mode           string
+		caFile         string
+		wantDirectives []string
+		wantOptions    []string
+	}{
+		{
+			name:        "checks tcp by default",
+			wantOptions: []string{"check"},
+		},
+		{
+			name:        "tcp",
+			healthCheck: HealthCheckTCP,
+			wantOptions: []string{"check"},
+		},
+		{
+			name:           "healthz",
+			healthCheck:    HealthCheckHTTPHealthz,
+			wantDirectives: []string{"option httpchk GET /healthz"},
+			wantOptions:    []string{"check", "check-ssl", "verify", "none"},
+		},
+		{
+			name:        "tcp in http mode keeps the traffic TLS",
+			healthCheck: HealthCheckTCP,
+			mode:        ModeHTTP,
+			wantOptions: []string{"check", "ssl", "verify", "none"},
+		},
+		{
+			name:        "ssl",
+			healthCheck: HealthCheckSSL,
+			caFile:      BackendCAPath,
+			wantOptions: []string{"check", "check-ssl", "verify", "required", "ca-file", BackendCAPath},
+		},
+		{
+			name:           "readyz",
+			healthCheck:    HealthCheckHTTPReadyz,
+			caFile:         BackendCAPath,
+			wantDirectives: []string{"option httpchk GET /readyz", "http-check expect status 200"},
+			wantOptions:    []string{"check", "check-ssl", "verify", "required", "ca-file", BackendCAPath},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			parsed := parseRendered(g, &ConfigData{
+				ControlPlanePort: 6443,
+				BackendServers:   backendServers,
+				BackendPools:     pools,
+				BackendCAFile:    tt.caFile,
+				Mode:             tt.mode,
+				HealthCheck:      tt.healthCheck,
+			})
+			for _, name := range []string{"kube-apiservers", "readers"} {
+				if tt.wantDirectives == nil {
+					g.Expect(parsed.Backend(name).Directives).To(BeEmpty())
+				} else {
+					g.Expect(parsed.Backend(name).Directives).To(Equal(tt.wantDirectives))
+				}
+				g.Expect(parsed.Backend(name).Servers[0].Options).To(Equal(tt.wantOptions))
+			}
+		})
+	}
+}
+
+func TestConfigRetries(t *testing.T) {
+	t.Run("renders no directives by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443})
+		g.Expect(parsed.Defaults).ToNot(ContainElement(HavePrefix("retries")))
+		g.Expect(parsed.Defaults).ToNot(ContainElement("option redispatch"))
+	})
+
+	t.Run("renders retries and redispatch when set", func(t *testing.T) {
+		g := NewWithT(t)
+		retries := 3
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, Retries: &retries, Redispatch: true})
+		g.Expect(parsed.Defaults).To(ContainElements("retries 3", "option redispatch"))
+	})
+
+	t.Run("renders zero retries", func(t *testing.T) {
+		g := NewWithT(t)
+		retries := 0
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, Retries: &retries})
+		g.Expect(parsed.Defaults).To(ContainElement("retries 0"))
+	})
+}
+
+func TestConfigConnectionTuning(t *testing.T) {
+	t.Run("renders no directives by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443})
+		g.Expect(parsed.Defaults).ToNot(ContainElement("option tcp-smart-connect"))
+		g.Expect(parsed.Defaults).ToNot(ContainElement(HavePrefix("http-reuse")))
+	})
+
+	t.Run("renders tcp-smart-connect", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, TCPSmartConnect: true})
+		g.Expect(parsed.Defaults).To(ContainElement("option tcp-smart-connect"))
+	})
+
+	t.Run("renders http-reuse in http mode", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, Mode: ModeHTTP, HTTPReuse: HTTPReuseAggressive})
+		g.Expect(parsed.Defaults).To(ContainElement("http-reuse aggressive"))
+	})
+
+	t.Run("rejects http-reuse in tcp mode", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := Config(&ConfigData{ControlPlanePort: 6443, HTTPReuse: HTTPReuseAlways})
+		g.Expect(err).To(MatchError(ContainSubstring("http-reuse requires the http mode")))
+	})
+
+	t.Run("rejects an unknown http-reuse strategy", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := Config(&ConfigData{ControlPlanePort: 6443, Mode: ModeHTTP, HTTPReuse: "sometimes"})
+		g.Expect(err).To(MatchError(ContainSubstring("http-reuse sometimes is not supported")))
+	})
+
+	t.Run("does not let the base configuration override the tuning", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort: 6443,
+			Mode:             ModeHTTP,
+			HTTPReuse:        HTTPReuseNever,
+			BaseConfig:       mustParseOverrides(g, "defaults\n  http-reuse always\n"),
+		})
+		g.Expect(parsed.Defaults).To(ContainElement("http-reuse never"))
+		g.Expect(parsed.Defaults).ToNot(ContainElement("http-reuse always"))
+	})
+}
+
+func TestConfigHibernated(t *testing.T) {
+	t.Run("routes connections by default", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443})
+		g.Expect(parsed.Frontend("control-plane").Directives).To(BeEmpty())
+		g.Expect(parsed.Hibernated()).To(BeFalse())
+	})
+
+	t.Run("closes connections in tcp mode", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, Hibernated: true})
+		g.Expect(parsed.Frontend("control-plane").Directives).To(Equal([]string{"tcp-request connection reject"}))
+		g.Expect(parsed.Hibernated()).To(BeTrue())
+	})
+
+	t.Run("answers 503 in http mode", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{ControlPlanePort: 6443, Hibernated: true, Mode: ModeHTTP})
+		g.Expect(parsed.Frontend("control-plane").Directives).To(Equal([]string{
+			`http-request return status 503 content-type text/plain string "cluster hibernated"`,
+		}))
+		g.Expect(parsed.Hibernated()).To(BeTrue())
+	})
+}
+
+func TestConfigDisabledServers(t *testing.T) {
+	g := NewWithT(t)
+	parsed := parseRendered(g, &ConfigData{
+		ControlPlanePort: 6443,
+		BackendServers: map[string]string{
+			"cp-1": "10.0.0.1:6443",
+			"cp-2": "10.0.0.2:6443",
+		},
+		DisabledServers: map[string]bool{"cp-2": true},
+	})
+
+	servers := parsed.Backend("kube-apiservers").Servers
+	g.Expect(servers).To(HaveLen(2))
+	g.Expect(servers[0].Options).ToNot(ContainElement("disabled"))
+	g.Expect(servers[1].Options).To(ContainElement("disabled"))
+}
@@ -1,8 +1,106 @@
 package loadbalancer
 
+import "fmt"
+
 const (
 	Image                  = "haproxy-alpine"
 	DefaultImageRepository = "haproxytech"
 	DefaultImageTag        = "2.4"
 	ConfigPath             = "/usr/local/etc/haproxy/haproxy.cfg"
+	// ConfigDir is the directory of ConfigPath, which also holds the certificates the provider writes.
+	ConfigDir = "/usr/local/etc/haproxy"
+	// ConfigFileMode is the mode the configuration is written with, readable by HAProxy when the image
+	// runs it as a non-root user.
+	ConfigFileMode = 0o644
+	// LastAppliedConfigPath is where the provider keeps a copy of the configuration it last wrote, to
+	// detect changes made to ConfigPath since.
+	LastAppliedConfigPath = "/usr/local/etc/haproxy/haproxy.cfg.last-applied"
+	// PIDFilePath is where HAProxy writes its PID, which is the master PID in master-worker mode.
+	PIDFilePath = "/var/run/haproxy.pid"
+
+	// FrontendName is the name of the control plane frontend in the rendered config.
+	FrontendName = "control-plane"
+	// BackendName is the name of the backend holding the control plane servers in the rendered config.
+	BackendName = "kube-apiservers"
+	// ControlPlanePort is the port the API servers listen on, and the control plane frontend binds to by default.
+	ControlPlanePort = 6443
+	// StatsPort is the port of the stats frontend.
+	StatsPort = 8404
+	// DefaultMetricsPort is the default port of the Prometheus exporter frontend.
+	DefaultMetricsPort = 8405
+	// MetricsPath is the path the Prometheus exporter frontend serves metrics on.
+	MetricsPath = "/metrics"
+	// MetricsUserlistName is the name of the userlist holding the credentials of the Prometheus exporter frontend.
+	MetricsUserlistName = "metrics-users"
+	// TLSCertificatePath is the directory the control plane frontend loads its certificates from in http mode.
+	TLSCertificatePath = "/usr/local/etc/haproxy/certs"
+	// BackendCAPath is where the CA bundle verifying the API server certificates is written in the container.
+	BackendCAPath = "/usr/local/etc/haproxy/backend-ca.crt"
+
+	// PeersName is the name of the peers section synchronizing stick tables between load balancers.
+	PeersName = "lb-peers"
+	// PeerPort is the port load balancers listen on for peer connections.
+	PeerPort = 10000
+
+	// DockerResolversName is the name of the resolvers section querying the docker embedded DNS server.
+	DockerResolversName = "docker"
+	// DockerDNSAddress is the address of the docker embedded DNS server, which resolves container names
+	// on user defined networks.
+	DockerDNSAddress = "127.0.0.11:53"
+
+	// InitAddrNone starts HAProxy with the servers it cannot resolve in maintenance, until the resolvers resolve them.
+	InitAddrNone = "none"
+	// DefaultInitAddr resolves the servers from the previous state, then with libc, and starts
+	// HAProxy with any server it still cannot resolve in maintenance.
+	DefaultInitAddr = "last,libc,none"
+
+	// HibernatedTCPDirective closes control plane connections cleanly while the cluster is hibernated in tcp mode.
+	HibernatedTCPDirective = "tcp-request connection reject"
+	// HibernatedHTTPDirective answers control plane requests while the cluster is hibernated in http mode.
+	HibernatedHTTPDirective = `http-request return status 503 content-type text/plain string "cluster hibernated"`
+
+	// ModeTCP passes the control plane traffic through at layer 4.
+	ModeTCP = "tcp"
+	// ModeHTTP terminates TLS and routes the control plane traffic at layer 7.
+	ModeHTTP = "http"
+
+	// HTTPReuseNever opens a new connection to the backend servers for each client connection.
+	HTTPReuseNever = "never"
+	// HTTPReuseSafe reuses idle backend connections for the requests following the first one of a client
+	// connection; this is the HAProxy default.
+	HTTPReuseSafe = "safe"
+	// HTTPReuseAggressive also reuses idle backend connections already reused once for the first requests.
+	HTTPReuseAggressive = "aggressive"
+	// HTTPReuseAlways reuses any idle backend connection for any request.
+	HTTPReuseAlways = "always"
+
+	// IPFamilyIPv4 binds the control plane frontends to the IPv4 addresses of the load balancer.
+	IPFamilyIPv4 = "IPv4"
+	// IPFamilyIPv6 binds the control plane frontends to the IPv6 addresses of the load balancer.
+	IPFamilyIPv6 = "IPv6"
+	// IPFamilyDualStack binds the control plane frontends to both the IPv4 and IPv6 addresses of the load balancer.
+	IPFamilyDualStack = "DualStack"
+
+	// HealthCheckTCP checks the backend servers accept connections.
+	HealthCheckTCP = "tcp"
+	// HealthCheckSSL checks the backend servers complete a TLS handshake.
+	HealthCheckSSL = "ssl"
+	// HealthCheckHTTPHealthz checks the healthz endpoint of the backend servers responds successfully, over TLS.
+	HealthCheckHTTPHealthz = "httpHealthz"
+	// HealthCheckHTTPReadyz checks the readyz endpoint of the backend servers responds with a 200, over TLS.
+	HealthCheckHTTPReadyz = "httpReadyz"
+
+	// JSONLogFormat is a log-format logging each connection as a JSON object, for log pipelines parsing JSON.
+	// It only uses the variables of the tcp logs, so it can be used in both modes.
+	JSONLogFormat = `{"time":"%t","client":"%ci:%cp","frontend":"%f","backend":"%b","server":"%s",` +
+		`"wait_queue_ms":%Tw,"connect_ms":%Tc,"duration_ms":%Tt,"bytes_read":%B,"termination_state":"%ts",` +
+		`"actconn":%ac,"feconn":%fc,"beconn":%bc,"srv_conn":%sc,"retries":%rc}`
+
+	// ProtocolTCP is the transport protocol of the backend pool ports.
+	ProtocolTCP = "tcp"
+	// ProtocolUDP is not supported by HAProxy for backend pool ports.
+	ProtocolUDP = "udp"
 )
+
+// StatsCSVURL is the URL of the stats page CSV export, as seen from inside the load balancer container.
+var StatsCSVURL = fmt.Sprintf("http://127.0.0.1:%d/;csv", StatsPort)
@@ -0,0 +1,142 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around the changes of a diff.
+const diffContext = 3
+
+// UnifiedDiff returns the changes from the configuration from to the configuration to as a unified diff,
+// with the given file names in its header, e.g. "live" and "desired". It returns an empty string if the
+// configurations are the same. Configurations are a few hundred lines at most, so the lines are matched
+// with a plain longest common subsequence.
+func UnifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	a, b := diffLines(from), diffLines(to)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// edits lists the lines of the diff, prefixed with ' ', '-' or '+'.
+	var edits []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, " "+a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			edits = append(edits, "+"+b[j])
+			j++
+		default:
+			edits = append(edits, "-"+a[i])
+			i++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	// lines[k] are the line numbers in from and to of the edit k, counting from 1.
+	fromLine, toLine := 1, 1
+	lines := make([][2]int, len(edits))
+	for k, edit := range edits {
+		lines[k] = [2]int{fromLine, toLine}
+		if edit[0] != '+' {
+			fromLine++
+		}
+		if edit[0] != '-' {
+			toLine++
+		}
+	}
+	for start := 0; start < len(edits); {
+		if edits[start][0] == ' ' {
+			start++
+			continue
+		}
+		// A hunk spans the changes closer than twice the context, with the context around them.
+		first := start - diffContext
+		if first < 0 {
+			first = 0
+		}
+		end := start
+		for k := start; k < len(edits) && k-end <= 2*diffContext+1; k++ {
+			if edits[k][0] != ' ' {
+				end = k
+			}
+		}
+		last := end + diffContext
+		if last > len(edits)-1 {
+			last = len(edits) - 1
+		}
+
+		fromCount, toCount := 0, 0
+		for _, edit := range edits[first : last+1] {
+			if edit[0] != '+' {
+				fromCount++
+			}
+			if edit[0] != '-' {
+				toCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(lines[first][0], fromCount), hunkRange(lines[first][1], toCount))
+		for _, edit := range edits[first : last+1] {
+			out.WriteString(edit + "\n")
+		}
+		start = last + 1
+	}
+	return out.String()
+}
+
+// diffLines splits a configuration into lines, without the empty line following the final newline.
+func diffLines(config string) []string {
+	if config == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(config, "\n"), "\n")
+}
+
+// hunkRange formats the start and length of a hunk range; an empty range starts at the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	live := `global
+  log stdout format raw local0 info
+
+defaults
+  mode tcp
+  timeout client 10s
+  timeout connect 5s
+  timeout server 10s
+
+backend kube-apiservers
+  server cp-1 10.0.0.1:6443 check
+  server cp-2 10.0.0.2:6443 check
+`
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{
+			name: "same configurations",
+			from: live,
+			to:   live,
+			want: "",
+		},
+		{
+			name: "changed and added lines in separate hunks",
+			from: live,
+			to: `global
+  log stdout format raw local0 debug
+
+defaults
+  mode tcp
+  timeout client 10s
+  timeout connect 5s
+  timeout server 10s
+
+backend kube-apiservers
+  server cp-1 10.0.0.1:6443 check
+  server cp-2 10.0.0.2:6443 check
+  server cp-3 10.0.0.3:6443 check
+`,
+			want: `--- live
++++ desired
+@@ -1,5 +1,5 @@
+ global
+-  log stdout format raw local0 info
++  log stdout format raw local0 debug
+ 
+ defaults
+   mode tcp
+@@ -10,3 +10,4 @@
+ backend kube-apiservers
+   server cp-1 10.0.0.1:6443 check
+   server cp-2 10.0.0.2:6443 check
++  server cp-3 10.0.0.3:6443 check
+`,
+		},
+		{
+			name: "removed line",
+			from: "backend kube-apiservers\n  server cp-1 10.0.0.1:6443 check\n  server cp-2 10.0.0.2:6443 check\n",
+			to:   "backend kube-apiservers\n  server cp-2 10.0.0.2:6443 check\n",
+			want: "--- live\n+++ desired\n@@ -1,3 +1,2 @@\n backend kube-apiservers\n-  server cp-1 10.0.0.1:6443 check\n   server cp-2 10.0.0.2:6443 check\n",
+		},
+		{
+			name: "empty live configuration",
+			from: "",
+			to:   "global\n  nbthread 4\n",
+			want: "--- live\n+++ desired\n@@ -0,0 +1,2 @@\n+global\n+  nbthread 4\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(UnifiedDiff("live", "desired", tt.from, tt.to)).To(Equal(tt.want))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestGenerateConfigGolden(t *testing.T) {
+	backendServers := map[string]string{
+		"cluster-cp-1": "172.18.0.3:6443",
+		"cluster-cp-2": "172.18.0.4:6443",
+	}
+
+	tests := []struct {
+		name string
+		data *ConfigData
+	}{
+		{
+			name: "no-backends",
+			data: &ConfigData{ControlPlanePort: 6443, EnableStats: true},
+		},
+		{
+			name: "default",
+			data: &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, EnableStats: true},
+		},
+		{
+			name: "ipv6",
+			data: &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, EnableStats: true, IPFamily: IPFamilyIPv6},
+		},
+		{
+			name: "dual-stack",
+			data: &ConfigData{ControlPlanePort: 6443, BackendServers: backendServers, EnableStats: true, IPFamily: IPFamilyDualStack},
+		},
+		{
+			name: "http-metrics",
+			data: &ConfigData{
+				ControlPlanePort: 6443,
+				FrontendPort:     7443,
+				BackendServers:   backendServers,
+				DisabledServers:  map[string]bool{"cluster-cp-2": true},
+				EnableStats:      true,
+				MetricsPort:      8405,
+				CheckPort:        10256,
+				Mode:             ModeHTTP,
+			},
+		},
+		{
+			name: "backend-pools",
+			data: &ConfigData{
+				ControlPlanePort: 6443,
+				BackendServers:   backendServers,
+				EnableStats:      true,
+				BackendPools: []BackendPool{
+					{Name: "readers", Port: 7443, Servers: map[string]string{"cluster-cp-2": "172.18.0.4:6443"}},
+					{Name: "writers", SNI: "write.example.com", Servers: map[string]string{"cluster-cp-1": "172.18.0.3:6443"}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			config, err := GenerateConfig(tt.data)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			golden := filepath.Join("testdata", tt.name+".cfg")
+			if *updateGolden {
+				g.Expect(os.WriteFile(golden, []byte(config), 0600)).To(Succeed())
+			}
+			want, err := os.ReadFile(golden)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(config).To(Equal(string(want)), "run go test ./pkg/loadbalancer -run TestGenerateConfigGolden -update to update %s", golden)
+
+			// Generating the configuration must not modify its input.
+			again, err := GenerateConfig(tt.data)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(again).To(Equal(config))
+		})
+	}
+}
@@ -0,0 +1,311 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"bufio"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ConfigOverrides are directives layered over sections of the rendered configuration. They are written
+// in the configuration syntax, listing only the directives to set, e.g.
+//
+//	defaults
+//	  timeout client 30s
+//	global
+//	  maxconn 4096
+//
+// A directive replaces the directives of the section with the same keyword, e.g. "timeout client 30s"
+// replaces "timeout client 10s", or is appended if there are none. Directives that can be repeated,
+// e.g. server, bind or http-request, are always appended.
+type ConfigOverrides []OverrideSection
+
+// OverrideSection is a section of ConfigOverrides.
+type OverrideSection struct {
+	// Header is the section keyword and name, e.g. "defaults" or "backend kube-apiservers".
+	Header string
+	// Directives are the directives to set in the section.
+	Directives []string
+}
+
+// overridableSections are the section keywords ConfigOverrides can adjust.
+var overridableSections = map[string]bool{"global": true, "defaults": true, "frontend": true, "backend": true}
+
+// sectionKeywords are the keywords starting a section of an HAProxy configuration.
+var sectionKeywords = map[string]bool{
+	"global": true, "defaults": true, "frontend": true, "backend": true, "listen": true, "peers": true,
+	"resolvers": true, "userlist": true, "program": true, "http-errors": true, "ring": true, "mailers": true,
+	"cache": true,
+}
+
+// repeatableKeywords are the directives a section can have several of, which overrides append.
+var repeatableKeywords = map[string]bool{
+	"server": true, "bind": true, "use_backend": true, "acl": true, "tcp-request": true, "http-request": true,
+	"http-response": true, "http-check": true, "peer": true, "nameserver": true, "errorfile": true,
+}
+
+// requiredDirectives are the directives the provider relies on, e.g. to reload HAProxy, which no
+// override can change.
+var requiredDirectives = map[string]map[string]bool{
+	"global": {"pidfile": true, "stats socket": true},
+}
+
+// defaultsOverrideKeys are the directives of the defaults section DefaultsOverride can set. The mode
+// is left out, it is set with ConfigData.Mode.
+var defaultsOverrideKeys = map[string]bool{
+	"timeout client": true, "timeout client-fin": true, "timeout connect": true, "timeout server": true,
+	"timeout server-fin": true, "timeout check": true, "timeout queue": true, "timeout tunnel": true,
+	"timeout http-request": true, "timeout http-keep-alive": true, "timeout tarpit": true,
+	"option redispatch": true, "option abortonclose": true, "option tcpka": true, "option clitcpka": true,
+	"option srvtcpka": true, "option dontlognull": true, "option log-health-checks": true, "option tcplog": true,
+	"option httplog": true, "option splice-auto": true, "option http-server-close": true,
+	"option http-keep-alive": true, "option forwardfor": true,
+	"retries": true, "retry-on": true, "maxconn": true, "fullconn": true, "backlog": true, "balance": true,
+	"hash-type": true, "default-server": true, "log": true, "log-format": true,
+}
+
+// DefaultsOverride returns the overrides setting directives of the defaults section, from their keys,
+// e.g. "timeout client" or "option tcpka", to their arguments, which can be empty. Only the known defaults
+// directives can be set; the directives are sorted by key, so the result does not depend on the map order.
+func DefaultsOverride(directives map[string]string) (ConfigOverrides, error) {
+	if len(directives) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(directives))
+	for key := range directives {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	section := OverrideSection{Header: "defaults"}
+	for _, key := range keys {
+		fields := strings.Fields(key)
+		if len(fields) == 0 || !defaultsOverrideKeys[strings.Join(fields, " ")] {
+			return nil, errors.Errorf("%q is not a supported defaults directive", key)
+		}
+		value := directives[key]
+		if strings.ContainsAny(value, "\n#") {
+			return nil, errors.Errorf("the arguments of %q must be on a single line without comments", key)
+		}
+		section.Directives = append(section.Directives, strings.Join(append(fields, strings.Fields(value)...), " "))
+	}
+	return ConfigOverrides{section}, nil
+}
+
+// ParseConfigOverrides parses overrides written in the configuration syntax. Only the global, defaults,
+// frontend and backend sections can be overridden.
+func ParseConfigOverrides(data string) (ConfigOverrides, error) {
+	overrides := ConfigOverrides{}
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := tokenize(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if sectionKeywords[fields[0]] {
+			if !overridableSections[fields[0]] {
+				return nil, errors.Errorf("line %d: %s sections cannot be overridden", lineNumber, fields[0])
+			}
+			if (fields[0] == "frontend" || fields[0] == "backend") && len(fields) < 2 {
+				return nil, errors.Errorf("line %d: %s section without a name", lineNumber, fields[0])
+			}
+			overrides = append(overrides, OverrideSection{Header: strings.Join(fields, " ")})
+			continue
+		}
+
+		if len(overrides) == 0 {
+			return nil, errors.Errorf("line %d: directive %q outside of a section", lineNumber, fields[0])
+		}
+		section := &overrides[len(overrides)-1]
+		section.Directives = append(section.Directives, strings.Join(fields, " "))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read config overrides")
+	}
+	return overrides, nil
+}
+
+// directiveKey returns the keyword identifying a directive, including the second token for the
+// directive families HAProxy tells apart by it, e.g. "timeout client" or "option httplog".
+func directiveKey(fields []string) string {
+	switch fields[0] {
+	case "timeout", "option", "stats":
+		if len(fields) > 1 {
+			return fields[0] + " " + fields[1]
+		}
+	}
+	return fields[0]
+}
+
+// dataDirectiveKeys returns the directives rendered from the fields of the configuration data, by section.
+func dataDirectiveKeys(data *ConfigData) map[string]map[string]bool {
+	keys := map[string]map[string]bool{
+		"global":   {},
+		"defaults": {"mode": true},
+	}
+	if data.Nbproc != 0 {
+		keys["global"]["nbproc"] = true
+	}
+	if data.Nbthread != 0 {
+		keys["global"]["nbthread"] = true
+	}
+	if data.MaxConn != 0 {
+		keys["global"]["maxconn"] = true
+	}
+	if data.FrontendMaxConn != 0 {
+		keys["frontend"] = map[string]bool{"maxconn": true}
+	}
+	if data.RunAsUser != "" {
+		keys["global"]["user"] = true
+	}
+	if data.RunAsGroup != "" {
+		keys["global"]["group"] = true
+	}
+	if data.Retries != nil {
+		keys["defaults"]["retries"] = true
+	}
+	if data.Redispatch {
+		keys["defaults"]["option redispatch"] = true
+	}
+	if data.TCPSmartConnect {
+		keys["defaults"]["option tcp-smart-connect"] = true
+	}
+	if data.HTTPReuse != "" {
+		keys["defaults"]["http-reuse"] = true
+	}
+	if data.LogFormat != "" {
+		keys["defaults"]["log-format"] = true
+	}
+	return keys
+}
+
+// layerConfig layers the base configuration, the defaults override and the patch of the data over the
+// rendered configuration. The base does not override the directives rendered from the data fields;
+// the defaults override and the patch override them.
+func layerConfig(config string, data *ConfigData) (string, error) {
+	dataKeys := dataDirectiveKeys(data)
+	for section, keys := range requiredDirectives {
+		for key := range keys {
+			if dataKeys[section] == nil {
+				dataKeys[section] = map[string]bool{}
+			}
+			dataKeys[section][key] = true
+		}
+	}
+
+	config, err := applyOverrides(config, data.BaseConfig, dataKeys)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to apply the base configuration")
+	}
+	defaults, err := DefaultsOverride(data.DefaultsOverride)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to apply the defaults override")
+	}
+	if config, err = applyOverrides(config, defaults, requiredDirectives); err != nil {
+		return "", errors.Wrap(err, "failed to apply the defaults override")
+	}
+	config, err = applyOverrides(config, data.ConfigPatch, requiredDirectives)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to apply the configuration patch")
+	}
+	return config, nil
+}
+
+// renderedSection is a section of a rendered configuration, with its lines as rendered.
+type renderedSection struct {
+	header string
+	lines  []string
+}
+
+// applyOverrides sets the directives of the overrides in the sections of config, skipping the protected
+// directives of each section.
+func applyOverrides(config string, overrides ConfigOverrides, protected map[string]map[string]bool) (string, error) {
+	if len(overrides) == 0 {
+		return config, nil
+	}
+
+	// The first section has no header, it holds the lines before the first section, e.g. comments.
+	sections := []*renderedSection{{}}
+	for _, line := range strings.Split(config, "\n") {
+		fields := tokenize(line)
+		if len(fields) > 0 && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && sectionKeywords[fields[0]] {
+			sections = append(sections, &renderedSection{header: strings.Join(fields, " ")})
+		}
+		current := sections[len(sections)-1]
+		current.lines = append(current.lines, line)
+	}
+
+	for _, override := range overrides {
+		var section *renderedSection
+		for _, s := range sections {
+			if s.header != "" && s.header == override.Header {
+				section = s
+			}
+		}
+		if section == nil {
+			return "", errors.Errorf("section %q is not in the configuration", override.Header)
+		}
+		for _, directive := range override.Directives {
+			setDirective(section, directive, protected[strings.Fields(override.Header)[0]])
+		}
+	}
+
+	var lines []string
+	for _, s := range sections {
+		lines = append(lines, s.lines...)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// setDirective replaces the directives of the section with the same key as directive, or appends it.
+func setDirective(section *renderedSection, directive string, protected map[string]bool) {
+	fields := strings.Fields(directive)
+	key := directiveKey(fields)
+	if protected[key] {
+		return
+	}
+
+	// Directives are appended after the last directive of the section, before the blank lines separating it from the next one.
+	last := 0
+	replaced := false
+	lines := make([]string, 0, len(section.lines)+1)
+	for i, line := range section.lines {
+		lineFields := tokenize(line)
+		if len(lineFields) > 0 {
+			last = len(lines)
+		}
+		if i > 0 && len(lineFields) > 0 && !repeatableKeywords[key] && directiveKey(lineFields) == key {
+			if !replaced {
+				lines = append(lines, "  "+directive)
+				replaced = true
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if !replaced {
+		lines = append(lines[:last+1], append([]string{"  " + directive}, lines[last+1:]...)...)
+	}
+	section.lines = lines
+}
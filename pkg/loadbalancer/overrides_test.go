@@ -0,0 +1,208 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func mustParseOverrides(g *WithT, data string) ConfigOverrides {
+	overrides, err := ParseConfigOverrides(data)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	return overrides
+}
+
+func TestParseConfigOverrides(t *testing.T) {
+	g := NewWithT(t)
+
+	overrides := mustParseOverrides(g, `# platform defaults
+defaults
+  timeout client   30s
+backend kube-apiservers
+  balance leastconn
+`)
+	g.Expect(overrides).To(Equal(ConfigOverrides{
+		{Header: "defaults", Directives: []string{"timeout client 30s"}},
+		{Header: "backend kube-apiservers", Directives: []string{"balance leastconn"}},
+	}))
+}
+
+func TestParseConfigOverridesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides string
+	}{
+		{
+			name:      "directive outside of a section",
+			overrides: "maxconn 100\n",
+		},
+		{
+			name:      "backend without a name",
+			overrides: "backend\n  balance leastconn\n",
+		},
+		{
+			name:      "section that cannot be overridden",
+			overrides: "peers lb-peers\n  peer lb-1 172.18.0.2:10000\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			_, err := ParseConfigOverrides(tt.overrides)
+			g.Expect(err).Should(HaveOccurred())
+		})
+	}
+}
+
+func TestDefaultsOverride(t *testing.T) {
+	tests := []struct {
+		name       string
+		directives map[string]string
+		want       ConfigOverrides
+		wantErr    bool
+	}{
+		{
+			name: "no directives",
+		},
+		{
+			name:       "directives sorted by key",
+			directives: map[string]string{"timeout  client": " 30s", "option tcpka": "", "balance": "leastconn"},
+			want: ConfigOverrides{{Header: "defaults", Directives: []string{
+				"balance leastconn", "option tcpka", "timeout client 30s",
+			}}},
+		},
+		{
+			name:       "unknown directive",
+			directives: map[string]string{"timeout clients": "30s"},
+			wantErr:    true,
+		},
+		{
+			name:       "mode",
+			directives: map[string]string{"mode": "http"},
+			wantErr:    true,
+		},
+		{
+			name:       "arguments on several lines",
+			directives: map[string]string{"timeout client": "30s\nmode http"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			overrides, err := DefaultsOverride(tt.directives)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(overrides).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestConfigLayering(t *testing.T) {
+	retries := 5
+	backendServers := map[string]string{"cp-1": "10.0.0.1:6443"}
+
+	t.Run("base overrides the built-in defaults", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort: 6443,
+			BackendServers:   backendServers,
+			BaseConfig: mustParseOverrides(g, `global
+  maxconn 4096
+defaults
+  timeout client 30s
+backend kube-apiservers
+  balance leastconn
+`),
+		})
+		g.Expect(parsed.Global).To(ContainElement("maxconn 4096"))
+		g.Expect(parsed.Defaults).To(ContainElement("timeout client 30s"))
+		g.Expect(parsed.Defaults).ToNot(ContainElement("timeout client 10s"))
+		g.Expect(parsed.Defaults).To(ContainElement("timeout server 10s"))
+		backend := parsed.Backend("kube-apiservers")
+		g.Expect(backend.Directives).To(Equal([]string{"balance leastconn"}))
+		g.Expect(backend.Servers).To(HaveLen(1))
+	})
+
+	t.Run("fields override the base", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort: 6443,
+			Retries:          &retries,
+			BaseConfig:       mustParseOverrides(g, "defaults\n  retries 1\n  mode http\n"),
+		})
+		g.Expect(parsed.Defaults).To(ContainElements("retries 5", "mode tcp"))
+		g.Expect(parsed.Defaults).ToNot(ContainElements("retries 1", "mode http"))
+	})
+
+	t.Run("the patch overrides the fields and the base", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort: 6443,
+			Retries:          &retries,
+			BaseConfig:       mustParseOverrides(g, "defaults\n  timeout client 30s\n"),
+			ConfigPatch:      mustParseOverrides(g, "defaults\n  retries 2\n  timeout client 1m\n"),
+		})
+		g.Expect(parsed.Defaults).To(ContainElements("retries 2", "timeout client 1m"))
+		g.Expect(parsed.Defaults).ToNot(ContainElements("retries 5", "timeout client 30s"))
+	})
+
+	t.Run("the defaults override overrides the fields and is overridden by the patch", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort: 6443,
+			Retries:          &retries,
+			DefaultsOverride: map[string]string{"retries": "4", "timeout client": "30s", "option tcpka": ""},
+			ConfigPatch:      mustParseOverrides(g, "defaults\n  timeout client 1m\n"),
+		})
+		g.Expect(parsed.Defaults).To(ContainElements("retries 4", "option tcpka", "timeout client 1m"))
+		g.Expect(parsed.Defaults).ToNot(ContainElements("retries 5", "timeout client 30s"))
+	})
+
+	t.Run("repeatable directives are appended", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort: 6443,
+			ConfigPatch:      mustParseOverrides(g, "frontend control-plane\n  bind *:7443\n"),
+		})
+		g.Expect(parsed.Frontend("control-plane").Binds).To(Equal([]string{"*:6443", "*:7443"}))
+	})
+
+	t.Run("the provider directives cannot be patched", func(t *testing.T) {
+		g := NewWithT(t)
+		parsed := parseRendered(g, &ConfigData{
+			ControlPlanePort: 6443,
+			ConfigPatch:      mustParseOverrides(g, "global\n  pidfile /tmp/haproxy.pid\n"),
+		})
+		g.Expect(parsed.Global).To(ContainElement("pidfile " + PIDFilePath))
+		g.Expect(parsed.Global).ToNot(ContainElement("pidfile /tmp/haproxy.pid"))
+	})
+
+	t.Run("rejects sections that are not rendered", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := Config(&ConfigData{
+			ControlPlanePort: 6443,
+			ConfigPatch:      mustParseOverrides(g, "backend readers\n  balance leastconn\n"),
+		})
+		g.Expect(err).To(MatchError(ContainSubstring(`section "backend readers" is not in the configuration`)))
+	})
+}
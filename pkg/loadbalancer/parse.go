@@ -0,0 +1,214 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParsedConfig is a structured representation of an HAProxy configuration.
+// Directives are normalized to single-space separated tokens with comments removed,
+// so two configurations that only differ in formatting compare as equal.
+type ParsedConfig struct {
+	// Global contains the directives of the global section.
+	Global []string
+	// Defaults contains the directives of the unnamed defaults section.
+	Defaults []string
+	// Frontends contains the frontend sections, in the order they appear.
+	Frontends []ParsedFrontend
+	// Backends contains the backend sections, in the order they appear.
+	Backends []ParsedBackend
+	// Sections contains any other section (e.g. peers, resolvers), in the order they appear.
+	Sections []ParsedSection
+}
+
+// ParsedFrontend is a frontend section of an HAProxy configuration.
+type ParsedFrontend struct {
+	// Name is the name of the frontend.
+	Name string
+	// Binds contains the arguments of each bind directive.
+	Binds []string
+	// DefaultBackend is the name of the default backend, if any.
+	DefaultBackend string
+	// Directives contains the remaining directives of the frontend.
+	Directives []string
+}
+
+// ParsedBackend is a backend section of an HAProxy configuration.
+type ParsedBackend struct {
+	// Name is the name of the backend.
+	Name string
+	// Servers contains the server entries of the backend.
+	Servers []ParsedServer
+	// Directives contains the remaining directives of the backend.
+	Directives []string
+}
+
+// ParsedServer is a server entry in a backend section.
+type ParsedServer struct {
+	// Name is the name of the server.
+	Name string
+	// Address is the address (host:port) of the server.
+	Address string
+	// Options contains the remaining server options (e.g. check, verify none).
+	Options []string
+}
+
+// ParsedSection is a section of an HAProxy configuration without a dedicated type.
+type ParsedSection struct {
+	// Kind is the section keyword (e.g. peers).
+	Kind string
+	// Name is the name of the section.
+	Name string
+	// Directives contains the directives of the section.
+	Directives []string
+}
+
+// Frontend returns the frontend with the given name, or nil if it does not exist.
+func (c *ParsedConfig) Frontend(name string) *ParsedFrontend {
+	for i := range c.Frontends {
+		if c.Frontends[i].Name == name {
+			return &c.Frontends[i]
+		}
+	}
+	return nil
+}
+
+// Backend returns the backend with the given name, or nil if it does not exist.
+func (c *ParsedConfig) Backend(name string) *ParsedBackend {
+	for i := range c.Backends {
+		if c.Backends[i].Name == name {
+			return &c.Backends[i]
+		}
+	}
+	return nil
+}
+
+// Hibernated returns true if the control plane frontend refuses connections, see ConfigData.Hibernated.
+func (c *ParsedConfig) Hibernated() bool {
+	frontend := c.Frontend(FrontendName)
+	if frontend == nil {
+		return false
+	}
+	for _, directive := range frontend.Directives {
+		if directive == HibernatedTCPDirective || directive == HibernatedHTTPDirective {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseConfig parses an HAProxy configuration into a ParsedConfig.
+func ParseConfig(data []byte) (*ParsedConfig, error) {
+	config := &ParsedConfig{}
+
+	// directives points to the list receiving the directives of the current section.
+	var directives *[]string
+	var frontend *ParsedFrontend
+	var backend *ParsedBackend
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := tokenize(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		keyword := fields[0]
+		name := ""
+		if len(fields) > 1 {
+			name = fields[1]
+		}
+
+		switch keyword {
+		case "global":
+			frontend, backend = nil, nil
+			directives = &config.Global
+			continue
+		case "defaults":
+			frontend, backend = nil, nil
+			directives = &config.Defaults
+			continue
+		case "frontend":
+			if name == "" {
+				return nil, errors.Errorf("line %d: frontend section without a name", lineNumber)
+			}
+			config.Frontends = append(config.Frontends, ParsedFrontend{Name: name})
+			frontend, backend = &config.Frontends[len(config.Frontends)-1], nil
+			directives = &frontend.Directives
+			continue
+		case "backend":
+			if name == "" {
+				return nil, errors.Errorf("line %d: backend section without a name", lineNumber)
+			}
+			config.Backends = append(config.Backends, ParsedBackend{Name: name})
+			frontend, backend = nil, &config.Backends[len(config.Backends)-1]
+			directives = &backend.Directives
+			continue
+		case "listen", "peers", "resolvers", "userlist", "program", "http-errors", "ring", "mailers", "cache":
+			config.Sections = append(config.Sections, ParsedSection{Kind: keyword, Name: name})
+			frontend, backend = nil, nil
+			directives = &config.Sections[len(config.Sections)-1].Directives
+			continue
+		}
+
+		if directives == nil {
+			return nil, errors.Errorf("line %d: directive %q outside of a section", lineNumber, keyword)
+		}
+
+		switch {
+		case frontend != nil && keyword == "bind":
+			frontend.Binds = append(frontend.Binds, strings.Join(fields[1:], " "))
+		case frontend != nil && keyword == "default_backend":
+			frontend.DefaultBackend = name
+		case backend != nil && keyword == "server":
+			if len(fields) < 3 {
+				return nil, errors.Errorf("line %d: server entry requires a name and an address", lineNumber)
+			}
+			backend.Servers = append(backend.Servers, ParsedServer{
+				Name:    fields[1],
+				Address: fields[2],
+				Options: fields[3:],
+			})
+		default:
+			*directives = append(*directives, strings.Join(fields, " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read config")
+	}
+
+	return config, nil
+}
+
+// tokenize splits a configuration line into its whitespace separated fields,
+// dropping anything after a comment marker.
+func tokenize(line string) []string {
+	fields := strings.Fields(line)
+	for i, f := range fields {
+		if strings.HasPrefix(f, "#") {
+			return fields[:i]
+		}
+	}
+	return fields
+}
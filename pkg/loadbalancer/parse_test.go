@@ -0,0 +1,128 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseRenderedConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	config, err := Config(&ConfigData{
+		ControlPlanePort: 6443,
+		BackendServers: map[string]string{
+			"cluster-cp-1": "172.18.0.3:6443",
+			"cluster-cp-2": "172.18.0.4:6443",
+		},
+		EnableStats: true,
+		HealthCheck: HealthCheckHTTPHealthz,
+	})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	parsed, err := ParseConfig([]byte(config))
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	g.Expect(parsed.Defaults).To(ContainElement("mode tcp"))
+	g.Expect(parsed.Frontends).To(HaveLen(2))
+
+	controlPlane := parsed.Frontend("control-plane")
+	g.Expect(controlPlane).ToNot(BeNil())
+	g.Expect(controlPlane.Binds).To(Equal([]string{"*:6443"}))
+	g.Expect(controlPlane.DefaultBackend).To(Equal("kube-apiservers"))
+
+	backend := parsed.Backend("kube-apiservers")
+	g.Expect(backend).ToNot(BeNil())
+	g.Expect(backend.Directives).To(Equal([]string{"option httpchk GET /healthz"}))
+	g.Expect(backend.Servers).To(Equal([]ParsedServer{
+		{Name: "cluster-cp-1", Address: "172.18.0.3:6443", Options: []string{"check", "check-ssl", "verify", "none"}},
+		{Name: "cluster-cp-2", Address: "172.18.0.4:6443", Options: []string{"check", "check-ssl", "verify", "none"}},
+	}))
+}
+
+func TestParseConfigIgnoresFormatting(t *testing.T) {
+	g := NewWithT(t)
+
+	compact := []byte(`global
+  log stdout format raw local0 info
+frontend control-plane
+  bind *:6443
+  default_backend kube-apiservers
+backend kube-apiservers
+  server cp-1 10.0.0.1:6443 check
+`)
+	verbose := []byte(`# a leading comment
+global
+	log   stdout format raw    local0 info   # trailing comment
+
+frontend   control-plane
+    bind *:6443
+
+    default_backend kube-apiservers
+backend kube-apiservers
+  # servers
+  server  cp-1   10.0.0.1:6443   check
+`)
+
+	compactParsed, err := ParseConfig(compact)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	verboseParsed, err := ParseConfig(verbose)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	g.Expect(verboseParsed).To(Equal(compactParsed))
+}
+
+func TestParseConfigOtherSections(t *testing.T) {
+	g := NewWithT(t)
+
+	parsed, err := ParseConfig([]byte(`peers lb-peers
+  peer lb-1 172.18.0.2:10000
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(parsed.Sections).To(Equal([]ParsedSection{
+		{Kind: "peers", Name: "lb-peers", Directives: []string{"peer lb-1 172.18.0.2:10000"}},
+	}))
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{
+			name:   "directive outside of a section",
+			config: "maxconn 100\n",
+		},
+		{
+			name:   "frontend without a name",
+			config: "frontend\n  bind *:6443\n",
+		},
+		{
+			name:   "server without an address",
+			config: "backend kube-apiservers\n  server cp-1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			_, err := ParseConfig([]byte(tt.config))
+			g.Expect(err).Should(HaveOccurred())
+		})
+	}
+}
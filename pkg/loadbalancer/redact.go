@@ -0,0 +1,60 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"strings"
+)
+
+// Redacted replaces the credentials removed from a configuration by RedactConfig.
+const Redacted = "<redacted>"
+
+// RedactConfig returns a copy of an HAProxy configuration with credentials (stats page
+// passwords and userlist passwords) replaced by Redacted, so that it can be shared e.g. in bug reports.
+// Lines without credentials are left untouched.
+func RedactConfig(config string) string {
+	lines := strings.Split(config, "\n")
+	for i, line := range lines {
+		fields := tokenize(line)
+		if !redactFields(fields) {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		lines[i] = indent + strings.Join(fields, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redactFields replaces the credentials in the fields of a directive, returning true if there were any.
+func redactFields(fields []string) bool {
+	switch {
+	case len(fields) >= 3 && fields[0] == "stats" && fields[1] == "auth":
+		user := strings.SplitN(fields[2], ":", 2)[0]
+		fields[2] = user + ":" + Redacted
+		return true
+	case len(fields) >= 2 && fields[0] == "user":
+		redacted := false
+		for j := 2; j < len(fields)-1; j++ {
+			if fields[j] == "password" || fields[j] == "insecure-password" {
+				fields[j+1] = Redacted
+				redacted = true
+			}
+		}
+		return redacted
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRedactConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	config := `frontend stats
+  bind *:8404
+  stats enable
+  stats auth admin:s3cr3t # stats page credentials
+
+userlist operators
+  user alice password $6$hash groups admins
+  user bob insecure-password hunter2
+  group admins
+`
+	g.Expect(RedactConfig(config)).To(Equal(`frontend stats
+  bind *:8404
+  stats enable
+  stats auth admin:<redacted>
+
+userlist operators
+  user alice password <redacted> groups admins
+  user bob insecure-password <redacted>
+  group admins
+`))
+}
+
+func TestRedactConfigWithoutCredentials(t *testing.T) {
+	g := NewWithT(t)
+
+	config, err := GenerateConfig(&ConfigData{ControlPlanePort: 6443, EnableStats: true})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(RedactConfig(config)).To(Equal(config))
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// MaxConfigSnapshotSize is the largest serialized ConfigData ExportConfig produces, so the snapshot fits
+// in an annotation next to the others (annotations are limited to 256KiB in total).
+const MaxConfigSnapshotSize = 32 * 1024
+
+// ExportConfig serializes the configuration data, e.g. to keep the backends a load balancer serves
+// across restarts of the provider. It fails if the result is larger than MaxConfigSnapshotSize.
+func ExportConfig(data *ConfigData) (string, error) {
+	snapshot, err := json.Marshal(data)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to serialize load balancer configuration")
+	}
+	if len(snapshot) > MaxConfigSnapshotSize {
+		return "", errors.Errorf("load balancer configuration snapshot is %d bytes, larger than the %d bytes limit", len(snapshot), MaxConfigSnapshotSize)
+	}
+	return string(snapshot), nil
+}
+
+// ImportConfig parses configuration data serialized by ExportConfig.
+func ImportConfig(snapshot string) (*ConfigData, error) {
+	if len(snapshot) > MaxConfigSnapshotSize {
+		return nil, errors.Errorf("load balancer configuration snapshot is %d bytes, larger than the %d bytes limit", len(snapshot), MaxConfigSnapshotSize)
+	}
+	data := &ConfigData{}
+	if err := json.Unmarshal([]byte(snapshot), data); err != nil {
+		return nil, errors.Wrap(err, "failed to parse load balancer configuration snapshot")
+	}
+	return data, nil
+}
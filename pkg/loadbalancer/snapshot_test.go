@@ -0,0 +1,73 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestExportImportConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	retries := 3
+	data := &ConfigData{
+		ControlPlanePort: 6443,
+		BackendServers:   map[string]string{"cp-1": "172.18.0.3:6443"},
+		BackendPools:     []BackendPool{{Name: "readers", Port: 7443, Servers: map[string]string{"cp-1": "172.18.0.3:6443"}}},
+		Retries:          &retries,
+		BaseConfig:       ConfigOverrides{{Header: "defaults", Directives: []string{"timeout client 30s"}}},
+	}
+	snapshot, err := ExportConfig(data)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	imported, err := ImportConfig(snapshot)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(imported).To(Equal(data))
+
+	_, err = ImportConfig("{")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestExportConfigOmitsMetricsAuth(t *testing.T) {
+	g := NewWithT(t)
+
+	snapshot, err := ExportConfig(&ConfigData{
+		MetricsPort: 8405,
+		MetricsAuth: &MetricsAuth{Username: "prometheus", Password: "s3cret"},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(snapshot).ToNot(ContainSubstring("s3cret"))
+	g.Expect(snapshot).ToNot(ContainSubstring("prometheus"))
+}
+
+func TestExportConfigTooLarge(t *testing.T) {
+	g := NewWithT(t)
+
+	servers := map[string]string{}
+	for i := 0; i < 2000; i++ {
+		servers[fmt.Sprintf("control-plane-%d", i)] = "172.18.0.3:6443"
+	}
+	_, err := ExportConfig(&ConfigData{BackendServers: servers})
+	g.Expect(err).To(MatchError(ContainSubstring("larger than the")))
+
+	_, err = ImportConfig(strings.Repeat(" ", MaxConfigSnapshotSize+1))
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// SNIRoute routes the TLS connections for a server name to a backend.
+type SNIRoute struct {
+	// ServerName is the server name (SNI) the client requests.
+	ServerName string
+	// Backend is the name of the backend receiving the connections, BackendName or a backend pool.
+	Backend string
+}
+
+// ParseSNIRoutes parses a comma separated list of <server name>=<backend> routes, e.g.
+// "a.example.com=kube-apiservers,b.example.com=readers".
+func ParseSNIRoutes(value string) ([]SNIRoute, error) {
+	routes := []SNIRoute{}
+	serverNames := map[string]bool{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		serverName, backend, ok := strings.Cut(entry, "=")
+		serverName, backend = strings.ToLower(strings.TrimSpace(serverName)), strings.TrimSpace(backend)
+		if !ok || serverName == "" || backend == "" {
+			return nil, errors.Errorf("invalid route %q, must be <server name>=<backend>", entry)
+		}
+		if msgs := validation.IsDNS1123Subdomain(serverName); len(msgs) > 0 {
+			return nil, errors.Errorf("invalid server name %q: %s", serverName, strings.Join(msgs, ", "))
+		}
+		if serverNames[serverName] {
+			return nil, errors.Errorf("duplicate route for server name %q", serverName)
+		}
+		serverNames[serverName] = true
+		routes = append(routes, SNIRoute{ServerName: serverName, Backend: backend})
+	}
+	if len(routes) == 0 {
+		return nil, errors.New("no routes")
+	}
+	return routes, nil
+}
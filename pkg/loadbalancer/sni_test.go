@@ -0,0 +1,73 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseSNIRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []SNIRoute
+		wantErr bool
+	}{
+		{
+			name:  "routes in order",
+			value: "a.example.com=kube-apiservers, B.example.com = readers,",
+			want: []SNIRoute{
+				{ServerName: "a.example.com", Backend: "kube-apiservers"},
+				{ServerName: "b.example.com", Backend: "readers"},
+			},
+		},
+		{
+			name:    "no routes",
+			value:   " , ",
+			wantErr: true,
+		},
+		{
+			name:    "route without a backend",
+			value:   "a.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "invalid server name",
+			value:   "a_b.example.com=readers",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate server name",
+			value:   "a.example.com=readers,a.example.com=kube-apiservers",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			routes, err := ParseSNIRoutes(tt.value)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(routes).To(Equal(tt.want))
+		})
+	}
+}
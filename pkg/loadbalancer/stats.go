@@ -0,0 +1,130 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// StatsFrontendName is the name of the proxy the stats page reports frontend totals under.
+	StatsFrontendName = "FRONTEND"
+	// StatsBackendName is the name of the proxy the stats page reports backend totals under.
+	StatsBackendName = "BACKEND"
+)
+
+// Stat is a row of the HAProxy stats CSV output, describing a frontend, a backend or a server.
+type Stat struct {
+	// ProxyName is the name of the frontend or backend (pxname).
+	ProxyName string
+	// ServiceName is the name of the server, or FRONTEND/BACKEND for the totals (svname).
+	ServiceName string
+	// Status is the status of the service, e.g. UP, DOWN, MAINT or OPEN (status).
+	Status string
+	// CheckStatus is the status of the last health check (check_status).
+	CheckStatus string
+	// Address is the address of the server, if known (addr).
+	Address string
+}
+
+// IsServer returns true if the row describes a server rather than frontend or backend totals.
+func (s Stat) IsServer() bool {
+	return s.ServiceName != StatsFrontendName && s.ServiceName != StatsBackendName
+}
+
+// IsUp returns true if the health checks report the server as up. A server going down, reported
+// as e.g. "UP 1/3" while its checks start failing, still serves traffic and is up too.
+func (s Stat) IsUp() bool {
+	return s.Status == "UP" || strings.HasPrefix(s.Status, "UP ")
+}
+
+// CheckPassed returns true if the last completed health check of the server passed, e.g. L4OK or L7OK.
+// Right after a reload no check has completed yet (INI) and the servers only look up.
+func (s Stat) CheckPassed() bool {
+	// A check in progress is reported with a "* " prefix along with the result of the last one.
+	status := strings.TrimPrefix(s.CheckStatus, "* ")
+	return strings.HasPrefix(status, "L") && strings.Contains(status, "OK")
+}
+
+// ParseStats parses the CSV output of the HAProxy stats page (the ";csv" uri suffix)
+// or of the "show stat" runtime API command.
+func ParseStats(data []byte) ([]Stat, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read stats header")
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "#"))] = i
+	}
+	if _, ok := columns["pxname"]; !ok {
+		return nil, errors.New("invalid stats header: missing pxname column")
+	}
+	if _, ok := columns["svname"]; !ok {
+		return nil, errors.New("invalid stats header: missing svname column")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	stats := []Stat{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read stats")
+		}
+		stats = append(stats, Stat{
+			ProxyName:   field(record, "pxname"),
+			ServiceName: field(record, "svname"),
+			Status:      field(record, "status"),
+			CheckStatus: field(record, "check_status"),
+			Address:     field(record, "addr"),
+		})
+	}
+	return stats, nil
+}
+
+// Servers returns the server rows for the given backend.
+func Servers(stats []Stat, backend string) []Stat {
+	servers := []Stat{}
+	for _, s := range stats {
+		if s.ProxyName == backend && s.IsServer() {
+			servers = append(servers, s)
+		}
+	}
+	return servers
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const testStats = `# pxname,svname,qcur,qmax,scur,smax,slim,stot,bin,bout,dreq,dresp,ereq,econ,eresp,wretr,wredis,status,weight,act,bck,chkfail,chkdown,lastchg,downtime,qlimit,pid,iid,sid,throttle,lbtot,tracked,type,rate,rate_lim,rate_max,check_status,check_code,check_duration,hrsp_1xx,addr,
+stats,FRONTEND,,,1,1,262124,1,0,0,0,0,0,,,,,OPEN,,,,,,,,,1,2,0,,,,0,1,0,1,,,,0,,
+control-plane,FRONTEND,,,0,0,262124,0,0,0,0,0,0,,,,,OPEN,,,,,,,,,1,3,0,,,,0,0,0,0,,,,,,
+kube-apiservers,cp-1,0,0,0,0,,0,0,0,,0,,0,0,0,0,UP,1,1,0,0,0,10,0,,1,4,1,,0,,2,0,,0,L7OK,200,3,,172.18.0.3:6443,
+kube-apiservers,cp-2,0,0,0,0,,0,0,0,,0,,0,0,0,0,DOWN,1,1,0,1,1,10,10,,1,4,2,,0,,2,0,,0,L4CON,,0,,172.18.0.4:6443,
+kube-apiservers,BACKEND,0,0,0,0,26213,0,0,0,0,0,,0,0,0,0,UP,1,1,0,,0,10,0,,1,4,0,,0,,1,0,,0,,,,,,
+`
+
+func TestParseStats(t *testing.T) {
+	g := NewWithT(t)
+
+	stats, err := ParseStats([]byte(testStats))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(stats).To(HaveLen(5))
+
+	g.Expect(Servers(stats, BackendName)).To(Equal([]Stat{
+		{ProxyName: BackendName, ServiceName: "cp-1", Status: "UP", CheckStatus: "L7OK", Address: "172.18.0.3:6443"},
+		{ProxyName: BackendName, ServiceName: "cp-2", Status: "DOWN", CheckStatus: "L4CON", Address: "172.18.0.4:6443"},
+	}))
+}
+
+func TestStatIsUp(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(Stat{Status: "UP"}.IsUp()).To(BeTrue())
+	g.Expect(Stat{Status: "UP 1/3"}.IsUp()).To(BeTrue())
+	g.Expect(Stat{Status: "DOWN 1/2"}.IsUp()).To(BeFalse())
+	g.Expect(Stat{Status: "DOWN"}.IsUp()).To(BeFalse())
+	g.Expect(Stat{Status: "MAINT"}.IsUp()).To(BeFalse())
+	g.Expect(Stat{Status: "no check"}.IsUp()).To(BeFalse())
+}
+
+func TestStatCheckPassed(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(Stat{CheckStatus: "L4OK"}.CheckPassed()).To(BeTrue())
+	g.Expect(Stat{CheckStatus: "L7OK"}.CheckPassed()).To(BeTrue())
+	g.Expect(Stat{CheckStatus: "* L7OK"}.CheckPassed()).To(BeTrue())
+	g.Expect(Stat{CheckStatus: "INI"}.CheckPassed()).To(BeFalse())
+	g.Expect(Stat{CheckStatus: "L4CON"}.CheckPassed()).To(BeFalse())
+	g.Expect(Stat{CheckStatus: "L7STS"}.CheckPassed()).To(BeFalse())
+	g.Expect(Stat{}.CheckPassed()).To(BeFalse())
+}
+
+func TestParseStatsEmpty(t *testing.T) {
+	g := NewWithT(t)
+
+	stats, err := ParseStats(nil)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(stats).To(BeEmpty())
+}
+
+func TestParseStatsInvalidHeader(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := ParseStats([]byte("<html>not the csv export</html>\n"))
+	g.Expect(err).Should(HaveOccurred())
+}